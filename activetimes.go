@@ -0,0 +1,42 @@
+package nntp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An ActiveTime is a line of LIST ACTIVE.TIMES: when a group was created,
+// and by whom.
+type ActiveTime struct {
+	Name    string
+	Created time.Time
+	// Creator is usually an email address, and may be empty.
+	Creator string
+}
+
+// ParseActiveTimes parses the lines of a LIST ACTIVE.TIMES response, or
+// an active.times file.  Real ones are messy, so lines without a name
+// and numeric time are skipped rather than failing the rest, and counted
+// in skipped.
+func ParseActiveTimes(lines []string) (rv []ActiveTime, skipped int) {
+	rv = make([]ActiveTime, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			skipped++
+			continue
+		}
+		secs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			skipped++
+			continue
+		}
+		at := ActiveTime{Name: fields[0], Created: time.Unix(secs, 0).UTC()}
+		if len(fields) > 2 {
+			at.Creator = strings.Join(fields[2:], " ")
+		}
+		rv = append(rv, at)
+	}
+	return rv, skipped
+}
@@ -0,0 +1,34 @@
+package nntp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseActiveTimes(t *testing.T) {
+	lines := []string{
+		"misc.test 1614987001 fred@example.com",
+		"alt.quiet 946684800",
+		"alt.spaced\t1000000000   Fred Bloggs <fred@example.com>",
+		"alt.broken yesterday fred@example.com",
+		"alt.lonely",
+		"",
+	}
+	got, skipped := ParseActiveTimes(lines)
+	want := []ActiveTime{
+		{"misc.test", time.Date(2021, 3, 5, 23, 30, 1, 0, time.UTC), "fred@example.com"},
+		{"alt.quiet", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"alt.spaced", time.Unix(1000000000, 0).UTC(), "Fred Bloggs <fred@example.com>"},
+	}
+	if skipped != 3 {
+		t.Errorf("Skipped %d lines", skipped)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
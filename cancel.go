@@ -0,0 +1,136 @@
+package nntp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// CancelOption customizes a cancel built by BuildCancel.
+type CancelOption func(*cancelConfig)
+
+type cancelConfig struct {
+	secret []byte
+	from   string
+}
+
+// WithCancelSecret provides the poster's Cancel-Lock secret, so that a
+// matching Cancel-Key is included when the original has a Cancel-Lock.
+func WithCancelSecret(secret []byte) CancelOption {
+	return func(c *cancelConfig) {
+		c.secret = secret
+	}
+}
+
+// WithCancelFrom overrides the From header, which otherwise matches the
+// original's.
+func WithCancelFrom(from string) CancelOption {
+	return func(c *cancelConfig) {
+		c.from = from
+	}
+}
+
+// BuildCancel builds a control message cancelling original.
+//
+// The cancel goes to the same newsgroups with the same From, and carries
+// reason as its body.
+func BuildCancel(original *Article, reason string, opts ...CancelOption) (*Article, error) {
+	var cfg cancelConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	msgid := strings.TrimSpace(original.MessageID())
+	if msgid == "" {
+		return nil, errors.New("can't cancel an article without a Message-ID")
+	}
+	groups := original.Header.Get("Newsgroups")
+	if groups == "" {
+		return nil, errors.New("can't cancel an article without Newsgroups")
+	}
+	from := cfg.from
+	if from == "" {
+		from = original.Header.Get("From")
+	}
+	if reason == "" {
+		reason = "Article cancelled by its poster."
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("From", from)
+	h.Set("Newsgroups", groups)
+	h.Set("Subject", "cmsg cancel "+msgid)
+	h.Set("Control", "cancel "+msgid)
+	h.Set("Message-Id", GenerateMessageID(messageIDDomain(msgid)))
+	h.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	if cfg.secret != nil && original.Header.Get("Cancel-Lock") != "" {
+		h.Set("Cancel-Key", CancelKey(cfg.secret, msgid))
+	}
+	body := reason + "\r\n"
+	return &Article{
+		Header: h,
+		Body:   strings.NewReader(body),
+		Bytes:  len(body),
+		Lines:  1,
+	}, nil
+}
+
+// messageIDDomain returns the part of a message-id after the @.
+func messageIDDomain(msgid string) string {
+	i := strings.LastIndexByte(msgid, '@')
+	if i == -1 {
+		return "invalid"
+	}
+	return strings.TrimSuffix(msgid[i+1:], ">")
+}
+
+// cancelKeyBytes derives the key for msgid as recommended by RFC 8315.
+func cancelKeyBytes(secret []byte, msgid string) string {
+	m := hmac.New(sha256.New, secret)
+	io.WriteString(m, msgid)
+	return base64.StdEncoding.EncodeToString(m.Sum(nil))
+}
+
+// CancelKey returns the Cancel-Key header value proving ownership of
+// msgid, for a poster using secret.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8315
+func CancelKey(secret []byte, msgid string) string {
+	return "sha256:" + cancelKeyBytes(secret, msgid)
+}
+
+// CancelLock returns the Cancel-Lock header value to include when
+// posting msgid, so that it can later be cancelled with CancelKey.
+func CancelLock(secret []byte, msgid string) string {
+	return "sha256:" + lockFor(cancelKeyBytes(secret, msgid))
+}
+
+func lockFor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// VerifyCancelKey reports whether any of the keys in a Cancel-Key
+// header value opens any of the locks in a Cancel-Lock header value.
+// Only the sha256 algorithm is supported.
+func VerifyCancelKey(lock, key string) bool {
+	for _, k := range strings.Fields(key) {
+		k = strings.TrimPrefix(k, "sha256:")
+		if strings.Contains(k, ":") {
+			continue
+		}
+		want := lockFor(k)
+		for _, l := range strings.Fields(lock) {
+			l = strings.TrimPrefix(l, "sha256:")
+			if subtle.ConstantTimeCompare([]byte(l), []byte(want)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package nntp
+
+import (
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestBuildCancel(t *testing.T) {
+	secret := []byte("s3cret")
+	original := &Article{Header: textproto.MIMEHeader{
+		"From":        {"Someone <someone@example.com>"},
+		"Newsgroups":  {"misc.test,alt.test"},
+		"Message-Id":  {"<abc@example.com>"},
+		"Cancel-Lock": {CancelLock(secret, "<abc@example.com>")},
+	}}
+	c, err := BuildCancel(original, "oops", WithCancelSecret(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, exp := range map[string]string{
+		"From":       "Someone <someone@example.com>",
+		"Newsgroups": "misc.test,alt.test",
+		"Control":    "cancel <abc@example.com>",
+		"Subject":    "cmsg cancel <abc@example.com>",
+	} {
+		if got := c.Header.Get(k); got != exp {
+			t.Errorf("%s: got %q, wanted %q", k, got, exp)
+		}
+	}
+	if id := c.MessageID(); id == original.MessageID() || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Unexpected cancel message-id %q", id)
+	}
+	if !VerifyCancelKey(original.Header.Get("Cancel-Lock"), c.Header.Get("Cancel-Key")) {
+		t.Errorf("Cancel-Key %q doesn't open the lock", c.Header.Get("Cancel-Key"))
+	}
+	body, _ := ioutil.ReadAll(c.Body)
+	if string(body) != "oops\r\n" {
+		t.Errorf("Got body %q", body)
+	}
+
+	// No lock, no key.
+	original.Header.Del("Cancel-Lock")
+	c, _ = BuildCancel(original, "", WithCancelSecret(secret))
+	if c.Header.Get("Cancel-Key") != "" {
+		t.Errorf("Cancel-Key added without a Cancel-Lock")
+	}
+
+	original.Header.Del("Message-Id")
+	if _, err := BuildCancel(original, ""); err == nil {
+		t.Errorf("Built a cancel without a Message-ID")
+	}
+}
+
+func TestVerifyCancelKey(t *testing.T) {
+	lock := CancelLock([]byte("a"), "<x@y>") + " sha1:bogus"
+	if VerifyCancelKey(lock, CancelKey([]byte("b"), "<x@y>")) {
+		t.Errorf("Wrong secret opened the lock")
+	}
+	if VerifyCancelKey(lock, CancelKey([]byte("a"), "<other@y>")) {
+		t.Errorf("Key for another message opened the lock")
+	}
+	if !VerifyCancelKey(lock, "sha1:junk "+CancelKey([]byte("a"), "<x@y>")) {
+		t.Errorf("Right key didn't open the lock")
+	}
+}
+
+func TestBuildSupersede(t *testing.T) {
+	secret := []byte("s3cret")
+	original := &Article{Header: textproto.MIMEHeader{
+		"From":        {"Someone <someone@example.com>"},
+		"Newsgroups":  {"misc.test"},
+		"Subject":     {"Re: typo"},
+		"References":  {"<parent@example.com>"},
+		"Message-Id":  {"<abc@example.com>"},
+		"Path":        {"news.example.com!not-for-mail"},
+		"Cancel-Lock": {CancelLock(secret, "<abc@example.com>")},
+	}}
+	s, err := BuildSupersede(original, "", strings.NewReader("fixed\r\n"),
+		WithCancelSecret(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Header
+	if h.Get("Supersedes") != "<abc@example.com>" ||
+		h.Get("References") != "<parent@example.com>" ||
+		h.Get("Subject") != "Re: typo" || h.Get("Path") != "" ||
+		s.MessageID() == original.MessageID() {
+		t.Errorf("Unexpected headers %v", h)
+	}
+	if !VerifyCancelKey(original.Header.Get("Cancel-Lock"), h.Get("Cancel-Key")) {
+		t.Errorf("Supersede can't cancel the original")
+	}
+	if h.Get("Cancel-Lock") != CancelLock(secret, s.MessageID()) {
+		t.Errorf("Replacement isn't locked")
+	}
+	if original.Header.Get("Path") == "" {
+		t.Errorf("Original headers were modified")
+	}
+}
@@ -0,0 +1,83 @@
+package nntp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Charsets seen on Usenet, keyed by lowercase name.  Anything missing
+// here is looked up in the WHATWG encoding index.
+var charsets = map[string]encoding.Encoding{
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+	"iso-8859-2":   charmap.ISO8859_2,
+	"iso-8859-3":   charmap.ISO8859_3,
+	"iso-8859-4":   charmap.ISO8859_4,
+	"iso-8859-5":   charmap.ISO8859_5,
+	"iso-8859-6":   charmap.ISO8859_6,
+	"iso-8859-7":   charmap.ISO8859_7,
+	"iso-8859-8":   charmap.ISO8859_8,
+	"iso-8859-9":   charmap.ISO8859_9,
+	"iso-8859-10":  charmap.ISO8859_10,
+	"iso-8859-13":  charmap.ISO8859_13,
+	"iso-8859-14":  charmap.ISO8859_14,
+	"iso-8859-15":  charmap.ISO8859_15,
+	"iso-8859-16":  charmap.ISO8859_16,
+	"koi8-r":       charmap.KOI8R,
+	"koi8-u":       charmap.KOI8U,
+	"windows-1250": charmap.Windows1250,
+	"windows-1251": charmap.Windows1251,
+	"windows-1252": charmap.Windows1252,
+	"windows-1253": charmap.Windows1253,
+	"windows-1254": charmap.Windows1254,
+	"windows-1255": charmap.Windows1255,
+	"windows-1256": charmap.Windows1256,
+	"windows-1257": charmap.Windows1257,
+	"windows-1258": charmap.Windows1258,
+	"gb2312":       simplifiedchinese.GBK,
+	"gbk":          simplifiedchinese.GBK,
+	"gb18030":      simplifiedchinese.GB18030,
+}
+
+// LookupCharset returns the encoding for a MIME charset name, or nil
+// for UTF-8 and US-ASCII which need no conversion.
+func LookupCharset(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.Trim(name, " \t\""))
+	switch name {
+	case "utf-8", "utf8", "us-ascii", "ascii", "":
+		return nil, nil
+	}
+	if e, ok := charsets[name]; ok {
+		return e, nil
+	}
+	// cp1252 and friends
+	if strings.HasPrefix(name, "cp125") {
+		if e, ok := charsets["windows-"+name[2:]]; ok {
+			return e, nil
+		}
+	}
+	e, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset %q", name)
+	}
+	return e, nil
+}
+
+// CharsetReader returns a reader converting input from the named charset
+// to UTF-8.  It's suitable for use as mime.WordDecoder.CharsetReader.
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	e, err := LookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return input, nil
+	}
+	return e.NewDecoder().Reader(input), nil
+}
@@ -0,0 +1,285 @@
+package nntpclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// headerOrder is the order writeArticle puts the usual headers in.  Any
+// others follow, sorted.
+var headerOrder = []string{
+	"Path",
+	"From",
+	"Newsgroups",
+	"Subject",
+	"Date",
+	"Message-Id",
+	"References",
+	"Followup-To",
+	"Reply-To",
+	"Organization",
+	"Distribution",
+	"Expires",
+	"Control",
+	"Approved",
+	"Supersedes",
+	"Mime-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+// maxHeaderLine is the length writeArticle folds header lines to, where
+// they have whitespace to fold at.
+const maxHeaderLine = 78
+
+// PostArticle posts an article, writing its headers and then streaming
+// its Body, which may be nil, without holding it in memory.
+//
+// The headers are checked before anything is sent: a value containing a
+// CR or LF is an error, unless it's already folded, with each line break
+// followed by a space or tab.  Long lines are folded.
+//
+// With EncodeHeaders set, values that aren't ASCII are sent as RFC 2047
+// encoded-words.  With AutoDistribution set, a missing Distribution
+// header is filled in first, in a.Header, as FillDistribution does.
+func (c *Client) PostArticle(a *nntp.Article) error {
+	if c.AutoDistribution {
+		if err := c.FillDistribution(a); err != nil {
+			return err
+		}
+	}
+	var hdr bytes.Buffer
+	if err := writeHeader(&hdr, a.Header, c.EncodeHeaders); err != nil {
+		return err
+	}
+	r := io.Reader(&hdr)
+	if a.Body != nil {
+		r = io.MultiReader(&hdr, a.Body)
+	}
+	return c.Post(r)
+}
+
+// GetArticle fetches an article with ARTICLE and parses its headers,
+// including continuation lines.  Body reads the rest, with line endings
+// as LF; it's positioned at the first body line, and is empty for an
+// article without one.
+//
+// Unless BufferBodies is set, Body must be read to the end before the
+// next command.  With it set, the body is read in full, and Bytes and
+// Lines give its size.  Errors for missing articles match
+// ErrNoSuchArticle.
+func (c *Client) GetArticle(specifier string) (*nntp.Article, error) {
+	_, _, r, err := c.articleish("ARTICLE", specifier, 220)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+	h, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err == io.EOF {
+		// The headers ran to the end: there's no body.
+		err = nil
+	}
+	if err != nil {
+		// Skip the rest so the connection stays usable.
+		io.Copy(ioutil.Discard, br)
+		return nil, fmt.Errorf("reading headers of article %s: %w", specifier, err)
+	}
+	if c.DecodeHeaders {
+		decodeHeader(h)
+	}
+	a := &nntp.Article{Header: h, Body: br}
+	if !c.BufferBodies {
+		c.lend()
+		return a, nil
+	}
+	body, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	a.Body = bytes.NewReader(body)
+	a.Bytes = len(body)
+	a.Lines = bytes.Count(body, []byte("\n"))
+	return a, nil
+}
+
+// HeadMIME fetches an article's headers with HEAD and parses them,
+// joining continuation lines, returning the article number and
+// message-id as Head does.  The response is read in full, even if it
+// doesn't parse.  Errors for missing articles match ErrNoSuchArticle.
+func (c *Client) HeadMIME(specifier string) (int64, string, textproto.MIMEHeader, error) {
+	n, id, r, err := c.articleish("HEAD", specifier, 221)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	h, err := parseHeaderLines(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("HEAD %s: %w", specifier, err)
+	}
+	if c.DecodeHeaders {
+		decodeHeader(h)
+	}
+	return n, id, h, nil
+}
+
+// decodeHeader decodes every value of h in place.  Encoded-words that
+// don't decode are left as they are.
+func decodeHeader(h textproto.MIMEHeader) {
+	for _, vs := range h {
+		for i, v := range vs {
+			vs[i], _ = nntp.DecodeHeader(v)
+		}
+	}
+}
+
+// parseHeaderLines parses header lines, up to the first blank one.
+func parseHeaderLines(lines []string) (textproto.MIMEHeader, error) {
+	h := textproto.MIMEHeader{}
+	var key string
+	for i, l := range lines {
+		if l == "" {
+			break
+		}
+		if l[0] == ' ' || l[0] == '\t' {
+			if key == "" {
+				return nil, fmt.Errorf("line %d: continuation without a header: %q", i+1, l)
+			}
+			vs := h[key]
+			vs[len(vs)-1] += " " + strings.TrimSpace(l)
+			continue
+		}
+		colon := strings.IndexByte(l, ':')
+		if colon <= 0 || strings.ContainsAny(l[:colon], " \t") {
+			return nil, fmt.Errorf("line %d: malformed header line: %q", i+1, l)
+		}
+		key = textproto.CanonicalMIMEHeaderKey(l[:colon])
+		h[key] = append(h[key], strings.TrimSpace(l[colon+1:]))
+	}
+	return h, nil
+}
+
+// writeArticle serializes an article's headers and body.
+func writeArticle(w io.Writer, a *nntp.Article) error {
+	if err := writeHeader(w, a.Header, false); err != nil {
+		return err
+	}
+	if a.Body == nil {
+		return nil
+	}
+	_, err := io.Copy(w, a.Body)
+	return err
+}
+
+// writeHeader writes a header, in headerOrder, and the blank line that
+// ends it, encoding values that aren't ASCII if encode is set.
+func writeHeader(w io.Writer, h textproto.MIMEHeader, encode bool) error {
+	rank := make(map[string]int, len(headerOrder))
+	for i, k := range headerOrder {
+		rank[k] = i
+	}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, iok := rank[textproto.CanonicalMIMEHeaderKey(keys[i])]
+		rj, jok := rank[textproto.CanonicalMIMEHeaderKey(keys[j])]
+		if iok != jok {
+			return iok
+		}
+		if iok {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+	bw := bufio.NewWriter(w)
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, ": \t\r\n") {
+			return fmt.Errorf("invalid header name %q", k)
+		}
+		for _, v := range h[k] {
+			if encode {
+				v = nntp.EncodeHeader(k, v)
+			}
+			lines, err := headerLines(k, v)
+			if err != nil {
+				return err
+			}
+			for _, l := range lines {
+				bw.WriteString(l)
+				bw.WriteString("\r\n")
+			}
+		}
+	}
+	bw.WriteString("\r\n")
+	return bw.Flush()
+}
+
+// headerLines returns the lines of a header field, folding those longer
+// than maxHeaderLine at whitespace.  A line break in the value is only
+// allowed as part of a fold.
+func headerLines(name, value string) ([]string, error) {
+	parts := strings.Split(value, "\n")
+	for i, p := range parts {
+		if i < len(parts)-1 {
+			p = strings.TrimSuffix(p, "\r")
+		}
+		if strings.Contains(p, "\r") {
+			return nil, fmt.Errorf("header %s: value contains a bare CR", name)
+		}
+		if i > 0 && (p == "" || (p[0] != ' ' && p[0] != '\t')) {
+			return nil, fmt.Errorf("header %s: value contains a line break that isn't a fold", name)
+		}
+		parts[i] = p
+	}
+	parts[0] = name + ": " + parts[0]
+	var rv []string
+	for _, p := range parts {
+		rv = append(rv, foldLine(p, len(name)+2)...)
+	}
+	return rv, nil
+}
+
+// foldLine splits a header line longer than maxHeaderLine before
+// whitespace, never within its first keep bytes.  A line without
+// anywhere to fold is left long.
+func foldLine(line string, keep int) []string {
+	var rv []string
+	for len(line) > maxHeaderLine {
+		i := strings.LastIndexAny(line[:maxHeaderLine+1], " \t")
+		if i <= keep {
+			// Nowhere to fold before the limit; take the first chance after it.
+			from := maxHeaderLine
+			if from <= keep {
+				from = keep + 1
+			}
+			if from >= len(line) {
+				break
+			}
+			j := strings.IndexAny(line[from:], " \t")
+			if j < 0 {
+				break
+			}
+			i = from + j
+		}
+		if strings.TrimSpace(line[i:]) == "" {
+			break
+		}
+		rv = append(rv, line[:i])
+		line = line[i:]
+		// A folded line mustn't be all whitespace.
+		keep = len(line) - len(strings.TrimLeft(line, " \t"))
+	}
+	return append(rv, line)
+}
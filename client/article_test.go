@@ -0,0 +1,292 @@
+package nntpclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestPostArticle(t *testing.T) {
+	var got []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("340 Send it")
+		lines, err := c.ReadDotLines()
+		if err != nil {
+			return
+		}
+		got = lines
+		c.PrintfLine("240 Thanks")
+	})
+	const bodyLines = 100000
+	body := io.LimitReader(readerFunc(func(p []byte) (int, error) {
+		for i := range p {
+			p[i] = "x\r\n"[i%3]
+		}
+		return len(p) / 3 * 3, nil
+	}), 3*bodyLines)
+	a := &nntp.Article{
+		Header: textproto.MIMEHeader{
+			"X-Extra":    {"b"},
+			"Subject":    {strings.Repeat("word ", 20) + "end"},
+			"Newsgroups": {"misc.test"},
+			"From":       {"a@example.com"},
+			"Message-Id": {"<1@example.com>"},
+			"Approved":   {"yes"},
+			"X-Another":  {"c"},
+		},
+		Body: body,
+	}
+	if err := c.PostArticle(a); err != nil {
+		t.Fatalf("PostArticle: %v", err)
+	}
+	want := []string{
+		"From: a@example.com",
+		"Newsgroups: misc.test",
+		"Subject: word word word word word word word word word word word word word word",
+		" word word word word word word end",
+		"Message-Id: <1@example.com>",
+		"Approved: yes",
+		"X-Another: c",
+		"X-Extra: b",
+		"",
+	}
+	if len(got) != len(want)+bodyLines {
+		t.Fatalf("Sent %d lines, want %d", len(got), len(want)+bodyLines)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("Line %d = %q, want %q", i, got[i], w)
+		}
+	}
+	if got[len(got)-1] != "x" {
+		t.Errorf("Last line = %q", got[len(got)-1])
+	}
+}
+
+func TestPostArticleBadHeader(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		t.Errorf("Sent %q", line)
+	})
+	for _, v := range []string{"a\nb", "a\rb", "a\r\nb", "a\n", "a\r\n \r\nb"} {
+		a := &nntp.Article{Header: textproto.MIMEHeader{"Subject": {v}}}
+		if err := c.PostArticle(a); err == nil {
+			t.Errorf("PostArticle with Subject %q succeeded", v)
+		}
+	}
+}
+
+func TestHeaderLines(t *testing.T) {
+	long := strings.Repeat("y", 90)
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"short", []string{"Subject: short"}},
+		{"folded\r\n\tline", []string{"Subject: folded", "\tline"}},
+		{"folded\n line", []string{"Subject: folded", " line"}},
+		{long, []string{"Subject: " + long}},
+		{long + " tail", []string{"Subject: " + long, " tail"}},
+		{"a " + long + " b", []string{"Subject: a", " " + long, " b"}},
+		{"trailing" + strings.Repeat(" ", 80), []string{"Subject: trailing" + strings.Repeat(" ", 80)}},
+	}
+	for _, test := range tests {
+		got, err := headerLines("Subject", test.value)
+		if err != nil {
+			t.Errorf("headerLines(%q): %v", test.value, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("headerLines(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func articleServer(t *testing.T) *Client {
+	return fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "ARTICLE <1@x>":
+			writeLines(c, 220, "1 <1@x>", "Subject: folded", "\tsubject", "Message-Id: <1@x>",
+				"", ".dotted", "", "last")
+		case "ARTICLE <2@x>":
+			writeLines(c, 220, "2 <2@x>", "Subject: no body", "Message-Id: <2@x>")
+		case "ARTICLE <3@x>":
+			writeLines(c, 220, "3 <3@x>", "Subject: empty body", "")
+		default:
+			c.PrintfLine("430 No such article")
+		}
+	})
+}
+
+func TestGetArticle(t *testing.T) {
+	c := articleServer(t)
+	for _, buffered := range []bool{false, true} {
+		c.BufferBodies = buffered
+		a, err := c.GetArticle("<1@x>")
+		if err != nil {
+			t.Fatalf("GetArticle: %v", err)
+		}
+		if got := a.Header.Get("Subject"); got != "folded subject" {
+			t.Errorf("Subject = %q", got)
+		}
+		if got := a.MessageID(); got != "<1@x>" {
+			t.Errorf("Message-Id = %q", got)
+		}
+		body, err := ioutil.ReadAll(a.Body)
+		if err != nil || string(body) != ".dotted\n\nlast\n" {
+			t.Errorf("Body = %q, %v", body, err)
+		}
+		if buffered && (a.Bytes != 14 || a.Lines != 3) {
+			t.Errorf("Bytes, Lines = %d, %d; want 14, 3", a.Bytes, a.Lines)
+		}
+		if !buffered && (a.Bytes != 0 || a.Lines != 0) {
+			t.Errorf("Bytes, Lines = %d, %d without buffering", a.Bytes, a.Lines)
+		}
+
+		for _, id := range []string{"<2@x>", "<3@x>"} {
+			a, err = c.GetArticle(id)
+			if err != nil {
+				t.Fatalf("GetArticle(%s): %v", id, err)
+			}
+			if a.MessageID() == "" && a.Header.Get("Subject") == "" {
+				t.Errorf("GetArticle(%s) headers = %v", id, a.Header)
+			}
+			if body, _ := ioutil.ReadAll(a.Body); len(body) != 0 {
+				t.Errorf("GetArticle(%s) body = %q", id, body)
+			}
+		}
+	}
+
+	if _, err := c.GetArticle("<4@x>"); !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("GetArticle of a missing article = %v", err)
+	}
+}
+
+func TestHeadMIME(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "HEAD <1@x>":
+			writeLines(c, 221, "1 <1@x>", "Subject: folded", "\t subject", "message-id: <1@x>",
+				"Newsgroups: a", "Newsgroups: b")
+		case "HEAD <2@x>":
+			writeLines(c, 221, "2 <2@x>", "Subject: fine", "no colon here")
+		case "HEAD <3@x>":
+			writeLines(c, 221, "3 <3@x>", "Subject: fine")
+		default:
+			c.PrintfLine("430 No such article")
+		}
+	})
+	n, id, h, err := c.HeadMIME("<1@x>")
+	if err != nil {
+		t.Fatalf("HeadMIME: %v", err)
+	}
+	if n != 1 || id != "<1@x>" {
+		t.Errorf("HeadMIME = %d %s", n, id)
+	}
+	if got := h.Get("Subject"); got != "folded subject" {
+		t.Errorf("Subject = %q", got)
+	}
+	if got := h.Get("Message-Id"); got != "<1@x>" {
+		t.Errorf("Message-Id = %q", got)
+	}
+	if got := h["Newsgroups"]; len(got) != 2 {
+		t.Errorf("Newsgroups = %q", got)
+	}
+
+	_, _, _, err = c.HeadMIME("<2@x>")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("HeadMIME of a bad header = %v, want an error naming line 2", err)
+	}
+	// The connection is still in step.
+	if _, _, h, err = c.HeadMIME("<3@x>"); err != nil || h.Get("Subject") != "fine" {
+		t.Errorf("HeadMIME after a bad header = %v, %v", h, err)
+	}
+	if _, _, _, err = c.HeadMIME("<4@x>"); !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("HeadMIME of a missing article = %v", err)
+	}
+}
+
+func TestDecodeHeaders(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "HEAD 1", "ARTICLE 1":
+			code := 221
+			if line == "ARTICLE 1" {
+				code = 220
+			}
+			writeLines(c, code, "1 <1@x>", "Subject: =?UTF-8?B?w6nDqcOp?=", "From: caf\xe9 <a@x>",
+				"X-Broken: =?x-unknown?Q?abc?=")
+		case "LIST OVERVIEW.FMT":
+			c.PrintfLine("503 No")
+		default:
+			writeLines(c, 224, "Overview follows",
+				"1\t=?ISO-8859-1?Q?caf=E9?=\tcaf\xe9 <a@x>\t\t<1@x>\t\t1\t1")
+		}
+	})
+	if _, _, h, _ := c.HeadMIME("1"); h.Get("Subject") != "=?UTF-8?B?w6nDqcOp?=" {
+		t.Errorf("Decoded without DecodeHeaders: %q", h.Get("Subject"))
+	}
+	c.DecodeHeaders = true
+	_, _, h, err := c.HeadMIME("1")
+	if err != nil || h.Get("Subject") != "ééé" || h.Get("From") != "café <a@x>" ||
+		h.Get("X-Broken") != "=?x-unknown?Q?abc?=" {
+		t.Errorf("HeadMIME = %q, %v", h, err)
+	}
+	a, err := c.GetArticle("1")
+	if err != nil || a.Header.Get("Subject") != "ééé" {
+		t.Errorf("GetArticle = %+v, %v", a, err)
+	}
+	ovs, err := c.OverviewFull("1")
+	if err != nil || len(ovs) != 1 || ovs[0].Subject != "café" || ovs[0].From != "café <a@x>" {
+		t.Errorf("OverviewFull = %+v, %v", ovs, err)
+	}
+}
+
+func TestEncodeHeaders(t *testing.T) {
+	var head []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "POST":
+			c.PrintfLine("340 Send it")
+			lines, err := c.ReadDotLines()
+			if err != nil {
+				return
+			}
+			for i, l := range lines {
+				if l == "" {
+					head = lines[:i]
+					break
+				}
+			}
+			c.PrintfLine("240 Thanks")
+		case "HEAD 1":
+			writeLines(c, 221, "1 <1@x>", head...)
+		}
+	})
+	subject := "Grüße aus" + strings.Repeat(" Köln", 20)
+	a := &nntp.Article{Header: textproto.MIMEHeader{
+		"Subject":    {subject},
+		"From":       {"Jürgen <j@example.com>"},
+		"Newsgroups": {"misc.test"},
+	}}
+	c.EncodeHeaders = true
+	if err := c.PostArticle(a); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range head {
+		for i := 0; i < len(l); i++ {
+			if l[i] >= 0x80 {
+				t.Fatalf("Sent a line that isn't ASCII: %q", l)
+			}
+		}
+	}
+	c.DecodeHeaders = true
+	_, _, h, err := c.HeadMIME("1")
+	if err != nil || h.Get("Subject") != subject || h.Get("From") != "Jürgen <j@example.com>" {
+		t.Errorf("HeadMIME = %q, %v", h, err)
+	}
+}
@@ -0,0 +1,201 @@
+package nntpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/textproto"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+const benchLines = 100000
+
+// replayConn answers with a canned response and discards what's written.
+type replayConn struct {
+	io.Reader
+}
+
+func (replayConn) Write(p []byte) (int, error) { return len(p), nil }
+func (replayConn) Close() error                { return nil }
+
+// replayClient returns a client that reads response.
+func replayClient(response []byte) *Client {
+	return &Client{session: &session{conn: textproto.NewConn(replayConn{bytes.NewReader(response)})}}
+}
+
+func overResponse(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("224 Overview follows\r\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\tRe: Subject number %d\tSomeone <someone@example.com>\t"+
+			"Mon, 2 Jan 2006 15:04:05 +0000\t<%d@example.com>\t<%d@example.com>\t%d\t%d\t"+
+			"Xref: news.example.com misc.test:%d\r\n", i, i, i, i-1, 1000+i, 10+i%50, i)
+	}
+	b.WriteString(".\r\n")
+	return b.Bytes()
+}
+
+func listResponse(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("215 list follows\r\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "alt.binaries.group%d %d %d y\r\n", i, 1000000+i, i)
+	}
+	b.WriteString(".\r\n")
+	return b.Bytes()
+}
+
+func BenchmarkOver(b *testing.B) {
+	resp := overResponse(benchLines)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(resp)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lines, err := replayClient(resp).Over("1-")
+		if err != nil || len(lines) != benchLines {
+			b.Fatalf("Got %d lines, %v", len(lines), err)
+		}
+	}
+}
+
+func BenchmarkOverParsed(b *testing.B) {
+	resp := overResponse(benchLines)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(resp)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		err := replayClient(resp).overEach("1-", func(ov nntp.Overview) error {
+			n++
+			return nil
+		})
+		if err != nil || n != benchLines {
+			b.Fatalf("Got %d overviews, %v", n, err)
+		}
+	}
+}
+
+// BenchmarkOverFunc reports the most heap reachable while the overview
+// is streamed, which stays flat however many lines there are, unlike
+// with Over.
+func BenchmarkOverFunc(b *testing.B) {
+	for _, n := range []int{benchLines / 100, benchLines} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			resp := overResponse(n)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(resp)))
+			var before, ms runtime.MemStats
+			var peak uint64
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				b.StartTimer()
+				got := 0
+				err := replayClient(resp).OverFunc("1-", func(line string) error {
+					got++
+					if got%(n/10) == 0 {
+						// Count what's still reachable, not garbage.
+						b.StopTimer()
+						runtime.GC()
+						runtime.ReadMemStats(&ms)
+						if ms.HeapAlloc > before.HeapAlloc && ms.HeapAlloc-before.HeapAlloc > peak {
+							peak = ms.HeapAlloc - before.HeapAlloc
+						}
+						b.StartTimer()
+					}
+					return nil
+				})
+				if err != nil || got != n {
+					b.Fatalf("Got %d lines, %v", got, err)
+				}
+			}
+			b.ReportMetric(float64(peak), "peak-heap-B")
+		})
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	resp := listResponse(benchLines)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(resp)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		groups, err := replayClient(resp).List("ACTIVE")
+		if err != nil || len(groups) != benchLines {
+			b.Fatalf("Got %d groups, %v", len(groups), err)
+		}
+	}
+}
+
+func BenchmarkListFunc(b *testing.B) {
+	resp := listResponse(benchLines)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(resp)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		err := replayClient(resp).ListFunc("ACTIVE", func(nntp.Group) error {
+			n++
+			return nil
+		})
+		if err != nil || n != benchLines {
+			b.Fatalf("Got %d groups, %v", n, err)
+		}
+	}
+}
+
+// TestAllocsPerLine guards the allocation counts the benchmarks measure.
+func TestAllocsPerLine(t *testing.T) {
+	const lines = 1000
+	over, list := overResponse(lines), listResponse(lines)
+	tests := []struct {
+		name string
+		max  float64
+		run  func()
+	}{
+		{"Over", 0.1, func() { replayClient(over).Over("1-") }},
+		{"OverFunc", 1.1, func() {
+			replayClient(over).OverFunc("1-", func(string) error { return nil })
+		}},
+		{"OverParsed", 1.1, func() {
+			replayClient(over).overEach("1-", func(nntp.Overview) error { return nil })
+		}},
+		{"List", 0.1, func() { replayClient(list).List("ACTIVE") }},
+		{"ListFunc", 0.1, func() {
+			replayClient(list).ListFunc("ACTIVE", func(nntp.Group) error { return nil })
+		}},
+	}
+	for _, test := range tests {
+		perLine := testing.AllocsPerRun(5, test.run) / lines
+		if perLine > test.max {
+			t.Errorf("%s: %.2f allocations per line, want at most %.1f",
+				test.name, perLine, test.max)
+		}
+	}
+}
+
+func TestReadDotLines(t *testing.T) {
+	long := strings.Repeat("x", 3*readDotLinesChunk)
+	resp := "224 Overview follows\r\n1\ta\r\n..stuffed\r\n" + long + "\r\n\r\nlast\n.\r\n"
+	c := replayClient([]byte(resp))
+	lines, err := c.Over("1-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1\ta", ".stuffed", long, "", "last"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Got %d lines, want %d", len(lines), len(want))
+		for i := range lines {
+			if i < len(want) && lines[i] != want[i] {
+				t.Errorf("Line %d: %.20q", i, lines[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package nntpclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBusy is matched by the error a command gives, before anything is
+// sent, while the connection has been lent to the caller's code: a data
+// block returned to be read, an article being read from the caller's
+// reader, a callback handling a response as it arrives, or answers to
+// CHECK and TAKETHIS that StreamResult hasn't collected.  Without
+// it, the command would read the tail of the earlier response as its
+// own, or wait for a reader that never finishes.  While the client is
+// busy with an exchange of its own, commands wait their turn instead.
+var ErrBusy = errors.New("connection busy")
+
+// busyBlock is what a connection is busy with between a response and
+// the end of its data block.
+const busyBlock = "data block not read to the end"
+
+// claim takes the connection for an exchange the caller carries out on
+// the wire itself, as Post does, until done is called.
+func (c *Client) claim(cmd string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.wait(cmd); err != nil {
+		return err
+	}
+	c.busy = cmd + " in progress"
+	return nil
+}
+
+// wait waits until the connection is free for cmd, failing with ErrBusy
+// if it's been lent to the caller.  While the RetryPolicy runs, only
+// commands sent through the Client it was given go ahead.  c.mu must be
+// held.
+func (c *Client) wait(cmd string) error {
+	for c.retrying && !c.retrier || c.busy != "" && !c.lent {
+		if c.free == nil {
+			c.free = sync.NewCond(&c.mu)
+		}
+		c.free.Wait()
+	}
+	if c.busy != "" {
+		return c.busyError(cmd)
+	}
+	return nil
+}
+
+// lend marks the exchange the connection is busy with, if any, as being
+// in the caller's hands until done is called, so that commands fail
+// with ErrBusy rather than wait for it.
+func (c *Client) lend() {
+	c.mu.Lock()
+	if c.busy != "" {
+		c.lent = true
+		c.wake()
+	}
+	c.mu.Unlock()
+}
+
+// done frees the connection taken with claim, or by a response with a
+// data block once it's been read.
+func (c *Client) done() {
+	c.mu.Lock()
+	c.busy, c.lent = "", false
+	c.wake()
+	c.mu.Unlock()
+}
+
+// wake wakes the commands waiting for the connection.  c.mu must be
+// held.
+func (c *Client) wake() {
+	if c.free != nil {
+		c.free.Broadcast()
+	}
+}
+
+// busyError reports that cmd wasn't sent.  c.mu must be held.
+func (c *Client) busyError(cmd string) error {
+	verb, _ := nextField(cmd)
+	return fmt.Errorf("%s: %w: %s", verb, ErrBusy, c.busy)
+}
+
+// hasDataBlock reports whether a successful response, with code, to a
+// command with verb is followed by a data block.
+func hasDataBlock(verb string, code int) bool {
+	switch code {
+	case 100, 101, 215, 220, 221, 222, 224, 225, 230, 231, 282:
+		return true
+	case 211:
+		return verb == "LISTGROUP"
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package nntpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// busyServer answers DATE, OVER and POST.
+func busyServer(t *testing.T) *Client {
+	return fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "DATE":
+			c.PrintfLine("111 20240102030405")
+		case "OVER 1-2":
+			writeLines(c, 224, "Overview follows",
+				"1\ta\tb\tc\t<1@x>\t\t3\t1", "2\ta\tb\tc\t<2@x>\t\t3\t1")
+		case "POST":
+			c.PrintfLine("340 Send it")
+			io.Copy(ioutil.Discard, c.DotReader())
+			c.PrintfLine("240 Posted")
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+}
+
+func TestBusy(t *testing.T) {
+	c := busyServer(t)
+
+	_, _, body, err := c.CommandDot("OVER 1-2", 224)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Date(); !errors.Is(err, ErrBusy) {
+		t.Errorf("Date with a data block unread: %v", err)
+	}
+	io.Copy(ioutil.Discard, body)
+	if _, err := c.Date(); err != nil {
+		t.Errorf("Date once the block's read: %v", err)
+	}
+
+	// A command from the article's reader, mid-stream.
+	var midErr error
+	r := readerFunc(func(p []byte) (int, error) {
+		_, midErr = c.Date()
+		return 0, io.EOF
+	})
+	if err := c.Post(r); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(midErr, ErrBusy) {
+		t.Errorf("Date during Post: %v", midErr)
+	}
+}
+
+func TestConcurrentCommands(t *testing.T) {
+	c := busyServer(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if i%2 == 0 {
+					if _, err := c.Date(); err != nil {
+						t.Errorf("Date: %v", err)
+					}
+					// Read while other goroutines' responses arrive.
+					c.LastResponse()
+					continue
+				}
+				lines, err := c.Over("1-2")
+				switch {
+				case err != nil:
+					t.Errorf("Over: %v", err)
+				case len(lines) != 2 || !strings.HasPrefix(lines[1], "2\t"):
+					t.Errorf("Over: %q", lines)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if _, err := c.Date(); err != nil {
+		t.Errorf("Date afterwards: %v", err)
+	}
+}
+
+// upgradingServer answers DATE, GROUP and CAPABILITIES before and after
+// STARTTLS, which it serves with cert.
+func upgradingServer(t *testing.T, cert tls.Certificate) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		c := textproto.NewConn(nc)
+		c.PrintfLine("200 fake server ready")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb, arg := nextField(line); verb {
+			case "DATE":
+				c.PrintfLine("111 20240102030405")
+			case "GROUP":
+				c.PrintfLine("211 1 1 1%s", arg)
+			case "CAPABILITIES":
+				writeLines(c, 101, "Capabilities", "VERSION 2", "READER")
+			case "STARTTLS":
+				c.PrintfLine("382 go ahead")
+				c = textproto.NewConn(tls.Server(nc, &tls.Config{Certificates: []tls.Certificate{cert}}))
+			case "QUIT":
+				c.PrintfLine("205 bye")
+				return
+			default:
+				c.PrintfLine("500 Unknown command")
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+// TestConcurrentSessionChanges is for the race detector: StartTLS and
+// Close run while other goroutines send commands and look at the
+// session.
+func TestConcurrentSessionChanges(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	c, err := New("tcp", upgradingServer(t, leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var closing int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&closing) == 0 {
+				_, err := c.Group("misc.test")
+				if err == nil {
+					_, err = c.Date()
+				}
+				if err != nil && atomic.LoadInt32(&closing) == 0 {
+					t.Errorf("Command: %v", err)
+					return
+				}
+				c.HasTLS()
+				c.CurrentGroup()
+				c.RemoteAddr()
+			}
+		}()
+	}
+	if err := c.StartTLS(&tls.Config{RootCAs: pool}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.HasTLS() {
+		t.Errorf("TLS not active")
+	}
+	if _, err := c.Date(); err != nil {
+		t.Errorf("Date over TLS: %v", err)
+	}
+	atomic.StoreInt32(&closing, 1)
+	c.Close()
+	wg.Wait()
+}
+
+func TestCloseStuckCommand(t *testing.T) {
+	for _, closer := range []string{"Close", "CloseNow"} {
+		asked := make(chan struct{})
+		c := fakeServer(t, func(c *textproto.Conn, line string) {
+			if line == "DATE" {
+				// Never answered.
+				close(asked)
+			}
+		})
+		errc := make(chan error, 1)
+		go func() {
+			_, err := c.Date()
+			errc <- err
+		}()
+		<-asked
+
+		state := make(chan string, 1)
+		go func() { state <- c.CurrentGroup() }()
+		select {
+		case <-state:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: CurrentGroup waited for the stuck command", closer)
+		}
+		go func() {
+			if closer == "Close" {
+				c.Close()
+			} else {
+				c.CloseNow()
+			}
+		}()
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("%s: the stuck command succeeded", closer)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s didn't interrupt the stuck command", closer)
+		}
+	}
+}
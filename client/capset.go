@@ -0,0 +1,129 @@
+package nntpclient
+
+import (
+	"strings"
+)
+
+// A CapSet is a parsed CAPABILITIES response.
+//
+// Labels and arguments are matched case-insensitively.  All methods may
+// be called on a nil *CapSet, which has no capabilities.
+type CapSet struct {
+	// Versions lists the protocol versions from the VERSION line.
+	Versions []string
+	// Implementation is the IMPLEMENTATION line's text in its original
+	// case, if the server sent one.
+	Implementation string
+	// State is the state of the session the client retrieved the
+	// capabilities in.
+	State SessionState
+	// lines are the response lines, uppercased.
+	lines []string
+	caps  map[string][]string
+	full  map[string]string
+}
+
+// A SessionState is what has changed in a session that may change the
+// server's capabilities, as RFC 3977 allows.
+type SessionState struct {
+	TLS           bool
+	Authenticated bool
+	// Reader is set once MODE READER succeeds.
+	Reader     bool
+	Compressed bool
+}
+
+// A CapsPolicy says what a client does with capabilities it has
+// retrieved when the session state changes: after STARTTLS,
+// authentication, MODE READER and COMPRESS.
+type CapsPolicy int
+
+const (
+	// CapsRefresh retrieves them again.
+	CapsRefresh CapsPolicy = iota
+	// CapsForget drops them, saving the round trip, until Capabilities
+	// is called again.
+	CapsForget
+)
+
+// ParseCapabilities builds a CapSet from the lines of a CAPABILITIES
+// response.
+func ParseCapabilities(lines []string) *CapSet {
+	rv := &CapSet{caps: map[string][]string{}, full: map[string]string{}}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		label := strings.ToUpper(fields[0])
+		switch label {
+		case "VERSION":
+			rv.Versions = fields[1:]
+		case "IMPLEMENTATION":
+			rv.Implementation = strings.TrimSpace(strings.TrimSpace(line)[len(fields[0]):])
+		}
+		args := make([]string, len(fields)-1)
+		for i, f := range fields[1:] {
+			args[i] = strings.ToUpper(f)
+		}
+		rv.caps[label] = args
+		rv.full[label] = strings.ToUpper(line)
+		rv.lines = append(rv.lines, strings.ToUpper(line))
+	}
+	return rv
+}
+
+// Has reports whether the capability is advertised.
+func (cs *CapSet) Has(label string) bool {
+	if cs == nil {
+		return false
+	}
+	_, ok := cs.caps[strings.ToUpper(label)]
+	return ok
+}
+
+// Args returns the uppercased arguments of a capability, or nil.
+func (cs *CapSet) Args(label string) []string {
+	if cs == nil {
+		return nil
+	}
+	return cs.caps[strings.ToUpper(label)]
+}
+
+// HasArg reports whether a capability is advertised with the given
+// argument, such as HasArg("LIST", "ACTIVE").
+func (cs *CapSet) HasArg(label, arg string) bool {
+	arg = strings.ToUpper(arg)
+	for _, a := range cs.Args(label) {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// SASLMechanisms returns the mechanisms advertised with SASL.
+func (cs *CapSet) SASLMechanisms() []string {
+	return cs.Args("SASL")
+}
+
+// CompressAlgorithms returns the algorithms advertised with COMPRESS.
+func (cs *CapSet) CompressAlgorithms() []string {
+	return cs.Args("COMPRESS")
+}
+
+// Lines returns the uppercased capability lines.
+func (cs *CapSet) Lines() []string {
+	if cs == nil {
+		return nil
+	}
+	return append([]string(nil), cs.lines...)
+}
+
+// line returns the uppercased line for a label, or "".
+func (cs *CapSet) line(label string) string {
+	if cs == nil {
+		return ""
+	}
+	return cs.full[strings.ToUpper(label)]
+}
@@ -0,0 +1,172 @@
+package nntpclient
+
+import (
+	"net/textproto"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var capLines = []string{
+	"VERSION 2 3",
+	"READER",
+	"IMPLEMENTATION INN 2.7.1 (MixedCase)",
+	"list active Newsgroups OVERVIEW.FMT",
+	"OVER MSGID",
+	"SASL PLAIN SCRAM-SHA-256",
+	"COMPRESS DEFLATE",
+}
+
+func TestCapSet(t *testing.T) {
+	cs := ParseCapabilities(capLines)
+	if !reflect.DeepEqual(cs.Versions, []string{"2", "3"}) {
+		t.Errorf("Versions %v", cs.Versions)
+	}
+	if cs.Implementation != "INN 2.7.1 (MixedCase)" {
+		t.Errorf("Implementation %q", cs.Implementation)
+	}
+	if !cs.Has("reader") || cs.Has("POST") {
+		t.Errorf("Has is wrong")
+	}
+	if !cs.HasArg("LIST", "newsgroups") || cs.HasArg("LIST", "HEADERS") || cs.HasArg("HDR", "x") {
+		t.Errorf("HasArg is wrong")
+	}
+	if !reflect.DeepEqual(cs.Args("OVER"), []string{"MSGID"}) || len(cs.Args("READER")) != 0 {
+		t.Errorf("Args is wrong")
+	}
+	if !reflect.DeepEqual(cs.SASLMechanisms(), []string{"PLAIN", "SCRAM-SHA-256"}) {
+		t.Errorf("SASL %v", cs.SASLMechanisms())
+	}
+	if !reflect.DeepEqual(cs.CompressAlgorithms(), []string{"DEFLATE"}) {
+		t.Errorf("COMPRESS %v", cs.CompressAlgorithms())
+	}
+
+	var none *CapSet
+	if none.Has("READER") || none.Args("LIST") != nil || none.Lines() != nil {
+		t.Errorf("nil CapSet has capabilities")
+	}
+}
+
+func TestClientCapabilities(t *testing.T) {
+	client := fakeServer(t, func(c *textproto.Conn, line string) {
+		writeLines(c, 101, "Capability list:", capLines...)
+	})
+	if _, err := client.HasCapabilityArgument("LIST", "ACTIVE"); err == nil {
+		t.Errorf("No error before capabilities were fetched")
+	}
+	lines, err := client.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != len(capLines) || lines[3] != "LIST ACTIVE NEWSGROUPS OVERVIEW.FMT" {
+		t.Errorf("Lines %v", lines)
+	}
+	if got := client.GetCapability("list"); got != "LIST ACTIVE NEWSGROUPS OVERVIEW.FMT" {
+		t.Errorf("GetCapability %q", got)
+	}
+	if ok, err := client.HasCapabilityArgument("list", "newsgroups"); !ok || err != nil {
+		t.Errorf("HasCapabilityArgument %v %v", ok, err)
+	}
+	if _, err := client.HasCapabilityArgument("HDR", "x"); err == nil {
+		t.Errorf("No error for missing capability")
+	}
+	if !client.Caps().Has("OVER") {
+		t.Errorf("Caps doesn't have OVER")
+	}
+}
+
+func TestCapsAfterAuth(t *testing.T) {
+	authed := map[*textproto.Conn]bool{}
+	var mu sync.Mutex
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch line {
+		case "CAPABILITIES":
+			if authed[c] {
+				writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "POST", "LIST ACTIVE NEWSGROUPS")
+			} else {
+				writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "AUTHINFO USER", "LIST ACTIVE")
+			}
+		case "authinfo user tim":
+			c.PrintfLine("381 Password required")
+		case "authinfo pass secret":
+			authed[c] = true
+			c.PrintfLine("281 Welcome")
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+
+	c, err := New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Capabilities(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.HasCapabilityArgument("LIST", "NEWSGROUPS"); ok || err != nil {
+		t.Errorf("Before authenticating: %v, %v", ok, err)
+	}
+	if _, err := c.Authenticate("tim", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.HasCapabilityArgument("LIST", "NEWSGROUPS"); !ok || err != nil {
+		t.Errorf("After authenticating: %v, %v", ok, err)
+	}
+	if !c.Caps().Has("POST") || !c.PostingAllowed() {
+		t.Errorf("POST not picked up: %q", c.Caps().Lines())
+	}
+	if got, want := c.Caps().State, (SessionState{Authenticated: true}); got != want {
+		t.Errorf("State %+v, want %+v", got, want)
+	}
+
+	c, err = New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.CapsPolicy = CapsForget
+	if _, err := c.Capabilities(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Authenticate("tim", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Caps() != nil {
+		t.Errorf("Capabilities kept from before authenticating")
+	}
+	if _, err := c.HasCapabilityArgument("LIST", "ACTIVE"); err == nil {
+		t.Errorf("HasCapabilityArgument answered from stale capabilities")
+	}
+}
+
+func TestSupports(t *testing.T) {
+	var sent []string
+	client := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		writeLines(c, 101, "Capability list:", "VERSION 2", "reader",
+			"list\tactive NEWSGROUPS \tOverview.fmt")
+	})
+	if !client.Supports("LIST") || !client.Supports("Reader") || client.Supports("OVER") {
+		t.Errorf("Supports is wrong")
+	}
+	args, ok := client.GetCapabilityArgs("list")
+	if want := []string{"ACTIVE", "NEWSGROUPS", "OVERVIEW.FMT"}; !ok || !reflect.DeepEqual(args, want) {
+		t.Errorf("GetCapabilityArgs %v %v, want %v", args, ok, want)
+	}
+	args[0] = "changed"
+	if !client.Caps().HasArg("LIST", "ACTIVE") {
+		t.Errorf("GetCapabilityArgs returned the CapSet's own slice")
+	}
+	if args, ok := client.GetCapabilityArgs("READER"); !ok || len(args) != 0 {
+		t.Errorf("GetCapabilityArgs READER %v %v", args, ok)
+	}
+	if _, ok := client.GetCapabilityArgs("HDR"); ok {
+		t.Errorf("GetCapabilityArgs found HDR")
+	}
+	if len(sent) != 1 {
+		t.Errorf("Sent %q, want one CAPABILITIES", sent)
+	}
+}
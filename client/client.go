@@ -2,229 +2,957 @@
 package nntpclient
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/yannik995/go-nntp"
 )
 
-// Client is an NNTP client.
+// Client is an NNTP client.  It's one session with the server, which
+// runs one command at a time: commands from several goroutines take
+// turns, each waiting until the one before has been read in full.  One
+// sent while the caller still holds the connection, as with a data
+// block returned unread, fails with ErrBusy.  Use a Pool for parallel
+// work.
 type Client struct {
-	conn         *textproto.Conn
-	netconn      net.Conn
-	tls          bool
-	Banner       string
-	capabilities []string
+	// session is the connection and its state, which the Client given
+	// to a RetryPolicy shares.
+	*session
+	// retrier is set on the Client given to a RetryPolicy, whose
+	// commands go ahead while it runs.
+	retrier bool
+
+	Banner string
+	// CancelSecret is the Cancel-Lock secret used by CancelArticle.
+	CancelSecret []byte
+	// CheckHeaders makes Hdr and HdrMessageID check the field against
+	// LIST HEADERS, fetched once, before asking for it.
+	CheckHeaders bool
+	// BufferBodies makes GetArticle read the whole body before
+	// returning, so that it stays readable across later commands.
+	BufferBodies bool
+	// DecodeHeaders makes OverviewFull, GetArticle and HeadMIME decode
+	// header values with nntp.DecodeHeader, for display.
+	DecodeHeaders bool
+	// EncodeHeaders makes PostArticle encode header values that aren't
+	// ASCII with nntp.EncodeHeader, for servers and readers that only
+	// take 7-bit headers.
+	EncodeHeaders bool
+	// AutoDistribution makes PostArticle fill in a missing Distribution
+	// header with FillDistribution.
+	AutoDistribution bool
+	// Tracer, if set, records a span for each command.
+	Tracer Tracer
+	// ReadTimeout and WriteTimeout, if set, bound each read from and
+	// write to the server, so a long data block is fine as long as it
+	// keeps arriving.  A timeout is a net.Error whose Timeout is true,
+	// and leaves the connection unusable.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// CapsPolicy is what happens to retrieved capabilities when the
+	// session state changes.
+	CapsPolicy CapsPolicy
+	// RetryPolicy, if set, decides whether idempotent commands that
+	// fail transiently are sent again.
+	RetryPolicy RetryPolicy
+	// PipelineWindow is how many commands FetchBodies and FetchArticles
+	// send before reading responses; 0 means 16.
+	PipelineWindow int
+}
+
+// session is a Client's connection and what's known about it.
+type session struct {
+	// owner is the Client the session was made for.
+	owner *Client
+	// mu guards busy and the session's state.  It's never held while
+	// waiting on the server, so that Close can interrupt a command.
+	mu sync.Mutex
+	// busy says what the connection is taken by, if anything, between
+	// commands, and lent whether that's in the caller's hands.
+	busy string
+	lent bool
+	// free is signalled when busy is cleared or lent set.
+	free    *sync.Cond
+	conn    *textproto.Conn
+	netconn net.Conn
+	// cc is the connection the client was made with, beneath STARTTLS
+	// and compression, bounded by the context from SetContext.
+	cc  *ctxConn
+	tls bool
+	// compressed is set once COMPRESS DEFLATE is active.
+	compressed bool
+	// xfeatureGzip is set once XFEATURE COMPRESS GZIP is active.
+	xfeatureGzip bool
+	closed       bool
+	// posting is whether the server last said posting is allowed.
+	posting bool
+	// lastCode and lastMsg are the last response, for LastResponse.
+	lastCode int
+	lastMsg  string
+	// host is the name the client dialed, for TLS.
+	host        string
+	caps        *CapSet
+	distribPats []nntp.DistribPat
+	// group is the group last selected with GROUP or LISTGROUP.
+	group string
+	// overCmd is OVER or XOVER, once it's known which the server takes.
+	overCmd string
+	// overviewFmt caches OverviewFormat; it's empty, not nil, if the
+	// server couldn't say.
+	overviewFmt []string
+	// hdrFields caches ListHeaders for CheckHeaders.
+	hdrFields []string
+	// authed and reader record the session state, with tls and
+	// compressed.
+	authed bool
+	reader bool
+	// streaming counts the answers to CHECK and TAKETHIS StreamResult
+	// hasn't collected.
+	streaming int
+	// retrying is set while the RetryPolicy runs.
+	retrying bool
+	ctx      context.Context
 }
 
-// New connects a client to an NNTP server.
+// New connects a client to an NNTP server.  Dial offers timeouts, other
+// ways of connecting, and setting up the session.
 func New(network, addr string) (*Client, error) {
-	netconn, err := net.Dial(network, addr)
-	if err != nil {
-		return nil, err
-	}
-	return connect(netconn)
+	return Dial(addr, WithNetwork(network))
 }
 
 // NewConn wraps an existing connection, for example one opened with tls.Dial
 func NewConn(netconn net.Conn) (*Client, error) {
-	client, err := connect(netconn)
+	client, err := connect(context.Background(), netconn, nil)
 	if err != nil {
 		return nil, err
 	}
-	if _, ok := netconn.(*tls.Conn); ok {
+	client.host = dialHost(netconn.RemoteAddr().String())
+	if tc, ok := netconn.(*tls.Conn); ok {
 		client.tls = true
+		if name := tc.ConnectionState().ServerName; name != "" {
+			client.host = name
+		}
 	}
 	return client, nil
 }
 
-// NewTLS connects to an NNTP server over a dedicated TLS port like 563
+// NewTLS connects to an NNTP server over a dedicated TLS port like 563.
+//
+// The certificate is verified for config's ServerName if it's set, and
+// for addr's host otherwise.  config may be nil; unless it says
+// otherwise, TLS 1.2 is the oldest version accepted.
 func NewTLS(network, addr string, config *tls.Config) (*Client, error) {
-	netconn, err := tls.Dial(network, addr, config)
-	if err != nil {
-		return nil, err
-	}
-	client, err := connect(netconn)
+	return Dial(addr, WithNetwork(network), WithTLS(config))
+}
+
+// dialHost returns the host part of a dial address.
+func dialHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		return nil, err
+		return addr
 	}
-	client.tls = true
-	return client, nil
+	return host
 }
 
-func connect(netconn net.Conn) (*Client, error) {
-	conn := textproto.NewConn(netconn)
-	_, msg, err := conn.ReadCodeLine(20)
+// connect reads the server's greeting, after a TLS handshake if config
+// is set, giving up if ctx is done first.
+func connect(ctx context.Context, netconn net.Conn, config *tls.Config) (*Client, error) {
+	cc := &ctxConn{Conn: netconn}
+	cc.watch(ctx)
+	defer cc.watch(nil)
+	var tconn net.Conn = cc
+	if config != nil {
+		tc := tls.Client(cc, config)
+		if err := tc.Handshake(); err != nil {
+			return nil, err
+		}
+		tconn = tc
+	}
+	conn := textproto.NewConn(tconn)
+	code, msg, err := conn.ReadCodeLine(20)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{
-		conn:    conn,
-		netconn: netconn,
-		Banner:  msg,
-	}, nil
+	c := &Client{session: &session{
+		conn:     conn,
+		netconn:  tconn,
+		cc:       cc,
+		tls:      config != nil,
+		posting:  code == 200,
+		lastCode: code,
+		lastMsg:  msg,
+	}, Banner: msg}
+	c.owner = c
+	cc.client = c
+	return c, nil
 }
 
-// Close this client.
+// quitTimeout is how long Close waits for the response to QUIT.
+const quitTimeout = 2 * time.Second
+
+// Close says goodbye to the server with QUIT and closes the connection,
+// which is closed even if QUIT fails.  If a command is in progress, it's
+// interrupted instead, without QUIT.  Closing a closed client does
+// nothing.
 func (c *Client) Close() error {
-	return c.conn.Close()
+	// QUIT is only sent if the connection is free: a command in
+	// progress may be stuck, and closing interrupts it.
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	idle := c.busy == "" && !c.retrying
+	if idle {
+		c.busy = "QUIT in progress"
+	}
+	netconn, conn := c.netconn, c.conn
+	c.mu.Unlock()
+	if idle {
+		netconn.SetDeadline(time.Now().Add(quitTimeout))
+		if err := conn.PrintfLine("QUIT"); err == nil {
+			c.readCodeLine(205)
+		}
+		c.done()
+	}
+	return c.CloseNow()
 }
 
-// Authenticate against an NNTP server using authinfo user/pass
+// CloseNow closes the connection without QUIT, as when its state is
+// unknown after an error.  A command in progress on another goroutine
+// is interrupted, and fails.
+func (c *Client) CloseNow() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn, cc := c.conn, c.cc
+	c.mu.Unlock()
+	if cc != nil {
+		cc.watch(nil)
+	}
+	return conn.Close()
+}
+
+// isClosed reports whether the client has been closed.
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// Authenticate against an NNTP server using authinfo user/pass.  A
+// server that accepts the user without a password isn't sent one.
+//
+// A refusal is an *AuthError with the server's reason, which is also
+// returned as msg: a rejection matches ErrAuthFailed, and a server that
+// was already authenticated or didn't expect the command
+// ErrAuthSequence.  The connection stays usable to try again.
+//
+// Capabilities that have been retrieved are retrieved again afterwards,
+// as they may have changed, or dropped if CapsPolicy is CapsForget; the
+// SASL and GENERIC methods do the same.
 func (c *Client) Authenticate(user, pass string) (msg string, err error) {
-	err = c.conn.PrintfLine("authinfo user %s", user)
-	if err != nil {
-		return
+	if err := checkArgument("AUTHINFO USER", user); err != nil {
+		return "", err
+	}
+	if err := checkArgument("AUTHINFO PASS", pass); err != nil {
+		return "", err
+	}
+	if err := c.claim("AUTHINFO"); err != nil {
+		return "", err
 	}
-	_, _, err = c.conn.ReadCodeLine(381)
+	msg, err = c.authinfo(user, pass)
+	c.done()
 	if err != nil {
-		return
+		return msg, err
 	}
+	return msg, c.authenticated()
+}
 
-	err = c.conn.PrintfLine("authinfo pass %s", pass)
+// authinfo sends AUTHINFO USER, and PASS if the server asks for it.
+func (c *Client) authinfo(user, pass string) (string, error) {
+	if err := c.conn.PrintfLine("authinfo user %s", user); err != nil {
+		return "", err
+	}
+	code, msg, err := c.readCodeLine(-1)
+	switch {
+	case err != nil:
+		return "", err
+	case code == 281:
+		// The server doesn't need a password.
+		return msg, nil
+	case code != 381:
+		return msg, authError(&textproto.Error{Code: code, Msg: msg})
+	}
+
+	if err := c.conn.PrintfLine("authinfo pass %s", pass); err != nil {
+		return "", err
+	}
+	_, msg, err = c.readCodeLine(281)
 	if err != nil {
-		return
+		return msg, authError(err)
+	}
+	return msg, nil
+}
+
+// authenticated records a successful authentication, which may change
+// the capabilities and whether posting is allowed.
+func (c *Client) authenticated() error {
+	c.mu.Lock()
+	c.authed = true
+	c.mu.Unlock()
+	return c.stateChanged(false)
+}
+
+// stateChanged applies the CapsPolicy to the capabilities after the
+// session state changes, if they've been retrieved or always is set.
+func (c *Client) stateChanged(always bool) error {
+	c.mu.Lock()
+	retrieved := c.caps != nil
+	if c.CapsPolicy == CapsForget {
+		c.caps = nil
+	}
+	policy := c.CapsPolicy
+	c.mu.Unlock()
+	if policy == CapsForget || !retrieved && !always {
+		return nil
 	}
-	_, msg, err = c.conn.ReadCodeLine(281)
-	return
+	_, err := c.Capabilities()
+	return err
+}
+
+// SessionState returns the current state of the session.
+func (c *Client) SessionState() SessionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionState()
+}
+
+// sessionState is SessionState with c.mu held.
+func (c *Client) sessionState() SessionState {
+	return SessionState{TLS: c.tls, Authenticated: c.authed, Reader: c.reader, Compressed: c.compressed}
 }
 
-func parsePosting(p string) nntp.PostingStatus {
-	switch p {
-	case "y":
-		return nntp.PostingPermitted
-	case "m":
-		return nntp.PostingModerated
+// PostingAllowed reports whether the server says posting is allowed: in
+// its greeting, 200 rather than 201, then in its answer to MODE READER,
+// and in the capabilities of a reader, which list POST, whenever
+// they're retrieved.  A server may still refuse a particular article.
+func (c *Client) PostingAllowed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.posting
+}
+
+// ModeReader switches a server that starts in transit mode, as INN
+// does, into reader mode, and reports whether posting is allowed.  The
+// capabilities change with the mode, so if they've been retrieved
+// they're retrieved again, or dropped, as CapsPolicy says.
+func (c *Client) ModeReader() (postingAllowed bool, err error) {
+	code, _, err := c.tracedCommand("MODE READER", 20, "")
+	if err != nil {
+		return false, err
 	}
-	return nntp.PostingNotPermitted
+	c.mu.Lock()
+	c.reader = true
+	c.overCmd = ""
+	c.overviewFmt = nil
+	c.mu.Unlock()
+	if err := c.stateChanged(false); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	c.posting = code == 200
+	c.mu.Unlock()
+	return code == 200, nil
 }
 
-// List groups
+// List groups.  sub is the rest of the LIST command; ListActive is
+// safer when it's a pattern.
+//
+// Lines that can't be parsed are skipped; the groups that were read are
+// returned along with a *SkippedLinesError counting them.  A line
+// without a posting flag is kept, with the status Unknown.  ListFunc
+// avoids holding every group at once.
 func (c *Client) List(sub string) (rv []nntp.Group, err error) {
-	_, _, err = c.Command("LIST "+sub, 215)
+	return c.listGroups("LIST "+sub, 215)
+}
+
+// ListActive lists the groups matching a wildmat, such as "comp.*", or
+// all groups if it's empty.  Lines that can't be parsed are reported as
+// for List.
+func (c *Client) ListActive(wildmat string) ([]nntp.Group, error) {
+	cmd := "LIST ACTIVE"
+	if wildmat != "" {
+		if err := nntp.ValidateWildmat(wildmat); err != nil {
+			return nil, err
+		}
+		cmd += " " + wildmat
+	}
+	return c.listGroups(cmd, 215)
+}
+
+// ListFunc is List without holding the groups in memory: it calls fn
+// with each group as its line is read, so even a full feed's active
+// file takes little memory.  If fn returns an error, the rest of the
+// response is read and skipped, to leave the connection usable, and the
+// error is returned.  Otherwise lines that couldn't be parsed are
+// counted in a *SkippedLinesError once all the groups have been handed
+// to fn.
+func (c *Client) ListFunc(sub string, fn func(g nntp.Group) error) error {
+	if _, _, err := c.tracedCommand("LIST "+sub, 215, ""); err != nil {
+		return err
+	}
+	defer c.done()
+	c.lend()
+	var p groupParser
+	err := readChunks(c.conn.R, func(chunk string) error {
+		return p.parse(chunk, fn)
+	})
 	if err != nil {
-		return
+		return err
 	}
-	var groupLines []string
-	groupLines, err = c.conn.ReadDotLines()
+	return p.err()
+}
+
+// listGroups issues a command answered with active file lines.
+func (c *Client) listGroups(cmd string, expectCode int) ([]nntp.Group, error) {
+	if _, _, err := c.tracedCommand(cmd, expectCode, ""); err != nil {
+		return nil, err
+	}
+	defer c.done()
+	rv := []nntp.Group{}
+	var p groupParser
+	err := readChunks(c.conn.R, func(chunk string) error {
+		// Grow by doubling, rather than by the quarter append
+		// settles on for large slices, which copies far more.
+		if n := len(rv) + strings.Count(chunk, "\n"); n > cap(rv) {
+			grown := make([]nntp.Group, len(rv), 2*n)
+			copy(grown, rv)
+			rv = grown
+		}
+		return p.parse(chunk, func(g nntp.Group) error {
+			rv = append(rv, g)
+			return nil
+		})
+	})
 	if err != nil {
-		return
+		return nil, err
+	}
+	return rv, p.err()
+}
+
+// NewGroups lists the groups created since a time.  Lines that can't be
+// parsed are reported as for List.
+func (c *Client) NewGroups(since time.Time) ([]nntp.Group, error) {
+	return c.listGroups("NEWGROUPS "+formatSince(since), 231)
+}
+
+// A SkippedLinesError is returned, along with everything that was read,
+// when some lines of a response couldn't be parsed.
+type SkippedLinesError struct {
+	// Skipped is how many lines were skipped.
+	Skipped int
+	// First is the first of them.
+	First string
+}
+
+func (e *SkippedLinesError) Error() string {
+	return fmt.Sprintf("skipped %d unparseable lines, the first %q", e.Skipped, e.First)
+}
+
+// NewNews lists the message-ids of articles posted since a time in the
+// groups matching a wildmat, such as "comp.lang.*,!comp.lang.c".
+func (c *Client) NewNews(wildmat string, since time.Time) ([]string, error) {
+	if err := nntp.ValidateWildmat(wildmat); err != nil {
+		return nil, err
+	}
+	lines, err := c.asLines("NEWNEWS "+wildmat+" "+formatSince(since), 230)
+	if err != nil {
+		return nil, err
 	}
-	rv = make([]nntp.Group, 0, len(groupLines))
-	for _, l := range groupLines {
-		parts := strings.Split(l, " ")
-		high, errh := strconv.ParseInt(parts[1], 10, 64)
-		low, errl := strconv.ParseInt(parts[2], 10, 64)
-		if errh == nil && errl == nil {
-			rv = append(rv, nntp.Group{
-				Name:    parts[0],
-				High:    high,
-				Low:     low,
-				Posting: parsePosting(parts[3]),
-			})
+	rv := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			rv = append(rv, l)
 		}
 	}
-	return
+	return rv, nil
+}
+
+// Date returns the server's current time, in UTC.
+func (c *Client) Date() (time.Time, error) {
+	_, msg, err := c.tracedCommand("DATE", 111, "")
+	if err != nil {
+		return time.Time{}, err
+	}
+	stamp, _ := nextField(msg)
+	if len(stamp) != 14 || strings.Trim(stamp, "0123456789") != "" {
+		return time.Time{}, errors.New("Don't know how to parse result: " + msg)
+	}
+	return time.Parse("20060102150405", stamp)
+}
+
+// formatSince formats a time as NEWGROUPS and NEWNEWS arguments.
+func formatSince(t time.Time) string {
+	date, tm := nntp.FormatNNTPDate(t)
+	return date + " " + tm + " GMT"
+}
+
+// A groupParser parses "name high low posting" lines, as LIST ACTIVE
+// and NEWGROUPS return them, counting those it doesn't understand.
+// Blank lines are ignored.
+type groupParser struct {
+	skipped *SkippedLinesError
+}
+
+// parse calls fn with the group on each line of chunk, stopping if it
+// fails.
+func (p *groupParser) parse(chunk string, fn func(nntp.Group) error) error {
+	for chunk != "" {
+		i := strings.IndexByte(chunk, '\n')
+		l := chunk[:i]
+		chunk = chunk[i+1:]
+		if strings.TrimLeft(l, " \t") == "" {
+			continue
+		}
+		g, ok := parseActiveLine(l)
+		if !ok {
+			if p.skipped == nil {
+				p.skipped = &SkippedLinesError{First: l}
+			}
+			p.skipped.Skipped++
+			continue
+		}
+		if err := fn(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// err returns a *SkippedLinesError if any lines were skipped.
+func (p *groupParser) err() error {
+	if p.skipped != nil {
+		return p.skipped
+	}
+	return nil
+}
+
+// parseActiveLine parses one line of an active file, reporting whether
+// it could.  A missing posting flag leaves the status Unknown.
+func parseActiveLine(l string) (nntp.Group, bool) {
+	name, rest := nextField(l)
+	highs, rest := nextField(rest)
+	lows, rest := nextField(rest)
+	posting, _ := nextField(rest)
+	high, errh := strconv.ParseInt(highs, 10, 64)
+	low, errl := strconv.ParseInt(lows, 10, 64)
+	if errh != nil || errl != nil {
+		return nntp.Group{}, false
+	}
+	g := nntp.Group{Name: name, High: high, Low: low}
+	if posting != "" {
+		var err error
+		g.Posting, g.AliasOf, err = nntp.ParsePostingStatus(posting)
+		if err != nil {
+			// Don't assume a flag we don't know allows posting.
+			g.Posting = nntp.PostingNotPermitted
+		}
+	}
+	return g, true
+}
+
+// nextField splits the first space or tab separated field off s.
+func nextField(s string) (field, rest string) {
+	s = strings.TrimLeft(s, " \t")
+	i := strings.IndexAny(s, " \t")
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], s[i:]
 }
 
 // Group selects a group.
 func (c *Client) Group(name string) (rv nntp.Group, err error) {
 	var msg string
-	_, msg, err = c.Command("GROUP "+name, 211)
+	_, msg, err = c.tracedCommand("GROUP "+name, 211, "")
 	if err != nil {
 		return
 	}
-	// count first last name
-	parts := strings.Split(msg, " ")
-	if len(parts) != 4 {
-		err = errors.New("Don't know how to parse result: " + msg)
+	return parseGroupLine(msg)
+}
+
+// CurrentGroup returns the group last selected with GROUP or LISTGROUP,
+// or "" if none has been.
+func (c *Client) CurrentGroup() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.group
+}
+
+// parseGroupLine parses the "count first last name" of a 211 response.
+// Words after the name, which some servers add, are ignored.
+func parseGroupLine(msg string) (nntp.Group, error) {
+	parts := strings.Fields(msg)
+	if len(parts) < 4 {
+		return nntp.Group{}, errors.New("Don't know how to parse result: " + msg)
+	}
+	var nums [3]int64
+	for i, what := range []string{"count", "low water mark", "high water mark"} {
+		n, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil {
+			return nntp.Group{}, fmt.Errorf("bad %s in group response %q", what, msg)
+		}
+		nums[i] = n
 	}
-	rv.Count, err = strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
-		return
+	return nntp.Group{Name: parts[3], Count: nums[0], Low: nums[1], High: nums[2]}, nil
+}
+
+// ListGroup selects a group and lists the numbers of the articles in
+// it, optionally only those in rng ("100-", "100-200").  An empty group
+// lists the currently selected group, but then rng can't be given.
+//
+// Lines that aren't article numbers are skipped; the numbers that were
+// read are returned along with an error describing the first of them.
+func (c *Client) ListGroup(group, rng string) ([]int64, nntp.Group, error) {
+	cmd := "LISTGROUP"
+	switch {
+	case group != "" && rng != "":
+		cmd += " " + group + " " + rng
+	case group != "":
+		cmd += " " + group
+	case rng != "":
+		return nil, nntp.Group{}, errors.New("LISTGROUP range requires a group")
 	}
-	rv.Low, err = strconv.ParseInt(parts[1], 10, 64)
+	_, msg, err := c.tracedCommand(cmd, 211, "")
 	if err != nil {
-		return
+		return nil, nntp.Group{}, err
 	}
-	rv.High, err = strconv.ParseInt(parts[2], 10, 64)
+	defer c.done()
+	r := dotLines{r: c.conn.R}
+	g, err := parseGroupLine(msg)
 	if err != nil {
-		return
+		r.drain()
+		return nil, g, err
 	}
-	rv.Name = parts[3]
-
-	return
+	var rv []int64
+	var bad error
+	for {
+		line, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rv, g, err
+		}
+		n, err := strconv.ParseInt(string(bytes.TrimSpace(line)), 10, 64)
+		if err != nil || n <= 0 {
+			if bad == nil {
+				bad = fmt.Errorf("bad article number in LISTGROUP %s: %q", g.Name, line)
+			}
+			continue
+		}
+		rv = append(rv, n)
+	}
+	return rv, g, bad
 }
 
-// Article grabs an article
+// Article grabs an article.  The specifier is an article number in the
+// current group, or a message-id, which may leave out the angle
+// brackets; an invalid message-id is an error and isn't sent.  Head,
+// Body and Stat take the same.  An article that doesn't exist gives an
+// error matching ErrNoSuchArticle, naming the command.
 func (c *Client) Article(specifier string) (int64, string, io.Reader, error) {
-	err := c.conn.PrintfLine("ARTICLE %s", specifier)
-	if err != nil {
-		return 0, "", nil, err
-	}
-	return c.articleish(220)
+	return c.handOver(c.articleish("ARTICLE", specifier, 220))
 }
 
 // Head gets the headers for an article
 func (c *Client) Head(specifier string) (int64, string, io.Reader, error) {
-	err := c.conn.PrintfLine("HEAD %s", specifier)
-	if err != nil {
-		return 0, "", nil, err
-	}
-	return c.articleish(221)
+	return c.handOver(c.articleish("HEAD", specifier, 221))
 }
 
 // Body gets the body of an article
 func (c *Client) Body(specifier string) (int64, string, io.Reader, error) {
-	err := c.conn.PrintfLine("BODY %s", specifier)
-	if err != nil {
-		return 0, "", nil, err
+	return c.handOver(c.articleish("BODY", specifier, 222))
+}
+
+// handOver lends the connection to the caller along with an article's
+// reader, if there is one.
+func (c *Client) handOver(n int64, id string, r io.Reader, err error) (int64, string, io.Reader, error) {
+	if err == nil {
+		c.lend()
 	}
-	return c.articleish(222)
+	return n, id, r, err
 }
 
-func (c *Client) articleish(expected int) (int64, string, io.Reader, error) {
-	_, msg, err := c.conn.ReadCodeLine(expected)
+func (c *Client) articleish(verb, specifier string, expected int) (int64, string, io.Reader, error) {
+	specifier, err := articleSpecifier(specifier)
 	if err != nil {
 		return 0, "", nil, err
 	}
-	parts := strings.SplitN(msg, " ", 2)
-	n, err := strconv.ParseInt(parts[0], 10, 64)
+	cmd := verb
+	if specifier != "" {
+		cmd += " " + specifier
+	}
+	end := c.startSpan("nntp.article", verb)
+	code, msg, err := c.command(cmd, expected, "")
+	if err != nil {
+		endSpan(end, code, -1, err)
+		return 0, "", nil, articleError(cmd, err)
+	}
+	n, id, err := parseArticleLine(msg)
 	if err != nil {
+		// Skip the data so the connection stays usable.
+		io.Copy(ioutil.Discard, c.dotReader())
+		endSpan(end, code, -1, err)
 		return 0, "", nil, err
 	}
-	return n, parts[1], c.conn.DotReader(), nil
+	if end == nil {
+		return n, id, c.dotReader(), nil
+	}
+	return n, id, &spanReader{r: c.dotReader(), end: end, code: code}, nil
+}
+
+// articleSpecifier checks the argument of ARTICLE, HEAD, BODY or STAT:
+// empty, an article number, or a message-id, which gets its angle
+// brackets if they're missing.
+func articleSpecifier(s string) (string, error) {
+	if s == "" || strings.Trim(s, "0123456789") == "" {
+		return s, nil
+	}
+	id := nntp.CanonicalMessageID(s)
+	return id, nntp.ValidateMessageID(id)
+}
+
+// messageIDArgument canonicalizes s as articleSpecifier does, for a
+// command that must only be given a message-id, not an article number
+// or nothing, which would ask about the current article.
+func messageIDArgument(verb, s string) (string, error) {
+	if err := checkArgument(verb, s); err != nil {
+		return "", err
+	}
+	if strings.Trim(s, "0123456789") == "" {
+		return "", fmt.Errorf("%s: %q isn't a message-id", verb, s)
+	}
+	return articleSpecifier(s)
+}
+
+// parseArticleLine parses the "n <message-id>" that starts the response
+// to commands selecting an article.
+func parseArticleLine(msg string) (int64, string, error) {
+	parts := strings.Fields(msg)
+	var n int64
+	var err error
+	if len(parts) >= 2 {
+		n, err = strconv.ParseInt(parts[0], 10, 64)
+	}
+	if len(parts) < 2 || err != nil {
+		return 0, "", errors.New("Don't know how to parse result: " + msg)
+	}
+	return n, parts[1], nil
+}
+
+// ErrNoSuchArticle matches, with errors.Is, the responses saying an
+// article doesn't exist or none is selected: 420, 423 and 430.  The
+// underlying *textproto.Error is still available with errors.As.
+var ErrNoSuchArticle = errors.New("no such article")
+
+// ErrNoNextArticle and ErrNoPreviousArticle match, with errors.Is, the
+// 421 and 422 responses to NEXT and LAST at the ends of a group.
+var (
+	ErrNoNextArticle     = errors.New("no next article")
+	ErrNoPreviousArticle = errors.New("no previous article")
+)
+
+// codedError makes a *textproto.Error match a sentinel error.
+type codedError struct {
+	err  *textproto.Error
+	kind error
+}
+
+func (e *codedError) Error() string        { return e.err.Error() }
+func (e *codedError) Unwrap() error        { return e.err }
+func (e *codedError) Is(target error) bool { return target == e.kind }
+
+// Temporary reports whether the response is transient, as IsTransient
+// does.
+func (e *codedError) Temporary() bool { return IsTransient(e.err) }
+
+// articleError makes the responses about missing articles match
+// ErrNoSuchArticle, ErrNoNextArticle or ErrNoPreviousArticle, saying
+// which command they answered.
+func articleError(cmd string, err error) error {
+	terr, ok := err.(*textproto.Error)
+	if !ok {
+		return err
+	}
+	var kind error
+	switch terr.Code {
+	case 420, 423, 430:
+		kind = ErrNoSuchArticle
+	case 421:
+		kind = ErrNoNextArticle
+	case 422:
+		kind = ErrNoPreviousArticle
+	default:
+		return err
+	}
+	return fmt.Errorf("%s: %w", cmd, &codedError{terr, kind})
+}
+
+// Stat checks that an article exists, without transferring it, and
+// returns its number and message-id.  The specifier is a message-id or
+// an article number in the current group.  An article that doesn't
+// exist gives an error matching ErrNoSuchArticle.  An empty specifier
+// means the current article.
+func (c *Client) Stat(specifier string) (int64, string, error) {
+	specifier, err := articleSpecifier(specifier)
+	if err != nil {
+		return 0, "", err
+	}
+	cmd := "STAT"
+	if specifier != "" {
+		cmd += " " + specifier
+	}
+	return c.selectArticle(cmd)
+}
+
+// Next moves the current article pointer to the next article in the
+// group and returns its number and message-id.  At the last article
+// the error matches ErrNoNextArticle, and with no current article,
+// ErrNoSuchArticle.
+func (c *Client) Next() (int64, string, error) {
+	return c.selectArticle("NEXT")
+}
+
+// Last moves the current article pointer to the previous article in the
+// group.  At the first article the error matches ErrNoPreviousArticle.
+func (c *Client) Last() (int64, string, error) {
+	return c.selectArticle("LAST")
+}
+
+// selectArticle sends a command answered with 223 and an article.
+func (c *Client) selectArticle(cmd string) (int64, string, error) {
+	_, msg, err := c.tracedCommand(cmd, 223, "")
+	if err != nil {
+		return 0, "", articleError(cmd, err)
+	}
+	return parseArticleLine(msg)
 }
 
 // Post a new article
 //
 // The reader should contain the entire article, headers and body in
-// RFC822ish format.
+// RFC822ish format.  If reading r fails part way through, the connection
+// is closed rather than post a truncated article.  LastResponse has the
+// server's answer.
 func (c *Client) Post(r io.Reader) error {
+	if err := c.claim("POST"); err != nil {
+		return err
+	}
+	defer c.done()
+	c.lend()
+	end := c.startSpan("nntp.post", "POST")
 	err := c.conn.PrintfLine("POST")
 	if err != nil {
+		endSpan(end, 0, -1, err)
 		return err
 	}
-	_, _, err = c.conn.ReadCodeLine(340)
+	_, _, err = c.readCodeLine(340)
 	if err != nil {
+		endSpan(end, 0, -1, err)
 		return err
 	}
 	w := c.conn.DotWriter()
-	_, err = io.Copy(w, r)
+	n, err := io.Copy(w, r)
 	if err != nil {
-		// This seems really bad
+		// Ending the block would post a truncated article.
+		c.CloseNow()
+		endSpan(end, 0, n, err)
 		return err
 	}
 	w.Close()
-	_, _, err = c.conn.ReadCodeLine(240)
+	code, _, err := c.readCodeLine(240)
+	endSpan(end, code, n, err)
 	return err
 }
 
+// CommandUpload sends a command that uploads a data block, like POST:
+// the server answers continueCode, the data from r is sent dot-encoded,
+// and the server answers finalCode.  It returns the final response.  A
+// server that refuses at the first step gets nothing read from r.
+//
+// If reading r fails, the block is still ended and the final response
+// read, so the connection stays usable, but the server will have
+// received a truncated block.  The read error is returned.
+func (c *Client) CommandUpload(cmd string, continueCode, finalCode int, r io.Reader) (int, string, error) {
+	verb, _ := nextField(cmd)
+	if err := checkArgument(verb, cmd); err != nil {
+		return 0, "", err
+	}
+	if err := c.claim(verb); err != nil {
+		return 0, "", err
+	}
+	defer c.done()
+	c.lend()
+	end := c.startSpan("nntp.upload", cmd)
+	if err := c.conn.PrintfLine("%s", cmd); err != nil {
+		endSpan(end, 0, -1, err)
+		return 0, "", err
+	}
+	if code, msg, err := c.readCodeLine(continueCode); err != nil {
+		endSpan(end, code, -1, err)
+		return code, msg, err
+	}
+	w := c.conn.DotWriter()
+	n, copyErr := io.Copy(w, r)
+	if err := w.Close(); err != nil {
+		endSpan(end, 0, n, err)
+		return 0, "", err
+	}
+	code, msg, err := c.readCodeLine(finalCode)
+	if copyErr != nil {
+		err = copyErr
+	}
+	endSpan(end, code, n, err)
+	return code, msg, err
+}
+
+// CancelArticle posts a cancel for an article posted earlier.
+//
+// If CancelSecret is set and the original carries a Cancel-Lock, the
+// cancel includes the matching Cancel-Key.
+func (c *Client) CancelArticle(original *nntp.Article) error {
+	var opts []nntp.CancelOption
+	if c.CancelSecret != nil {
+		opts = append(opts, nntp.WithCancelSecret(c.CancelSecret))
+	}
+	cancel, err := nntp.BuildCancel(original, "", opts...)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeArticle(&buf, cancel); err != nil {
+		return err
+	}
+	return c.Post(&buf)
+}
+
 // Command sends a low-level command and get a response.
 //
 // This will return an error if the code doesn't match the expectCode
@@ -232,21 +960,214 @@ func (c *Client) Post(r io.Reader) error {
 // be 200 or you'll get an error.  If you specify "2", any code from
 // 200 (inclusive) to 300 (exclusive) will be success.  An expectCode
 // of -1 disables this behavior.
+//
+// A response with a data block is left for the caller to read, and
+// until then other commands fail with ErrBusy; use CommandDot for those.
 func (c *Client) Command(cmd string, expectCode int) (int, string, error) {
-	err := c.conn.PrintfLine(cmd)
+	code, msg, err := c.tracedCommand(cmd, expectCode, "")
+	c.lend()
+	return code, msg, err
+}
+
+// tracedCommand is Command, leaving the connection busy with hold, as
+// command does.
+func (c *Client) tracedCommand(cmd string, expectCode int, hold string) (int, string, error) {
+	end := c.startSpan("nntp.command", cmd)
+	code, msg, err := c.command(cmd, expectCode, hold)
+	endSpan(end, code, -1, err)
+	return code, msg, err
+}
+
+// ErrInvalidArgument is returned, before anything is sent, for a command
+// or argument containing CR, LF or NUL, which would end the command
+// early and could smuggle in another.
+var ErrInvalidArgument = errors.New("argument contains CR, LF or NUL")
+
+// checkArgument checks that s can be sent as part of a command, naming
+// the command, but not s, which may be a password, if it can't.
+func checkArgument(cmd, s string) error {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return fmt.Errorf("%s: %w", cmd, ErrInvalidArgument)
+	}
+	return nil
+}
+
+// LastResponse returns the code and text of the last response from the
+// server, successful or not, starting with the greeting.  It includes
+// the intermediate responses, like 340 to POST, and the status lines of
+// responses with a data block.  After Post, it's the 240 response,
+// whose text many servers use for the message-id they assigned.
+func (c *Client) LastResponse() (code int, msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastCode, c.lastMsg
+}
+
+// readCodeLine reads a response as textproto does, and notes it for
+// LastResponse.
+func (c *Client) readCodeLine(expectCode int) (int, string, error) {
+	code, msg, err := c.conn.ReadCodeLine(expectCode)
+	if code != 0 {
+		c.mu.Lock()
+		c.lastCode, c.lastMsg = code, msg
+		c.mu.Unlock()
+	}
+	return code, msg, err
+}
+
+// CommandDot sends a command whose response has a data block, such as
+// an extension command, and checks the status line as Command does.
+// The block is read from body, which must be read to EOF before the
+// next command; until then, commands fail with ErrBusy.
+func (c *Client) CommandDot(cmd string, expectCode int) (code int, msg string, body io.Reader, err error) {
+	code, msg, err = c.tracedCommand(cmd, expectCode, busyBlock)
 	if err != nil {
-		return 0, "", err
+		return code, msg, nil, err
+	}
+	c.lend()
+	return code, msg, c.dotReader(), nil
+}
+
+// dotReader returns a reader for the data block that follows a
+// response, which frees the connection once it's been read.
+func (c *Client) dotReader() io.Reader {
+	return &dotBody{c: c, r: c.conn.DotReader()}
+}
+
+// dotBody is a data block returned to the caller, which notes when it's
+// been read.
+type dotBody struct {
+	c    *Client
+	r    io.Reader
+	read bool
+}
+
+func (b *dotBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && !b.read {
+		b.read = true
+		b.c.done()
 	}
-	return c.conn.ReadCodeLine(expectCode)
+	return n, err
 }
 
 // asLines issues a command and returns the response's data block as lines.
 func (c *Client) asLines(cmd string, expectCode int) ([]string, error) {
-	_, _, err := c.Command(cmd, expectCode)
+	_, _, err := c.tracedCommand(cmd, expectCode, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.readDotLines()
+}
+
+// readDotLinesChunk is how many bytes of lines readDotLines collects
+// before converting them to a string.
+const readDotLinesChunk = 64 << 10
+
+// readDotLines is textproto's ReadDotLines, but copies the lines into
+// strings a chunk at a time and slices them out, saving an allocation
+// per line on long LIST and OVER responses.
+func (c *Client) readDotLines() ([]string, error) {
+	defer c.done()
+	return readDotLines(c.conn.R)
+}
+
+// readDotLines reads the lines of a data block from br, as the method
+// does from the connection.
+func readDotLines(br *bufio.Reader) ([]string, error) {
+	var rv []string
+	err := readChunks(br, func(chunk string) error {
+		for chunk != "" {
+			i := strings.IndexByte(chunk, '\n')
+			rv = append(rv, chunk[:i])
+			chunk = chunk[i+1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// readChunks reads the lines of a data block from br and calls fn with
+// them a chunk of about readDotLinesChunk bytes at a time, each line
+// ended with a newline.  Lines sliced out of a chunk share its memory.
+// If fn fails, the rest of the block is skipped.
+func readChunks(br *bufio.Reader, fn func(chunk string) error) error {
+	r := dotLines{r: br}
+	data := make([]byte, 0, readDotLinesChunk)
+	for {
+		line, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+		if len(data) >= readDotLinesChunk {
+			if err := fn(string(data)); err != nil {
+				// Leave the connection ready for the next command.
+				r.drain()
+				return err
+			}
+			data = data[:0]
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return fn(string(data))
+}
+
+// dotLines reads the lines of a dot-encoded block straight from the
+// connection's buffer.  textproto's DotReader goes a byte at a time,
+// which dominates the cost of long responses.
+type dotLines struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// next returns the next line without its line ending or dot-stuffing,
+// or io.EOF at the end of the block.  The line is only valid until the
+// next call.
+func (d *dotLines) next() ([]byte, error) {
+	line, err := d.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		d.buf = append(d.buf[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = d.r.ReadSlice('\n')
+			d.buf = append(d.buf, line...)
+		}
+		line = d.buf
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
 		return nil, err
 	}
-	return c.conn.ReadDotLines()
+	line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+	if len(line) > 0 && line[0] == '.' {
+		if len(line) == 1 {
+			return nil, io.EOF
+		}
+		line = line[1:]
+	}
+	return line, nil
+}
+
+// drain skips the rest of the block.
+func (d *dotLines) drain() error {
+	for {
+		if _, err := d.next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
 }
 
 // Capabilities retrieves a list of supported capabilities.
@@ -257,11 +1178,23 @@ func (c *Client) Capabilities() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	for i, line := range caps {
-		caps[i] = strings.ToUpper(line)
+	set := ParseCapabilities(caps)
+	c.mu.Lock()
+	set.State = c.sessionState()
+	c.caps = set
+	if set.Has("READER") {
+		c.posting = set.Has("POST")
 	}
-	c.capabilities = caps
-	return caps, nil
+	c.mu.Unlock()
+	return set.Lines(), nil
+}
+
+// Caps returns the capabilities from the last call to Capabilities, or
+// nil if they haven't been retrieved.
+func (c *Client) Caps() *CapSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps
 }
 
 // GetCapability returns a complete capability line.
@@ -271,17 +1204,45 @@ func (c *Client) Capabilities() ([]string, error) {
 //
 // From https://datatracker.ietf.org/doc/html/rfc3977#section-3.3.1
 func (c *Client) GetCapability(capability string) string {
-	capability = strings.ToUpper(capability)
-	for _, capa := range c.capabilities {
-		i := strings.IndexAny(capa, "\t ")
-		if i != -1 && capa[:i] == capability {
-			return capa
+	return c.Caps().line(capability)
+}
+
+// Supports reports whether the server advertises a capability, such as
+// "OVER", retrieving the capabilities first if they haven't been.  If
+// that fails, nothing is supported.
+func (c *Client) Supports(label string) bool {
+	_, ok := c.GetCapabilityArgs(label)
+	return ok
+}
+
+// GetCapabilityArgs returns the uppercased tokens after the label in a
+// capability line, such as ACTIVE and NEWSGROUPS for "LIST", and whether
+// the capability is advertised at all.  Like Supports, it retrieves the
+// capabilities if they haven't been.
+func (c *Client) GetCapabilityArgs(label string) ([]string, bool) {
+	caps := c.Caps()
+	if caps == nil {
+		if _, err := c.Capabilities(); err != nil {
+			return nil, false
 		}
-		if capa == capability {
-			return capa
+		caps = c.Caps()
+	}
+	if !caps.Has(label) {
+		return nil, false
+	}
+	return append([]string{}, caps.Args(label)...), true
+}
+
+// supportsArg reports whether a capability is advertised with arg, as
+// GetCapabilityArgs finds it.
+func (c *Client) supportsArg(label, arg string) bool {
+	args, _ := c.GetCapabilityArgs(label)
+	for _, a := range args {
+		if strings.EqualFold(a, arg) {
+			return true
 		}
 	}
-	return ""
+	return false
 }
 
 // HasCapabilityArgument indicates whether a capability arg is supported.
@@ -294,20 +1255,13 @@ func (c *Client) GetCapability(capability string) string {
 func (c *Client) HasCapabilityArgument(
 	capability, argument string,
 ) (bool, error) {
-	if c.capabilities == nil {
+	if c.Caps() == nil {
 		return false, errors.New("Capabilities unpopulated")
 	}
-	capLine := c.GetCapability(capability)
-	if capLine == "" {
+	if !c.Caps().Has(capability) {
 		return false, errors.New("No such capability")
 	}
-	argument = strings.ToUpper(argument)
-	for _, capArg := range strings.Fields(capLine)[1:] {
-		if capArg == argument {
-			return true, nil
-		}
-	}
-	return false, nil
+	return c.Caps().HasArg(capability, argument), nil
 }
 
 // ListOverviewFmt performs a LIST OVERVIEW.FMT query.
@@ -325,37 +1279,673 @@ func (c *Client) ListOverviewFmt() ([]string, error) {
 	return fields, nil
 }
 
+// OverviewFormat is ListOverviewFmt, fetched once per connection and
+// then remembered.
+func (c *Client) OverviewFormat() ([]string, error) {
+	c.mu.Lock()
+	format := c.overviewFmt
+	c.mu.Unlock()
+	if format != nil {
+		return format, nil
+	}
+	format, err := c.ListOverviewFmt()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.overviewFmt = format
+	c.mu.Unlock()
+	return format, nil
+}
+
+// overviewFormat is OverviewFormat for parsing overviews: a server that
+// can't list its format is taken to send only full fields.
+func (c *Client) overviewFormat() ([]string, error) {
+	format, err := c.OverviewFormat()
+	if _, ok := err.(*textproto.Error); ok {
+		c.mu.Lock()
+		c.overviewFmt = []string{}
+		c.mu.Unlock()
+		return nil, nil
+	}
+	return format, err
+}
+
 // Over returns a list of raw overview lines with tab-separated fields.
+// Servers that only implement XOVER are sent that instead.  A range
+// without articles, or a missing article, gives an error matching
+// ErrNoSuchArticle.
 func (c *Client) Over(specifier string) ([]string, error) {
-	lines, err := c.asLines("OVER "+specifier, 224)
+	msg, err := c.over(specifier)
+	if err != nil {
+		return nil, err
+	}
+	defer c.done()
+	br, err := c.responseData(msg)
+	if err != nil {
+		return nil, err
+	}
+	return readDotLines(br)
+}
+
+// ErrBadOverview is matched by the errors OverviewFull gives for lines it
+// can't parse.
+var ErrBadOverview = errors.New("malformed overview line")
+
+// OverviewFull is Over with each line parsed.  A line without the eight
+// mandatory fields or a valid article number is skipped; the error for
+// the first such line matches ErrBadOverview and quotes it, and is
+// returned along with the lines that did parse.
+//
+// The fields after the eighth are named with OverviewFormat, so Xref is
+// found wherever the server puts it; pass the format to each overview's
+// Fields for the others.  A server that can't list its format is
+// treated as sending only full fields.
+func (c *Client) OverviewFull(specifier string) ([]nntp.Overview, error) {
+	format, err := c.overviewFormat()
+	if err != nil {
+		return nil, err
+	}
+	lines, err := c.Over(specifier)
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]nntp.Overview, 0, len(lines))
+	var bad error
+	for _, l := range lines {
+		ov, err := nntp.ParseOverview(l)
+		if err != nil || strings.Count(l, "\t") < 7 {
+			if bad == nil {
+				bad = fmt.Errorf("%w: %q", ErrBadOverview, l)
+			}
+			continue
+		}
+		if xref, ok := ov.Fields(format)["Xref"]; ok {
+			ov.Xref = xref
+		}
+		if c.DecodeHeaders {
+			ov = ov.Decoded()
+		}
+		rv = append(rv, ov)
+	}
+	return rv, bad
+}
+
+// over issues OVER, or XOVER for servers that only know that.  Unless
+// capabilities say which to use, OVER is tried first and XOVER if it's
+// not recognized; either way the choice is remembered.  It returns the
+// response's text.
+func (c *Client) over(specifier string) (string, error) {
+	c.mu.Lock()
+	cmd := c.overCmd
+	c.mu.Unlock()
+	if cmd == "" && c.Caps() != nil {
+		cmd = "OVER"
+		if !c.Supports("OVER") {
+			cmd = "XOVER"
+		}
+		c.mu.Lock()
+		c.overCmd = cmd
+		c.mu.Unlock()
+	}
+	known := cmd != ""
+	if !known {
+		cmd = "OVER"
+	}
+	_, msg, err := c.tracedCommand(cmd+" "+specifier, 224, "")
+	if terr, ok := err.(*textproto.Error); ok && terr.Code == 500 && !known {
+		cmd = "XOVER"
+		_, msg, err = c.tracedCommand("XOVER "+specifier, 224, "")
+	}
+	if !known && (err == nil || cmd == "XOVER") {
+		c.mu.Lock()
+		c.overCmd = cmd
+		c.mu.Unlock()
+	}
+	return msg, articleError(cmd+" "+specifier, err)
+}
+
+// ListHeaders retrieves the fields HDR supports: header names, metadata
+// items like ":bytes", and ":" if any header may be asked for.
+func (c *Client) ListHeaders() ([]string, error) {
+	lines, err := c.asLines("LIST HEADERS", 215)
+	if err != nil {
+		return nil, err
+	}
+	rv := lines[:0]
+	for _, l := range lines {
+		if field, _ := nextField(l); field != "" {
+			rv = append(rv, field)
+		}
+	}
+	return rv, nil
+}
+
+// ErrUnsupportedHeader is returned by Hdr and HdrMessageID, with
+// CheckHeaders set, for fields LIST HEADERS doesn't include.
+var ErrUnsupportedHeader = errors.New("header not supported by HDR")
+
+// HdrSupported reports whether HDR supports a field, according to LIST
+// HEADERS.  The list is fetched once per client.
+func (c *Client) HdrSupported(field string) (bool, error) {
+	c.mu.Lock()
+	fields := c.hdrFields
+	c.mu.Unlock()
+	if fields == nil {
+		var err error
+		if fields, err = c.ListHeaders(); err != nil {
+			return false, err
+		}
+		if fields == nil {
+			fields = []string{}
+		}
+		c.mu.Lock()
+		c.hdrFields = fields
+		c.mu.Unlock()
+	}
+	for _, f := range fields {
+		// ":" covers every header, but not metadata items.
+		if strings.EqualFold(f, field) || f == ":" && !strings.HasPrefix(field, ":") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Hdr retrieves one header field, or overview metadata such as
+// ":bytes", from a range of articles in the current group, keyed by
+// article number.  Articles without the header map to "".
+//
+// Servers whose capabilities, if retrieved, don't list HDR are sent the
+// older XHDR instead.
+func (c *Client) Hdr(field, specifier string) (map[int64]string, error) {
+	rv := map[int64]string{}
+	err := c.hdrEach(field, specifier, func(n int64, value string) {
+		rv[n] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// HdrMessageID retrieves one header field from the article with a
+// message-id.
+func (c *Client) HdrMessageID(field, id string) (string, error) {
+	var rv string
+	found := false
+	err := c.hdrEach(field, id, func(n int64, value string) {
+		rv, found = value, true
+	})
+	if err == nil && !found {
+		err = ErrNoSuchArticle
+	}
+	return rv, err
+}
+
+// hdrEach issues HDR, or XHDR, and calls fn with each line's article
+// number and value.  Lines for a message-id have the number 0.
+func (c *Client) hdrEach(field, specifier string, fn func(int64, string)) error {
+	if c.CheckHeaders {
+		ok, err := c.HdrSupported(field)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnsupportedHeader, field)
+		}
+	}
+	cmd, code := "HDR", 225
+	if c.Caps() != nil && !c.Caps().Has("HDR") {
+		cmd, code = "XHDR", 221
+	}
+	if specifier != "" {
+		cmd += " " + field + " " + specifier
+	} else {
+		cmd += " " + field
+	}
+	_, msg, err := c.tracedCommand(cmd, code, "")
+	if err != nil {
+		return articleError(cmd, err)
+	}
+	return c.eachHdrLine(msg, fn)
+}
+
+// eachHdrLine reads the "n value" lines of a response with the text msg,
+// as sent for HDR, XHDR and XPAT, calling fn for each.
+func (c *Client) eachHdrLine(msg string, fn func(int64, string)) error {
+	defer c.done()
+	br, err := c.responseData(msg)
+	if err != nil {
+		return err
+	}
+	r := dotLines{r: br}
+	for {
+		line, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		nums, value := nextField(string(line))
+		n, err := strconv.ParseInt(nums, 10, 64)
+		if err != nil {
+			// XHDR by message-id may send the id in place of 0.
+			if !strings.HasPrefix(nums, "<") {
+				continue
+			}
+			n = 0
+		}
+		fn(n, strings.TrimLeft(value, " \t"))
+	}
+}
+
+// ListDistribPats retrieves the server's LIST DISTRIB.PATS.
+func (c *Client) ListDistribPats() ([]nntp.DistribPat, error) {
+	lines, err := c.asLines("LIST DISTRIB.PATS", 215)
+	if err != nil {
+		return nil, err
+	}
+	return nntp.ParseDistribPats(lines)
+}
+
+// ListNewsgroups retrieves the descriptions of the groups matching a
+// wildmat, or of all groups if it's empty, keyed by group name.
+// Descriptions that aren't UTF-8 are assumed to be Windows-1252.
+func (c *Client) ListNewsgroups(wildmat string) (map[string]string, error) {
+	cmd := "LIST NEWSGROUPS"
+	if wildmat != "" {
+		if err := nntp.ValidateWildmat(wildmat); err != nil {
+			return nil, err
+		}
+		cmd += " " + wildmat
+	}
+	if _, _, err := c.tracedCommand(cmd, 215, ""); err != nil {
+		return nil, err
+	}
+	defer c.done()
+	dr := c.conn.DotReader()
+	rv, err := nntp.ReadNewsgroups(dr)
+	// Leave the connection ready for the next command.
+	if _, derr := io.Copy(ioutil.Discard, dr); err == nil {
+		err = derr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// ErrUnsupportedList matches, with errors.Is, a 501 or 503 response to
+// a LIST keyword the server doesn't know or doesn't maintain.
+var ErrUnsupportedList = errors.New("unsupported LIST keyword")
+
+// listKeyword issues LIST with a keyword and returns the lines of the
+// response.
+func (c *Client) listKeyword(keyword string) ([]string, error) {
+	lines, err := c.asLines("LIST "+keyword, 215)
+	if terr, ok := err.(*textproto.Error); ok && (terr.Code == 501 || terr.Code == 503) {
+		err = &codedError{terr, ErrUnsupportedList}
+	}
+	return lines, err
+}
+
+// ListDistributions retrieves the server's LIST DISTRIBUTIONS, keyed by
+// distribution name.
+func (c *Client) ListDistributions() (map[string]string, error) {
+	lines, err := c.listKeyword("DISTRIBUTIONS")
+	if err != nil {
+		return nil, err
+	}
+	rv := make(map[string]string, len(lines))
+	for _, l := range lines {
+		name, desc := nextField(l)
+		if name != "" {
+			rv[name] = strings.TrimLeft(desc, " \t")
+		}
+	}
+	return rv, nil
+}
+
+// ListModerators retrieves the server's LIST MODERATORS, in order, with
+// the address templates as sent.  nntp.SubmissionAddress picks the one
+// for a group.
+func (c *Client) ListModerators() ([]nntp.Moderator, error) {
+	lines, err := c.listKeyword("MODERATORS")
 	if err != nil {
 		return nil, err
 	}
-	return lines, nil
+	return nntp.ParseModerators(lines)
+}
+
+// ListActiveTimes retrieves the server's LIST ACTIVE.TIMES: when each
+// group was created, and by whom.  Malformed lines are skipped and
+// counted rather than failing the call.
+func (c *Client) ListActiveTimes() (rv []nntp.ActiveTime, skipped int, err error) {
+	lines, err := c.asLines("LIST ACTIVE.TIMES", 215)
+	if err != nil {
+		return nil, 0, err
+	}
+	rv, skipped = nntp.ParseActiveTimes(lines)
+	return rv, skipped, nil
+}
+
+// FillDistribution sets an article's Distribution header from the
+// server's DISTRIB.PATS if it doesn't have one.  The patterns are
+// fetched once per client.  Servers without DISTRIB.PATS leave the
+// article unchanged.
+func (c *Client) FillDistribution(a *nntp.Article) error {
+	if a.Header.Get("Distribution") != "" {
+		return nil
+	}
+	c.mu.Lock()
+	pats := c.distribPats
+	c.mu.Unlock()
+	if pats == nil {
+		var err error
+		pats, err = c.ListDistribPats()
+		if terr, ok := err.(*textproto.Error); ok && terr.Code/100 == 5 {
+			pats, err = []nntp.DistribPat{}, nil
+		}
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.distribPats = pats
+		c.mu.Unlock()
+	}
+	groups := nntp.ParseDistributions(a.Header.Get("Newsgroups"))
+	if d := nntp.ChooseDistribution(pats, groups); d != "" {
+		a.Header.Set("Distribution", d)
+	}
+	return nil
+}
+
+// HasArticle reports whether the server has the article with a
+// message-id, which may lack its angle brackets as for Stat.
+func (c *Client) HasArticle(msgid string) (bool, error) {
+	msgid, err := messageIDArgument("STAT", msgid)
+	if err != nil {
+		return false, err
+	}
+	_, _, err = c.tracedCommand("STAT "+msgid, 223, "")
+	if err == nil {
+		return true, nil
+	}
+	if err = articleError("STAT "+msgid, err); errors.Is(err, ErrNoSuchArticle) {
+		return false, nil
+	}
+	return false, err
+}
+
+// HasArticles reports which of a list of message-ids the server has,
+// pipelining the STAT commands.  Articles the server doesn't have are
+// simply false; any other failure stops the run, and the articles
+// checked so far are returned with the error.
+func (c *Client) HasArticles(msgids []string) (map[string]bool, error) {
+	rv := make(map[string]bool, len(msgids))
+	for start := 0; start < len(msgids); start += statBatch {
+		end := start + statBatch
+		if end > len(msgids) {
+			end = len(msgids)
+		}
+		found, err := c.statMany(msgids[start:end])
+		if err != nil {
+			return rv, err
+		}
+		for i, ok := range found {
+			rv[msgids[start+i]] = ok
+		}
+	}
+	return rv, nil
+}
+
+// statBatch is how many STAT commands statMany sends before reading
+// their responses.
+const statBatch = 100
+
+// statMany looks up message-ids with STAT, pipelining the commands, and
+// reports which exist.
+func (c *Client) statMany(ids []string) ([]bool, error) {
+	canonical := make([]string, len(ids))
+	for i, id := range ids {
+		id, err := messageIDArgument("STAT", id)
+		if err != nil {
+			return nil, err
+		}
+		canonical[i] = id
+	}
+	ids = canonical
+	if err := c.claim("STAT"); err != nil {
+		return nil, err
+	}
+	defer c.done()
+	rv := make([]bool, len(ids))
+	for start := 0; start < len(ids); start += statBatch {
+		end := start + statBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		for _, id := range ids[start:end] {
+			if err := c.conn.PrintfLine("STAT %s", id); err != nil {
+				return nil, err
+			}
+		}
+		var failed error
+		for i := start; i < end; i++ {
+			code, msg, err := c.readCodeLine(0)
+			switch {
+			case code == 223:
+				rv[i] = true
+			case code == 430 || code == 423:
+			case err != nil:
+				return nil, err
+			case failed == nil:
+				// Read the rest of the batch's responses, to leave
+				// the connection usable.
+				failed = &textproto.Error{Code: code, Msg: msg}
+			}
+		}
+		if failed != nil {
+			return nil, failed
+		}
+	}
+	return rv, nil
+}
+
+// OverFunc is Over without holding the response in memory: it calls fn
+// with each raw line as it arrives, so a range of millions of articles
+// takes no more memory than one.  If fn returns an error, the rest of
+// the response is read and skipped, to leave the connection usable, and
+// the error is returned.
+func (c *Client) OverFunc(specifier string, fn func(line string) error) error {
+	return c.overLines(specifier, true, func(line []byte) error {
+		return fn(string(line))
+	})
+}
+
+// OverviewFunc is OverviewFull without holding the response in memory:
+// it calls fn with each overview, parsed as OverviewFull parses it, as
+// it arrives.  Lines that can't be parsed are skipped.  If fn returns an
+// error, the rest of the response is skipped and the error is returned.
+func (c *Client) OverviewFunc(specifier string, fn func(nntp.Overview) error) error {
+	format, err := c.overviewFormat()
+	if err != nil {
+		return err
+	}
+	return c.overLines(specifier, true, func(line []byte) error {
+		if bytes.Count(line, []byte("\t")) < 7 {
+			return nil
+		}
+		ov, err := nntp.ParseOverview(string(line))
+		if err != nil {
+			return nil
+		}
+		if xref, ok := ov.Fields(format)["Xref"]; ok {
+			ov.Xref = xref
+		}
+		if c.DecodeHeaders {
+			ov = ov.Decoded()
+		}
+		return fn(ov)
+	})
+}
+
+// overEach issues an OVER command and calls fn with each line, parsed,
+// as it arrives.  Lines that can't be parsed are skipped.
+func (c *Client) overEach(specifier string, fn func(nntp.Overview) error) error {
+	return c.overLines(specifier, false, func(line []byte) error {
+		ov, err := nntp.ParseOverview(string(line))
+		if err != nil {
+			return nil
+		}
+		return fn(ov)
+	})
+}
+
+// overLines issues an OVER command and calls fn with each line as it
+// arrives.  The line is only valid until fn returns.  If lend is set, fn
+// is the caller's, and the connection is lent to it.
+func (c *Client) overLines(specifier string, lend bool, fn func([]byte) error) error {
+	msg, err := c.over(specifier)
+	if err != nil {
+		return err
+	}
+	defer c.done()
+	if lend {
+		c.lend()
+	}
+	br, err := c.responseData(msg)
+	if err != nil {
+		return err
+	}
+	r := dotLines{r: br}
+	for {
+		line, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(line); err != nil {
+			// Leave the connection ready for the next command.
+			r.drain()
+			return err
+		}
+	}
 }
 
 func (c *Client) HasTLS() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.tls
 }
 
-// StartTLS sends the STARTTLS command and refreshes capabilities.
+// TLSConnectionState returns the state of the TLS connection, with the
+// version, cipher suite and the server's certificates, and whether TLS
+// is active.  A connection given to NewConn counts if it's a *tls.Conn.
+func (c *Client) TLSConnectionState() (tls.ConnectionState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tc, ok := c.netconn.(*tls.Conn)
+	if !ok {
+		// NewConn and Reconnect wrap the connection they're given.
+		tc, ok = c.cc.Conn.(*tls.Conn)
+	}
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tc.ConnectionState(), true
+}
+
+// RemoteAddr returns the server's network address.
+func (c *Client) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.netconn.RemoteAddr()
+}
+
+// LocalAddr returns the client's end of the connection.
+func (c *Client) LocalAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.netconn.LocalAddr()
+}
+
+// ErrNoStartTLS is returned by StartTLS when the capabilities have been
+// retrieved and don't list STARTTLS.
+var ErrNoStartTLS = errors.New("server doesn't advertise STARTTLS")
+
+// ErrPlaintextInjection is returned by StartTLS when more than the 382
+// response arrived before the handshake.  Whoever sent it could have
+// slipped responses in ahead of TLS, so the connection is closed.
+var ErrPlaintextInjection = errors.New("STARTTLS: data received before the TLS handshake")
+
+// StartTLS sends the STARTTLS command, completes the handshake, so that
+// a bad certificate is reported here, and retrieves the capabilities
+// again, unless CapsPolicy is CapsForget.  If capabilities have been
+// retrieved before, they must list STARTTLS.
 //
 // See https://datatracker.ietf.org/doc/html/rfc4642 and net/smtp.go, from
 // which this was adapted, and maybe NNTP.startls in Python's nntplib also.
+//
+// If config is nil or has no ServerName, the certificate is verified for
+// the host the client dialed.  As with NewTLS, TLS 1.2 is the oldest
+// version accepted unless config sets MinVersion.
 func (c *Client) StartTLS(config *tls.Config) error {
-	if c.tls {
+	state := c.SessionState()
+	if state.TLS {
 		return errors.New("TLS already active")
 	}
-	_, _, err := c.Command("STARTTLS", 382)
-	if err != nil {
+	if state.Compressed {
+		// TLS would start inside the compressed stream.
+		return errors.New("STARTTLS after COMPRESS")
+	}
+	if c.Caps() != nil && !c.Supports("STARTTLS") {
+		return ErrNoStartTLS
+	}
+	config = tlsConfig(config, c.host)
+	if err := c.startTLS(config); err != nil {
 		return err
 	}
-	c.netconn = tls.Client(c.netconn, config)
-	c.conn = textproto.NewConn(c.netconn)
-	c.tls = true
-	_, err = c.Capabilities()
+	// RFC 4642 has the client retrieve them regardless.
+	return c.stateChanged(true)
+}
+
+// startTLS is the STARTTLS exchange and handshake, during which the
+// connection is taken, so that no command goes out in the clear or
+// reads the handshake as its response.
+func (c *Client) startTLS(config *tls.Config) error {
+	if err := c.claim("STARTTLS"); err != nil {
+		return err
+	}
+	defer c.done()
+	end := c.startSpan("nntp.command", "STARTTLS")
+	err := c.conn.PrintfLine("STARTTLS")
+	var code int
+	if err == nil {
+		code, _, err = c.readCodeLine(382)
+	}
+	endSpan(end, code, -1, err)
 	if err != nil {
 		return err
 	}
+	if c.conn.R.Buffered() > 0 {
+		c.CloseNow()
+		return ErrPlaintextInjection
+	}
+	tc := tls.Client(c.netconn, config)
+	if err := tc.Handshake(); err != nil {
+		// There's no going back to plaintext.
+		c.CloseNow()
+		return err
+	}
+	c.mu.Lock()
+	c.netconn = tc
+	c.conn = textproto.NewConn(c.netconn)
+	c.tls = true
+	c.mu.Unlock()
 	return nil
 }
@@ -0,0 +1,67 @@
+package nntpclient
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeServerAddr starts a scripted NNTP server on a loopback port and
+// returns its address.  handle is called with each command line a client
+// sends.
+func fakeServerAddr(t *testing.T, handle func(c *textproto.Conn, line string)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(nc, handle)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveFake(nc net.Conn, handle func(c *textproto.Conn, line string)) {
+	c := textproto.NewConn(nc)
+	defer c.Close()
+	c.PrintfLine("200 fake server ready")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		if strings.EqualFold(line, "QUIT") {
+			c.PrintfLine("205 bye")
+			return
+		}
+		handle(c, line)
+	}
+}
+
+// fakeServer starts a scripted NNTP server and returns a client connected
+// to it.
+func fakeServer(t *testing.T, handle func(c *textproto.Conn, line string)) *Client {
+	client, err := New("tcp", fakeServerAddr(t, handle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// writeLines sends a multi-line response.
+func writeLines(c *textproto.Conn, code int, msg string, lines ...string) {
+	c.PrintfLine("%d %s", code, msg)
+	dw := c.DotWriter()
+	for _, l := range lines {
+		dw.Write([]byte(l + "\n"))
+	}
+	dw.Close()
+}
@@ -0,0 +1,91 @@
+package nntpclient
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// sessionServer answers QUIT, and hangs up on HANGUP.  When a client
+// disconnects it sends the commands it received on sessions.
+func sessionServer(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	sessions := make(chan string, 10)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				c := textproto.NewConn(nc)
+				defer c.Close()
+				var got []string
+				defer func() { sessions <- strings.Join(got, " ") }()
+				c.PrintfLine("200 ready")
+				for {
+					line, err := c.ReadLine()
+					if err != nil {
+						return
+					}
+					got = append(got, line)
+					switch line {
+					case "QUIT":
+						c.PrintfLine("205 bye")
+					case "HANGUP":
+						return
+					default:
+						c.PrintfLine("111 20210305233001")
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), sessions
+}
+
+func TestClose(t *testing.T) {
+	addr, sessions := sessionServer(t)
+	dial := func() *Client {
+		c, err := New("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := dial()
+	c.Date()
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Second Close: %v", err)
+	}
+	if got := <-sessions; got != "DATE QUIT" {
+		t.Errorf("Close sent %q", got)
+	}
+
+	c = dial()
+	c.CloseNow()
+	if got := <-sessions; got != "" {
+		t.Errorf("CloseNow sent %q", got)
+	}
+
+	// After the server has gone away.
+	c = dial()
+	c.conn.PrintfLine("HANGUP")
+	<-sessions
+	if _, err := c.Date(); err == nil {
+		t.Fatalf("Connection survived the server hanging up")
+	}
+	c.Close()
+	if _, err := c.Date(); err == nil {
+		t.Errorf("Connection still usable after Close")
+	}
+}
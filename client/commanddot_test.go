@@ -0,0 +1,37 @@
+package nntpclient
+
+import (
+	"io/ioutil"
+	"net/textproto"
+	"testing"
+)
+
+func TestCommandDot(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "LIST MOTD":
+			writeLines(c, 215, "Message of the day follows", "Welcome!", ".Dotted line")
+		default:
+			c.PrintfLine("503 No motd")
+		}
+	})
+	code, msg, body, err := c.CommandDot("LIST MOTD", 215)
+	if err != nil || code != 215 || msg != "Message of the day follows" {
+		t.Fatalf("Got %d %q, %v", code, msg, err)
+	}
+	if _, _, _, err := c.CommandDot("LIST MOTD", 215); err == nil {
+		t.Errorf("Sent a command with the body unread")
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil || string(data) != "Welcome!\n.Dotted line\n" {
+		t.Errorf("Body %q, %v", data, err)
+	}
+
+	if _, _, body, err := c.CommandDot("XINDEX misc.test", 224); err == nil || body != nil {
+		t.Errorf("Unexpected code gave %v, %v", body, err)
+	}
+	if _, _, body, err = c.CommandDot("LIST MOTD", 215); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(body)
+}
@@ -0,0 +1,362 @@
+package nntpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// ServerConfig describes how to reach and log in to a server.
+type ServerConfig struct {
+	Network string
+	Addr    string
+	// TLS, if set, connects with TLS.
+	TLS *tls.Config
+	// ServerName, if set, is the name sent with SNI and expected on the
+	// server's certificate instead of Addr's host, for providers reached
+	// through an alias or address their certificate doesn't name.
+	// Setting it connects with TLS.
+	ServerName string
+	// Pins, if set, connects with TLS and trusts only the pinned
+	// certificates.
+	Pins *Pins
+	// Proxy, if set, is an HTTP proxy to tunnel through with CONNECT.
+	Proxy *url.URL
+	// ProxyFromEnvironment uses the proxy set by HTTPS_PROXY, unless
+	// NO_PROXY excludes the server, when Proxy isn't set.
+	ProxyFromEnvironment bool
+	// NetDialer, if set, makes the connection instead of net.Dialer,
+	// for example through a SOCKS5 proxy.  With an HTTP proxy, it's the
+	// connection to the proxy.
+	NetDialer ContextDialer
+	// User and Pass, if set, are sent with AUTHINFO.
+	User, Pass string
+}
+
+// Dial connects to the server and authenticates.
+func (sc ServerConfig) Dial() (*Client, error) {
+	return sc.DialContext(context.Background())
+}
+
+// DialContext is Dial, giving up when ctx is done before the server, or
+// the proxy, has answered.
+func (sc ServerConfig) DialContext(ctx context.Context) (*Client, error) {
+	network := sc.Network
+	if network == "" {
+		network = "tcp"
+	}
+	config := sc.TLS
+	if sc.ServerName != "" {
+		config = config.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.ServerName = sc.ServerName
+	}
+	if sc.Pins != nil {
+		if config == nil || config.ServerName == "" {
+			config = config.Clone()
+			if config == nil {
+				config = &tls.Config{}
+			}
+			if host, _, err := net.SplitHostPort(sc.Addr); err == nil {
+				config.ServerName = host
+			}
+		}
+		config = sc.Pins.Config(config)
+	}
+	proxy := sc.Proxy
+	if proxy == nil && sc.ProxyFromEnvironment {
+		var err error
+		if proxy, err = ProxyFromEnvironment(sc.Addr); err != nil {
+			return nil, err
+		}
+	}
+	var c *Client
+	var err error
+	d := Dialer{NetDialer: sc.NetDialer}
+	switch {
+	case proxy != nil:
+		c, err = sc.dialProxy(ctx, &d, proxy, config)
+	case config != nil:
+		c, err = d.DialTLSContext(ctx, network, sc.Addr, config)
+	default:
+		c, err = d.DialContext(ctx, network, sc.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sc.User != "" {
+		if _, err := c.Authenticate(sc.User, sc.Pass); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// dialProxy connects through an HTTP proxy with d, with TLS if config is
+// set.
+func (sc ServerConfig) dialProxy(ctx context.Context, d *Dialer, proxy *url.URL, config *tls.Config) (*Client, error) {
+	conn, err := d.DialProxyContext(ctx, sc.Addr, proxy)
+	if err != nil {
+		return nil, err
+	}
+	release := bound(ctx, conn)
+	err = conn.(*bufferedConn).checkTunnel(config != nil)
+	if cerr := release(); cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if config != nil {
+		config = tlsConfig(config, dialHost(sc.Addr))
+	}
+	c, err := connect(ctx, conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.host = dialHost(sc.Addr)
+	return c, nil
+}
+
+// A SampleSpec chooses which article numbers of a group to check.
+type SampleSpec interface {
+	Sample(low, high int64) []int64
+}
+
+// RandomSample picks N article numbers uniformly from the group, or none
+// if N isn't positive.
+type RandomSample struct {
+	N int
+	// Rand is the source of randomness, if set.
+	Rand *rand.Rand
+}
+
+// Sample implements SampleSpec.
+func (s RandomSample) Sample(low, high int64) []int64 {
+	if high < low || s.N <= 0 {
+		return nil
+	}
+	if int64(s.N) >= high-low+1 {
+		return RecentSample{s.N}.Sample(low, high)
+	}
+	r := s.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	seen := map[int64]bool{}
+	rv := make([]int64, 0, s.N)
+	for len(rv) < s.N {
+		n := low + r.Int63n(high-low+1)
+		if !seen[n] {
+			seen[n] = true
+			rv = append(rv, n)
+		}
+	}
+	return rv
+}
+
+// RecentSample picks the N highest article numbers.
+type RecentSample struct {
+	N int
+}
+
+// Sample implements SampleSpec.
+func (s RecentSample) Sample(low, high int64) []int64 {
+	var rv []int64
+	for n := high; n >= low && len(rv) < s.N; n-- {
+		rv = append(rv, n)
+	}
+	return rv
+}
+
+// ExplicitSample checks exactly the listed article numbers.
+type ExplicitSample []int64
+
+// Sample implements SampleSpec.
+func (s ExplicitSample) Sample(low, high int64) []int64 {
+	return s
+}
+
+// DefaultAgeBuckets are the upper bounds of the age buckets in a
+// CompletionReport.  Older articles fall into a final unbounded bucket.
+var DefaultAgeBuckets = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+	3 * 365 * 24 * time.Hour,
+}
+
+// Completion counts how many sampled articles were found.
+type Completion struct {
+	Sampled, Present int
+}
+
+// Percent returns the share of sampled articles present.
+func (c Completion) Percent() float64 {
+	if c.Sampled == 0 {
+		return 0
+	}
+	return 100 * float64(c.Present) / float64(c.Sampled)
+}
+
+// A ServerCompletion is one server's result in a CompletionReport.
+type ServerCompletion struct {
+	Addr string
+	Completion
+	// Buckets holds the results by article age, indexed like the
+	// report's AgeBuckets, plus one for older articles.
+	Buckets []Completion
+	// Err is set if the server couldn't be checked.
+	Err error
+}
+
+// A CompletionReport is the result of CompletionCheck.
+type CompletionReport struct {
+	Group string
+	// AgeBuckets are the upper bounds of the age buckets.  Articles
+	// with an unparseable date count as older than all of them.
+	AgeBuckets []time.Duration
+	Servers    []ServerCompletion
+	// Union counts articles present on at least one server.
+	Union ServerCompletion
+}
+
+// CompletionCheck measures which of a group's articles each server has.
+//
+// The sample is drawn from the first server's overview data and each
+// article is looked up by message-id with STAT on every server.
+func CompletionCheck(servers []ServerConfig, group string, sample SampleSpec) (*CompletionReport, error) {
+	if len(servers) == 0 {
+		return &CompletionReport{Group: group}, nil
+	}
+	first, err := servers[0].Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer first.Close()
+	g, err := first.Group(group)
+	if err != nil {
+		return nil, err
+	}
+	numbers := sample.Sample(g.Low, g.High)
+	overviews, err := first.overviewsOf(numbers)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	buckets := make([]int, len(overviews))
+	ids := make([]string, len(overviews))
+	for i, ov := range overviews {
+		ids[i] = ov.MessageID
+		buckets[i] = ageBucket(DefaultAgeBuckets, ov.Time, now)
+	}
+
+	found := make([][]bool, len(servers))
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := first
+			if i > 0 {
+				var err error
+				if c, err = servers[i].Dial(); err != nil {
+					errs[i] = err
+					return
+				}
+				defer c.Close()
+			}
+			found[i], errs[i] = c.statMany(ids)
+		}(i)
+	}
+	wg.Wait()
+
+	rv := &CompletionReport{Group: group, AgeBuckets: DefaultAgeBuckets}
+	newResult := func(addr string) ServerCompletion {
+		return ServerCompletion{Addr: addr, Buckets: make([]Completion, len(DefaultAgeBuckets)+1)}
+	}
+	rv.Union = newResult("")
+	union := make([]bool, len(ids))
+	for i, sc := range servers {
+		res := newResult(sc.Addr)
+		res.Err = errs[i]
+		if res.Err == nil {
+			for j, present := range found[i] {
+				res.count(buckets[j], present)
+				union[j] = union[j] || present
+			}
+		}
+		rv.Servers = append(rv.Servers, res)
+	}
+	for j, present := range union {
+		rv.Union.count(buckets[j], present)
+	}
+	return rv, nil
+}
+
+func (sc *ServerCompletion) count(bucket int, present bool) {
+	sc.Sampled++
+	sc.Buckets[bucket].Sampled++
+	if present {
+		sc.Present++
+		sc.Buckets[bucket].Present++
+	}
+}
+
+func ageBucket(bounds []time.Duration, t, now time.Time) int {
+	if t.IsZero() {
+		return len(bounds)
+	}
+	age := now.Sub(t)
+	for i, b := range bounds {
+		if age < b {
+			return i
+		}
+	}
+	return len(bounds)
+}
+
+// overviewsOf fetches the overviews of the given article numbers in the
+// current group, asking for contiguous runs at once.
+func (c *Client) overviewsOf(numbers []int64) ([]nntp.Overview, error) {
+	sorted := append([]int64(nil), numbers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	wanted := map[int64]bool{}
+	for _, n := range sorted {
+		wanted[n] = true
+	}
+	var rv []nntp.Overview
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		r := nntp.ArticleRange{Low: sorted[i], High: sorted[j]}
+		err := c.overEach(r.String(), func(ov nntp.Overview) error {
+			if wanted[ov.Number] && ov.MessageID != "" {
+				rv = append(rv, ov)
+			}
+			return nil
+		})
+		if err != nil && !IsCode(err, 423) {
+			return nil, err
+		}
+		i = j + 1
+	}
+	return rv, nil
+}
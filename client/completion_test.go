@@ -0,0 +1,107 @@
+package nntpclient
+
+import (
+	"fmt"
+	"math/rand"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// completionServer serves group misc.test with articles 1-100, article n
+// posted n days ago, and answers STAT for the message-ids has accepts.
+func completionServer(has func(n int) bool) func(c *textproto.Conn, line string) {
+	return func(c *textproto.Conn, line string) {
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "GROUP":
+			c.PrintfLine("211 100 1 100 misc.test")
+		case "OVER":
+			var low, high int
+			if _, err := fmt.Sscanf(fields[1], "%d-%d", &low, &high); err != nil {
+				fmt.Sscanf(fields[1], "%d", &low)
+				high = low
+			}
+			var lines []string
+			for n := low; n <= high; n++ {
+				date := time.Now().Add(-time.Duration(n)*24*time.Hour + time.Hour)
+				lines = append(lines, fmt.Sprintf("%d\ts\tf\t%s\t<%d@x>\t\t1\t1",
+					n, date.Format(time.RFC1123Z), n))
+			}
+			writeLines(c, 224, "Overview follows", lines...)
+		case "STAT":
+			var n int
+			fmt.Sscanf(fields[1], "<%d@x>", &n)
+			if has(n) {
+				c.PrintfLine("223 0 %s", fields[1])
+			} else {
+				c.PrintfLine("430 No such article")
+			}
+		default:
+			c.PrintfLine("500 what?")
+		}
+	}
+}
+
+func TestCompletionCheck(t *testing.T) {
+	// Retention of 5 days on the first server, odd articles on the second.
+	servers := []ServerConfig{
+		{Addr: fakeServerAddr(t, completionServer(func(n int) bool { return n <= 5 }))},
+		{Addr: fakeServerAddr(t, completionServer(func(n int) bool { return n%2 == 1 }))},
+	}
+	rep, err := CompletionCheck(servers, "misc.test", ExplicitSample{1, 2, 3, 8, 9, 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Servers) != 2 {
+		t.Fatalf("Got %d servers", len(rep.Servers))
+	}
+	a, b := rep.Servers[0], rep.Servers[1]
+	if a.Err != nil || b.Err != nil {
+		t.Fatalf("Errors %v %v", a.Err, b.Err)
+	}
+	if a.Completion != (Completion{6, 3}) || b.Completion != (Completion{6, 3}) {
+		t.Errorf("Completion %+v %+v", a.Completion, b.Completion)
+	}
+	if rep.Union.Completion != (Completion{6, 4}) {
+		t.Errorf("Union %+v", rep.Union.Completion)
+	}
+	// Buckets: <1d, <7d, <30d, <1y, <3y, older.
+	exp := []Completion{{1, 1}, {2, 2}, {2, 0}, {1, 0}, {0, 0}, {0, 0}}
+	if !reflect.DeepEqual(a.Buckets, exp) {
+		t.Errorf("Buckets %v, wanted %v", a.Buckets, exp)
+	}
+	if a.Percent() != 50 {
+		t.Errorf("Percent %v", a.Percent())
+	}
+}
+
+func TestSampleSpecs(t *testing.T) {
+	if got := (RecentSample{3}).Sample(1, 100); !reflect.DeepEqual(got, []int64{100, 99, 98}) {
+		t.Errorf("Recent %v", got)
+	}
+	got := RandomSample{N: 10, Rand: rand.New(rand.NewSource(1))}.Sample(1, 1000)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if len(got) != 10 || got[0] < 1 || got[9] > 1000 {
+		t.Errorf("Random %v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] == got[i-1] {
+			t.Errorf("Random sample repeats %d", got[i])
+		}
+	}
+	if got := (RandomSample{N: 10}).Sample(5, 7); len(got) != 3 {
+		t.Errorf("Random over a small group %v", got)
+	}
+	for _, n := range []int{0, -1} {
+		if got := (RandomSample{N: n}).Sample(1, 1000); len(got) != 0 {
+			t.Errorf("Random with N %d: %v", n, got)
+		}
+		if got := (RecentSample{n}).Sample(1, 1000); len(got) != 0 {
+			t.Errorf("Recent with N %d: %v", n, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package nntpclient
+
+import (
+	"compress/flate"
+	"errors"
+	"io"
+	"net/textproto"
+)
+
+// ErrUncompressedInjection is returned by Compress when more than the 206
+// response arrived before compression started.  Those bytes would be
+// lost, or read as responses nobody compressed, so the connection is
+// closed.
+var ErrUncompressedInjection = errors.New("COMPRESS: data received before compression started")
+
+// Compress turns on the COMPRESS DEFLATE extension of RFC 8054.  From
+// then on everything sent and received on the connection, data blocks
+// included, is compressed; it can't be turned off again.  It mostly pays
+// off for header heavy work like OVER and HDR.  The capabilities are
+// then retrieved again, or dropped, as CapsPolicy says.
+//
+// Capabilities must list COMPRESS DEFLATE; they're retrieved if they
+// haven't been.  RFC 8054 forbids compressing on top of TLS compression,
+// which crypto/tls never negotiates, but compressing secrets alongside
+// data an attacker can choose has the same weakness; callers who are
+// concerned shouldn't call Compress on a connection that will send both.
+func (c *Client) Compress() error {
+	if c.SessionState().Compressed {
+		return errors.New("compression already active")
+	}
+	if !c.supportsArg("COMPRESS", "DEFLATE") {
+		return errors.New("server doesn't advertise COMPRESS DEFLATE")
+	}
+	if err := c.compress(); err != nil {
+		return err
+	}
+	return c.stateChanged(false)
+}
+
+// compress is the COMPRESS exchange, during which the connection is
+// taken, so that nothing is sent or read uncompressed after it.
+func (c *Client) compress() error {
+	if err := c.claim("COMPRESS"); err != nil {
+		return err
+	}
+	defer c.done()
+	end := c.startSpan("nntp.command", "COMPRESS DEFLATE")
+	err := c.conn.PrintfLine("COMPRESS DEFLATE")
+	var code int
+	if err == nil {
+		code, _, err = c.readCodeLine(206)
+	}
+	endSpan(end, code, -1, err)
+	if err != nil {
+		return err
+	}
+	if c.conn.R.Buffered() > 0 {
+		c.CloseNow()
+		return ErrUncompressedInjection
+	}
+	// The level is only an error if it's out of range.
+	w, _ := flate.NewWriter(c.netconn, flate.DefaultCompression)
+	c.mu.Lock()
+	c.conn = textproto.NewConn(&deflateConn{
+		r: flate.NewReader(c.netconn),
+		w: w,
+		c: c.netconn,
+	})
+	c.compressed = true
+	c.mu.Unlock()
+	return nil
+}
+
+// deflateConn compresses both directions of a connection.
+type deflateConn struct {
+	r io.ReadCloser
+	w *flate.Writer
+	c io.Closer
+}
+
+func (d *deflateConn) Read(p []byte) (int, error) {
+	return d.r.Read(p)
+}
+
+// Write compresses p and flushes it, so that the server sees each
+// command as soon as textproto writes it.
+func (d *deflateConn) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, d.w.Flush()
+}
+
+func (d *deflateConn) Close() error {
+	d.w.Close()
+	d.r.Close()
+	return d.c.Close()
+}
@@ -0,0 +1,129 @@
+package nntpclient
+
+import (
+	"compress/flate"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingConn counts the bytes written to a connection.
+type countingConn struct {
+	net.Conn
+	n *int64
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	atomic.AddInt64(c.n, int64(len(p)))
+	return c.Conn.Write(p)
+}
+
+// compressServer is a server that supports COMPRESS DEFLATE, serving
+// many overview lines and taking posts.  It counts the bytes it sends.
+func compressServer(t *testing.T, sent *int64, posted chan<- []string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	over := make([]string, 1000)
+	for i := range over {
+		over[i] = "1\tRe: The same subject again\tfred@example.com\t5 Mar 2021 23:30:01 +0000\t<a@example.com>\t\t120\t4"
+	}
+	go func() {
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		raw := countingConn{nc, sent}
+		c := textproto.NewConn(raw)
+		defer c.Close()
+		c.PrintfLine("200 ready")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			switch line {
+			case "CAPABILITIES":
+				writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "OVER", "COMPRESS DEFLATE")
+			case "COMPRESS DEFLATE":
+				c.PrintfLine("206 Compression active")
+				w, _ := flate.NewWriter(raw, flate.DefaultCompression)
+				c = textproto.NewConn(&deflateConn{r: flate.NewReader(nc), w: w, c: nc})
+			case "OVER 1-1000":
+				writeLines(c, 224, "Overview follows", over...)
+			case "POST":
+				c.PrintfLine("340 Send it")
+				lines, err := c.ReadDotLines()
+				if err != nil {
+					return
+				}
+				posted <- lines
+				c.PrintfLine("240 Thanks")
+			default:
+				c.PrintfLine("500 Unknown command")
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestCompress(t *testing.T) {
+	var sent int64
+	posted := make(chan []string, 1)
+	c, err := New("tcp", compressServer(t, &sent, posted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The capabilities are retrieved first.
+	if err := c.Compress(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Compress(); err == nil {
+		t.Errorf("Compressed twice")
+	}
+
+	before := atomic.LoadInt64(&sent)
+	lines, err := c.Over("1-1000")
+	if err != nil || len(lines) != 1000 || !strings.HasPrefix(lines[999], "1\tRe: The same") {
+		t.Fatalf("Over returned %d lines: %v", len(lines), err)
+	}
+	if n := atomic.LoadInt64(&sent) - before; n > 10000 {
+		t.Errorf("Overview took %d bytes on the wire", n)
+	}
+
+	err = c.Post(strings.NewReader("Newsgroups: misc.test\r\nSubject: hi\r\n\r\n.leading dot\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-posted; len(got) != 4 || got[3] != ".leading dot" {
+		t.Errorf("Server got %q", got)
+	}
+	if err := c.StartTLS(nil); err == nil {
+		t.Errorf("STARTTLS allowed after COMPRESS")
+	}
+}
+
+func TestCompressInjection(t *testing.T) {
+	// A response injected after 206, in the same packet, uncompressed.
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "CAPABILITIES":
+			writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "COMPRESS DEFLATE")
+		case "COMPRESS DEFLATE":
+			c.W.WriteString("206 Compression active\r\n211 1 1 1 injected\r\n")
+			c.W.Flush()
+		}
+	})
+	if err := c.Compress(); err != ErrUncompressedInjection {
+		t.Errorf("Injected response: %v", err)
+	}
+	if _, err := c.Date(); err == nil {
+		t.Errorf("Connection still open after the injection")
+	}
+}
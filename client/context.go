@@ -0,0 +1,178 @@
+package nntpclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// NewContext connects a client to an NNTP server, as New does, giving up
+// when ctx is done before the server has greeted it.  Once connected,
+// ctx no longer matters; use SetContext to bound commands.
+func NewContext(ctx context.Context, network, addr string) (*Client, error) {
+	var d Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// ctxConn is the connection under a client, whose reads and writes are
+// bounded by the context being watched, the client's timeouts and any
+// deadline set.  Once one fails because the context is done or time ran
+// out, they all fail with that error from then on: the server may be
+// part way through a response, so the connection can't be used again.
+type ctxConn struct {
+	net.Conn
+	// client has the ReadTimeout and WriteTimeout.
+	client *Client
+	ctx    context.Context
+	// mu guards ctx, cancelled, deadline and err, which watch, the
+	// goroutine watching ctx and SetDeadline change while reads and
+	// writes are going on.
+	mu        sync.Mutex
+	cancelled bool
+	deadline  time.Time
+	// err is the error that ended the connection.
+	err error
+	// watchMu serializes watch, which may be called from SetContext and
+	// CloseNow at once.
+	watchMu sync.Mutex
+	// stop ends the goroutine watching ctx, and done is closed when
+	// it has.
+	stop, done chan struct{}
+}
+
+// longAgo is a deadline in the past, to interrupt reads and writes.
+var longAgo = time.Unix(1, 0)
+
+func (cc *ctxConn) Read(p []byte) (int, error) {
+	var timeout time.Duration
+	if cc.client != nil {
+		timeout = cc.client.ReadTimeout
+	}
+	if err := cc.arm(timeout, cc.Conn.SetReadDeadline); err != nil {
+		return 0, err
+	}
+	n, err := cc.Conn.Read(p)
+	if err != nil {
+		err = cc.check(err)
+	}
+	return n, err
+}
+
+func (cc *ctxConn) Write(p []byte) (int, error) {
+	var timeout time.Duration
+	if cc.client != nil {
+		timeout = cc.client.WriteTimeout
+	}
+	if err := cc.arm(timeout, cc.Conn.SetWriteDeadline); err != nil {
+		return 0, err
+	}
+	n, err := cc.Conn.Write(p)
+	if err != nil {
+		err = cc.check(err)
+	}
+	return n, err
+}
+
+// SetDeadline sets a deadline for reads and writes, which applies along
+// with the context's and the timeouts.
+func (cc *ctxConn) SetDeadline(t time.Time) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.deadline = t
+	if cc.cancelled {
+		return nil
+	}
+	return cc.Conn.SetDeadline(t)
+}
+
+// arm sets the deadline of a read or write with set: the earliest of
+// the context's, the one from SetDeadline, and timeout from now.  It
+// fails if the connection has ended or the context has been cancelled.
+func (cc *ctxConn) arm(timeout time.Duration, set func(time.Time) error) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.err != nil {
+		return cc.err
+	}
+	if cc.cancelled {
+		cc.err = cc.ctx.Err()
+		return cc.err
+	}
+	d := cc.deadline
+	if cc.ctx != nil {
+		if cd, ok := cc.ctx.Deadline(); ok {
+			d = earlier(d, cd)
+		}
+	}
+	if timeout > 0 {
+		d = earlier(d, time.Now().Add(timeout))
+	}
+	set(d)
+	return nil
+}
+
+// earlier returns the earlier of two deadlines, where zero is none.
+func earlier(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// check returns the error a read or write failed with, or the context's
+// if it's done, and ends the connection if it was either of those or a
+// timeout.
+func (cc *ctxConn) check(err error) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.ctx != nil {
+		cerr := cc.ctx.Err()
+		if d, ok := cc.ctx.Deadline(); ok && cerr == nil && !time.Now().Before(d) {
+			// The connection's deadline can pass before the context's.
+			cerr = context.DeadlineExceeded
+		}
+		if cerr != nil {
+			cc.err = cerr
+			return cerr
+		}
+	}
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		cc.err = err
+	}
+	return err
+}
+
+// watch makes ctx bound reads and writes, in place of the context
+// watched before.  Its deadline applies to each, and when it's cancelled
+// any read or write in progress is interrupted.  A nil ctx removes the
+// bound.
+func (cc *ctxConn) watch(ctx context.Context) {
+	cc.watchMu.Lock()
+	defer cc.watchMu.Unlock()
+	if cc.stop != nil {
+		close(cc.stop)
+		<-cc.done
+		cc.stop, cc.done = nil, nil
+	}
+	cc.mu.Lock()
+	cc.ctx = ctx
+	cc.cancelled = false
+	cc.mu.Unlock()
+	if ctx == nil || ctx.Done() == nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	cc.stop, cc.done = stop, done
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			cc.mu.Lock()
+			cc.cancelled = true
+			cc.Conn.SetDeadline(longAgo)
+			cc.mu.Unlock()
+		case <-stop:
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package nntpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+// stallingServer starts a server that sends the start of a response to
+// OVER and ARTICLE and then stalls until the test ends.
+func stallingServer(t *testing.T) string {
+	stall := make(chan struct{})
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "OVER 1-1000000", "ARTICLE 1":
+			c.PrintfLine("224 Overview follows")
+			c.PrintfLine("1\ta\tb\tc\t<1@x>\t\t3\t1")
+			<-stall
+		default:
+			c.PrintfLine("111 20240102030405")
+		}
+	})
+	t.Cleanup(func() { close(stall) })
+	return addr
+}
+
+func TestContextCancel(t *testing.T) {
+	addr := stallingServer(t)
+	c, err := NewContext(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.SetContext(ctx)
+	if _, err := c.Date(); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := c.Over("1-1000000"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Over: %v", err)
+	}
+	c.SetContext(context.Background())
+	if _, err := c.Date(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Connection still used after cancelling: %v", err)
+	}
+}
+
+func TestContextCancelBody(t *testing.T) {
+	addr := stallingServer(t)
+	c, err := New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.SetContext(ctx)
+	_, _, r, err := c.CommandDot("ARTICLE 1", 224)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := io.Copy(ioutil.Discard, r); !errors.Is(err, context.Canceled) {
+		t.Errorf("Reading the data block: %v", err)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	addr := stallingServer(t)
+	c, err := New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	c.SetContext(ctx)
+	if _, err := c.Over("1-1000000"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Over: %v", err)
+	}
+}
+
+func TestContextIdle(t *testing.T) {
+	addr := stallingServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := NewContext(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+	// The dialing context doesn't bound the connection.
+	cancel()
+	if _, err := c.Date(); err != nil {
+		t.Errorf("After cancelling the dialing context: %v", err)
+	}
+
+	// Nor does a context that ended without interrupting anything, once
+	// it's replaced.
+	ctx, cancel = context.WithCancel(context.Background())
+	c.SetContext(ctx)
+	cancel()
+	c.SetContext(context.Background())
+	if _, err := c.Date(); err != nil {
+		t.Errorf("After an idle cancel: %v", err)
+	}
+}
+
+func TestNewContextGreeting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		// Accept, but never greet.
+		nc, err := l.Accept()
+		if err == nil {
+			defer nc.Close()
+			io.Copy(ioutil.Discard, nc)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := NewContext(ctx, "tcp", l.Addr().String()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Silent server: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Took %v to give up", d)
+	}
+}
+
+func TestContextConcurrent(t *testing.T) {
+	c, err := NewContext(context.Background(), "tcp", stallingServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Contexts are swapped, and the connection closed, while the
+	// commands are going on.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			c.SetContext(ctx)
+			c.Context()
+			cancel()
+		}
+		c.CloseNow()
+	}()
+	for {
+		if _, err := c.Date(); err != nil {
+			break
+		}
+	}
+	<-done
+}
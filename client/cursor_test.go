@@ -0,0 +1,93 @@
+package nntpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+)
+
+// cursorServer keeps a current article pointer over articles 3, 5 and 9
+// of misc.test.
+func cursorServer(t *testing.T) *Client {
+	articles := []int64{3, 5, 9}
+	cur := -1
+	selected := false
+	return fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "GROUP misc.test":
+			selected, cur = true, 0
+			c.PrintfLine("211 3 3 9 misc.test")
+			return
+		case "GROUP empty.test":
+			selected, cur = true, -1
+			c.PrintfLine("211 0 1 0 empty.test")
+			return
+		}
+		if !selected {
+			c.PrintfLine("412 No newsgroup selected")
+			return
+		}
+		if cur < 0 {
+			c.PrintfLine("420 Current article number is invalid")
+			return
+		}
+		switch line {
+		case "NEXT":
+			if cur == len(articles)-1 {
+				c.PrintfLine("421 No next article in this group")
+				return
+			}
+			cur++
+		case "LAST":
+			if cur == 0 {
+				c.PrintfLine("422 No previous article in this group")
+				return
+			}
+			cur--
+		default:
+			c.PrintfLine("500 what?")
+			return
+		}
+		c.PrintfLine("223 %d <%d@x> retrieved", articles[cur], articles[cur])
+	})
+}
+
+func TestNextLast(t *testing.T) {
+	c := cursorServer(t)
+	var terr *textproto.Error
+	if _, _, err := c.Next(); !errors.As(err, &terr) || terr.Code != 412 {
+		t.Errorf("Next without a group: %v", err)
+	}
+	c.Group("misc.test")
+
+	// At the first article.
+	if _, _, err := c.Last(); !errors.Is(err, ErrNoPreviousArticle) {
+		t.Errorf("Last at the first article: %v", err)
+	}
+	var got []int64
+	for {
+		n, id, err := c.Next()
+		if errors.Is(err, ErrNoNextArticle) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "<"+fmt.Sprint(n)+"@x>" {
+			t.Errorf("Article %d has message-id %s", n, id)
+		}
+		got = append(got, n)
+	}
+	if fmt.Sprint(got) != "[5 9]" {
+		t.Errorf("Walked forward through %v", got)
+	}
+	if n, _, err := c.Last(); n != 5 || err != nil {
+		t.Errorf("Last from the last article: %d %v", n, err)
+	}
+
+	c.Group("empty.test")
+	if _, _, err := c.Next(); !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("Next with no current article: %v", err)
+	}
+}
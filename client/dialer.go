@@ -0,0 +1,109 @@
+package nntpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// A ContextDialer makes network connections, as net.Dialer does.  The
+// dialers of golang.org/x/net/proxy, for SOCKS5 among others, are
+// ContextDialers, and so is anything with a DialContext method, like an
+// SSH client's tunnel.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// A Dialer connects clients to NNTP servers with timeouts, or through a
+// ContextDialer.  The zero value connects as New and NewTLS do.
+type Dialer struct {
+	// NetDialer, if set, makes the connection; TLS, if it's used, is
+	// started over it.
+	NetDialer ContextDialer
+	// Timeout bounds connecting, up to the server's greeting.
+	Timeout time.Duration
+	// ReadTimeout and WriteTimeout are given to the clients connected.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// Dial connects a client to an NNTP server.
+func (d *Dialer) Dial(network, addr string) (*Client, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects a client to an NNTP server, giving up when ctx is
+// done before the server has greeted it.  Once connected, ctx no longer
+// matters.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (*Client, error) {
+	return d.dial(ctx, network, addr, nil)
+}
+
+// DialTLS connects a client to an NNTP server over a dedicated TLS port,
+// as NewTLS does.
+func (d *Dialer) DialTLS(network, addr string, config *tls.Config) (*Client, error) {
+	return d.DialTLSContext(context.Background(), network, addr, config)
+}
+
+// DialTLSContext is DialTLS, giving up when ctx is done before the
+// server has greeted the client.  The certificate is verified for
+// config's ServerName if it's set, and for addr's host otherwise, which
+// is also sent with SNI.  config isn't modified, and may be nil.
+func (d *Dialer) DialTLSContext(ctx context.Context, network, addr string, config *tls.Config) (*Client, error) {
+	return d.dial(ctx, network, addr, tlsConfig(config, dialHost(addr)))
+}
+
+// tlsConfig returns a copy of config, or a new one if it's nil, which
+// verifies the certificate for host unless config names a server, and
+// allows TLS 1.2 and later unless config sets a minimum.
+func tlsConfig(config *tls.Config, host string) *tls.Config {
+	rv := config.Clone()
+	if rv == nil {
+		rv = &tls.Config{}
+	}
+	if rv.ServerName == "" {
+		rv.ServerName = host
+	}
+	if rv.MinVersion == 0 {
+		rv.MinVersion = tls.VersionTLS12
+	}
+	return rv
+}
+
+// dial connects, with TLS if config is set.
+func (d *Dialer) dial(ctx context.Context, network, addr string, config *tls.Config) (*Client, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	var nd ContextDialer = &net.Dialer{}
+	if d.NetDialer != nil {
+		nd = d.NetDialer
+	}
+	netconn, err := nd.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	client, err := connect(ctx, netconn, config)
+	if err != nil {
+		netconn.Close()
+		return nil, err
+	}
+	client.host = dialHost(addr)
+	if config != nil {
+		client.host = config.ServerName
+	}
+	client.ReadTimeout = d.ReadTimeout
+	client.WriteTimeout = d.WriteTimeout
+	return client, nil
+}
+
+// SetDeadline sets a time after which reads from and writes to the
+// server fail, as net.Conn's does, along with the ReadTimeout,
+// WriteTimeout and the context from SetContext.  A zero time removes
+// it.  Like a timeout, reaching it leaves the connection unusable.
+func (c *Client) SetDeadline(t time.Time) error {
+	return c.netconn.SetDeadline(t)
+}
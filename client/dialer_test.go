@@ -0,0 +1,161 @@
+package nntpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func isTimeout(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+func TestReadTimeout(t *testing.T) {
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "OVER 1-10":
+			// Slow, but never silent for long.
+			c.PrintfLine("224 Overview follows")
+			for i := 1; i <= 10; i++ {
+				time.Sleep(10 * time.Millisecond)
+				c.PrintfLine("%d\ta\tb\tc\t<%d@x>\t\t3\t1", i, i)
+			}
+			c.PrintfLine(".")
+		case "DATE":
+			c.PrintfLine("111 20240102030405")
+		}
+	})
+	d := Dialer{Timeout: time.Second, ReadTimeout: 50 * time.Millisecond}
+	c, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+	if c.ReadTimeout != d.ReadTimeout {
+		t.Errorf("ReadTimeout %v", c.ReadTimeout)
+	}
+
+	if lines, err := c.Over("1-10"); err != nil || len(lines) != 10 {
+		t.Errorf("Slow overview: %d lines, %v", len(lines), err)
+	}
+	// The server doesn't answer this.
+	_, err = c.Group("misc.test")
+	if !isTimeout(err) {
+		t.Errorf("Unanswered command: %v", err)
+	}
+	// The next command fails at once, without reaching the server.
+	if _, err2 := c.Date(); err2 != err {
+		t.Errorf("After a timeout: %v", err2)
+	}
+}
+
+func TestSetDeadline(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {})
+	if err := c.SetDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Date(); !isTimeout(err) {
+		t.Errorf("Past the deadline: %v", err)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		// Accept, but never greet.
+		nc, err := l.Accept()
+		if err == nil {
+			defer nc.Close()
+			io.Copy(ioutil.Discard, nc)
+		}
+	}()
+	d := Dialer{Timeout: 20 * time.Millisecond}
+	if _, err := d.Dial("tcp", l.Addr().String()); !isTimeout(err) {
+		t.Errorf("Silent server: %v", err)
+	}
+}
+
+// recordingDialer connects every address to one server, recording the
+// addresses asked for.
+type recordingDialer struct {
+	addr string
+	got  []string
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.got = append(d.got, network+" "+addr)
+	var nd net.Dialer
+	return nd.DialContext(ctx, "tcp", d.addr)
+}
+
+func TestNetDialer(t *testing.T) {
+	rec := &recordingDialer{addr: fakeServerAddr(t, func(c *textproto.Conn, line string) {})}
+	d := Dialer{NetDialer: rec}
+	c, err := d.Dial("tcp", "news.example.com:119")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if c.host != "news.example.com" {
+		t.Errorf("Host %q", c.host)
+	}
+
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	rec.addr = tlsServer(t, leaf)
+	// SNI and verification use the name dialed, not where the dialer
+	// really connected.
+	c, err = d.DialTLS("tcp", "localhost:563", &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatalf("DialTLS: %v", err)
+	}
+	if !c.HasTLS() {
+		t.Errorf("No TLS")
+	}
+	c.Close()
+	if _, err := d.DialTLS("tcp", "news.example.com:563", &tls.Config{RootCAs: pool}); err == nil {
+		t.Errorf("DialTLS accepted a certificate for the wrong name")
+	}
+
+	sc := ServerConfig{Addr: "localhost:563", TLS: &tls.Config{RootCAs: pool}, NetDialer: rec}
+	if c, err := sc.Dial(); err != nil {
+		t.Errorf("ServerConfig.Dial: %v", err)
+	} else {
+		c.Close()
+	}
+
+	want := []string{"tcp news.example.com:119", "tcp localhost:563", "tcp news.example.com:563", "tcp localhost:563"}
+	if strings.Join(rec.got, "|") != strings.Join(want, "|") {
+		t.Errorf("Dialed %q, want %q", rec.got, want)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	if c := tlsConfig(nil, "news.example.com"); c.ServerName != "news.example.com" || c.MinVersion != tls.VersionTLS12 {
+		t.Errorf("From nil: %q %x", c.ServerName, c.MinVersion)
+	}
+	given := &tls.Config{ServerName: "localhost", MinVersion: tls.VersionTLS13}
+	if c := tlsConfig(given, "127.0.0.1"); c == given || c.ServerName != "localhost" || c.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Given settings: %q %x", c.ServerName, c.MinVersion)
+	}
+	given = &tls.Config{}
+	tlsConfig(given, "news.example.com")
+	if given.ServerName != "" || given.MinVersion != 0 {
+		t.Errorf("Caller's config modified")
+	}
+}
@@ -0,0 +1,86 @@
+package nntpclient
+
+import (
+	"net/textproto"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestFillDistribution(t *testing.T) {
+	requests := 0
+	client := fakeServer(t, func(c *textproto.Conn, line string) {
+		if line != "LIST DISTRIB.PATS" {
+			c.PrintfLine("500 what?")
+			return
+		}
+		requests++
+		writeLines(c, 215, "Distribution patterns follow",
+			"10:local.*:local", "5:*:world")
+	})
+	a := &nntp.Article{Header: textproto.MIMEHeader{"Newsgroups": {"comp.lang.go, local.test"}}}
+	if err := client.FillDistribution(a); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Header.Get("Distribution"); got != "local" {
+		t.Errorf("Distribution %q", got)
+	}
+
+	a = &nntp.Article{Header: textproto.MIMEHeader{"Newsgroups": {"comp.lang.go"}}}
+	client.FillDistribution(a)
+	if got := a.Header.Get("Distribution"); got != "world" {
+		t.Errorf("Distribution %q", got)
+	}
+
+	a.Header.Set("Distribution", "mine")
+	client.FillDistribution(a)
+	if got := a.Header.Get("Distribution"); got != "mine" {
+		t.Errorf("Existing distribution replaced by %q", got)
+	}
+	if requests != 1 {
+		t.Errorf("Fetched patterns %d times", requests)
+	}
+}
+
+func TestFillDistributionUnsupported(t *testing.T) {
+	client := fakeServer(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("503 not supported")
+	})
+	a := &nntp.Article{Header: textproto.MIMEHeader{"Newsgroups": {"comp.lang.go"}}}
+	if err := client.FillDistribution(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Header["Distribution"]; ok {
+		t.Errorf("Distribution set without patterns")
+	}
+}
+
+func TestPostArticleDistribution(t *testing.T) {
+	posted := make(chan []string, 1)
+	client := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "LIST DISTRIB.PATS":
+			writeLines(c, 215, "Distribution patterns follow", "10:local.*:local")
+		case "POST":
+			c.PrintfLine("340 send it")
+			lines, _ := c.ReadDotLines()
+			posted <- lines
+			c.PrintfLine("240 thanks")
+		default:
+			c.PrintfLine("500 what?")
+		}
+	})
+	client.AutoDistribution = true
+	a := &nntp.Article{Header: textproto.MIMEHeader{"Newsgroups": {"local.test"}, "Subject": {"hi"}}}
+	if err := client.PostArticle(a); err != nil {
+		t.Fatal(err)
+	}
+	got := <-posted
+	found := false
+	for _, l := range got {
+		found = found || l == "Distribution: local"
+	}
+	if !found {
+		t.Errorf("Posted %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+)
+
+// Error is the error for a response with an unexpected code, carrying
+// the code and the server's text.  It's the *textproto.Error textproto
+// returns, so errors.As works with either name.  The sentinel errors,
+// such as ErrNoSuchArticle, wrap one.
+type Error = textproto.Error
+
+// ResponseCode returns the response code carried by err, if it has one.
+// Network and parsing errors don't.
+func ResponseCode(err error) (int, bool) {
+	var terr *Error
+	if errors.As(err, &terr) {
+		return terr.Code, true
+	}
+	return 0, false
+}
+
+// IsCode reports whether err is a response with the given code.
+func IsCode(err error, code int) bool {
+	got, ok := ResponseCode(err)
+	return ok && got == code
+}
+
+// IsTemporary reports whether err is a 4xx response: the command was
+// understood but failed, and may work later or in another state.  That
+// includes answers, like 430 for a missing article, that retrying the
+// same server won't change.
+func IsTemporary(err error) bool {
+	code, ok := ResponseCode(err)
+	return ok && code/100 == 4
+}
+
+// IsPermanent reports whether err is a 5xx response: the command is
+// unknown, unsupported or not permitted, and repeating it won't help.
+func IsPermanent(err error) bool {
+	code, ok := ResponseCode(err)
+	return ok && code/100 == 5
+}
+
+// IsTransient reports whether err is a response saying the service is
+// unavailable for now, 400 or 503, so that the command may work if it's
+// tried again later or on a new connection.  After a 400 the server
+// closes the connection.
+//
+// 503 also answers commands a server doesn't support at all, which a
+// RetryPolicy can recognize from the command it's given.
+func IsTransient(err error) bool {
+	code, ok := ResponseCode(err)
+	return ok && (code == 400 || code == 503)
+}
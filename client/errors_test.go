@@ -0,0 +1,48 @@
+package nntpclient
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "STAT <gone@x>":
+			c.PrintfLine("430 No such article")
+		case "authinfo user u":
+			c.PrintfLine("381 Password required")
+		case "authinfo pass p":
+			c.PrintfLine("481 Bad password")
+		default:
+			c.PrintfLine("500 What?")
+		}
+	})
+	_, _, err := c.Stat("<gone@x>")
+	if !IsCode(err, 430) || !IsTemporary(err) || IsPermanent(err) || !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("Missing article: %v", err)
+	}
+	var terr *textproto.Error
+	if !errors.As(err, &terr) || terr.Msg != "No such article" {
+		t.Errorf("Missing article isn't a *textproto.Error: %v", err)
+	}
+
+	_, _, err = c.Command("BOGUS", 200)
+	var nerr *Error
+	if !IsCode(err, 500) || !IsPermanent(err) || IsTemporary(err) || !errors.As(err, &nerr) {
+		t.Errorf("Unknown command: %v", err)
+	}
+
+	_, err = c.Authenticate("u", "p")
+	if code, ok := ResponseCode(err); !ok || code != 481 || !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Bad password: %v", err)
+	}
+
+	for _, err := range []error{nil, io.EOF, errors.New("parse error")} {
+		if _, ok := ResponseCode(err); ok || IsTemporary(err) || IsPermanent(err) || IsCode(err, 0) {
+			t.Errorf("%v treated as a response", err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package nntpclient
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// An ExportFormat selects how ExportOverview writes overviews.
+type ExportFormat int
+
+const (
+	// ExportJSONLines writes one JSON object per line, in the form of
+	// nntp.Overview's MarshalJSON.
+	ExportJSONLines ExportFormat = iota
+	// ExportCSV writes CSV with a header row, with the same columns as
+	// the JSON form.
+	ExportCSV
+)
+
+// DefaultExportBatch is the default number of articles requested per
+// OVER command by ExportOverview.
+const DefaultExportBatch = 100000
+
+var exportColumns = []string{"number", "subject", "from", "date", "date_header",
+	"message_id", "references", "bytes", "lines", "xref"}
+
+// ExportOptions configure ExportOverview.
+type ExportOptions struct {
+	Format ExportFormat
+	// Resume is the last article number written by an earlier,
+	// interrupted export.  Articles up to it are skipped, and no CSV
+	// header is written.
+	Resume int64
+	// Batch is the number of articles requested per OVER command for a
+	// bounded range.  Defaults to DefaultExportBatch.
+	Batch int64
+	// Progress, if set, is called after each batch has been written
+	// with the last article number written and the number of overviews
+	// written so far.
+	Progress func(last, count int64)
+}
+
+// ExportOverview streams the overviews of articles in r from the current
+// group to w.
+//
+// It returns the last article number written, which can be passed as
+// ExportOptions.Resume to continue after an error.  Output is flushed
+// after every batch, so everything up to that number has been written.
+func (c *Client) ExportOverview(w io.Writer, r nntp.ArticleRange, opts *ExportOptions) (last int64, err error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	batch := opts.Batch
+	if batch <= 0 {
+		batch = DefaultExportBatch
+	}
+	last = opts.Resume
+	low := r.Low
+	if last >= low {
+		low = last + 1
+	}
+
+	bw := bufio.NewWriter(w)
+	var write func(nntp.Overview) error
+	var flush func() error
+	switch opts.Format {
+	case ExportCSV:
+		cw := csv.NewWriter(bw)
+		if opts.Resume == 0 {
+			if err := cw.Write(exportColumns); err != nil {
+				return last, err
+			}
+		}
+		write = func(ov nntp.Overview) error {
+			return cw.Write(overviewRecord(ov))
+		}
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+	default:
+		enc := json.NewEncoder(bw)
+		write = func(ov nntp.Overview) error {
+			return enc.Encode(ov)
+		}
+		flush = bw.Flush
+	}
+
+	var count int64
+	for low <= r.High {
+		high := r.High
+		if high != math.MaxInt64 && high-low >= batch {
+			high = low + batch - 1
+		}
+		spec := nntp.ArticleRange{Low: low, High: high}.String()
+		written := last
+		err = c.overEach(spec, func(ov nntp.Overview) error {
+			if ov.Number <= written {
+				return nil
+			}
+			if err := write(ov); err != nil {
+				return err
+			}
+			written = ov.Number
+			count++
+			return nil
+		})
+		if IsCode(err, 423) {
+			// Nothing in this batch.
+			err = nil
+		}
+		ferr := flush()
+		if ferr == nil {
+			last = written
+		} else if err == nil {
+			err = ferr
+		}
+		if err != nil {
+			return last, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(last, count)
+		}
+		if high == math.MaxInt64 {
+			break
+		}
+		low = high + 1
+	}
+	return last, nil
+}
+
+func overviewRecord(ov nntp.Overview) []string {
+	date := ""
+	if !ov.Time.IsZero() {
+		date = ov.Time.Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatInt(ov.Number, 10),
+		ov.Subject,
+		ov.From,
+		date,
+		ov.Date,
+		ov.MessageID,
+		ov.References,
+		strconv.FormatInt(ov.Bytes, 10),
+		strconv.FormatInt(ov.Lines, 10),
+		ov.Xref,
+	}
+}
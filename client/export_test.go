@@ -0,0 +1,135 @@
+package nntpclient
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// overServer answers OVER for articles 1-25 except multiples of 10.
+func overServer(c *textproto.Conn, line string) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != "OVER" {
+		c.PrintfLine("500 what?")
+		return
+	}
+	bounds := strings.SplitN(fields[1], "-", 2)
+	low, _ := strconv.ParseInt(bounds[0], 10, 64)
+	high := low
+	if len(bounds) == 2 {
+		high = math.MaxInt64
+		if bounds[1] != "" {
+			high, _ = strconv.ParseInt(bounds[1], 10, 64)
+		}
+	}
+	var lines []string
+	for n := low; n <= high && n <= 25; n++ {
+		if n%10 != 0 {
+			lines = append(lines, fmt.Sprintf(
+				"%d\tSubject %d\ta@x\tMon, 2 Jan 2006 15:04:05 +0000\t<%d@x>\t\t100\t2", n, n, n))
+		}
+	}
+	if len(lines) == 0 {
+		c.PrintfLine("423 No articles in that range")
+		return
+	}
+	writeLines(c, 224, "Overview follows", lines...)
+}
+
+func TestExportOverviewJSON(t *testing.T) {
+	client := fakeServer(t, overServer)
+	var buf bytes.Buffer
+	var progress []int64
+	last, err := client.ExportOverview(&buf, nntp.ArticleRange{Low: 1, High: 30},
+		&ExportOptions{Batch: 10, Progress: func(last, count int64) {
+			progress = append(progress, last, count)
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 25 {
+		t.Errorf("Last = %d", last)
+	}
+	if fmt.Sprint(progress) != "[9 9 19 18 25 23]" {
+		t.Errorf("Progress %v", progress)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 23 {
+		t.Fatalf("Got %d lines", len(lines))
+	}
+	var ov nntp.Overview
+	if err := json.Unmarshal([]byte(lines[9]), &ov); err != nil {
+		t.Fatal(err)
+	}
+	if ov.Number != 11 || ov.MessageID != "<11@x>" || ov.Time.IsZero() {
+		t.Errorf("Line 10 is %+v", ov)
+	}
+}
+
+func TestExportOverviewCSVResume(t *testing.T) {
+	client := fakeServer(t, overServer)
+	var buf bytes.Buffer
+	last, err := client.ExportOverview(&buf, nntp.ArticleRange{Low: 1, High: 15},
+		&ExportOptions{Format: ExportCSV})
+	if err != nil || last != 15 {
+		t.Fatalf("First part: %d %v", last, err)
+	}
+	last, err = client.ExportOverview(&buf, nntp.ArticleRange{Low: 1, High: math.MaxInt64},
+		&ExportOptions{Format: ExportCSV, Resume: last})
+	if err != nil || last != 25 {
+		t.Fatalf("Resumed: %d %v", last, err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 24 || records[0][0] != "number" {
+		t.Fatalf("Got %d records, starting %v", len(records), records[0])
+	}
+	for i, rec := range records[1:] {
+		if i > 0 {
+			prev, _ := strconv.Atoi(records[i][0])
+			n, _ := strconv.Atoi(rec[0])
+			if n <= prev {
+				t.Errorf("Record %d repeats or goes back: %v", i, rec)
+			}
+		}
+	}
+	if records[1][3] != "2006-01-02T15:04:05Z" {
+		t.Errorf("Date column %q", records[1][3])
+	}
+}
+
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n <= 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestExportOverviewResumeToken(t *testing.T) {
+	client := fakeServer(t, overServer)
+	// The first batch is flushed in one write, the second fails.
+	last, err := client.ExportOverview(&failingWriter{1}, nntp.ArticleRange{Low: 1, High: 25},
+		&ExportOptions{Batch: 10})
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if last != 9 {
+		t.Errorf("Resume token %d, wanted 9", last)
+	}
+}
@@ -0,0 +1,237 @@
+package nntpclient
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+)
+
+// Errors a peer gives when offered an article.  They match, with
+// errors.Is, the *textproto.Error responses, which are still available
+// with errors.As.
+var (
+	// ErrNotWanted means the peer already has the article or doesn't
+	// want it (435, or 438 when streaming).  Don't offer it again.
+	ErrNotWanted = errors.New("article not wanted")
+	// ErrTransferFailed means the transfer failed and the article may
+	// be offered again later (436, or 431 when streaming).
+	ErrTransferFailed = errors.New("article transfer failed, try again later")
+	// ErrRejected means the peer received the article and refused it
+	// (437, or 439 when streaming).  Don't offer it again.
+	ErrRejected = errors.New("article rejected")
+)
+
+// feedError makes a peer's responses to IHAVE, CHECK and TAKETHIS match
+// ErrNotWanted, ErrTransferFailed or ErrRejected.
+func feedError(err error) error {
+	if terr, ok := err.(*textproto.Error); ok {
+		switch terr.Code {
+		case 435, 438:
+			return &codedError{terr, ErrNotWanted}
+		case 436, 431:
+			return &codedError{terr, ErrTransferFailed}
+		case 437, 439:
+			return &codedError{terr, ErrRejected}
+		}
+	}
+	return err
+}
+
+// IHave offers an article to a peer server, sending it from r if the
+// peer wants it.  The reader should contain the entire article, headers
+// and body, as for Post.
+//
+// A peer that doesn't want the article is reported with ErrNotWanted
+// before anything is read from r.  If reading r fails part way through,
+// the connection is closed rather than send the peer a truncated
+// article.
+func (c *Client) IHave(msgid string, r io.Reader) error {
+	if err := checkArgument("IHAVE", msgid); err != nil {
+		return err
+	}
+	if err := c.claim("IHAVE"); err != nil {
+		return err
+	}
+	defer c.done()
+	c.lend()
+	end := c.startSpan("nntp.ihave", "IHAVE")
+	err := c.conn.PrintfLine("IHAVE %s", msgid)
+	if err != nil {
+		endSpan(end, 0, -1, err)
+		return err
+	}
+	_, _, err = c.readCodeLine(335)
+	if err != nil {
+		endSpan(end, 0, -1, err)
+		return feedError(err)
+	}
+	w := c.conn.DotWriter()
+	n, err := io.Copy(w, r)
+	if err != nil {
+		c.CloseNow()
+		endSpan(end, 0, n, err)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		endSpan(end, 0, n, err)
+		return err
+	}
+	code, _, err := c.readCodeLine(235)
+	endSpan(end, code, n, err)
+	return feedError(err)
+}
+
+// ModeStream switches the connection to the streaming feed of RFC 4644,
+// which allows CHECK and TAKETHIS.
+func (c *Client) ModeStream() error {
+	_, _, err := c.tracedCommand("MODE STREAM", 203, "")
+	return err
+}
+
+// Check asks the peer whether it wants an article, waiting for the
+// answer.  A peer that wants it gives nil; otherwise the error matches
+// ErrNotWanted, or ErrTransferFailed if it should be offered again
+// later.
+func (c *Client) Check(msgid string) error {
+	if err := c.SendCheck(msgid); err != nil {
+		return err
+	}
+	_, err := c.StreamResult()
+	return err
+}
+
+// TakeThis sends an article to the peer and waits for the answer, which
+// is nil, or an error matching ErrRejected or ErrTransferFailed.
+func (c *Client) TakeThis(msgid string, r io.Reader) error {
+	if err := c.SendTakeThis(msgid, r); err != nil {
+		return err
+	}
+	_, err := c.StreamResult()
+	return err
+}
+
+// SendCheck sends CHECK without waiting for the answer, which is
+// collected in turn by StreamResult.  Until every answer has been
+// collected, other commands fail with ErrBusy.
+//
+// Sending many commands before collecting their answers is the point of
+// streaming, but a peer stops reading once its answers back up, so
+// collect them as they arrive rather than all at the end.
+func (c *Client) SendCheck(msgid string) error {
+	if err := checkArgument("CHECK", msgid); err != nil {
+		return err
+	}
+	if err := c.claimStream("CHECK", false); err != nil {
+		return err
+	}
+	err := c.conn.PrintfLine("CHECK %s", msgid)
+	c.streamDone(err)
+	return err
+}
+
+// SendTakeThis sends TAKETHIS and the article without waiting for the
+// answer, which is collected in turn by StreamResult.  As with IHave, the
+// connection is closed if reading r fails part way through.
+func (c *Client) SendTakeThis(msgid string, r io.Reader) error {
+	if err := checkArgument("TAKETHIS", msgid); err != nil {
+		return err
+	}
+	if err := c.claimStream("TAKETHIS", false); err != nil {
+		return err
+	}
+	c.lend()
+	end := c.startSpan("nntp.takethis", "TAKETHIS")
+	err := c.sendTakeThis(msgid, r, end)
+	c.streamDone(err)
+	return err
+}
+
+// sendTakeThis writes TAKETHIS and the article, finishing its span.
+func (c *Client) sendTakeThis(msgid string, r io.Reader, end EndFunc) error {
+	if err := c.conn.PrintfLine("TAKETHIS %s", msgid); err != nil {
+		endSpan(end, 0, -1, err)
+		return err
+	}
+	w := c.conn.DotWriter()
+	n, err := io.Copy(w, r)
+	if err != nil {
+		c.CloseNow()
+		endSpan(end, 0, n, err)
+		return err
+	}
+	err = w.Close()
+	endSpan(end, 0, n, err)
+	return err
+}
+
+// StreamResult reads the answer to the oldest CHECK or TAKETHIS that
+// hasn't been collected, returning the message-id it's about.  A CHECK
+// the peer wants or a TAKETHIS it accepted gives a nil error; the
+// others match ErrNotWanted, ErrTransferFailed or ErrRejected.
+func (c *Client) StreamResult() (msgid string, err error) {
+	if err := c.claimStream("CHECK or TAKETHIS", true); err != nil {
+		return "", err
+	}
+	_, msg, err := c.readCodeLine(23)
+	c.streamCollected()
+	msgid, _ = nextField(msg)
+	return msgid, feedError(err)
+}
+
+// ErrNoStreamResult is returned by StreamResult when every CHECK and
+// TAKETHIS sent has had its answer collected.
+var ErrNoStreamResult = errors.New("no CHECK or TAKETHIS answer pending")
+
+// busyStream is what a connection is busy with while answers to CHECK
+// and TAKETHIS are pending.
+const busyStream = "CHECK or TAKETHIS answers not collected"
+
+// claimStream takes the connection for CHECK, TAKETHIS or, if collect
+// is set, collecting an answer, which unlike other commands may go ahead
+// while answers are pending.
+func (c *Client) claimStream(cmd string, collect bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.busy != busyStream {
+		if err := c.wait(cmd); err != nil && c.busy != busyStream {
+			return err
+		}
+	}
+	if collect && c.streaming == 0 {
+		return ErrNoStreamResult
+	}
+	c.busy, c.lent = cmd+" in progress", false
+	return nil
+}
+
+// streamDone frees the connection taken with claimStream to send a
+// command, counting its answer as pending unless sending failed.
+func (c *Client) streamDone(err error) {
+	c.mu.Lock()
+	if err == nil {
+		c.streaming++
+	}
+	c.streamFree()
+	c.mu.Unlock()
+}
+
+// streamCollected frees the connection taken with claimStream to
+// collect an answer.
+func (c *Client) streamCollected() {
+	c.mu.Lock()
+	c.streaming--
+	c.streamFree()
+	c.mu.Unlock()
+}
+
+// streamFree leaves the connection busy in the caller's hands while
+// answers are pending, so that other commands don't read them as their
+// own, and frees it otherwise.  c.mu must be held.
+func (c *Client) streamFree() {
+	if c.streaming > 0 {
+		c.busy, c.lent = busyStream, true
+	} else {
+		c.busy, c.lent = "", false
+	}
+	c.wake()
+}
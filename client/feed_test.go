@@ -0,0 +1,208 @@
+package nntpclient
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+const feedArticle = "Message-Id: <%s>\r\nNewsgroups: misc.test\r\n\r\n.dotted body\r\n"
+
+// feedServer takes articles by IHAVE.  It already has <have@x>, and
+// fails or rejects <later@x> and <spam@x> once it has read them.
+func feedServer(t *testing.T) (*Client, map[string][]string) {
+	got := map[string][]string{}
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		id := strings.TrimPrefix(line, "IHAVE ")
+		if id == "<have@x>" {
+			c.PrintfLine("435 Already have it")
+			return
+		}
+		c.PrintfLine("335 Send it")
+		lines, err := c.ReadDotLines()
+		if err != nil {
+			return
+		}
+		got[id] = lines
+		switch id {
+		case "<later@x>":
+			c.PrintfLine("436 Disk full")
+		case "<spam@x>":
+			c.PrintfLine("437 Spam")
+		default:
+			c.PrintfLine("235 Thanks")
+		}
+	})
+	return c, got
+}
+
+func TestIHave(t *testing.T) {
+	c, got := feedServer(t)
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"<new@x>", nil},
+		{"<have@x>", ErrNotWanted},
+		{"<later@x>", ErrTransferFailed},
+		{"<spam@x>", ErrRejected},
+		{"<new2@x>", nil},
+	}
+	for _, test := range tests {
+		read := false
+		r := readerFunc(func(p []byte) (int, error) {
+			read = true
+			return 0, io.EOF
+		})
+		body := io.MultiReader(r, strings.NewReader(strings.Replace(feedArticle, "%s", test.id[1:len(test.id)-1], 1)))
+		err := c.IHave(test.id, body)
+		if !errors.Is(err, test.want) || (err == nil) != (test.want == nil) {
+			t.Errorf("IHave(%s) = %v, want %v", test.id, err, test.want)
+		}
+		var terr *textproto.Error
+		if err != nil && !errors.As(err, &terr) {
+			t.Errorf("IHave(%s) error %v isn't a *textproto.Error", test.id, err)
+		}
+		if test.want == ErrNotWanted && read {
+			t.Errorf("IHave(%s) read the article", test.id)
+		}
+	}
+	if lines := got["<new@x>"]; len(lines) != 4 || lines[3] != ".dotted body" {
+		t.Errorf("Sent %q", lines)
+	}
+	if _, ok := got["<have@x>"]; ok {
+		t.Errorf("Sent an unwanted article")
+	}
+
+	// A failing reader doesn't finish the article.
+	err := c.IHave("<broken@x>", io.MultiReader(strings.NewReader("Subject: half\r\n"),
+		readerFunc(func([]byte) (int, error) { return 0, errors.New("disk error") })))
+	if err == nil {
+		t.Errorf("Read error was ignored")
+	}
+	if _, err := c.Date(); err == nil {
+		t.Errorf("Connection still open after a failed transfer")
+	}
+	if _, ok := got["<broken@x>"]; ok {
+		t.Errorf("Truncated article was delivered")
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+// streamServer is a streaming peer.  It has <have@x>, is too busy for
+// <busy@x> and rejects <spam@x>.
+func streamServer(t *testing.T) (*Client, map[string][]string) {
+	got := map[string][]string{}
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		cmd, id := nextField(line)
+		id = strings.TrimSpace(id)
+		switch {
+		case line == "MODE STREAM":
+			c.PrintfLine("203 Streaming permitted")
+		case cmd == "CHECK" && id == "<have@x>":
+			c.PrintfLine("438 %s", id)
+		case cmd == "CHECK" && id == "<busy@x>":
+			c.PrintfLine("431 %s", id)
+		case cmd == "CHECK":
+			c.PrintfLine("238 %s", id)
+		case cmd == "TAKETHIS":
+			lines, err := c.ReadDotLines()
+			if err != nil {
+				return
+			}
+			if id == "<spam@x>" {
+				c.PrintfLine("439 %s", id)
+				return
+			}
+			got[id] = lines
+			c.PrintfLine("239 %s", id)
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+	return c, got
+}
+
+func TestStreaming(t *testing.T) {
+	c, got := streamServer(t)
+	if err := c.ModeStream(); err != nil {
+		t.Fatal(err)
+	}
+	article := func(id string) io.Reader {
+		return strings.NewReader(strings.Replace(feedArticle, "<%s>", id, 1))
+	}
+
+	// Offer everything, then send what's wanted while collecting the
+	// answers to the offers.
+	offers := []string{"<a@x>", "<have@x>", "<busy@x>", "<spam@x>", "<b@x>"}
+	for _, id := range offers {
+		if err := c.SendCheck(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sent []string
+	for range offers {
+		id, err := c.StreamResult()
+		switch {
+		case err == nil:
+			if err := c.SendTakeThis(id, article(id)); err != nil {
+				t.Fatal(err)
+			}
+			sent = append(sent, id)
+		case id == "<have@x>" && errors.Is(err, ErrNotWanted):
+		case id == "<busy@x>" && errors.Is(err, ErrTransferFailed):
+		default:
+			t.Errorf("CHECK %s: %v", id, err)
+		}
+	}
+	if strings.Join(sent, " ") != "<a@x> <spam@x> <b@x>" {
+		t.Errorf("Sent %v", sent)
+	}
+	for _, want := range sent {
+		id, err := c.StreamResult()
+		if id != want {
+			t.Errorf("Answer for %s, expected %s", id, want)
+		}
+		if (err != nil) != (id == "<spam@x>") || (err != nil && !errors.Is(err, ErrRejected)) {
+			t.Errorf("TAKETHIS %s: %v", id, err)
+		}
+	}
+	if len(got) != 2 || got["<b@x>"][0] != "Message-Id: <b@x>" {
+		t.Errorf("Received %q", got)
+	}
+
+	if _, err := c.StreamResult(); err != ErrNoStreamResult {
+		t.Errorf("StreamResult with nothing pending: %v", err)
+	}
+
+	// Other commands wait for the answers to be collected rather than
+	// read them as their own.
+	if err := c.SendCheck("<a@x>"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Date(); !errors.Is(err, ErrBusy) {
+		t.Errorf("DATE with an answer pending: %v", err)
+	}
+	if id, err := c.StreamResult(); id != "<a@x>" || err != nil {
+		t.Errorf("Answer %s, %v after DATE", id, err)
+	}
+	if _, err := c.Date(); err == nil || errors.Is(err, ErrBusy) {
+		t.Errorf("DATE once answers were collected: %v", err)
+	}
+
+	// The lock-step forms.
+	if err := c.Check("<have@x>"); !errors.Is(err, ErrNotWanted) {
+		t.Errorf("Check: %v", err)
+	}
+	if err := c.TakeThis("<c@x>", article("<c@x>")); err != nil {
+		t.Errorf("TakeThis: %v", err)
+	}
+	if err := c.TakeThis("<spam@x>", article("<spam@x>")); !errors.Is(err, ErrRejected) {
+		t.Errorf("TakeThis spam: %v", err)
+	}
+}
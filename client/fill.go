@@ -0,0 +1,263 @@
+package nntpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"sort"
+	"sync"
+
+	nntpencoding "github.com/yannik995/go-nntp/encoding"
+	"github.com/yannik995/go-nntp/nzb"
+)
+
+// ErrMissingEverywhere is recorded for a segment no server could supply.
+var ErrMissingEverywhere = errors.New("article missing on all servers")
+
+// A FillServer is a server a Fetcher downloads from.
+type FillServer struct {
+	ServerConfig
+	// Name identifies the server in statistics.  Defaults to Addr.
+	Name string
+	// Priority orders servers, lowest first.  Servers of equal priority
+	// are tried in the order given.
+	Priority int
+	// MaxConns caps concurrent connections to the server.  Defaults
+	// to 1.
+	MaxConns int
+}
+
+// ServerStats count what a server did during a job.
+type ServerStats struct {
+	// Fetched segments, including fills.
+	Fetched int
+	// Fills are segments fetched after a higher priority server failed.
+	Fills int
+	// NotFound counts 430 and 423 responses.
+	NotFound int
+	// Corrupt counts segments that failed yEnc verification.
+	Corrupt int
+	// Errors counts connection and protocol failures.
+	Errors int
+}
+
+// A SegmentOutcome records where a segment came from.
+type SegmentOutcome struct {
+	MessageID string
+	// Server that supplied the segment, or "" if none did.
+	Server string
+	Err    error
+}
+
+// A FetchResult is the outcome of a Fetcher job.
+type FetchResult struct {
+	Segments []SegmentOutcome
+	// Missing lists segments no server supplied intact.
+	Missing []string
+	// Stats by server name.
+	Stats map[string]*ServerStats
+	mu    sync.Mutex
+}
+
+// A Fetcher downloads segments, trying servers in priority order and
+// filling segments missing or corrupt on one server from the next.
+type Fetcher struct {
+	// Tracer, if set, records a span for each Fetch job, with the
+	// commands it sends as children.
+	Tracer  Tracer
+	servers []*fillServer
+}
+
+type fillServer struct {
+	FillServer
+	sem  chan struct{}
+	mu   sync.Mutex
+	idle []*Client
+}
+
+// NewFetcher creates a Fetcher for the servers.
+func NewFetcher(servers []FillServer) *Fetcher {
+	rv := &Fetcher{}
+	for _, s := range servers {
+		if s.Name == "" {
+			s.Name = s.Addr
+		}
+		if s.MaxConns <= 0 {
+			s.MaxConns = 1
+		}
+		rv.servers = append(rv.servers, &fillServer{
+			FillServer: s,
+			sem:        make(chan struct{}, s.MaxConns),
+		})
+	}
+	sort.SliceStable(rv.servers, func(i, j int) bool {
+		return rv.servers[i].Priority < rv.servers[j].Priority
+	})
+	return rv
+}
+
+// Close the Fetcher's idle connections.
+func (f *Fetcher) Close() error {
+	for _, s := range f.servers {
+		s.mu.Lock()
+		for _, c := range s.idle {
+			c.Close()
+		}
+		s.idle = nil
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Fetch downloads and decodes segments into asm, using up to workers
+// segments at a time.
+//
+// Each segment is tried on every server in priority order until one
+// supplies an intact copy.  Segments that are missing or corrupt
+// everywhere are listed in the result rather than retried.
+func (f *Fetcher) Fetch(segments []nzb.Segment, asm *nntpencoding.Assembler, workers int) *FetchResult {
+	rv := f.newResult(len(segments))
+	if workers <= 0 {
+		workers = 1
+	}
+	ctx := context.Background()
+	var end EndFunc
+	if f.Tracer != nil {
+		ctx, end = f.Tracer.StartSpan(ctx, "nntp.fetch", Attr{AttrSegments, len(segments)})
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				rv.Segments[i] = f.fetchSegment(ctx, segments[i].MessageID, asm, rv)
+			}
+		}()
+	}
+	for i := range segments {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	rv.listMissing()
+	if end != nil {
+		end(nil, Attr{AttrMissing, len(rv.Missing)})
+	}
+	return rv
+}
+
+func (f *Fetcher) newResult(segments int) *FetchResult {
+	rv := &FetchResult{
+		Segments: make([]SegmentOutcome, segments),
+		Stats:    map[string]*ServerStats{},
+	}
+	for _, s := range f.servers {
+		rv.Stats[s.Name] = &ServerStats{}
+	}
+	return rv
+}
+
+// listMissing fills in Missing once the segments are done.
+func (r *FetchResult) listMissing() {
+	for _, o := range r.Segments {
+		if o.Server == "" && o.Err != ErrJobCancelled {
+			r.Missing = append(r.Missing, o.MessageID)
+		}
+	}
+}
+
+func (f *Fetcher) fetchSegment(ctx context.Context, msgid string, asm *nntpencoding.Assembler, rv *FetchResult) SegmentOutcome {
+	type corrupt struct {
+		res  *nntpencoding.Result
+		data []byte
+		err  error
+	}
+	var bad *corrupt
+	for i, s := range f.servers {
+		res, data, err := s.fetch(ctx, f.Tracer, msgid)
+		rv.mu.Lock()
+		stats := rv.Stats[s.Name]
+		switch {
+		case err == nil:
+			stats.Fetched++
+			if i > 0 {
+				stats.Fills++
+			}
+		case isNotFound(err):
+			stats.NotFound++
+		case nntpencoding.IsVerificationError(err):
+			stats.Corrupt++
+		default:
+			stats.Errors++
+		}
+		rv.mu.Unlock()
+
+		if err == nil {
+			return SegmentOutcome{msgid, s.Name, asm.AddSegment(msgid, res, data, nil)}
+		}
+		if nntpencoding.IsVerificationError(err) {
+			bad = &corrupt{res, data, err}
+		}
+	}
+	if bad != nil {
+		// Let the assembler's report account for the bad copy.
+		return SegmentOutcome{msgid, "", asm.AddSegment(msgid, bad.res, bad.data, bad.err)}
+	}
+	return SegmentOutcome{MessageID: msgid, Err: ErrMissingEverywhere}
+}
+
+func isNotFound(err error) bool {
+	var terr *textproto.Error
+	return errors.As(err, &terr) && (terr.Code == 430 || terr.Code == 423)
+}
+
+// fetch downloads and decodes one segment.
+func (s *fillServer) fetch(ctx context.Context, tracer Tracer, msgid string) (*nntpencoding.Result, []byte, error) {
+	s.sem <- struct{}{}
+	defer func() { <-s.sem }()
+
+	s.mu.Lock()
+	var c *Client
+	if n := len(s.idle); n > 0 {
+		c, s.idle = s.idle[n-1], s.idle[:n-1]
+	}
+	s.mu.Unlock()
+	if c == nil {
+		var err error
+		if c, err = s.DialContext(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	c.Tracer = tracer
+	c.SetContext(ctx)
+
+	res, data, err := fetchBody(c, "<"+msgid+">")
+	var terr *textproto.Error
+	if err != nil && !errors.As(err, &terr) && !nntpencoding.IsVerificationError(err) {
+		// The connection's state is unknown.
+		c.CloseNow()
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	s.idle = append(s.idle, c)
+	s.mu.Unlock()
+	return res, data, err
+}
+
+func fetchBody(c *Client, id string) (*nntpencoding.Result, []byte, error) {
+	_, _, body, err := c.Body(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	res, err := nntpencoding.YEnc{}.Decode(&buf, body)
+	if _, cerr := io.Copy(ioutil.Discard, body); err == nil {
+		err = cerr
+	}
+	return res, buf.Bytes(), err
+}
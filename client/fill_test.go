@@ -0,0 +1,120 @@
+package nntpclient
+
+import (
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	nntpencoding "github.com/yannik995/go-nntp/encoding"
+	"github.com/yannik995/go-nntp/nzb"
+)
+
+// encodeParts yEnc encodes data as parts of partSize bytes.
+func encodeParts(t *testing.T, data []byte, partSize int) []string {
+	var rv []string
+	total := (len(data) + partSize - 1) / partSize
+	for i := 0; i < total; i++ {
+		begin := i * partSize
+		end := begin + partSize
+		if end > len(data) {
+			end = len(data)
+		}
+		var buf bytes.Buffer
+		err := nntpencoding.YEnc{}.Encode(&buf, bytes.NewReader(data[begin:end]), &nntpencoding.Meta{
+			Name: "file.bin", Size: int64(len(data)), Part: i + 1, Total: total,
+			Begin: int64(begin + 1), End: int64(end),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv = append(rv, buf.String())
+	}
+	return rv
+}
+
+// bodyServer serves BODY <n@x> from bodies, 1-based, skipping those
+// missing says it doesn't have.
+func bodyServer(bodies []string, missing func(n int) bool, mu *sync.Mutex, requests *[]string) func(*textproto.Conn, string) {
+	return func(c *textproto.Conn, line string) {
+		var n int
+		if _, err := fmt.Sscanf(line, "BODY <%d@x>", &n); err != nil {
+			c.PrintfLine("500 what?")
+			return
+		}
+		mu.Lock()
+		*requests = append(*requests, line)
+		mu.Unlock()
+		if n < 1 || n > len(bodies) || missing(n) {
+			c.PrintfLine("430 No such article")
+			return
+		}
+		c.PrintfLine("222 0 <%d@x>", n)
+		dw := c.DotWriter()
+		dw.Write([]byte(strings.Replace(bodies[n-1], "\r\n", "\n", -1)))
+		dw.Close()
+	}
+}
+
+func TestFetcherFill(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	parts := encodeParts(t, data, 256)
+
+	// The primary lacks part 2 and has a corrupt part 3.
+	primary := append([]string(nil), parts...)
+	primary[2] = regexp.MustCompile(`pcrc32=[0-9a-f]{8}`).ReplaceAllString(primary[2], "pcrc32=00000000")
+	var mu sync.Mutex
+	var primaryReqs, fillReqs []string
+	fetcher := NewFetcher([]FillServer{
+		{ServerConfig: ServerConfig{Addr: fakeServerAddr(t, bodyServer(parts, func(n int) bool { return n == 4 }, &mu, &fillReqs))},
+			Name: "fill", Priority: 10, MaxConns: 2},
+		{ServerConfig: ServerConfig{Addr: fakeServerAddr(t, bodyServer(primary, func(n int) bool { return n == 2 || n == 4 }, &mu, &primaryReqs))},
+			Name: "primary", MaxConns: 2},
+	})
+	defer fetcher.Close()
+
+	var segments []nzb.Segment
+	for i := 1; i <= len(parts); i++ {
+		segments = append(segments, nzb.Segment{Number: i, MessageID: fmt.Sprintf("%d@x", i)})
+	}
+	out := make(writerAt, len(data))
+	asm, err := nntpencoding.NewAssembler(out, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := fetcher.Fetch(segments, asm, 3)
+
+	servers := []string{}
+	for _, o := range res.Segments {
+		servers = append(servers, o.Server)
+	}
+	if strings.Join(servers, ",") != "primary,fill,fill," {
+		t.Errorf("Suppliers %v", servers)
+	}
+	if len(res.Missing) != 1 || res.Missing[0] != "4@x" {
+		t.Errorf("Missing %v", res.Missing)
+	}
+	if res.Segments[3].Err != ErrMissingEverywhere {
+		t.Errorf("Segment 4 error %v", res.Segments[3].Err)
+	}
+	p, f := *res.Stats["primary"], *res.Stats["fill"]
+	if p != (ServerStats{Fetched: 1, NotFound: 2, Corrupt: 1}) {
+		t.Errorf("Primary stats %+v", p)
+	}
+	if f != (ServerStats{Fetched: 2, Fills: 2, NotFound: 1}) {
+		t.Errorf("Fill stats %+v", f)
+	}
+	if len(primaryReqs) != 4 || len(fillReqs) != 3 {
+		t.Errorf("Requests: primary %v, fill %v", primaryReqs, fillReqs)
+	}
+	want := asm.Missing()
+	if len(want) != 1 || want[0] != (nntpencoding.Range{Begin: 769, End: 1024}) {
+		t.Errorf("Assembler missing %v", want)
+	}
+	if !bytes.Equal(out[:768], data[:768]) {
+		t.Errorf("Assembled data differs")
+	}
+}
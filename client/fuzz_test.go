@@ -0,0 +1,97 @@
+//go:build go1.18
+// +build go1.18
+
+package nntpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// scriptedClient returns a client whose server answers its first command
+// with response, verbatim, and hangs up.
+func scriptedClient(t *testing.T, response string) *Client {
+	server, client := net.Pipe()
+	go func() {
+		defer server.Close()
+		io.WriteString(server, "200 scripted\r\n")
+		buf := make([]byte, 512)
+		if _, err := server.Read(buf); err != nil {
+			return
+		}
+		io.WriteString(server, response)
+	}()
+	c, err := NewConn(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// block makes a response with a data block.
+func block(status, data string) string {
+	return status + "\r\n" + data + "\r\n.\r\n"
+}
+
+func FuzzGroup(f *testing.F) {
+	f.Add("211 10 1 10 misc.test")
+	f.Add("211 10 1 10")
+	f.Add("211 ")
+	f.Add("211 a b c d")
+	f.Fuzz(func(t *testing.T, response string) {
+		scriptedClient(t, response+"\r\n").Group("misc.test")
+	})
+}
+
+func FuzzList(f *testing.F) {
+	f.Add("misc.test 10 1 y\r\nalt.test 5 2 m")
+	f.Add("misc.test 10")
+	f.Add("misc.test\r\n\r\n x y z")
+	f.Fuzz(func(t *testing.T, data string) {
+		scriptedClient(t, block("215 list follows", data)).List("ACTIVE")
+	})
+}
+
+func FuzzArticle(f *testing.F) {
+	f.Add("220 1 <a@b>", "Subject: x\r\n\r\nbody")
+	f.Add("220 1", "Subject: x\r\n\r\nbody")
+	f.Add("220 ", "")
+	f.Add("220 x <a@b>", "")
+	f.Fuzz(func(t *testing.T, status, data string) {
+		_, _, r, err := scriptedClient(t, block(status, data)).Article("<a@b>")
+		if err == nil {
+			ioutil.ReadAll(r)
+		}
+	})
+}
+
+func FuzzCapabilities(f *testing.F) {
+	f.Add("VERSION 2\r\nREADER\r\nIMPLEMENTATION x y\r\nLIST ACTIVE")
+	f.Add("\r\n \r\nVERSION")
+	f.Fuzz(func(t *testing.T, data string) {
+		c := scriptedClient(t, block("101 Capability list:", data))
+		if _, err := c.Capabilities(); err == nil {
+			c.Caps().SASLMechanisms()
+			c.GetCapability("LIST")
+			c.HasCapabilityArgument("LIST", "ACTIVE")
+		}
+	})
+}
+
+func FuzzOver(f *testing.F) {
+	f.Add("1\ts\tf\td\t<m>\t\t10\t2\r\n2\tshort")
+	f.Add("\t\t\t\r\nx\r\n-1\t\t\t\t\t\t\t\t\t\tXref:")
+	f.Fuzz(func(t *testing.T, data string) {
+		c := scriptedClient(t, block("224 overview follows", data))
+		c.overEach("1-", func(ov nntp.Overview) error {
+			ov.ReferenceIDs()
+			ov.Decoded()
+			return nil
+		})
+	})
+}
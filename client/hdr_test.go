@@ -0,0 +1,103 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestHdr(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "HDR Subject 3-5", "XHDR Subject 3-5":
+			code := 225
+			if line[0] == 'X' {
+				code = 221
+			}
+			writeLines(c, code, "Headers follow", "3 Hello there", "4", "5 \tRe: Hello")
+		case "HDR Subject <a@example.com>":
+			writeLines(c, 225, "Headers follow", "0 Hello there")
+		case "XHDR Subject <a@example.com>":
+			writeLines(c, 221, "Headers follow", "<a@example.com> Hello there")
+		case "HDR Subject <missing@example.com>", "XHDR Subject <missing@example.com>":
+			c.PrintfLine("430 No such article")
+		case "CAPABILITIES":
+			writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "OVER")
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+
+	for _, cmd := range []string{"HDR", "XHDR"} {
+		if cmd == "XHDR" {
+			if _, err := c.Capabilities(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := c.Hdr("Subject", "3-5")
+		if err != nil {
+			t.Fatalf("%s: %v", cmd, err)
+		}
+		want := map[int64]string{3: "Hello there", 4: "", 5: "Re: Hello"}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %q", cmd, got)
+		}
+		for n, v := range want {
+			if g, ok := got[n]; !ok || g != v {
+				t.Errorf("%s: article %d = %q, want %q", cmd, n, g, v)
+			}
+		}
+
+		subject, err := c.HdrMessageID("Subject", "<a@example.com>")
+		if err != nil || subject != "Hello there" {
+			t.Errorf("%s by message-id: %q, %v", cmd, subject, err)
+		}
+		if _, err := c.HdrMessageID("Subject", "<missing@example.com>"); !errors.Is(err, ErrNoSuchArticle) {
+			t.Errorf("%s for a missing article: %v", cmd, err)
+		}
+	}
+}
+
+func TestListHeaders(t *testing.T) {
+	lists := 0
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "LIST HEADERS":
+			lists++
+			writeLines(c, 215, "Field list follows", "Subject", "References", ":bytes", ":lines")
+		case "HDR subject 1", "HDR :bytes 1":
+			writeLines(c, 225, "Headers follow", "1 120")
+		default:
+			c.PrintfLine("503 Field not supported")
+		}
+	})
+	fields, err := c.ListHeaders()
+	if err != nil || strings.Join(fields, " ") != "Subject References :bytes :lines" {
+		t.Fatalf("ListHeaders: %q, %v", fields, err)
+	}
+
+	c.CheckHeaders = true
+	for _, field := range []string{"subject", ":bytes"} {
+		if _, err := c.Hdr(field, "1"); err != nil {
+			t.Errorf("Hdr(%s): %v", field, err)
+		}
+	}
+	if _, err := c.Hdr("X-Face", "1"); !errors.Is(err, ErrUnsupportedHeader) {
+		t.Errorf("Hdr(X-Face): %v", err)
+	}
+	if _, err := c.HdrMessageID(":size", "<a@x>"); !errors.Is(err, ErrUnsupportedHeader) {
+		t.Errorf("HdrMessageID(:size): %v", err)
+	}
+	if lists != 2 {
+		t.Errorf("LIST HEADERS sent %d times", lists)
+	}
+
+	// ":" allows any header, but not metadata.
+	c.hdrFields = []string{":", ":bytes"}
+	for field, want := range map[string]bool{"X-Face": true, ":bytes": true, ":lines": false} {
+		if ok, _ := c.HdrSupported(field); ok != want {
+			t.Errorf("HdrSupported(%s) = %v", field, ok)
+		}
+	}
+}
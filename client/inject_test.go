@@ -0,0 +1,75 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCommandInjection(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		mu.Lock()
+		seen = append(seen, line)
+		mu.Unlock()
+		if line == "DATE" {
+			c.PrintfLine("111 20240102030405")
+			return
+		}
+		c.PrintfLine("500 Unexpected %q", line)
+	})
+
+	calls := map[string]func() error{
+		"Group": func() error {
+			_, err := c.Group("misc.test\r\nPOST")
+			return err
+		},
+		"Command": func() error {
+			_, _, err := c.Command("GROUP a\nGROUP b", 211)
+			return err
+		},
+		"Authenticate user": func() error {
+			_, err := c.Authenticate("u\r\nQUIT", "p")
+			return err
+		},
+		"Authenticate pass": func() error {
+			_, err := c.Authenticate("u", "secret\x00")
+			return err
+		},
+		"ListGroup": func() error {
+			_, _, err := c.ListGroup("misc.test", "1-\r\nLIST")
+			return err
+		},
+		"IHave": func() error { return c.IHave("<a@b>\r\nQUIT", strings.NewReader("")) },
+		"HasArticles": func() error {
+			_, err := c.HasArticles([]string{"<a@b>", "<c@d>\nQUIT"})
+			return err
+		},
+		"CommandUpload": func() error {
+			_, _, err := c.CommandUpload("POST\r\nQUIT", 340, 240, strings.NewReader(""))
+			return err
+		},
+	}
+	for name, call := range calls {
+		err := call()
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: %v", name, err)
+		} else if strings.Contains(err.Error(), "secret") {
+			t.Errorf("%s: error shows the argument: %v", name, err)
+		}
+		if _, err := c.Date(); err != nil {
+			t.Fatalf("After %s: %v", name, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, line := range seen {
+		if line != "DATE" {
+			t.Errorf("Server received %q", line)
+		}
+	}
+}
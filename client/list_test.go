@@ -0,0 +1,229 @@
+package nntpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestListActiveTimes(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		if line != "LIST ACTIVE.TIMES" {
+			c.PrintfLine("500 what?")
+			return
+		}
+		writeLines(c, 215, "Group creations follow",
+			"misc.test 1614987001 fred@example.com", "alt.broken soon", "alt.quiet 946684800")
+	})
+	times, skipped, err := c.ListActiveTimes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 || len(times) != 2 || times[0].Creator != "fred@example.com" ||
+		times[1].Name != "alt.quiet" || times[1].Created.Year() != 2000 {
+		t.Errorf("Got %+v, skipped %d", times, skipped)
+	}
+}
+
+func TestListNewsgroups(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "LIST NEWSGROUPS":
+			writeLines(c, 215, "Descriptions follow",
+				"misc.test\tFor testing things out", "alt.spaced  \t Lots  of   space ",
+				"alt.caf\xe9 Caf\xe9 talk", "alt.bare")
+		case "LIST NEWSGROUPS misc.*":
+			writeLines(c, 215, "Descriptions follow", "misc.test\tFor testing things out")
+		default:
+			c.PrintfLine("500 what?")
+		}
+	})
+	got, err := c.ListNewsgroups("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"misc.test":  "For testing things out",
+		"alt.spaced": "Lots  of   space",
+		"alt.café":   "Café talk",
+		"alt.bare":   "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("Got %q", got)
+	}
+	for name, desc := range want {
+		if got[name] != desc {
+			t.Errorf("%s: %q, want %q", name, got[name], desc)
+		}
+	}
+	got, err = c.ListNewsgroups("misc.*")
+	if err != nil || len(got) != 1 {
+		t.Errorf("With a wildmat: %q, %v", got, err)
+	}
+}
+
+func TestListDistributionsModerators(t *testing.T) {
+	supported := true
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch {
+		case !supported:
+			c.PrintfLine("503 Not maintained")
+		case line == "LIST DISTRIBUTIONS":
+			writeLines(c, 215, "Distributions follow",
+				"local\tLocal to this site", "world  Everywhere in the world")
+		case line == "LIST MODERATORS":
+			writeLines(c, 215, "Moderators follow",
+				"example.*:mod-%s@example.com", "*:%s@moderators.isc.org")
+		default:
+			c.PrintfLine("501 Unknown keyword")
+		}
+	})
+	dists, err := c.ListDistributions()
+	if err != nil || len(dists) != 2 || dists["local"] != "Local to this site" ||
+		dists["world"] != "Everywhere in the world" {
+		t.Errorf("Distributions %q, %v", dists, err)
+	}
+	mods, err := c.ListModerators()
+	if err != nil || len(mods) != 2 || mods[0].Address != "mod-%s@example.com" {
+		t.Errorf("Moderators %+v, %v", mods, err)
+	}
+	if got := nntp.SubmissionAddress(mods, "misc.announce"); got != "misc-announce@moderators.isc.org" {
+		t.Errorf("Submission address %q", got)
+	}
+
+	supported = false
+	if _, err := c.ListDistributions(); !errors.Is(err, ErrUnsupportedList) {
+		t.Errorf("Unsupported distributions: %v", err)
+	}
+	if _, err := c.ListModerators(); !errors.Is(err, ErrUnsupportedList) {
+		t.Errorf("Unsupported moderators: %v", err)
+	}
+}
+
+func TestListActive(t *testing.T) {
+	var sent []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		switch line {
+		case "LIST ACTIVE":
+			writeLines(c, 215, "Groups follow", "misc.test 9 3 y", "comp.lang.go 120 1 m")
+		case "LIST ACTIVE comp.*":
+			writeLines(c, 215, "Groups follow", "comp.lang.go 120 1 m")
+		default:
+			c.PrintfLine("501 Syntax error")
+		}
+	})
+	groups, err := c.ListActive("")
+	if err != nil || len(groups) != 2 {
+		t.Errorf("All groups: %+v, %v", groups, err)
+	}
+	groups, err = c.ListActive("comp.*")
+	if err != nil || len(groups) != 1 || groups[0].Name != "comp.lang.go" ||
+		groups[0].High != 120 || groups[0].Posting != nntp.PostingModerated {
+		t.Errorf("comp.*: %+v, %v", groups, err)
+	}
+	for _, bad := range []string{"comp.* alt.*", "comp.*\r\nQUIT", "comp.*,"} {
+		if _, err := c.ListActive(bad); err == nil {
+			t.Errorf("ListActive(%q) was sent", bad)
+		}
+	}
+	if len(sent) != 2 {
+		t.Errorf("Sent %q", sent)
+	}
+}
+
+func TestListActiveFlags(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		writeLines(c, 215, "Groups follow", "old.name 0 0 =misc.test", "junk 5 1 j",
+			"local 5 1 x", "odd 5 1 q")
+	})
+	groups, err := c.ListActive("")
+	if err != nil || len(groups) != 4 {
+		t.Fatalf("ListActive: %+v, %v", groups, err)
+	}
+	want := []struct {
+		status  nntp.PostingStatus
+		aliasOf string
+	}{
+		{nntp.PostingAlias, "misc.test"},
+		{nntp.PostingJunk, ""},
+		{nntp.PostingNoLocal, ""},
+		{nntp.PostingNotPermitted, ""},
+	}
+	for i, w := range want {
+		if groups[i].Posting != w.status || groups[i].AliasOf != w.aliasOf {
+			t.Errorf("%s: %v %q, want %v %q", groups[i].Name, groups[i].Posting, groups[i].AliasOf,
+				w.status, w.aliasOf)
+		}
+	}
+}
+
+func TestListSkipped(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		writeLines(c, 215, "Groups follow", "tabbed\t9\t3\ty", "trailing 9 3 m  ", "noflag 9 3",
+			"short 9", "bad x 3 y", "", "fine 1 1 n")
+	})
+	groups, err := c.List("ACTIVE")
+	var skipped *SkippedLinesError
+	if !errors.As(err, &skipped) || skipped.Skipped != 2 || skipped.First != "short 9" {
+		t.Errorf("List error = %v", err)
+	}
+	var names []string
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	if strings.Join(names, " ") != "tabbed trailing noflag fine" {
+		t.Fatalf("List = %+v", groups)
+	}
+	if groups[0].Posting != nntp.PostingPermitted || groups[1].Posting != nntp.PostingModerated ||
+		groups[2].Posting != nntp.Unknown || groups[2].High != 9 {
+		t.Errorf("List = %+v", groups)
+	}
+}
+
+func TestListFunc(t *testing.T) {
+	// Enough groups to span several chunks.
+	lines := []string{"bad x 3 y"}
+	for i := 1; i <= 10000; i++ {
+		lines = append(lines, fmt.Sprintf("alt.binaries.group%d %d 1 y", i, i))
+	}
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		if line == "DATE" {
+			c.PrintfLine("111 20240102030405")
+			return
+		}
+		writeLines(c, 215, "Groups follow", lines...)
+	})
+	n := 0
+	err := c.ListFunc("ACTIVE", func(g nntp.Group) error {
+		n++
+		if g.High != int64(n) || g.Name != fmt.Sprintf("alt.binaries.group%d", n) {
+			t.Fatalf("Group %d: %+v", n, g)
+		}
+		return nil
+	})
+	var skipped *SkippedLinesError
+	if !errors.As(err, &skipped) || skipped.Skipped != 1 || n != 10000 {
+		t.Errorf("ListFunc: %d groups, %v", n, err)
+	}
+
+	stop := errors.New("stop")
+	n = 0
+	err = c.ListFunc("ACTIVE", func(g nntp.Group) error {
+		if n++; n == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 10 {
+		t.Errorf("ListFunc: %d groups, %v", n, err)
+	}
+	// The rest of the response was skipped.
+	if _, err := c.Date(); err != nil {
+		t.Errorf("Date after stopping: %v", err)
+	}
+}
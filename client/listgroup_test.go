@@ -0,0 +1,84 @@
+package nntpclient
+
+import (
+	"fmt"
+	"net/textproto"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestListGroup(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "LISTGROUP misc.test", "LISTGROUP":
+			writeLines(c, 211, "3 3 900 misc.test list follows", "3", "17", "900")
+		case "LISTGROUP misc.test 10-":
+			writeLines(c, 211, "3 3 900 misc.test list follows", "17", "900")
+		case "LISTGROUP alt.broken":
+			writeLines(c, 211, "3 1 3 alt.broken list follows", "1", "two", "3")
+		default:
+			c.PrintfLine("411 No such newsgroup")
+		}
+	})
+
+	tests := []struct {
+		group, rng string
+		want       string
+	}{
+		{"misc.test", "", "[3 17 900]"},
+		{"misc.test", "10-", "[17 900]"},
+		{"", "", "[3 17 900]"},
+	}
+	for _, test := range tests {
+		nums, g, err := c.ListGroup(test.group, test.rng)
+		if err != nil {
+			t.Errorf("ListGroup(%q, %q): %v", test.group, test.rng, err)
+			continue
+		}
+		if fmt.Sprint(nums) != test.want {
+			t.Errorf("ListGroup(%q, %q) = %v, want %s", test.group, test.rng, nums, test.want)
+		}
+		if g.Name != "misc.test" || g.Count != 3 || g.Low != 3 || g.High != 900 {
+			t.Errorf("ListGroup(%q, %q) group %+v", test.group, test.rng, g)
+		}
+	}
+
+	nums, _, err := c.ListGroup("alt.broken", "")
+	if err == nil || fmt.Sprint(nums) != "[1 3]" {
+		t.Errorf("Malformed line gave %v, %v", nums, err)
+	}
+	if _, _, err := c.ListGroup("", "1-"); err == nil {
+		t.Errorf("Range without a group was accepted")
+	}
+	if _, _, err := c.ListGroup("alt.missing", ""); err == nil {
+		t.Errorf("Missing group was accepted")
+	}
+	// The connection is still in step.
+	if nums, _, err := c.ListGroup("misc.test", ""); err != nil || len(nums) != 3 {
+		t.Errorf("After errors: %v, %v", nums, err)
+	}
+}
+
+func TestParseGroupLine(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want nntp.Group
+		ok   bool
+	}{
+		{"3 1 5 misc.test", nntp.Group{Name: "misc.test", Count: 3, Low: 1, High: 5}, true},
+		{"3 1 5 misc.test group selected", nntp.Group{Name: "misc.test", Count: 3, Low: 1, High: 5}, true},
+		{"3\t1  5 misc.test", nntp.Group{Name: "misc.test", Count: 3, Low: 1, High: 5}, true},
+		{"3 1 5", nntp.Group{}, false},
+		{"", nntp.Group{}, false},
+		{"x 1 5 misc.test", nntp.Group{}, false},
+		{"3 y 5 misc.test", nntp.Group{}, false},
+		{"3 1 z misc.test", nntp.Group{}, false},
+	}
+	for _, test := range tests {
+		got, err := parseGroupLine(test.msg)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseGroupLine(%q) = %+v, %v", test.msg, got, err)
+		}
+	}
+}
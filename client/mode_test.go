@@ -0,0 +1,99 @@
+package nntpclient
+
+import (
+	"net"
+	"net/textproto"
+	"testing"
+)
+
+func TestModeReader(t *testing.T) {
+	for _, code := range []int{200, 201} {
+		reader := false
+		c := fakeServer(t, func(c *textproto.Conn, line string) {
+			switch line {
+			case "MODE READER":
+				reader = true
+				c.PrintfLine("%d Reader mode", code)
+			case "CAPABILITIES":
+				if reader {
+					writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "OVER")
+				} else {
+					writeLines(c, 101, "Capability list:", "VERSION 2", "IHAVE", "MODE-READER")
+				}
+			default:
+				c.PrintfLine("500 Unknown command")
+			}
+		})
+		if _, err := c.Capabilities(); err != nil {
+			t.Fatal(err)
+		}
+		posting, err := c.ModeReader()
+		if err != nil {
+			t.Fatalf("%d: %v", code, err)
+		}
+		if posting != (code == 200) {
+			t.Errorf("%d: posting allowed is %v", code, posting)
+		}
+		if !c.Caps().Has("READER") || c.Caps().Has("MODE-READER") {
+			t.Errorf("%d: capabilities not refreshed: %q", code, c.Caps().Lines())
+		}
+	}
+
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("502 Transit service only")
+	})
+	if _, err := c.ModeReader(); err == nil {
+		t.Errorf("502 was accepted")
+	}
+}
+
+func TestPostingAllowed(t *testing.T) {
+	server, client := net.Pipe()
+	go func() {
+		s := textproto.NewConn(server)
+		defer s.Close()
+		s.PrintfLine("201 Posting prohibited")
+		authed := false
+		for {
+			line, err := s.ReadLine()
+			if err != nil {
+				return
+			}
+			switch line {
+			case "MODE READER":
+				s.PrintfLine("200 Posting allowed")
+			case "CAPABILITIES":
+				if authed {
+					writeLines(s, 101, "Capability list:", "VERSION 2", "READER", "POST")
+				} else {
+					writeLines(s, 101, "Capability list:", "VERSION 2", "READER")
+				}
+			case "authinfo user u":
+				s.PrintfLine("381 Password required")
+			case "authinfo pass p":
+				authed = true
+				s.PrintfLine("281 Authentication accepted")
+			default:
+				s.PrintfLine("500 Unknown command")
+			}
+		}
+	}()
+	c, err := NewConn(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	if c.PostingAllowed() {
+		t.Errorf("Posting allowed after 201 greeting")
+	}
+	if _, err := c.ModeReader(); err != nil || !c.PostingAllowed() {
+		t.Errorf("MODE READER 200: %v %v", c.PostingAllowed(), err)
+	}
+	if _, err := c.Capabilities(); err != nil || c.PostingAllowed() {
+		t.Errorf("Reader capabilities without POST: %v %v", c.PostingAllowed(), err)
+	}
+	if _, err := c.Authenticate("u", "p"); err != nil || !c.PostingAllowed() {
+		t.Errorf("After authenticating: %v %v", c.PostingAllowed(), err)
+	}
+}
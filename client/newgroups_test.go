@@ -0,0 +1,98 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestNewGroups(t *testing.T) {
+	var got string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		got = line
+		switch line {
+		case "NEWGROUPS 20210305 233001 GMT":
+			writeLines(c, 231, "list of new newsgroups follows",
+				"misc.new 12 1 y", "alt.new.moderated 0 1 m", "garbage")
+		case "NEWGROUPS 20300101 000000 GMT":
+			writeLines(c, 231, "list of new newsgroups follows")
+		default:
+			c.PrintfLine("501 Syntax error")
+		}
+	})
+
+	// 2021-03-06 08:30:01 in Tokyo is still the 5th in UTC.
+	tokyo := time.FixedZone("JST", 9*60*60)
+	groups, err := c.NewGroups(time.Date(2021, 3, 6, 8, 30, 1, 0, tokyo))
+	var skipped *SkippedLinesError
+	if !errors.As(err, &skipped) || skipped.First != "garbage" {
+		t.Fatalf("NewGroups sent %q: %v", got, err)
+	}
+	if len(groups) != 2 || groups[0].Name != "misc.new" || groups[0].High != 12 ||
+		groups[1].Posting != nntp.PostingModerated {
+		t.Errorf("Parsed %+v", groups)
+	}
+
+	groups, err = c.NewGroups(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(groups) != 0 {
+		t.Errorf("Empty list: %v, %v", groups, err)
+	}
+}
+
+func TestNewNews(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "NEWNEWS misc.*,!misc.private 20210305 233001 GMT":
+			writeLines(c, 230, "list of new articles follows",
+				"<a@example.com>", "  <b@example.com>\t", "")
+		default:
+			c.PrintfLine("501 Syntax error %s", line)
+		}
+	})
+
+	since := time.Date(2021, 3, 5, 23, 30, 1, 0, time.UTC)
+	ids, err := c.NewNews("misc.*,!misc.private", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "<a@example.com>" || ids[1] != "<b@example.com>" {
+		t.Errorf("Got %q", ids)
+	}
+	if _, err := c.NewNews(" ", since); err == nil {
+		t.Errorf("Empty wildmat was accepted")
+	}
+}
+
+func TestDate(t *testing.T) {
+	responses := map[string]string{}
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("%s", responses[line])
+	})
+
+	tests := []struct {
+		resp string
+		want time.Time
+		ok   bool
+	}{
+		{"111 20210305233001", time.Date(2021, 3, 5, 23, 30, 1, 0, time.UTC), true},
+		{"111 19991209000000 server time", time.Date(1999, 12, 9, 0, 0, 0, 0, time.UTC), true},
+		{"111 2021035233001", time.Time{}, false},
+		{"111 202103052330015", time.Time{}, false},
+		{"111 2021-3-05233001", time.Time{}, false},
+		{"111 20211305233001", time.Time{}, false},
+		{"500 Unknown command", time.Time{}, false},
+	}
+	for _, test := range tests {
+		responses["DATE"] = test.resp
+		got, err := c.Date()
+		if (err == nil) != test.ok || !got.Equal(test.want) {
+			t.Errorf("%q gave %v, %v", test.resp, got, err)
+		}
+		if err == nil && got.Location() != time.UTC {
+			t.Errorf("%q gave a time in %v", test.resp, got.Location())
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package nntpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+)
+
+// An Option configures a connection made by Dial.
+type Option func(*options)
+
+type options struct {
+	network    string
+	dialer     Dialer
+	tls        *tls.Config
+	startTLS   *tls.Config
+	auth       bool
+	user, pass string
+	modeReader bool
+	caps       bool
+	compress   bool
+	tracer     Tracer
+	reconnect  bool
+	notify     func(cmd string, err error)
+}
+
+// WithNetwork dials a network other than "tcp", such as "tcp6".
+func WithNetwork(network string) Option {
+	return func(o *options) { o.network = network }
+}
+
+// WithTLS connects with TLS from the start, as to port 563, as NewTLS
+// does.  config may be nil.
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		o.tls = config
+	}
+}
+
+// WithStartTLS upgrades the connection with STARTTLS as soon as the
+// server has greeted it.  config may be nil.
+func WithStartTLS(config *tls.Config) Option {
+	return func(o *options) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		o.startTLS = config
+	}
+}
+
+// WithDialer makes the connection with d, for example through a SOCKS5
+// proxy.
+func WithDialer(d ContextDialer) Option {
+	return func(o *options) { o.dialer.NetDialer = d }
+}
+
+// WithTimeout bounds connecting, up to the server's greeting.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.dialer.Timeout = d }
+}
+
+// WithIOTimeout sets the client's ReadTimeout and WriteTimeout.
+func WithIOTimeout(read, write time.Duration) Option {
+	return func(o *options) {
+		o.dialer.ReadTimeout = read
+		o.dialer.WriteTimeout = write
+	}
+}
+
+// WithAuth authenticates with AUTHINFO USER and PASS.
+func WithAuth(user, pass string) Option {
+	return func(o *options) {
+		o.auth = true
+		o.user, o.pass = user, pass
+	}
+}
+
+// WithModeReader switches the server to reader mode, unless its
+// capabilities, if they've been retrieved, show it's there already.
+func WithModeReader() Option {
+	return func(o *options) { o.modeReader = true }
+}
+
+// WithCapabilitiesOnConnect retrieves the capabilities once connected,
+// so that Caps is ready.
+func WithCapabilitiesOnConnect() Option {
+	return func(o *options) { o.caps = true }
+}
+
+// WithCompress turns on COMPRESS DEFLATE, last, if the server offers it.
+func WithCompress() Option {
+	return func(o *options) {
+		o.compress = true
+		o.caps = true
+	}
+}
+
+// WithTracer sets the client's Tracer before the first command.
+func WithTracer(t Tracer) Option {
+	return func(o *options) { o.tracer = t }
+}
+
+// WithReconnect makes the client dial again when an idempotent command
+// finds the connection lost, as servers drop idle ones, or the server
+// answers 400, that it's closing the connection.  Other errors,
+// including 503 for something the server doesn't support, are returned
+// as they are.  The session is set up again as the options
+// ask, the group last selected is selected again, and the command is
+// sent once more.  POST, IHAVE and the other commands that upload an
+// article are never sent again; their error is returned.
+//
+// notify, if not nil, is called with the command and its error before
+// each attempt to reconnect, for logging.  This replaces the client's
+// RetryPolicy.
+func WithReconnect(notify func(cmd string, err error)) Option {
+	return func(o *options) {
+		o.reconnect = true
+		o.notify = notify
+	}
+}
+
+// Dial connects a client to an NNTP server and sets up the session as
+// the options ask, in this order: the greeting, CAPABILITIES, STARTTLS,
+// MODE READER, AUTHINFO and then COMPRESS, which RFC 8054 puts after
+// authentication.  If any step fails, the connection is closed and the
+// step's error returned.
+func Dial(addr string, opts ...Option) (*Client, error) {
+	return DialContext(context.Background(), addr, opts...)
+}
+
+// DialContext is Dial, giving up when ctx is done before the server has
+// greeted the client.  To bound the rest of the sequence too, use
+// WithTimeout.
+func DialContext(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	o := options{network: "tcp"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var c *Client
+	var err error
+	if o.tls != nil {
+		c, err = o.dialer.DialTLSContext(ctx, o.network, addr, o.tls)
+	} else {
+		c, err = o.dialer.DialContext(ctx, o.network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Tracer = o.tracer
+	if err := o.setUp(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if o.reconnect {
+		c.RetryPolicy = o.redial(addr)
+	}
+	return c, nil
+}
+
+// redial is the RetryPolicy of WithReconnect.
+func (o *options) redial(addr string) RetryPolicy {
+	return func(c *Client, cmd string, err error) error {
+		if code, ok := ResponseCode(err); ok && code != 400 {
+			// The connection is fine; dialing again would get the
+			// same answer.
+			return err
+		}
+		if o.notify != nil {
+			o.notify(cmd, err)
+		}
+		group := c.CurrentGroup()
+		var nc *Client
+		if o.tls != nil {
+			nc, err = o.dialer.DialTLSContext(c.Context(), o.network, addr, o.tls)
+		} else {
+			nc, err = o.dialer.DialContext(c.Context(), o.network, addr)
+		}
+		if err != nil {
+			return err
+		}
+		c.adopt(nc)
+		if err := o.setUp(c); err != nil {
+			return err
+		}
+		if group != "" {
+			if _, err := c.Group(group); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// setUp runs the steps after the greeting.
+func (o *options) setUp(c *Client) error {
+	if o.caps {
+		if _, err := c.Capabilities(); err != nil {
+			return err
+		}
+	}
+	if o.startTLS != nil {
+		if err := c.StartTLS(o.startTLS); err != nil {
+			return err
+		}
+	}
+	if o.modeReader && !c.Caps().Has("READER") {
+		if _, err := c.ModeReader(); err != nil {
+			return err
+		}
+	}
+	if o.auth {
+		if _, err := c.Authenticate(o.user, o.pass); err != nil {
+			return err
+		}
+	}
+	if o.compress && c.Caps().HasArg("COMPRESS", "DEFLATE") {
+		if err := c.Compress(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package nntpclient
+
+import (
+	"compress/flate"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// setupServer is a server in transit mode that supports STARTTLS,
+// MODE READER, AUTHINFO and COMPRESS, logging the commands it gets.
+type setupServer struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (s *setupServer) commands() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.log, "|")
+}
+
+func (s *setupServer) start(t *testing.T, cert tls.Certificate) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		var raw net.Conn = nc
+		c := textproto.NewConn(raw)
+		c.PrintfLine("200 ready")
+		reader := false
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.log = append(s.log, line)
+			s.mu.Unlock()
+			switch line {
+			case "CAPABILITIES":
+				if reader {
+					writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "AUTHINFO USER", "COMPRESS DEFLATE")
+				} else {
+					writeLines(c, 101, "Capability list:", "VERSION 2", "MODE-READER", "STARTTLS")
+				}
+			case "STARTTLS":
+				c.PrintfLine("382 Go ahead")
+				raw = tls.Server(nc, &tls.Config{Certificates: []tls.Certificate{cert}})
+				c = textproto.NewConn(raw)
+			case "MODE READER":
+				reader = true
+				c.PrintfLine("200 Posting allowed")
+			case "authinfo user tim":
+				c.PrintfLine("381 Password required")
+			case "authinfo pass secret":
+				c.PrintfLine("281 Welcome")
+			case "authinfo pass wrong":
+				c.PrintfLine("481 Rejected")
+			case "COMPRESS DEFLATE":
+				c.PrintfLine("206 Compression active")
+				w, _ := flate.NewWriter(raw, flate.DefaultCompression)
+				c = textproto.NewConn(&deflateConn{r: flate.NewReader(raw), w: w, c: raw})
+			case "DATE":
+				c.PrintfLine("111 20240102030405")
+			case "QUIT":
+				c.PrintfLine("205 Bye")
+				return
+			default:
+				c.PrintfLine("500 Unknown command")
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestDialOptions(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	var s setupServer
+	addr := s.start(t, leaf)
+
+	c, err := Dial(addr,
+		WithCompress(),
+		WithAuth("tim", "secret"),
+		WithModeReader(),
+		WithStartTLS(&tls.Config{RootCAs: pool, ServerName: "localhost"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if !c.HasTLS() || !c.compressed || !c.Caps().Has("READER") {
+		t.Errorf("TLS %v, compressed %v, caps %q", c.HasTLS(), c.compressed, c.Caps().Lines())
+	}
+	c.Close()
+	want := "CAPABILITIES|STARTTLS|CAPABILITIES|MODE READER|CAPABILITIES|" +
+		"authinfo user tim|authinfo pass secret|CAPABILITIES|COMPRESS DEFLATE|CAPABILITIES|DATE|QUIT"
+	if got := s.commands(); got != want {
+		t.Errorf("Commands\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDialOptionsMinimal(t *testing.T) {
+	var s setupServer
+	addr := s.start(t, tls.Certificate{})
+
+	// Without capabilities, MODE READER is sent regardless.
+	_, err := Dial(addr, WithModeReader(), WithAuth("tim", "wrong"))
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Rejected login: %v", err)
+	}
+	if got, want := s.commands(), "MODE READER|authinfo user tim|authinfo pass wrong|QUIT"; got != want {
+		t.Errorf("Commands %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// xoverServer only implements XOVER, and counts the commands it's sent.
+func xoverServer(t *testing.T, caps ...string) (*Client, map[string]int) {
+	sent := map[string]int{}
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		cmd, _ := nextField(line)
+		sent[cmd]++
+		switch cmd {
+		case "XOVER":
+			writeLines(c, 224, "Overview information follows",
+				"3\tHello\tfred@example.com\t5 Mar 2021 23:30:01 +0000\t<a@example.com>\t\t120\t4")
+		case "CAPABILITIES":
+			writeLines(c, 101, "Capability list:", caps...)
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+	return c, sent
+}
+
+func TestXOverFallback(t *testing.T) {
+	c, sent := xoverServer(t)
+	for i := 0; i < 3; i++ {
+		lines, err := c.Over("1-10")
+		if err != nil || len(lines) != 1 || !strings.HasPrefix(lines[0], "3\tHello") {
+			t.Fatalf("Over: %q, %v", lines, err)
+		}
+	}
+	var subjects []string
+	err := c.overEach("1-10", func(ov nntp.Overview) error {
+		subjects = append(subjects, ov.Subject)
+		return nil
+	})
+	if err != nil || len(subjects) != 1 || subjects[0] != "Hello" {
+		t.Errorf("overEach: %q, %v", subjects, err)
+	}
+	if sent["OVER"] != 1 || sent["XOVER"] != 4 {
+		t.Errorf("Sent %v", sent)
+	}
+
+	// With capabilities, OVER isn't tried at all.
+	c, sent = xoverServer(t, "VERSION 2", "READER")
+	if _, err := c.Capabilities(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Over("1-10"); err != nil {
+		t.Fatal(err)
+	}
+	if sent["OVER"] != 0 || sent["XOVER"] != 1 {
+		t.Errorf("Sent %v with capabilities", sent)
+	}
+}
+
+func TestOverviewFull(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		if line == "LIST OVERVIEW.FMT" {
+			c.PrintfLine("503 Not here")
+			return
+		}
+		writeLines(c, 224, "Overview information follows",
+			"3\tHello\tfred@example.com\t5 Mar 2021 23:30:01 +0000\t<a@example.com>\t\t5000000000\t4\tXref: h misc.test:3",
+			"4\tShort\tfred@example.com",
+			"x\tNo number\t\t\t\t\t\t",
+			"5\tEmpty\t\t\t<b@example.com>\t\t\t")
+	})
+	ovs, err := c.OverviewFull("1-10")
+	if !errors.Is(err, ErrBadOverview) || !strings.Contains(err.Error(), "Short") {
+		t.Errorf("OverviewFull error = %v, want one matching ErrBadOverview for the short line", err)
+	}
+	if len(ovs) != 2 {
+		t.Fatalf("OverviewFull = %+v, want 2 overviews", ovs)
+	}
+	ov := ovs[0]
+	if ov.Number != 3 || ov.Subject != "Hello" || ov.Bytes != 5000000000 || ov.Lines != 4 ||
+		ov.Time.IsZero() || ov.Xref != "h misc.test:3" || len(ov.ExtraFields()) != 1 {
+		t.Errorf("OverviewFull[0] = %+v", ov)
+	}
+	if ovs[1].Number != 5 || ovs[1].MessageID != "<b@example.com>" {
+		t.Errorf("OverviewFull[1] = %+v", ovs[1])
+	}
+}
+
+func TestOverviewFullFormat(t *testing.T) {
+	fmts := 0
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		if line == "LIST OVERVIEW.FMT" {
+			fmts++
+			writeLines(c, 215, "Order of fields", "Subject:", "From:", "Date:", "Message-ID:",
+				"References:", ":bytes", ":lines", "Newsgroups:full", "Xref:")
+			return
+		}
+		writeLines(c, 224, "Overview information follows",
+			"3\tHi\tf@x\t\t<a@x>\t\t1\t1\tNewsgroups: a,b\th a:3 b:7",
+			"4\tHi\tf@x\t\t<b@x>\t\t1\t1\tNewsgroups: a")
+	})
+	for i := 0; i < 2; i++ {
+		ovs, err := c.OverviewFull("1-10")
+		if err != nil || len(ovs) != 2 {
+			t.Fatalf("OverviewFull = %+v, %v", ovs, err)
+		}
+		if ovs[0].Xref != "h a:3 b:7" || ovs[1].Xref != "" {
+			t.Errorf("Xref = %q, %q", ovs[0].Xref, ovs[1].Xref)
+		}
+		format, _ := c.OverviewFormat()
+		if got := ovs[0].Fields(format)["Newsgroups"]; got != "a,b" {
+			t.Errorf("Newsgroups = %q", got)
+		}
+	}
+	if fmts != 1 {
+		t.Errorf("Sent LIST OVERVIEW.FMT %d times", fmts)
+	}
+}
+
+func TestOverFunc(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "LIST OVERVIEW.FMT":
+			writeLines(c, 215, "Order of fields", "Subject:", "From:", "Date:", "Message-ID:",
+				"References:", ":bytes", ":lines", "Xref:full")
+		case "DATE":
+			c.PrintfLine("111 20240102030405")
+		default:
+			writeLines(c, 224, "Overview information follows",
+				"3\tHello\tf@x\t\t<a@x>\t\t1\t1\tXref: h a:3",
+				"4\tShort\tf@x",
+				"5\tBye\tf@x\t\t<b@x>\t\t1\t1",
+				"6\tLast\tf@x\t\t<c@x>\t\t1\t1")
+		}
+	})
+	var lines []string
+	if err := c.OverFunc("1-10", func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}); err != nil || len(lines) != 4 || lines[1] != "4\tShort\tf@x" {
+		t.Errorf("OverFunc: %q, %v", lines, err)
+	}
+
+	stop := errors.New("stop")
+	var subjects []string
+	err := c.OverviewFunc("1-10", func(ov nntp.Overview) error {
+		subjects = append(subjects, ov.Subject)
+		if ov.Number == 3 && ov.Xref != "h a:3" {
+			t.Errorf("Xref = %q", ov.Xref)
+		}
+		if ov.Number == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || strings.Join(subjects, " ") != "Hello Bye" {
+		t.Errorf("OverviewFunc: %q, %v", subjects, err)
+	}
+	// The rest of the response was skipped.
+	if _, err := c.Date(); err != nil {
+		t.Errorf("Date after stopping: %v", err)
+	}
+}
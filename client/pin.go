@@ -0,0 +1,201 @@
+package nntpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// A Fingerprint is the SHA-256 hash of a certificate's public key
+// (its SubjectPublicKeyInfo), which stays the same when the certificate
+// is renewed with the same key.
+type Fingerprint [sha256.Size]byte
+
+// SPKIFingerprint returns a certificate's fingerprint.
+func SPKIFingerprint(cert *x509.Certificate) Fingerprint {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// String formats the fingerprint as "sha256/" and base64, the form
+// ParseFingerprint and HPKP use.
+func (f Fingerprint) String() string {
+	return "sha256/" + base64.StdEncoding.EncodeToString(f[:])
+}
+
+// ParseFingerprint parses a fingerprint either in the "sha256/base64"
+// form or as hex, optionally separated by colons.
+func ParseFingerprint(s string) (Fingerprint, error) {
+	var rv Fingerprint
+	var b []byte
+	var err error
+	if strings.HasPrefix(s, "sha256/") {
+		b, err = base64.StdEncoding.DecodeString(s[len("sha256/"):])
+	} else {
+		b, err = hex.DecodeString(strings.Replace(s, ":", "", -1))
+	}
+	if err != nil || len(b) != len(rv) {
+		return rv, errors.New("invalid fingerprint " + s)
+	}
+	copy(rv[:], b)
+	return rv, nil
+}
+
+// A PinError is a server certificate that matched no pin.
+type PinError struct {
+	// Presented is the fingerprint of the server's leaf certificate.
+	Presented Fingerprint
+	// Err is the chain verification error when pinned to certificates.
+	Err error
+}
+
+func (e *PinError) Error() string {
+	msg := "certificate pin mismatch: server presented " + e.Presented.String()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Pins trusts particular certificates instead of the system's CAs.  A
+// server is accepted if its leaf certificate has one of the
+// Fingerprints, or chains up to a certificate it presents that has one,
+// or if its leaf certificate is one of Certificates or is signed by one
+// of them; a chain must be valid for the name being dialed.
+type Pins struct {
+	Fingerprints []Fingerprint
+	Certificates []*x509.Certificate
+	// LogOnly verifies the server as usual, against the system CAs, and
+	// only logs pin mismatches.  It's for rolling out new pins.
+	LogOnly bool
+	// Logf logs mismatches in LogOnly mode.  Defaults to log.Printf.
+	Logf func(format string, args ...interface{})
+}
+
+// Config returns a copy of config, or of an empty config if it's nil,
+// that enforces the pins on every connection, resumed ones included.
+// VerifyPeerCertificate and VerifyConnection callbacks already in config
+// still run after the pins are checked, but unless LogOnly is set they
+// get no verified chains, since the system CAs aren't used.
+//
+// Chains up to a pinned certificate are checked against the name the
+// connection was made for, as sent with SNI.  An IP address isn't sent,
+// so when dialing one, set config's ServerName to it; ServerConfig does.
+func (p *Pins) Config(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	rv := config.Clone()
+	nextPeer, nextConn := config.VerifyPeerCertificate, config.VerifyConnection
+	if !p.LogOnly {
+		rv.InsecureSkipVerify = true
+	}
+	rv.VerifyPeerCertificate = nil
+	rv.VerifyConnection = func(cs tls.ConnectionState) error {
+		name := cs.ServerName
+		if name == "" {
+			name = rv.ServerName
+		}
+		if err := p.verify(cs.PeerCertificates, name); err != nil {
+			if !p.LogOnly {
+				return err
+			}
+			logf := p.Logf
+			if logf == nil {
+				logf = log.Printf
+			}
+			logf("Allowing %s: %v", name, err)
+		}
+		if nextPeer != nil {
+			rawCerts := make([][]byte, len(cs.PeerCertificates))
+			for i, cert := range cs.PeerCertificates {
+				rawCerts[i] = cert.Raw
+			}
+			if err := nextPeer(rawCerts, cs.VerifiedChains); err != nil {
+				return err
+			}
+		}
+		if nextConn != nil {
+			return nextConn(cs)
+		}
+		return nil
+	}
+	return rv
+}
+
+// pinned reports whether cert has one of the Fingerprints.
+func (p *Pins) pinned(cert *x509.Certificate) bool {
+	fp := SPKIFingerprint(cert)
+	for _, pin := range p.Fingerprints {
+		if fp == pin {
+			return true
+		}
+	}
+	return false
+}
+
+// verify checks the presented certificates against the pins.  A chain
+// is only accepted for serverName, so with none only a pinned leaf is.
+func (p *Pins) verify(certs []*x509.Certificate, serverName string) error {
+	if len(certs) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if p.pinned(leaf) {
+		return nil
+	}
+	for _, cert := range p.Certificates {
+		if bytes.Equal(cert.Raw, leaf.Raw) {
+			return nil
+		}
+	}
+	rv := &PinError{Presented: SPKIFingerprint(leaf)}
+	if serverName == "" {
+		rv.Err = errors.New("no server name to check a chain against")
+		return rv
+	}
+	// Anyone can send a pinned CA's certificate along with their own,
+	// so one further up the chain only counts if it signed the leaf.
+	for _, cert := range certs[1:] {
+		if !p.pinned(cert) {
+			continue
+		}
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		_, err := leaf.Verify(x509.VerifyOptions{
+			DNSName:       serverName,
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	if len(p.Certificates) == 0 {
+		return rv
+	}
+	roots := x509.NewCertPool()
+	for _, cert := range p.Certificates {
+		roots.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		rv.Err = fmt.Errorf("not signed by a pinned certificate: %v", err)
+		return rv
+	}
+	return nil
+}
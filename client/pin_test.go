@@ -0,0 +1,196 @@
+package nntpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert creates a certificate signed by parent, or self-signed if
+// parent is nil.
+func testCert(t *testing.T, name string, parent *tls.Certificate, ca bool) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if !ca {
+		tmpl.DNSNames = []string{"localhost"}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	signer, signerKey := tmpl, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// tlsServer serves an NNTP greeting over TLS with cert.
+func tlsServer(t *testing.T, cert tls.Certificate) string {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(nc, func(c *textproto.Conn, line string) {})
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestPins(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	other := testCert(t, "Other CA", nil, true)
+	addr := tlsServer(t, leaf)
+	leafPin := SPKIFingerprint(leaf.Leaf)
+
+	parsed, err := ParseFingerprint(leafPin.String())
+	if err != nil || parsed != leafPin {
+		t.Errorf("Round trip of %s: %v, %v", leafPin, parsed, err)
+	}
+	var hexBytes []string
+	for _, b := range leafPin {
+		hexBytes = append(hexBytes, fmt.Sprintf("%02X", b))
+	}
+	hexPin := strings.Join(hexBytes, ":")
+	if parsed, err := ParseFingerprint(hexPin); err != nil || parsed != leafPin {
+		t.Errorf("Parsing %s: %v, %v", hexPin, parsed, err)
+	}
+
+	var logged []string
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca.Leaf)
+	tests := []struct {
+		name   string
+		pins   Pins
+		config *tls.Config
+		pinErr bool
+		ok     bool
+	}{
+		{"leaf fingerprint", Pins{Fingerprints: []Fingerprint{leafPin}}, nil, false, true},
+		{"wrong fingerprint", Pins{Fingerprints: []Fingerprint{SPKIFingerprint(other.Leaf)}}, nil, true, false},
+		{"CA certificate", Pins{Certificates: []*x509.Certificate{ca.Leaf}}, nil, false, true},
+		{"other CA", Pins{Certificates: []*x509.Certificate{other.Leaf}}, nil, true, false},
+		{"log only", Pins{Fingerprints: []Fingerprint{SPKIFingerprint(other.Leaf)}, LogOnly: true,
+			Logf: func(f string, args ...interface{}) { logged = append(logged, fmt.Sprintf(f, args...)) }},
+			&tls.Config{RootCAs: caPool}, false, true},
+		{"log only untrusted", Pins{Fingerprints: []Fingerprint{leafPin}, LogOnly: true}, nil, false, false},
+		{"composed callback", Pins{Fingerprints: []Fingerprint{leafPin}}, &tls.Config{
+			VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error { return errors.New("vetoed") },
+		}, false, false},
+	}
+	for _, test := range tests {
+		pins := test.pins
+		c, err := ServerConfig{Addr: addr, TLS: test.config, Pins: &pins}.Dial()
+		if c != nil {
+			c.Close()
+		}
+		var perr *PinError
+		isPinErr := errors.As(err, &perr)
+		if (err == nil) != test.ok || isPinErr != test.pinErr {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if isPinErr && !strings.Contains(err.Error(), leafPin.String()) {
+			t.Errorf("%s: error doesn't name the presented pin: %v", test.name, err)
+		}
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], leafPin.String()) {
+		t.Errorf("Logged %q", logged)
+	}
+}
+
+func TestPinsChain(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	attacker := testCert(t, "localhost", nil, false)
+	caPin := SPKIFingerprint(ca.Leaf)
+	withCA := func(cert tls.Certificate) tls.Certificate {
+		cert.Certificate = append(cert.Certificate, ca.Certificate[0])
+		return cert
+	}
+
+	tests := []struct {
+		name string
+		cert tls.Certificate
+		ok   bool
+	}{
+		{"signed by pinned CA", withCA(leaf), true},
+		// The genuine CA certificate is public; sending it along with a
+		// leaf it didn't sign proves nothing.
+		{"pinned CA appended", withCA(attacker), false},
+	}
+	for _, test := range tests {
+		c, err := ServerConfig{
+			Addr: tlsServer(t, test.cert),
+			Pins: &Pins{Fingerprints: []Fingerprint{caPin}},
+		}.Dial()
+		if c != nil {
+			c.Close()
+		}
+		var perr *PinError
+		if test.ok && err != nil || !test.ok && !errors.As(err, &perr) {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func TestPinsServerName(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	leaf.Certificate = append(leaf.Certificate, ca.Certificate[0])
+	pins := &Pins{Fingerprints: []Fingerprint{SPKIFingerprint(ca.Leaf)}}
+	d := Dialer{NetDialer: &recordingDialer{addr: tlsServer(t, leaf)}}
+
+	// The name is filled in after Config, as DialTLS does from the
+	// address, and the certificate doesn't have it.
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{"localhost:563", true},
+		{"news.example.com:563", false},
+		{"127.0.0.1:563", false},
+	}
+	for _, test := range tests {
+		c, err := d.DialTLS("tcp", test.addr, pins.Config(nil))
+		if c != nil {
+			c.Close()
+		}
+		var perr *PinError
+		if test.ok && err != nil || !test.ok && !errors.As(err, &perr) {
+			t.Errorf("%s: %v", test.addr, err)
+		}
+	}
+}
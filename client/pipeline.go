@@ -0,0 +1,115 @@
+package nntpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net/textproto"
+)
+
+// defaultPipelineWindow is how many commands FetchBodies and
+// FetchArticles send ahead when PipelineWindow isn't set.
+const defaultPipelineWindow = 16
+
+// A FetchFunc handles one article fetched by FetchBodies or
+// FetchArticles.  If the server couldn't send it, body is nil and err
+// says why: one matching ErrNoSuchArticle for a missing article.
+// Otherwise body is the data block, which is valid until the FetchFunc
+// returns; whatever it leaves unread is skipped.  Returning an error
+// stops the fetch, and it's returned.
+type FetchFunc func(spec string, n int64, msgid string, body io.Reader, err error) error
+
+// FetchBodies gets the bodies of many articles, each specified as for
+// Body, calling handle with each in the order given.  Rather than wait
+// for each response before sending the next command, it keeps
+// PipelineWindow commands ahead, which over a distant server saves most
+// of the time spent on round trips.
+//
+// The connection is busy until FetchBodies returns, so handle can't send
+// commands of its own.  An error other than handle's means the
+// connection failed.
+func (c *Client) FetchBodies(specs []string, handle FetchFunc) error {
+	return c.fetchMany("BODY", 222, specs, handle)
+}
+
+// FetchArticles is FetchBodies for whole articles, as Article gets them.
+func (c *Client) FetchArticles(specs []string, handle FetchFunc) error {
+	return c.fetchMany("ARTICLE", 220, specs, handle)
+}
+
+// fetchMany sends verb for each of specs, pipelined, and hands the
+// responses to handle.  Once handle fails, no more commands are sent,
+// but the responses to those already sent are read and skipped.
+func (c *Client) fetchMany(verb string, code int, specs []string, handle FetchFunc) error {
+	cmds := make([]string, len(specs))
+	for i, spec := range specs {
+		arg, err := articleSpecifier(spec)
+		if err != nil {
+			return err
+		}
+		cmds[i] = verb
+		if arg != "" {
+			cmds[i] += " " + arg
+		}
+		if err := checkArgument(verb, cmds[i]); err != nil {
+			return err
+		}
+	}
+	if err := c.claim(verb); err != nil {
+		return err
+	}
+	defer c.done()
+	c.lend()
+	window := c.PipelineWindow
+	if window <= 0 {
+		window = defaultPipelineWindow
+	}
+
+	// The commands are short, and at most window of them are unanswered,
+	// so sending one never waits on the server reading, which could
+	// deadlock with the server waiting on a response being read.
+	sent := 0
+	send := func() error {
+		if sent == len(cmds) {
+			return nil
+		}
+		sent++
+		return c.conn.PrintfLine("%s", cmds[sent-1])
+	}
+	for sent < window && sent < len(cmds) {
+		if err := send(); err != nil {
+			return err
+		}
+	}
+	var herr error
+	for i := 0; i < sent; i++ {
+		_, msg, err := c.readCodeLine(code)
+		if _, ok := err.(*textproto.Error); !ok && err != nil {
+			return err
+		}
+		// Keep the window full while the response is handled.
+		if herr == nil {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if herr == nil {
+				herr = handle(specs[i], 0, "", nil, articleError(cmds[i], err))
+			}
+			continue
+		}
+		dr := c.conn.DotReader()
+		if herr == nil {
+			n, id, err := parseArticleLine(msg)
+			if err != nil {
+				herr = handle(specs[i], 0, "", nil, err)
+			} else {
+				herr = handle(specs[i], n, id, dr, nil)
+			}
+		}
+		if _, err := io.Copy(ioutil.Discard, dr); err != nil {
+			return err
+		}
+	}
+	return herr
+}
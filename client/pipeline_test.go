@@ -0,0 +1,153 @@
+package nntpclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// latencyServer starts a server that answers BODY and ARTICLE each after
+// delay, as if it were far away, but reads commands as they arrive, so
+// that pipelined ones are answered a round trip apart.  Article n
+// exists for n up to 100; its body is "body n".
+func latencyServer(t *testing.T, delay time.Duration) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		type command struct {
+			line string
+			at   time.Time
+		}
+		cmds := make(chan command, 1000)
+		go func() {
+			defer close(cmds)
+			r := textproto.NewReader(bufio.NewReader(nc))
+			for {
+				line, err := r.ReadLine()
+				if err != nil {
+					return
+				}
+				cmds <- command{line, time.Now()}
+			}
+		}()
+		w := textproto.NewConn(nc)
+		w.PrintfLine("200 ready")
+		for cmd := range cmds {
+			time.Sleep(time.Until(cmd.at.Add(delay)))
+			verb, arg := nextField(cmd.line)
+			var n int
+			fmt.Sscan(arg, &n)
+			switch {
+			case verb == "QUIT":
+				w.PrintfLine("205 bye")
+				return
+			case n < 1 || n > 100:
+				w.PrintfLine("423 No article with that number")
+			case verb == "BODY":
+				writeLines(w, 222, fmt.Sprintf("%d <%d@x> body", n, n), fmt.Sprintf("body %d", n))
+			case verb == "ARTICLE":
+				writeLines(w, 220, fmt.Sprintf("%d <%d@x> article", n, n),
+					fmt.Sprintf("Message-ID: <%d@x>", n), "", fmt.Sprintf("body %d", n))
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestFetchBodies(t *testing.T) {
+	const delay = 10 * time.Millisecond
+	c, err := New("tcp", latencyServer(t, delay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var specs []string
+	for i := 1; i <= 40; i++ {
+		specs = append(specs, fmt.Sprint(i))
+	}
+	// Missing articles, paired with their specs.
+	specs[4], specs[30] = "200", "300"
+
+	var got []string
+	start := time.Now()
+	err = c.FetchBodies(specs, func(spec string, n int64, msgid string, body io.Reader, err error) error {
+		if err != nil {
+			if !errors.Is(err, ErrNoSuchArticle) {
+				t.Errorf("%s: %v", spec, err)
+			}
+			got = append(got, spec+" missing")
+			return nil
+		}
+		b, _ := ioutil.ReadAll(body)
+		if want := "body " + spec + "\n"; string(b) != want || fmt.Sprint(n) != spec || msgid != "<"+spec+"@x>" {
+			t.Errorf("%s: %d %s %q", spec, n, msgid, b)
+		}
+		got = append(got, spec)
+		return nil
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join(specs, "|")
+	want = strings.Replace(want, "200", "200 missing", 1)
+	want = strings.Replace(want, "300", "300 missing", 1)
+	if strings.Join(got, "|") != want {
+		t.Errorf("Handled\n%s\nwant\n%s", strings.Join(got, "|"), want)
+	}
+	// One at a time, 40 round trips would take 400ms.
+	if elapsed > time.Duration(len(specs))*delay/2 {
+		t.Errorf("Took %v", elapsed)
+	}
+	if _, err := c.Date(); errors.Is(err, ErrBusy) {
+		t.Errorf("Connection left busy: %v", err)
+	}
+}
+
+func TestFetchArticlesStop(t *testing.T) {
+	c, err := New("tcp", latencyServer(t, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.PipelineWindow = 4
+
+	stop := errors.New("stop")
+	var handled []string
+	err = c.FetchArticles([]string{"1", "2", "3", "4", "5", "6", "7", "8"},
+		func(spec string, n int64, msgid string, body io.Reader, err error) error {
+			handled = append(handled, spec)
+			if spec == "2" {
+				return stop
+			}
+			return nil
+		})
+	if err != stop {
+		t.Errorf("Fetch: %v", err)
+	}
+	if got := strings.Join(handled, " "); got != "1 2" {
+		t.Errorf("Handled %s", got)
+	}
+	// The responses already asked for were skipped.
+	if _, _, r, err := c.Body("9"); err != nil {
+		t.Errorf("Body after stopping: %v", err)
+	} else if b, _ := ioutil.ReadAll(r); string(b) != "body 9\n" {
+		t.Errorf("Body after stopping: %q", b)
+	}
+}
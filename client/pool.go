@@ -0,0 +1,289 @@
+package nntpclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by Get once the pool has been closed.
+var ErrPoolClosed = errors.New("pool closed")
+
+// A Pool shares up to a fixed number of connections to one server
+// among goroutines.  Connections are dialed with Dial, and so
+// authenticated and set up by its options, as they're needed.
+type Pool struct {
+	addr string
+	opts []Option
+	// slots holds a token for each connection handed out.
+	slots chan struct{}
+
+	// MaxIdleTime, if set, closes connections that have been idle for
+	// longer instead of handing them out.
+	MaxIdleTime time.Duration
+	// KeepAlive, if set, sends DATE on connections that have been idle
+	// for as long, before the server or a NAT times them out.  Those
+	// that don't answer are closed.  Set it before the first Put.
+	KeepAlive time.Duration
+	// Tracer, if set, records a span for each Get and Put.  The health
+	// check and dial a Get makes are started in its span's context.
+	Tracer Tracer
+
+	mu    sync.Mutex
+	idle  []idleClient
+	inUse int
+	// pinging counts idle connections the keepalive has out.
+	pinging int
+	closed  bool
+	// stop ends the keepalive goroutine.
+	stop chan struct{}
+}
+
+type idleClient struct {
+	c     *Client
+	since time.Time
+	// pinged is when the keepalive last checked c.
+	pinged time.Time
+}
+
+// PoolStats counts a pool's connections.
+type PoolStats struct {
+	// Open is Idle plus InUse.
+	Open  int
+	Idle  int
+	InUse int
+}
+
+// NewPool makes a pool of up to maxConns connections to addr, dialed
+// with opts.
+func NewPool(addr string, maxConns int, opts ...Option) *Pool {
+	if maxConns < 1 {
+		maxConns = 1
+	}
+	return &Pool{addr: addr, opts: opts, slots: make(chan struct{}, maxConns)}
+}
+
+// Get returns a connection, idle or newly dialed, which must be given
+// back with Put.  An idle connection is checked with DATE first, and
+// closed for another if it doesn't answer.  If all the pool's
+// connections are in use, Get waits for one to be put back, or for ctx
+// to be done.
+func (p *Pool) Get(ctx context.Context) (*Client, error) {
+	var end EndFunc
+	if p.Tracer != nil {
+		ctx, end = p.Tracer.StartSpan(ctx, "nntp.pool.get", Attr{AttrServer, p.addr})
+	}
+	c, dialed, err := p.get(ctx)
+	if end != nil {
+		end(err, Attr{AttrDialed, dialed})
+	}
+	return c, err
+}
+
+// get is Get without the span.  It reports whether the connection was
+// newly dialed.
+func (p *Pool) get(ctx context.Context) (*Client, bool, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, false, ErrPoolClosed
+	}
+	p.inUse++
+	p.mu.Unlock()
+	for {
+		c := p.takeIdle()
+		if c == nil {
+			break
+		}
+		if p.healthy(ctx, c) {
+			return c, false, nil
+		}
+		c.CloseNow()
+	}
+	c, err := DialContext(ctx, p.addr, p.opts...)
+	if err != nil {
+		p.release()
+		return nil, true, err
+	}
+	return c, true, nil
+}
+
+// takeIdle returns the most recently used idle connection, or nil if
+// there are none.  Connections idle too long are closed.
+func (p *Pool) takeIdle() *Client {
+	p.mu.Lock()
+	var expired []*Client
+	if p.MaxIdleTime > 0 {
+		cutoff := time.Now().Add(-p.MaxIdleTime)
+		keep := p.idle[:0]
+		for _, ic := range p.idle {
+			if ic.since.Before(cutoff) {
+				expired = append(expired, ic.c)
+			} else {
+				keep = append(keep, ic)
+			}
+		}
+		p.idle = keep
+	}
+	var c *Client
+	if n := len(p.idle); n > 0 {
+		c = p.idle[n-1].c
+		p.idle = p.idle[:n-1]
+	}
+	p.mu.Unlock()
+	for _, ec := range expired {
+		ec.Close()
+	}
+	return c
+}
+
+// healthy checks an idle connection with DATE.  A server that doesn't
+// know DATE still answers, which is all that matters.
+func (p *Pool) healthy(ctx context.Context, c *Client) bool {
+	c.SetContext(ctx)
+	_, err := c.Date()
+	c.SetContext(nil)
+	if err == nil {
+		return true
+	}
+	code, ok := ResponseCode(err)
+	return ok && code != 400
+}
+
+// release gives up the slot of a connection that's no longer in use.
+func (p *Pool) release() {
+	p.mu.Lock()
+	p.inUse--
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// Put gives a connection back to the pool.  Close a connection whose
+// state is in doubt, after an error, with CloseNow before putting it
+// back; the pool then dials a new one when it's needed.  One that's
+// still busy, with a data block unread, is closed too.
+func (p *Pool) Put(c *Client) {
+	if p.Tracer != nil {
+		_, end := p.Tracer.StartSpan(c.Context(), "nntp.pool.put", Attr{AttrServer, p.addr})
+		defer end(nil)
+	}
+	c.mu.Lock()
+	busy := c.busy != ""
+	c.mu.Unlock()
+	if busy {
+		c.CloseNow()
+	}
+	p.mu.Lock()
+	if p.closed || c.isClosed() {
+		p.mu.Unlock()
+		c.Close()
+		p.release()
+		return
+	}
+	c.SetContext(nil)
+	p.idle = append(p.idle, idleClient{c: c, since: time.Now()})
+	if p.KeepAlive > 0 && p.stop == nil {
+		p.stop = make(chan struct{})
+		go p.keepAlive(p.stop)
+	}
+	p.mu.Unlock()
+	p.release()
+}
+
+// keepAlive pings idle connections until stop is closed.
+func (p *Pool) keepAlive(stop chan struct{}) {
+	t := time.NewTicker(p.KeepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		for p.pingIdle() {
+		}
+	}
+}
+
+// pingIdle checks an idle connection that has been quiet for KeepAlive,
+// holding a slot meanwhile.  It reports whether there was one.
+func (p *Pool) pingIdle() bool {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return false
+	}
+	p.mu.Lock()
+	i, cutoff := -1, time.Now().Add(-p.KeepAlive)
+	if !p.closed {
+		for j, ic := range p.idle {
+			if ic.since.Before(cutoff) && ic.pinged.Before(cutoff) {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 {
+		p.mu.Unlock()
+		<-p.slots
+		return false
+	}
+	ic := p.idle[i]
+	p.idle = append(p.idle[:i], p.idle[i+1:]...)
+	p.pinging++
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.KeepAlive)
+	dead := !p.healthy(ctx, ic.c)
+	cancel()
+	p.mu.Lock()
+	if !dead && !p.closed {
+		ic.pinged = time.Now()
+		p.idle = append(p.idle, ic)
+	} else {
+		dead = true
+	}
+	p.pinging--
+	p.mu.Unlock()
+	if dead {
+		ic.c.CloseNow()
+	}
+	<-p.slots
+	return true
+}
+
+// Stats counts the pool's connections.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	idle := len(p.idle) + p.pinging
+	return PoolStats{Open: idle + p.inUse, Idle: idle, InUse: p.inUse}
+}
+
+// Close closes the idle connections, and the ones in use as they're put
+// back.  Get fails from then on.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	p.mu.Unlock()
+	var first error
+	for _, ic := range idle {
+		if err := ic.c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
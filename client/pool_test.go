@@ -0,0 +1,255 @@
+package nntpclient
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// poolServer starts a server that answers DATE, with 400 once sick is
+// set.
+func poolServer(t *testing.T, sick *int32) *recordingDialer {
+	return &recordingDialer{addr: fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		if atomic.LoadInt32(sick) != 0 {
+			c.PrintfLine("400 Service temporarily unavailable")
+			return
+		}
+		c.PrintfLine("111 20240102030405")
+	})}
+}
+
+func TestPoolReuse(t *testing.T) {
+	var sick int32
+	rec := poolServer(t, &sick)
+	p := NewPool("news.example.com:119", 2, WithDialer(rec))
+	defer p.Close()
+	ctx := context.Background()
+
+	a, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Stats(), (PoolStats{Open: 2, InUse: 2}); got != want {
+		t.Errorf("Stats %+v, want %+v", got, want)
+	}
+	p.Put(a)
+	p.Put(b)
+	if got, want := p.Stats(), (PoolStats{Open: 2, Idle: 2}); got != want {
+		t.Errorf("Stats %+v, want %+v", got, want)
+	}
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != b {
+		t.Errorf("Got a connection other than the last one put back")
+	}
+	p.Put(c)
+	if len(rec.got) != 2 {
+		t.Errorf("Dialed %d times, want 2", len(rec.got))
+	}
+}
+
+func TestPoolExhausted(t *testing.T) {
+	var sick int32
+	rec := poolServer(t, &sick)
+	p := NewPool("news.example.com:119", 1, WithDialer(rec))
+	defer p.Close()
+
+	a, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get with none free: %v", err)
+	}
+
+	got := make(chan *Client)
+	go func() {
+		c, err := p.Get(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Put(a)
+	if c := <-got; c != a {
+		t.Errorf("Waiting Get didn't get the connection put back")
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	var sick int32
+	rec := poolServer(t, &sick)
+	p := NewPool("news.example.com:119", 1, WithDialer(rec))
+	defer p.Close()
+	ctx := context.Background()
+
+	a, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(a)
+	atomic.StoreInt32(&sick, 1)
+	b, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == a || !a.closed {
+		t.Errorf("Unhealthy connection handed out")
+	}
+	atomic.StoreInt32(&sick, 0)
+
+	// A connection that was closed while in use isn't kept.
+	b.CloseNow()
+	p.Put(b)
+	if got, want := p.Stats(), (PoolStats{}); got != want {
+		t.Errorf("Stats %+v, want %+v", got, want)
+	}
+	if len(rec.got) != 2 {
+		t.Errorf("Dialed %d times, want 2", len(rec.got))
+	}
+}
+
+func TestPoolMaxIdleTime(t *testing.T) {
+	var sick int32
+	rec := poolServer(t, &sick)
+	p := NewPool("news.example.com:119", 1, WithDialer(rec))
+	p.MaxIdleTime = 10 * time.Millisecond
+	defer p.Close()
+
+	a, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(a)
+	time.Sleep(20 * time.Millisecond)
+	b, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Put(b)
+	if b == a || !a.closed {
+		t.Errorf("Expired connection handed out")
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	var sick int32
+	rec := poolServer(t, &sick)
+	p := NewPool("news.example.com:119", 2, WithDialer(rec))
+	a, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(a)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.closed || b.closed {
+		t.Errorf("Closed idle %v, in use %v", a.closed, b.closed)
+	}
+	p.Put(b)
+	if !b.closed {
+		t.Errorf("Connection put back after Close left open")
+	}
+	if _, err := p.Get(context.Background()); err != ErrPoolClosed {
+		t.Errorf("Get after Close: %v", err)
+	}
+	if got, want := p.Stats(), (PoolStats{}); got != want {
+		t.Errorf("Stats %+v, want %+v", got, want)
+	}
+}
+
+func TestPoolKeepAlive(t *testing.T) {
+	var sick, dates int32
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		atomic.AddInt32(&dates, 1)
+		if atomic.LoadInt32(&sick) != 0 {
+			c.PrintfLine("400 Idle too long")
+			return
+		}
+		c.PrintfLine("111 20240102030405")
+	})
+	p := NewPool(addr, 1)
+	p.KeepAlive = 10 * time.Millisecond
+	defer p.Close()
+
+	a, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(a)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&dates) == 0 {
+		t.Errorf("No keepalive sent")
+	}
+	if got, want := p.Stats(), (PoolStats{Open: 1, Idle: 1}); got != want {
+		t.Errorf("Stats %+v, want %+v", got, want)
+	}
+
+	atomic.StoreInt32(&sick, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Open != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := p.Stats(); got.Open != 0 {
+		t.Errorf("Dead connection kept: %+v", got)
+	}
+}
+
+func TestPoolTracing(t *testing.T) {
+	var sick int32
+	rec := poolServer(t, &sick)
+	tracer := &recordingTracer{}
+	p := NewPool("news.example.com:119", 1, WithDialer(rec))
+	p.Tracer = tracer
+	defer p.Close()
+	ctx, _ := tracer.StartSpan(context.Background(), "job")
+
+	for i := 0; i < 2; i++ {
+		c, err := p.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetContext(ctx)
+		p.Put(c)
+	}
+
+	want := []struct {
+		name   string
+		dialed interface{}
+	}{
+		{"job", nil},
+		{"nntp.pool.get", true},
+		{"nntp.pool.put", nil},
+		{"nntp.pool.get", false},
+		{"nntp.pool.put", nil},
+	}
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if len(tracer.spans) != len(want) {
+		t.Fatalf("Got %d spans, want %d", len(tracer.spans), len(want))
+	}
+	for i, w := range want[1:] {
+		s := tracer.spans[i+1]
+		if s.name != w.name || s.parent != "job" || !s.ended || s.err != nil ||
+			s.attrs[AttrServer] != "news.example.com:119" || s.attrs[AttrDialed] != w.dialed {
+			t.Errorf("Span %d: %+v, want %s", i+1, s, w.name)
+		}
+	}
+}
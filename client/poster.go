@@ -0,0 +1,244 @@
+package nntpclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+	"github.com/yannik995/go-nntp/encoding"
+	"github.com/yannik995/go-nntp/nzb"
+)
+
+// DefaultPartSize is the amount of file data posted per article.
+const DefaultPartSize = 750000
+
+// An ArticlePoster posts serialized articles.  Client implements it.
+type ArticlePoster interface {
+	Post(r io.Reader) error
+}
+
+// A Poster splits files into parts, yEnc encodes them and posts each
+// part as an article.
+type Poster struct {
+	// Conns to post with.  Parts are posted concurrently, one at a time
+	// per connection.
+	Conns []ArticlePoster
+	// From header for all parts.
+	From string
+	// Groups the parts are posted to.
+	Groups []string
+	// Domain for generated message-ids.  Defaults to the domain of the
+	// From address.
+	Domain string
+	// PartSize is the amount of file data per part, DefaultPartSize if
+	// zero.
+	PartSize int64
+	// LineLength of the yEnc encoding, the encoding default if zero.
+	LineLength int
+	// Retries of a failed part before giving up on it.
+	Retries int
+	// Comment is put in front of the generated subjects.
+	Comment string
+}
+
+// PartResult describes the posting of one part.
+type PartResult struct {
+	Number    int
+	MessageID string
+	// Bytes is the size of the posted article.
+	Bytes int64
+	// Attempts made to post the part.
+	Attempts int
+	// Err is the error of the last attempt, nil if posting succeeded.
+	Err error
+}
+
+// A PostReport describes the posting of a file.
+type PostReport struct {
+	Name    string
+	Subject string
+	Poster  string
+	Groups  []string
+	Date    time.Time
+	Parts   []PartResult
+}
+
+// Failed returns the parts that could not be posted.
+func (r *PostReport) Failed() []PartResult {
+	var rv []PartResult
+	for _, p := range r.Parts {
+		if p.Err != nil {
+			rv = append(rv, p)
+		}
+	}
+	return rv
+}
+
+// NZB describes the posted parts as an NZB file entry.
+func (r *PostReport) NZB() nzb.File {
+	f := nzb.File{
+		Poster:  r.Poster,
+		Date:    r.Date,
+		Subject: r.Subject,
+		Groups:  r.Groups,
+	}
+	for _, p := range r.Parts {
+		if p.Err != nil {
+			continue
+		}
+		f.Segments = append(f.Segments, nzb.Segment{
+			Bytes:     p.Bytes,
+			Number:    p.Number,
+			MessageID: strings.Trim(p.MessageID, "<>"),
+		})
+	}
+	return f
+}
+
+// PostFile posts the file at path.
+func (p *Poster) PostFile(path string) (*PostReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return p.Post(filepath.Base(path), f, st.Size())
+}
+
+// Post posts size bytes from r as a file called name.
+//
+// An error is only returned if posting couldn't start; failures of
+// individual parts are recorded in the report.
+func (p *Poster) Post(name string, r io.ReaderAt, size int64) (*PostReport, error) {
+	if len(p.Conns) == 0 {
+		return nil, errors.New("no connections to post with")
+	}
+	if len(p.Groups) == 0 {
+		return nil, errors.New("no groups to post to")
+	}
+	domain := p.Domain
+	if domain == "" {
+		addr, err := mail.ParseAddress(p.From)
+		if err != nil {
+			return nil, fmt.Errorf("parsing From: %v", err)
+		}
+		domain = addr.Address[strings.LastIndexByte(addr.Address, '@')+1:]
+	}
+	partSize := p.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+
+	crc := crc32.NewIEEE()
+	if _, err := io.Copy(crc, io.NewSectionReader(r, 0, size)); err != nil {
+		return nil, err
+	}
+
+	total := int((size + partSize - 1) / partSize)
+	if total == 0 {
+		total = 1
+	}
+	report := &PostReport{
+		Name:    name,
+		Subject: p.subject(name, 1, total),
+		Poster:  p.From,
+		Groups:  p.Groups,
+		Date:    time.Now().UTC(),
+		Parts:   make([]PartResult, total),
+	}
+
+	parts := make(chan int)
+	var wg sync.WaitGroup
+	for _, conn := range p.Conns {
+		wg.Add(1)
+		go func(conn ArticlePoster) {
+			defer wg.Done()
+			for i := range parts {
+				report.Parts[i] = p.postPart(conn, r, name, domain, i+1,
+					total, size, partSize, crc.Sum32())
+			}
+		}(conn)
+	}
+	for i := 0; i < total; i++ {
+		parts <- i
+	}
+	close(parts)
+	wg.Wait()
+	return report, nil
+}
+
+func (p *Poster) subject(name string, part, total int) string {
+	width := len(fmt.Sprint(total))
+	s := fmt.Sprintf("\"%s\" yEnc (%0*d/%d)", name, width, part, total)
+	if p.Comment != "" {
+		s = p.Comment + " - " + s
+	}
+	return s
+}
+
+func (p *Poster) postPart(conn ArticlePoster, r io.ReaderAt, name, domain string,
+	part, total int, size, partSize int64, fileCRC uint32) PartResult {
+
+	begin := int64(part-1) * partSize
+	end := begin + partSize
+	if end > size {
+		end = size
+	}
+	m := &nntpencoding.Meta{
+		Name:      name,
+		Size:      size,
+		Part:      part,
+		Total:     total,
+		Begin:     begin + 1,
+		End:       end,
+		FileCRC32: fileCRC,
+	}
+	if total == 1 {
+		m.Part, m.Total = 0, 0
+	}
+	var body bytes.Buffer
+	y := nntpencoding.YEnc{LineLength: p.LineLength}
+	res := PartResult{Number: part}
+	if err := y.Encode(&body, io.NewSectionReader(r, begin, end-begin), m); err != nil {
+		res.Err = err
+		return res
+	}
+
+	for res.Attempts <= p.Retries {
+		res.Attempts++
+		// A fresh id per attempt, since a failed attempt may still
+		// have reached the server.
+		res.MessageID = nntp.GenerateMessageID(domain)
+		a := &nntp.Article{
+			Header: textproto.MIMEHeader{
+				"From":       {p.From},
+				"Newsgroups": {strings.Join(p.Groups, ",")},
+				"Subject":    {p.subject(name, part, total)},
+				"Message-Id": {res.MessageID},
+			},
+			Body: bytes.NewReader(body.Bytes()),
+		}
+		var article bytes.Buffer
+		writeArticle(&article, a)
+		res.Bytes = int64(article.Len())
+		res.Err = conn.Post(&article)
+		if res.Err == nil {
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,101 @@
+package nntpclient
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yannik995/go-nntp/encoding"
+)
+
+// recordingPoster keeps posted articles, failing the first attempt at
+// every article whose subject contains failOnce.
+type recordingPoster struct {
+	mu       sync.Mutex
+	failOnce string
+	failed   map[string]bool
+	articles map[string][]byte
+}
+
+func (rp *recordingPoster) Post(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	h, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	if err != nil {
+		return err
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	subj := h.Get("Subject")
+	if rp.failOnce != "" && strings.Contains(subj, rp.failOnce) && !rp.failed[subj] {
+		rp.failed[subj] = true
+		return errors.New("441 posting failed")
+	}
+	rp.articles[h.Get("Message-Id")] = data
+	return nil
+}
+
+func TestPoster(t *testing.T) {
+	data := make([]byte, 2500)
+	rand.New(rand.NewSource(1)).Read(data)
+	rp := &recordingPoster{failOnce: "(2/3)", failed: map[string]bool{},
+		articles: map[string][]byte{}}
+	p := &Poster{
+		Conns:    []ArticlePoster{rp, rp},
+		From:     "Poster <poster@example.com>",
+		Groups:   []string{"alt.binaries.test"},
+		PartSize: 1000,
+		Retries:  1,
+	}
+	report, err := p.Post("file.bin", bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Failed()) != 0 || len(report.Parts) != 3 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if report.Parts[1].Attempts != 2 {
+		t.Errorf("Part 2 took %d attempts, wanted 2", report.Parts[1].Attempts)
+	}
+
+	out := make([]byte, len(data))
+	a, _ := nntpencoding.NewAssembler(writerAt(out), int64(len(data)))
+	for _, part := range report.Parts {
+		article := rp.articles[part.MessageID]
+		if !strings.HasSuffix(part.MessageID, "@example.com>") || article == nil {
+			t.Fatalf("Part %d has message-id %q", part.Number, part.MessageID)
+		}
+		if !bytes.Contains(article, []byte(`Subject: "file.bin" yEnc (`)) {
+			t.Errorf("Part %d has unexpected subject", part.Number)
+		}
+		var dec bytes.Buffer
+		res, err := nntpencoding.YEnc{}.Decode(&dec, bytes.NewReader(article))
+		if err != nil {
+			t.Fatalf("Part %d: %v", part.Number, err)
+		}
+		if err := a.Add(&res.Meta, dec.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !a.Complete() || !bytes.Equal(out, data) {
+		t.Errorf("Posted parts don't reassemble into the file")
+	}
+	if f := report.NZB(); len(f.Segments) != 3 || f.Subject != `"file.bin" yEnc (1/3)` {
+		t.Errorf("Unexpected NZB entry %+v", f)
+	}
+}
+
+type writerAt []byte
+
+func (w writerAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(w[off:], p), nil
+}
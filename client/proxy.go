@@ -0,0 +1,210 @@
+package nntpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// A ProxyError is a failure to tunnel through an HTTP proxy, as opposed
+// to an error from the NNTP server.
+type ProxyError struct {
+	// Proxy is the proxy's address.
+	Proxy string
+	// StatusCode is the proxy's HTTP status, or 0 if it didn't give one.
+	StatusCode int
+	Err        error
+}
+
+func (e *ProxyError) Error() string {
+	return "proxy " + e.Proxy + ": " + e.Err.Error()
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// ProxyFromEnvironment returns the proxy for addr configured by the
+// HTTPS_PROXY and NO_PROXY environment variables, or nil for none.
+func ProxyFromEnvironment(addr string) (*url.URL, error) {
+	return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+}
+
+// DialProxy connects to addr through an HTTP proxy with CONNECT.  User
+// information in the proxy URL is sent as basic authentication, and an
+// https proxy URL is reached with TLS.
+//
+// The connection is ready for the NNTP greeting, or the TLS handshake;
+// NewConn or tls.Client take it from there.  ServerConfig's Dial also
+// checks that the proxy doesn't add anything of its own to the tunnel.
+func DialProxy(addr string, proxy *url.URL) (net.Conn, error) {
+	var d Dialer
+	return d.DialProxyContext(context.Background(), addr, proxy)
+}
+
+// DialProxyContext is DialProxy, connecting to the proxy with d's
+// NetDialer, and giving up when ctx is done or d's Timeout has passed
+// before the proxy has answered CONNECT.
+func (d *Dialer) DialProxyContext(ctx context.Context, addr string, proxy *url.URL) (net.Conn, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+	fail := func(code int, err error) error {
+		return &ProxyError{Proxy: proxyAddr, StatusCode: code, Err: err}
+	}
+
+	if proxy.Scheme != "http" && proxy.Scheme != "" && proxy.Scheme != "https" {
+		return nil, fail(0, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme))
+	}
+	var nd ContextDialer = &net.Dialer{}
+	if d.NetDialer != nil {
+		nd = d.NetDialer
+	}
+	raw, err := nd.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fail(0, err)
+	}
+	release := bound(ctx, raw)
+	conn, err := connectProxy(raw, addr, proxy)
+	if cerr := release(); cerr != nil {
+		err = fail(0, cerr)
+	}
+	if err != nil {
+		raw.Close()
+		if perr, ok := err.(*ProxyError); ok {
+			perr.Proxy = proxyAddr
+		}
+		return nil, err
+	}
+	conn.proxy = proxyAddr
+	return conn, nil
+}
+
+// connectProxy asks the proxy connected with raw for a tunnel to addr,
+// with TLS first for an https proxy.  The ProxyError it returns lacks
+// the proxy's address.
+func connectProxy(raw net.Conn, addr string, proxy *url.URL) (*bufferedConn, error) {
+	fail := func(code int, err error) error {
+		return &ProxyError{StatusCode: code, Err: err}
+	}
+	conn := raw
+	if proxy.Scheme == "https" {
+		tc := tls.Client(raw, &tls.Config{ServerName: proxy.Hostname()})
+		if err := tc.Handshake(); err != nil {
+			return nil, fail(0, err)
+		}
+		conn = tc
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := proxy.User; u != nil {
+		pass, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+
+			base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+pass)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fail(0, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fail(0, fmt.Errorf("reading CONNECT response: %v", err))
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return nil, fail(resp.StatusCode, fmt.Errorf("authentication failed: %s", resp.Status))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fail(resp.StatusCode, fmt.Errorf("CONNECT %s refused: %s", addr, resp.Status))
+	}
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bound makes ctx's deadline and cancellation apply to conn's reads and
+// writes until the function it returns is called, which clears the
+// deadline and returns ctx's error if it's done.
+func bound(ctx context.Context, conn net.Conn) func() error {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(longAgo)
+		case <-stop:
+		}
+	}()
+	return func() error {
+		close(stop)
+		<-done
+		conn.SetDeadline(time.Time{})
+		return ctx.Err()
+	}
+}
+
+// checkTunnel makes sure the proxy hasn't put anything of its own into
+// the tunnel.  Over TLS nothing should arrive before the handshake;
+// otherwise NNTP servers speak first, so the next thing should be a
+// response code.
+func (c *bufferedConn) checkTunnel(useTLS bool) error {
+	var injected []byte
+	if useTLS {
+		injected, _ = c.r.Peek(c.r.Buffered())
+	} else {
+		start, err := c.r.Peek(4)
+		if err != nil {
+			return &ProxyError{Proxy: c.proxy, StatusCode: http.StatusOK,
+				Err: fmt.Errorf("reading through tunnel: %v", err)}
+		}
+		if !isResponseStart(start) {
+			injected = start
+		}
+	}
+	if len(injected) > 0 {
+		return &ProxyError{Proxy: c.proxy, StatusCode: http.StatusOK,
+			Err: fmt.Errorf("proxy injected %q into the tunnel", injected)}
+	}
+	return nil
+}
+
+// isResponseStart reports whether b starts an NNTP response line.
+func isResponseStart(b []byte) bool {
+	return len(b) == 4 && b[0] >= '1' && b[0] <= '5' &&
+		b[1] >= '0' && b[1] <= '9' && b[2] >= '0' && b[2] <= '9' &&
+		(b[3] == ' ' || b[3] == '\r' || b[3] == '-')
+}
+
+// bufferedConn is a connection whose first bytes were already read into
+// a bufio.Reader.
+type bufferedConn struct {
+	net.Conn
+	r     *bufio.Reader
+	proxy string
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,145 @@
+package nntpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProxy runs a CONNECT proxy.  respond writes the response to a
+// CONNECT request and reports whether to tunnel.
+func fakeProxy(t *testing.T, respond func(w io.Writer, req *http.Request) bool) *url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				req, err := http.ReadRequest(bufio.NewReader(nc))
+				if err != nil || req.Method != "CONNECT" || !respond(nc, req) {
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, nc)
+				io.Copy(nc, target)
+			}()
+		}
+	}()
+	return &url.URL{Scheme: "http", Host: l.Addr().String()}
+}
+
+func TestProxy(t *testing.T) {
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("500 what?")
+	})
+	proxy := fakeProxy(t, func(w io.Writer, req *http.Request) bool {
+		switch req.Header.Get("Proxy-Authorization") {
+		case "":
+			fmt.Fprintf(w, "HTTP/1.1 403 Forbidden\r\n\r\n")
+			return false
+		case "Basic dXNlcjpzZWNyZXQ=":
+		default:
+			fmt.Fprintf(w, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return false
+		}
+		if req.Host != addr {
+			fmt.Fprintf(w, "HTTP/1.1 400 Bad Request\r\n\r\n")
+			return false
+		}
+		fmt.Fprintf(w, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return true
+	})
+
+	withUser := func(u *url.Userinfo) *url.URL {
+		rv := *proxy
+		rv.User = u
+		return &rv
+	}
+	c, err := ServerConfig{Addr: addr, Proxy: withUser(url.UserPassword("user", "secret"))}.Dial()
+	if err != nil {
+		t.Fatalf("Dialing through the proxy: %v", err)
+	}
+	if c.Banner != "fake server ready" || c.host != "127.0.0.1" {
+		t.Errorf("Banner %q, host %q", c.Banner, c.host)
+	}
+	c.Close()
+
+	tests := []struct {
+		proxy *url.URL
+		code  int
+		msg   string
+	}{
+		{proxy, 403, "refused"},
+		{withUser(url.UserPassword("user", "wrong")), 407, "authentication failed"},
+	}
+	for _, test := range tests {
+		_, err := ServerConfig{Addr: addr, Proxy: test.proxy}.Dial()
+		var perr *ProxyError
+		if !errors.As(err, &perr) || perr.StatusCode != test.code || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("Got %v, want %d %s", err, test.code, test.msg)
+		}
+	}
+}
+
+func TestProxyInjection(t *testing.T) {
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {})
+	proxy := fakeProxy(t, func(w io.Writer, req *http.Request) bool {
+		fmt.Fprintf(w, "HTTP/1.1 200 OK\r\n\r\nX-Injected: yes\r\n")
+		return true
+	})
+	_, err := ServerConfig{Addr: addr, Proxy: proxy}.Dial()
+	var perr *ProxyError
+	if !errors.As(err, &perr) || !strings.Contains(err.Error(), "injected") {
+		t.Errorf("Got %v", err)
+	}
+}
+
+func TestProxyTimeout(t *testing.T) {
+	// A proxy that never answers CONNECT, reached through NetDialer.
+	stall := make(chan struct{})
+	t.Cleanup(func() { close(stall) })
+	proxy := fakeProxy(t, func(w io.Writer, req *http.Request) bool {
+		<-stall
+		return false
+	})
+	rec := &recordingDialer{addr: proxy.Host}
+	proxy.Host = "proxy.example.com:3128"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ServerConfig{Addr: "news.example.com:119", Proxy: proxy, NetDialer: rec}.DialContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DialContext: %v", err)
+	}
+	d := Dialer{Timeout: 50 * time.Millisecond, NetDialer: rec}
+	if _, err := d.DialProxyContext(context.Background(), "news.example.com:119", proxy); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DialProxyContext: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Took %v", time.Since(start))
+	}
+	if len(rec.got) != 2 || rec.got[0] != "tcp proxy.example.com:3128" {
+		t.Errorf("Dialed %q", rec.got)
+	}
+}
@@ -0,0 +1,345 @@
+package nntpclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	nntpencoding "github.com/yannik995/go-nntp/encoding"
+	"github.com/yannik995/go-nntp/nzb"
+)
+
+// ErrJobCancelled is recorded for the segments of a cancelled job that
+// were never fetched.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// JobState is where a Job is in its life.
+type JobState int
+
+const (
+	// JobQueued jobs are waiting for or being fetched by workers.
+	JobQueued JobState = iota
+	// JobPaused jobs have no new segments fetched until resumed.
+	JobPaused
+	// JobCancelled jobs have no new segments fetched ever again.
+	JobCancelled
+	// JobDone jobs have had every segment tried.
+	JobDone
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobQueued:
+		return "queued"
+	case JobPaused:
+		return "paused"
+	case JobCancelled:
+		return "cancelled"
+	case JobDone:
+		return "done"
+	}
+	return "unknown"
+}
+
+// rateSamples is how many recent segments a job's throughput is
+// estimated from.
+const rateSamples = 16
+
+// JobProgress is a snapshot of how far a Job has got.
+type JobProgress struct {
+	State JobState
+	// Done and Total count segments.  Done includes segments that
+	// couldn't be fetched.
+	Done, Total int
+	// Bytes and TotalBytes are the NZB sizes of the segments done and
+	// of all of them.
+	Bytes, TotalBytes int64
+	// Rate is recent throughput in bytes per second, or 0 if it isn't
+	// known yet.
+	Rate float64
+	// ETA is the time left at Rate, or 0 if it isn't known.
+	ETA time.Duration
+}
+
+// A DownloadQueue runs Fetcher jobs by priority.  Its workers always
+// fetch the next segment of the highest priority runnable job, so a
+// small urgent job overtakes a big one already running.  Jobs of equal
+// priority run in the order they were added.
+type DownloadQueue struct {
+	fetcher *Fetcher
+	mu      sync.Mutex
+	cond    *sync.Cond
+	// jobs that aren't finished, in the order they were added.
+	jobs   []*Job
+	nextID int
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// A Job is a set of segments queued for download.
+type Job struct {
+	// ID numbers jobs in the order they were added, from 1.
+	ID       int
+	q        *DownloadQueue
+	segments []nzb.Segment
+	asm      *nntpencoding.Assembler
+	result   *FetchResult
+	ctx      context.Context
+	end      EndFunc
+	finished chan struct{}
+
+	// Guarded by q.mu.
+	priority   int
+	state      JobState
+	next       int
+	inflight   int
+	done       int
+	bytes      int64
+	totalBytes int64
+	samples    []rateSample
+}
+
+type rateSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// NewDownloadQueue starts workers fetching with f.
+func NewDownloadQueue(f *Fetcher, workers int) *DownloadQueue {
+	q := &DownloadQueue{fetcher: f}
+	q.cond = sync.NewCond(&q.mu)
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Add queues segments to be fetched into asm.  Higher priorities go
+// first.
+func (q *DownloadQueue) Add(segments []nzb.Segment, asm *nntpencoding.Assembler, priority int) *Job {
+	j := &Job{
+		q:        q,
+		segments: segments,
+		asm:      asm,
+		result:   q.fetcher.newResult(len(segments)),
+		ctx:      context.Background(),
+		finished: make(chan struct{}),
+		priority: priority,
+	}
+	for _, s := range segments {
+		j.totalBytes += s.Bytes
+	}
+	if q.fetcher.Tracer != nil {
+		j.ctx, j.end = q.fetcher.Tracer.StartSpan(j.ctx, "nntp.fetch",
+			Attr{AttrSegments, len(segments)})
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	j.ID = q.nextID
+	if q.closed {
+		j.state = JobCancelled
+	}
+	q.jobs = append(q.jobs, j)
+	j.finish()
+	q.cond.Broadcast()
+	return j
+}
+
+// Close cancels the jobs still queued and stops the workers once the
+// segments in flight are done.
+func (q *DownloadQueue) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	for _, j := range q.jobs {
+		j.state = JobCancelled
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range append([]*Job(nil), q.jobs...) {
+		j.finish()
+	}
+	return nil
+}
+
+func (q *DownloadQueue) work() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		var j *Job
+		for !q.closed {
+			if j = q.pick(); j != nil {
+				break
+			}
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		i := j.next
+		j.next++
+		j.inflight++
+		q.mu.Unlock()
+
+		seg := j.segments[i]
+		outcome := q.fetcher.fetchSegment(j.ctx, seg.MessageID, j.asm, j.result)
+
+		q.mu.Lock()
+		j.result.Segments[i] = outcome
+		j.inflight--
+		j.done++
+		j.bytes += seg.Bytes
+		j.samples = append(j.samples, rateSample{time.Now(), j.bytes})
+		if len(j.samples) > rateSamples {
+			j.samples = j.samples[1:]
+		}
+		j.finish()
+		q.mu.Unlock()
+	}
+}
+
+// pick returns the highest priority job with segments left to start.
+func (q *DownloadQueue) pick() *Job {
+	var rv *Job
+	for _, j := range q.jobs {
+		if j.state != JobQueued || j.next == len(j.segments) {
+			continue
+		}
+		if rv == nil || j.priority > rv.priority {
+			rv = j
+		}
+	}
+	return rv
+}
+
+// finish completes the job if nothing more will be fetched for it.
+// Called with q.mu held.
+func (j *Job) finish() {
+	if j.state == JobDone || j.inflight > 0 {
+		return
+	}
+	if j.state != JobCancelled && j.done < len(j.segments) {
+		return
+	}
+	select {
+	case <-j.finished:
+		return
+	default:
+	}
+	for i := j.next; i < len(j.segments); i++ {
+		j.result.Segments[i] = SegmentOutcome{
+			MessageID: j.segments[i].MessageID,
+			Err:       ErrJobCancelled,
+		}
+	}
+	j.result.listMissing()
+	if j.state != JobCancelled {
+		j.state = JobDone
+	}
+	for i, other := range j.q.jobs {
+		if other == j {
+			j.q.jobs = append(j.q.jobs[:i], j.q.jobs[i+1:]...)
+			break
+		}
+	}
+	if j.end != nil {
+		j.end(nil, Attr{AttrMissing, len(j.result.Missing)})
+	}
+	close(j.finished)
+}
+
+// Priority returns the job's priority.
+func (j *Job) Priority() int {
+	j.q.mu.Lock()
+	defer j.q.mu.Unlock()
+	return j.priority
+}
+
+// SetPriority changes the job's priority.  It applies from the next
+// segment a worker picks.
+func (j *Job) SetPriority(priority int) {
+	j.q.mu.Lock()
+	defer j.q.mu.Unlock()
+	j.priority = priority
+	j.q.cond.Broadcast()
+}
+
+// Pause stops new segments of the job being fetched.  Those in flight
+// finish.
+func (j *Job) Pause() {
+	j.q.mu.Lock()
+	defer j.q.mu.Unlock()
+	if j.state == JobQueued {
+		j.state = JobPaused
+	}
+}
+
+// Resume lets a paused job continue.
+func (j *Job) Resume() {
+	j.q.mu.Lock()
+	defer j.q.mu.Unlock()
+	if j.state == JobPaused {
+		j.state = JobQueued
+		// Throughput from before the pause would skew the ETA.
+		j.samples = nil
+		j.q.cond.Broadcast()
+	}
+}
+
+// Cancel stops the job.  Segments in flight finish and their
+// connections go back to the Fetcher; the rest are recorded with
+// ErrJobCancelled.
+func (j *Job) Cancel() {
+	j.q.mu.Lock()
+	defer j.q.mu.Unlock()
+	if j.state == JobDone {
+		return
+	}
+	j.state = JobCancelled
+	j.finish()
+}
+
+// Done returns a channel that's closed when the job finishes or is
+// cancelled and its segments in flight are done.
+func (j *Job) Done() <-chan struct{} {
+	return j.finished
+}
+
+// Wait waits for the job to finish and returns its result.
+func (j *Job) Wait() *FetchResult {
+	<-j.finished
+	return j.result
+}
+
+// Progress reports how far the job has got.
+func (j *Job) Progress() JobProgress {
+	j.q.mu.Lock()
+	defer j.q.mu.Unlock()
+	rv := JobProgress{
+		State:      j.state,
+		Done:       j.done,
+		Total:      len(j.segments),
+		Bytes:      j.bytes,
+		TotalBytes: j.totalBytes,
+	}
+	if n := len(j.samples); n > 1 {
+		first, last := j.samples[0], j.samples[n-1]
+		if d := last.at.Sub(first.at); d > 0 {
+			rv.Rate = float64(last.bytes-first.bytes) / d.Seconds()
+		}
+	}
+	if rv.Rate > 0 && j.state != JobDone && j.state != JobCancelled {
+		rv.ETA = time.Duration(float64(rv.TotalBytes-rv.Bytes) / rv.Rate * float64(time.Second))
+	}
+	return rv
+}
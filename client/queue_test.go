@@ -0,0 +1,134 @@
+package nntpclient
+
+import (
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+
+	nntpencoding "github.com/yannik995/go-nntp/encoding"
+	"github.com/yannik995/go-nntp/nzb"
+)
+
+// gatedQueue returns a queue with one worker fetching from a server that
+// serves parts for any BODY <n@x>, sending each command line to arrived
+// and waiting on gate before answering.
+func gatedQueue(t *testing.T, parts []string) (q *DownloadQueue, arrived chan string, gate chan struct{}) {
+	arrived, gate = make(chan string), make(chan struct{})
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		var n int
+		fmt.Sscanf(line, "BODY <%d@x>", &n)
+		arrived <- line
+		<-gate
+		c.PrintfLine("222 0 <%d@x>", n)
+		dw := c.DotWriter()
+		dw.Write([]byte(parts[(n-1)%len(parts)]))
+		dw.Close()
+	})
+	f := NewFetcher([]FillServer{{ServerConfig: ServerConfig{Addr: addr}}})
+	q = NewDownloadQueue(f, 1)
+	t.Cleanup(func() {
+		q.Close()
+		f.Close()
+	})
+	return q, arrived, gate
+}
+
+// queueJob returns n segments numbered from first and an assembler
+// for them.
+func queueJob(t *testing.T, size, first, n int) ([]nzb.Segment, *nntpencoding.Assembler, writerAt) {
+	var segments []nzb.Segment
+	for i := 0; i < n; i++ {
+		segments = append(segments, nzb.Segment{
+			Number: i + 1, Bytes: 256, MessageID: fmt.Sprintf("%d@x", first+i),
+		})
+	}
+	out := make(writerAt, size)
+	asm, err := nntpencoding.NewAssembler(out, int64(size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return segments, asm, out
+}
+
+func TestDownloadQueuePriority(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	parts := encodeParts(t, data, 256)
+	q, arrived, gate := gatedQueue(t, parts)
+
+	segsA, asmA, outA := queueJob(t, len(data), 1, 4)
+	segsB, asmB, outB := queueJob(t, len(data), 5, 4)
+	a := q.Add(segsA, asmA, 0)
+	order := []string{<-arrived}
+	// B arrives while A's first segment is in flight and overtakes it.
+	b := q.Add(segsB, asmB, 10)
+	for i := 0; i < 8; i++ {
+		gate <- struct{}{}
+		if i < 7 {
+			order = append(order, <-arrived)
+		}
+	}
+	a.Wait()
+	b.Wait()
+
+	want := []string{"BODY <1@x>", "BODY <5@x>", "BODY <6@x>", "BODY <7@x>",
+		"BODY <8@x>", "BODY <2@x>", "BODY <3@x>", "BODY <4@x>"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("Fetched %v", order)
+	}
+	if !bytes.Equal(outA, data) || !bytes.Equal(outB, data) {
+		t.Errorf("Assembled data differs")
+	}
+	p := a.Progress()
+	if p.State != JobDone || p.Done != 4 || p.Bytes != 1024 || p.TotalBytes != 1024 || p.ETA != 0 {
+		t.Errorf("Progress %+v", p)
+	}
+}
+
+func TestDownloadQueuePauseCancel(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	parts := encodeParts(t, data, 256)
+	q, arrived, gate := gatedQueue(t, parts)
+
+	segs, asm, _ := queueJob(t, len(data), 1, 4)
+	j := q.Add(segs, asm, 0)
+	<-arrived
+	j.Pause()
+	gate <- struct{}{}
+	deadline := time.Now().Add(5 * time.Second)
+	for j.Progress().Done != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("First segment never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case line := <-arrived:
+		t.Fatalf("Paused job fetched %s", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if p := j.Progress(); p.State != JobPaused {
+		t.Errorf("State %v", p.State)
+	}
+
+	j.Resume()
+	if line := <-arrived; line != "BODY <2@x>" {
+		t.Errorf("Resumed with %s", line)
+	}
+	j.Cancel()
+	gate <- struct{}{}
+	res := j.Wait()
+
+	for i, o := range res.Segments {
+		if (i < 2) != (o.Err == nil && o.Server != "") || (i >= 2 && o.Err != ErrJobCancelled) {
+			t.Errorf("Segment %d: %+v", i, o)
+		}
+	}
+	if len(res.Missing) != 0 {
+		t.Errorf("Missing %v", res.Missing)
+	}
+	if p := j.Progress(); p.State != JobCancelled || p.Done != 2 {
+		t.Errorf("Progress %+v", p)
+	}
+}
@@ -0,0 +1,32 @@
+package nntpclient
+
+import (
+	"github.com/yannik995/go-nntp"
+)
+
+// OverRange is Over for a range of articles, which is checked with
+// Validate before it's sent.
+func (c *Client) OverRange(r nntp.ArticleRange) ([]string, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return c.Over(r.String())
+}
+
+// HdrRange is Hdr for a range of articles, which is checked with
+// Validate before it's sent.
+func (c *Client) HdrRange(field string, r nntp.ArticleRange) (map[int64]string, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return c.Hdr(field, r.String())
+}
+
+// ListGroupRange is ListGroup for a range of articles in group, which
+// is checked with Validate before it's sent.
+func (c *Client) ListGroupRange(group string, r nntp.ArticleRange) ([]int64, nntp.Group, error) {
+	if err := r.Validate(); err != nil {
+		return nil, nntp.Group{}, err
+	}
+	return c.ListGroup(group, r.String())
+}
@@ -0,0 +1,47 @@
+package nntpclient
+
+import (
+	"net/textproto"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestRangeCommands(t *testing.T) {
+	var sent []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		switch line {
+		case "OVER 10-":
+			writeLines(c, 224, "Overview follows", "10\ts\tf\td\t<a@x>\t\t1\t1")
+		case "HDR Subject 5":
+			writeLines(c, 225, "Headers follow", "5 s")
+		case "LISTGROUP misc.test 3-4":
+			writeLines(c, 211, "2 3 4 misc.test", "3", "4")
+		default:
+			c.PrintfLine("500 Unexpected")
+		}
+	})
+	if lines, err := c.OverRange(nntp.From(10)); err != nil || len(lines) != 1 {
+		t.Errorf("OverRange = %q, %v", lines, err)
+	}
+	if h, err := c.HdrRange("Subject", nntp.Single(5)); err != nil || h[5] != "s" {
+		t.Errorf("HdrRange = %v, %v", h, err)
+	}
+	if ns, _, err := c.ListGroupRange("misc.test", nntp.Between(3, 4)); err != nil || len(ns) != 2 {
+		t.Errorf("ListGroupRange = %v, %v", ns, err)
+	}
+
+	if _, err := c.OverRange(nntp.Between(4, 3)); err == nil {
+		t.Errorf("OverRange of an empty range succeeded")
+	}
+	if _, err := c.HdrRange("Subject", nntp.Single(0)); err == nil {
+		t.Errorf("HdrRange of article 0 succeeded")
+	}
+	if _, _, err := c.ListGroupRange("misc.test", nntp.Between(-1, 3)); err == nil {
+		t.Errorf("ListGroupRange of a negative range succeeded")
+	}
+	if len(sent) != 3 {
+		t.Errorf("Sent %q", sent)
+	}
+}
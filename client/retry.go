@@ -0,0 +1,185 @@
+package nntpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// A RetryPolicy is consulted when an idempotent command fails with a
+// transient error, as reported by IsTransient, or because the
+// connection was lost, as when the server dropped it while it sat idle.
+// It may wait, or replace
+// the connection with Reconnect and restore what the command relies on,
+// such as authentication and the selected group; returning nil then
+// sends the command once more, and whatever that gives is returned.
+// Returning an error gives up, and the error is returned instead.
+//
+// The policy is given a Client sharing the connection, whose commands,
+// sent from any goroutine, go ahead while those sent through others
+// wait until it returns.  They're never retried.
+type RetryPolicy func(c *Client, cmd string, err error) error
+
+// idempotent lists the commands a RetryPolicy may have sent again.
+// They only read, so sending one twice does no harm.  POST, IHAVE,
+// TAKETHIS and the rest of the commands that upload an article are
+// never retried, nor are NEXT and LAST, which move the current article,
+// or commands that change the session, like MODE READER and AUTHINFO.
+var idempotent = map[string]bool{
+	"ARTICLE":      true,
+	"BODY":         true,
+	"CAPABILITIES": true,
+	"DATE":         true,
+	"GROUP":        true,
+	"HDR":          true,
+	"HEAD":         true,
+	"HELP":         true,
+	"LIST":         true,
+	"LISTGROUP":    true,
+	"NEWGROUPS":    true,
+	"NEWNEWS":      true,
+	"OVER":         true,
+	"STAT":         true,
+	"XGTITLE":      true,
+	"XHDR":         true,
+	"XOVER":        true,
+	"XPAT":         true,
+	"XZVER":        true,
+}
+
+// command sends cmd and reads the response, as Command does without the
+// span, with the connection taken but c.mu free meanwhile, and gives the RetryPolicy its chance if an idempotent command
+// fails transiently or loses the connection.  After a response that
+// isn't an error, the connection is left busy with hold, if it's set,
+// or with the data block that follows, until done is called.
+func (c *Client) command(cmd string, expectCode int, hold string) (int, string, error) {
+	verb, args := nextField(cmd)
+	verb = strings.ToUpper(verb)
+	if err := c.claim(cmd); err != nil {
+		return 0, "", err
+	}
+	code, msg, err := c.exchange(cmd, expectCode)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.RetryPolicy != nil && !c.retrying && !c.closed &&
+		(IsTransient(err) || lostConnection(err)) && idempotent[verb] {
+		// The connection is free for the policy's own commands; other
+		// goroutines' wait until it's done.
+		c.busy = ""
+		c.retrying = true
+		c.mu.Unlock()
+		rc := *c
+		rc.retrier = true
+		perr := c.RetryPolicy(&rc, cmd, err)
+		c.mu.Lock()
+		c.retrying = false
+		if perr == nil {
+			perr = c.wait(cmd)
+		}
+		if perr != nil {
+			c.wake()
+			return code, msg, perr
+		}
+		c.busy = cmd + " in progress"
+		c.mu.Unlock()
+		code, msg, err = c.exchange(cmd, expectCode)
+		c.mu.Lock()
+	}
+	c.busy = ""
+	defer c.wake()
+	if err != nil {
+		return code, msg, err
+	}
+	if (verb == "GROUP" || verb == "LISTGROUP") && args != "" {
+		c.group, _ = nextField(args)
+	}
+	if hold != "" {
+		c.busy = hold
+	} else if hasDataBlock(verb, code) {
+		c.busy = busyBlock
+	}
+	return code, msg, nil
+}
+
+// lostConnection reports whether err means the connection is gone, or
+// unusable after a timeout, rather than that the server refused
+// something.  Cancelling the context doesn't count.
+func lostConnection(err error) bool {
+	if _, ok := ResponseCode(err); ok {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed)
+}
+
+// exchange sends cmd and reads the response.  A command that would
+// break into more than one line isn't sent.
+func (c *Client) exchange(cmd string, expectCode int) (int, string, error) {
+	verb, _ := nextField(cmd)
+	if err := checkArgument(verb, cmd); err != nil {
+		return 0, "", err
+	}
+	if err := c.conn.PrintfLine("%s", cmd); err != nil {
+		return 0, "", err
+	}
+	return c.readCodeLine(expectCode)
+}
+
+// Reconnect replaces the client's connection with netconn, a new
+// connection to the same server, for example from a RetryPolicy after
+// the server said it was closing.  The old connection is closed without
+// QUIT.
+//
+// Apart from the context set with SetContext, nothing carries over from
+// the old connection: capabilities, TLS, compression, authentication and
+// the selected group all start afresh.
+// If the new connection doesn't greet the client, it's closed and the
+// client is left as it was.
+func (c *Client) Reconnect(netconn net.Conn) error {
+	nc, err := connect(c.Context(), netconn, nil)
+	if err != nil {
+		netconn.Close()
+		return err
+	}
+	_, nc.tls = netconn.(*tls.Conn)
+	c.adopt(nc)
+	c.mu.Lock()
+	// Nothing is pending on the new connection.
+	c.busy, c.lent = "", false
+	c.wake()
+	c.mu.Unlock()
+	return nil
+}
+
+// adopt moves nc's connection into c, closing c's, and starts the
+// session afresh.
+func (c *Client) adopt(nc *Client) {
+	c.CloseNow()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn, c.netconn, c.cc = nc.conn, nc.netconn, nc.cc
+	c.cc.client = c.owner
+	c.owner.Banner, c.posting = nc.Banner, nc.posting
+	c.lastCode, c.lastMsg = nc.lastCode, nc.lastMsg
+	c.tls = nc.tls
+	c.compressed = false
+	c.authed, c.reader = false, false
+	c.xfeatureGzip = false
+	c.closed = false
+	c.caps = nil
+	c.overCmd = ""
+	c.overviewFmt = nil
+	c.hdrFields = nil
+	c.group = ""
+	c.streaming = 0
+	if c.ctx != nil {
+		c.cc.watch(c.ctx)
+	}
+}
@@ -0,0 +1,256 @@
+package nntpclient
+
+import (
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		mu.Lock()
+		first := !failed[line]
+		failed[line] = true
+		mu.Unlock()
+		switch {
+		case line == "GROUP misc.test" && first:
+			c.PrintfLine("400 Service discontinued")
+			c.Close()
+		case line == "GROUP misc.test":
+			c.PrintfLine("211 2 1 2 misc.test")
+		case line == "OVER 1-2" && first:
+			c.PrintfLine("503 Overview temporarily unavailable")
+		case line == "OVER 1-2":
+			writeLines(c, 224, "Overview follows", "1\ta\tb\tc\t<1@x>\t\t3\t1")
+		case line == "ARTICLE <a@b>":
+			c.PrintfLine("503 Try later")
+		default:
+			c.PrintfLine("503 Not now")
+		}
+	})
+	c, err := New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var tried []string
+	c.RetryPolicy = func(c *Client, cmd string, err error) error {
+		tried = append(tried, cmd)
+		if !IsTransient(err) {
+			t.Errorf("%s: retrying %v", cmd, err)
+		}
+		if cmd == "ARTICLE <a@b>" {
+			return errors.New("giving up")
+		}
+		if IsCode(err, 400) {
+			nc, err := net.Dial("tcp", addr)
+			if err != nil {
+				return err
+			}
+			return c.Reconnect(nc)
+		}
+		return nil
+	}
+
+	if g, err := c.Group("misc.test"); err != nil || g.High != 2 {
+		t.Errorf("Group after reconnecting: %+v %v", g, err)
+	}
+	if lines, err := c.Over("1-2"); err != nil || len(lines) != 1 {
+		t.Errorf("Over after retrying: %q %v", lines, err)
+	}
+	if _, _, _, err := c.Article("<a@b>"); err == nil || err.Error() != "giving up" {
+		t.Errorf("Article when the policy gives up: %v", err)
+	}
+	// Commands that aren't idempotent never reach the policy.
+	if err := c.Post(nil); !IsTransient(err) {
+		t.Errorf("Post: %v", err)
+	}
+	if _, _, err := c.Command("POST", 340); !IsTransient(err) {
+		t.Errorf("POST: %v", err)
+	}
+	if _, _, err := c.Next(); !IsTransient(err) {
+		t.Errorf("Next: %v", err)
+	}
+	want := []string{"GROUP misc.test", "OVER 1-2", "ARTICLE <a@b>"}
+	if len(tried) != len(want) {
+		t.Fatalf("Retried %q, want %q", tried, want)
+	}
+	for i := range want {
+		if tried[i] != want[i] {
+			t.Errorf("Retried %q, want %q", tried, want)
+		}
+	}
+}
+
+func TestRetryExclusive(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		mu.Lock()
+		log = append(log, line)
+		first := len(log) == 1
+		mu.Unlock()
+		switch line {
+		case "GROUP misc.test":
+			if first {
+				c.PrintfLine("503 Not now")
+			} else {
+				c.PrintfLine("211 2 1 2 misc.test")
+			}
+		case "CAPABILITIES":
+			writeLines(c, 101, "Capability list:", "VERSION 2", "READER")
+		case "DATE":
+			c.PrintfLine("111 20240102030405")
+		}
+	})
+	c, err := New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	started := make(chan struct{})
+	dated := make(chan error)
+	go func() {
+		<-started
+		_, err := c.Date()
+		dated <- err
+	}()
+	c.RetryPolicy = func(c *Client, cmd string, err error) error {
+		close(started)
+		// The data block is the policy's, and doesn't let the other
+		// goroutine in when it's been read.  The policy's commands may
+		// come from a goroutine of its own.
+		errc := make(chan error, 1)
+		go func() {
+			_, err := c.Capabilities()
+			errc <- err
+		}()
+		if err := <-errc; err != nil {
+			return err
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	if _, err := c.Group("misc.test"); err != nil {
+		t.Errorf("Group: %v", err)
+	}
+	if err := <-dated; err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := strings.Join(log, "|"), "GROUP misc.test|CAPABILITIES|GROUP misc.test|DATE"; got != want {
+		t.Errorf("Commands\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRetryOnce(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("503 Still unavailable")
+	})
+	calls := 0
+	c.RetryPolicy = func(c *Client, cmd string, err error) error {
+		calls++
+		// Commands sent from the policy aren't retried.
+		if _, err := c.Group("misc.test"); !IsTransient(err) {
+			t.Errorf("Group from the policy: %v", err)
+		}
+		return nil
+	}
+	if _, err := c.Group("misc.test"); !IsTransient(err) {
+		t.Errorf("Group: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Policy called %d times", calls)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	drop := false
+	groups := map[*textproto.Conn]string{}
+	addr := fakeServerAddr(t, func(c *textproto.Conn, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		log = append(log, line)
+		if drop {
+			// Gone, as an idle connection is.
+			drop = false
+			c.Close()
+			return
+		}
+		switch line {
+		case "authinfo user tim":
+			c.PrintfLine("381 Password required")
+		case "authinfo pass secret":
+			c.PrintfLine("281 Welcome")
+		case "GROUP misc.test":
+			groups[c] = "misc.test"
+			c.PrintfLine("211 2 1 2 misc.test")
+		case "LIST DISTRIBUTIONS":
+			c.PrintfLine("503 Not maintained")
+		case "STAT 1":
+			if groups[c] == "" {
+				c.PrintfLine("412 No newsgroup selected")
+			} else {
+				c.PrintfLine("223 1 <1@x> Article exists")
+			}
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+	var reconnects []string
+	c, err := Dial(addr, WithAuth("tim", "secret"), WithReconnect(func(cmd string, err error) {
+		reconnects = append(reconnects, cmd)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Group("misc.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	drop = true
+	mu.Unlock()
+	if _, _, err := c.Stat("1"); err != nil {
+		t.Errorf("Stat after the connection dropped: %v", err)
+	}
+	if c.CurrentGroup() != "misc.test" {
+		t.Errorf("Current group %q", c.CurrentGroup())
+	}
+
+	// A feature the server lacks isn't a reason to dial again.
+	if _, err := c.ListDistributions(); !IsCode(err, 503) {
+		t.Errorf("ListDistributions: %v", err)
+	}
+
+	// An article is never sent twice.
+	mu.Lock()
+	drop = true
+	mu.Unlock()
+	if err := c.Post(strings.NewReader("Subject: x\r\n\r\nbody\r\n")); err == nil || !lostConnection(err) {
+		t.Errorf("Post after the connection dropped: %v", err)
+	}
+
+	if got, want := strings.Join(reconnects, "|"), "STAT 1"; got != want {
+		t.Errorf("Reconnected for %q, want %q", got, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := "authinfo user tim|authinfo pass secret|GROUP misc.test|STAT 1|" +
+		"authinfo user tim|authinfo pass secret|GROUP misc.test|STAT 1|LIST DISTRIBUTIONS|POST"
+	if got := strings.Join(log, "|"); got != want {
+		t.Errorf("Commands\n%s\nwant\n%s", got, want)
+	}
+}
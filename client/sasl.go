@@ -0,0 +1,240 @@
+package nntpclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/textproto"
+)
+
+// Errors from authentication.  They match, with errors.Is, the
+// *AuthError carrying the server's reason, which is available with
+// errors.As.
+var (
+	// ErrAuthFailed means the credentials were rejected (481).
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrAuthSequence means the exchange went wrong, or the client
+	// was already authenticated (482).
+	ErrAuthSequence = errors.New("authentication commands out of sequence")
+	// ErrAuthUnavailable means the server won't authenticate this
+	// connection, for example without TLS (502).
+	ErrAuthUnavailable = errors.New("authentication not permitted")
+	// ErrAuthEncryptionRequired means the mechanism needs encryption
+	// or a stronger mechanism (483).
+	ErrAuthEncryptionRequired = errors.New("encryption or stronger authentication required")
+)
+
+// An AuthError is a server's refusal to authenticate, with its reason,
+// such as a bad password or too many connections for the account.  It
+// matches ErrAuthFailed, ErrAuthSequence, ErrAuthUnavailable or
+// ErrAuthEncryptionRequired, according to its code, and unwraps to the
+// *textproto.Error.
+type AuthError struct {
+	Code int
+	Msg  string
+}
+
+func (e *AuthError) Error() string { return e.Unwrap().Error() }
+func (e *AuthError) Unwrap() error { return &textproto.Error{Code: e.Code, Msg: e.Msg} }
+
+func (e *AuthError) Is(target error) bool {
+	switch e.Code {
+	case 481:
+		return target == ErrAuthFailed
+	case 482:
+		return target == ErrAuthSequence
+	case 483:
+		return target == ErrAuthEncryptionRequired
+	case 502:
+		return target == ErrAuthUnavailable
+	}
+	return false
+}
+
+// authError turns the failure responses to AUTHINFO into *AuthErrors.
+func authError(err error) error {
+	if terr, ok := err.(*textproto.Error); ok {
+		switch terr.Code {
+		case 481, 482, 483, 502:
+			return &AuthError{Code: terr.Code, Msg: terr.Msg}
+		}
+	}
+	return err
+}
+
+// AuthenticateSASLPlain authenticates with the SASL PLAIN mechanism of
+// RFC 4616, as authcid, acting as authzid if that's not empty.  Some
+// servers only accept SASL once TLS is active.
+//
+// Capabilities must list SASL PLAIN; they're retrieved if they haven't
+// been.
+func (c *Client) AuthenticateSASLPlain(authzid, authcid, password string) error {
+	if !c.supportsArg("SASL", "PLAIN") {
+		return errors.New("server doesn't advertise SASL PLAIN")
+	}
+	resp := []byte(authzid + "\x00" + authcid + "\x00" + password)
+	_, err := c.authenticateSASL("PLAIN", resp, respondOnce("PLAIN", resp))
+	return err
+}
+
+// AuthenticateSASLExternal authenticates with the SASL EXTERNAL
+// mechanism of RFC 4422, where the server takes the identity from the
+// TLS client certificate, and returns the server's message, which on
+// failure is its reason.  authzid asks to act as another identity, and
+// is usually empty.
+//
+// TLS must be active, and capabilities must list SASL EXTERNAL if
+// they've been retrieved.
+func (c *Client) AuthenticateSASLExternal(authzid string) (string, error) {
+	if !c.HasTLS() {
+		return "", errors.New("SASL EXTERNAL requires TLS")
+	}
+	if c.Caps() != nil && !c.supportsArg("SASL", "EXTERNAL") {
+		return "", errors.New("server doesn't advertise SASL EXTERNAL")
+	}
+	resp := []byte(authzid)
+	return c.authenticateSASL("EXTERNAL", resp, respondOnce("EXTERNAL", resp))
+}
+
+// respondOnce answers a mechanism's single challenge with resp, for
+// servers that ignore the initial response and ask for it.
+func respondOnce(mech string, resp []byte) func([]byte) ([]byte, error) {
+	sent := false
+	return func([]byte) ([]byte, error) {
+		if sent {
+			return nil, errors.New("unexpected SASL " + mech + " challenge")
+		}
+		sent = true
+		return resp, nil
+	}
+}
+
+// authenticateSASL runs AUTHINFO SASL with a mechanism, sending initial
+// as the initial response if it's not nil.  respond answers each 383
+// challenge; if it fails, the exchange is cancelled and its error
+// returned.  On success it returns the server's message.
+func (c *Client) authenticateSASL(mech string, initial []byte, respond func(challenge []byte) ([]byte, error)) (string, error) {
+	msg, err := c.saslExchange(mech, initial, respond)
+	if err != nil {
+		return msg, err
+	}
+	return msg, c.authenticated()
+}
+
+// saslExchange is authenticateSASL up to success, keeping the
+// connection busy until it's over.
+func (c *Client) saslExchange(mech string, initial []byte, respond func(challenge []byte) ([]byte, error)) (string, error) {
+	cmd := "AUTHINFO SASL " + mech
+	if initial != nil {
+		cmd += " " + encodeSASL(initial)
+	}
+	code, msg, err := c.tracedCommand(cmd, -1, "AUTHINFO SASL in progress")
+	if err == nil {
+		defer c.done()
+	}
+	for err == nil {
+		switch code {
+		case 281, 283:
+			// 283 carries additional data from the server, which
+			// none of the mechanisms here need.
+			return msg, nil
+		case 383:
+			challenge, derr := base64.StdEncoding.DecodeString(trimSASL(msg))
+			var resp []byte
+			if derr == nil {
+				resp, derr = respond(challenge)
+			}
+			if derr != nil {
+				// Cancel the exchange, and report why.
+				if err := c.conn.PrintfLine("*"); err != nil {
+					return "", err
+				}
+				c.readCodeLine(-1)
+				return "", derr
+			}
+			if err := c.conn.PrintfLine("%s", encodeSASL(resp)); err != nil {
+				return "", err
+			}
+			code, msg, err = c.readCodeLine(-1)
+		default:
+			return msg, authError(&textproto.Error{Code: code, Msg: msg})
+		}
+	}
+	return "", authError(err)
+}
+
+// maxGenericSteps is how many challenges AuthenticateGeneric answers
+// before giving up on the server.
+const maxGenericSteps = 20
+
+// AuthenticateGeneric authenticates with AUTHINFO GENERIC, the legacy
+// extension for site-specific authenticators.  step is called with the
+// text of each 38x challenge and returns the line to answer with.  The
+// server's final message is returned whether authentication succeeds
+// or not.
+//
+// If step fails, or the server keeps challenging, the exchange is
+// cancelled with "*".
+func (c *Client) AuthenticateGeneric(mechanism string, step func(challenge string) (response string, err error)) (string, error) {
+	msg, err := c.genericExchange(mechanism, step)
+	if err != nil {
+		return msg, err
+	}
+	return msg, c.authenticated()
+}
+
+// genericExchange is AuthenticateGeneric up to success, keeping the
+// connection busy until it's over.
+func (c *Client) genericExchange(mechanism string, step func(challenge string) (response string, err error)) (string, error) {
+	code, msg, err := c.tracedCommand("AUTHINFO GENERIC "+mechanism, -1, "AUTHINFO GENERIC in progress")
+	if err == nil {
+		defer c.done()
+		// step is the caller's.
+		c.lend()
+	}
+	for i := 0; err == nil; i++ {
+		switch {
+		case code == 281:
+			return msg, nil
+		case code/10 == 38:
+			var resp string
+			var serr error
+			if i == maxGenericSteps {
+				serr = fmt.Errorf("AUTHINFO GENERIC %s: gave up after %d challenges", mechanism, i)
+			} else if resp, serr = step(msg); serr == nil {
+				serr = checkArgument("AUTHINFO GENERIC "+mechanism, resp)
+			}
+			if serr != nil {
+				if err := c.conn.PrintfLine("*"); err != nil {
+					return "", err
+				}
+				_, msg, _ := c.readCodeLine(-1)
+				return msg, serr
+			}
+			if err := c.conn.PrintfLine("%s", resp); err != nil {
+				return "", err
+			}
+			code, msg, err = c.readCodeLine(-1)
+		default:
+			return msg, authError(&textproto.Error{Code: code, Msg: msg})
+		}
+	}
+	return msg, authError(err)
+}
+
+// encodeSASL encodes a SASL response, where "=" stands for an empty one.
+func encodeSASL(b []byte) string {
+	if len(b) == 0 {
+		return "="
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// trimSASL returns the base64 challenge from a 383 response's text.
+func trimSASL(msg string) string {
+	field, _ := nextField(msg)
+	if field == "=" {
+		return ""
+	}
+	return field
+}
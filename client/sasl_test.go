@@ -0,0 +1,261 @@
+package nntpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	var sent []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		switch line {
+		case "authinfo user tim":
+			c.PrintfLine("381 Password required")
+		case "authinfo pass tanstaaf":
+			c.PrintfLine("281 Authentication accepted")
+		case "authinfo pass wrong":
+			c.PrintfLine("481 Authentication failed")
+		case "authinfo user open":
+			c.PrintfLine("281 Authentication accepted, no password needed")
+		case "authinfo user again":
+			c.PrintfLine("482 Already authenticated")
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+
+	tests := []struct {
+		user, pass string
+		msg        string
+		err        error
+		sent       int
+	}{
+		{"tim", "tanstaaf", "Authentication accepted", nil, 2},
+		{"tim", "wrong", "Authentication failed", ErrAuthFailed, 2},
+		{"open", "unused", "Authentication accepted, no password needed", nil, 1},
+		{"again", "unused", "Already authenticated", ErrAuthSequence, 1},
+	}
+	for _, test := range tests {
+		sent = nil
+		msg, err := c.Authenticate(test.user, test.pass)
+		if msg != test.msg || !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s/%s: %q %v", test.user, test.pass, msg, err)
+		}
+		if len(sent) != test.sent {
+			t.Errorf("%s/%s: sent %q", test.user, test.pass, sent)
+		}
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "authinfo user tim":
+			c.PrintfLine("381 Password required")
+		case "authinfo pass tanstaaf":
+			c.PrintfLine("281 Authentication accepted")
+		case "authinfo pass wrong":
+			c.PrintfLine("481 Too many connections for this account")
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+	msg, err := c.Authenticate("tim", "wrong")
+	var aerr *AuthError
+	if !errors.As(err, &aerr) || aerr.Code != 481 || aerr.Msg != "Too many connections for this account" {
+		t.Errorf("Rejected: %v", err)
+	}
+	if msg != "Too many connections for this account" || !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Rejected: %q %v", msg, err)
+	}
+	var terr *textproto.Error
+	if !errors.As(err, &terr) || terr.Code != 481 {
+		t.Errorf("AuthError doesn't unwrap to the response: %v", err)
+	}
+	if _, err := c.Authenticate("tim", "tanstaaf"); err != nil {
+		t.Errorf("Second attempt: %v", err)
+	}
+}
+
+func TestSASLPlain(t *testing.T) {
+	var sent []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		switch line {
+		case "CAPABILITIES":
+			writeLines(c, 101, "Capability list:", "VERSION 2", "READER", "SASL PLAIN EXTERNAL")
+		case "AUTHINFO SASL PLAIN AHRpbQB0YW5zdGFhZnRhbnN0YWFm":
+			c.PrintfLine("281 Authentication accepted")
+		case "AUTHINFO SASL PLAIN YWRtaW4AdGltAHRhbnN0YWFmdGFuc3RhYWY=":
+			// Ask for the response again.
+			c.PrintfLine("383 =")
+		case "YWRtaW4AdGltAHRhbnN0YWFmdGFuc3RhYWY=":
+			c.PrintfLine("281 Authentication accepted")
+		case "AUTHINFO SASL PLAIN AHRpbQB3cm9uZw==":
+			c.PrintfLine("481 Bad password for tim")
+		case "AUTHINFO SASL PLAIN AGxvb3AAbG9vcA==":
+			c.PrintfLine("383 =")
+		case "AGxvb3AAbG9vcA==":
+			c.PrintfLine("383 =")
+		case "*":
+			c.PrintfLine("481 Cancelled")
+		default:
+			c.PrintfLine("502 Not over an unencrypted connection")
+		}
+	})
+
+	// The capabilities are retrieved first.
+	if err := c.AuthenticateSASLPlain("", "tim", "tanstaaftanstaaf"); err != nil {
+		t.Fatal(err)
+	}
+	if sent[0] != "CAPABILITIES" {
+		t.Errorf("Sent %q", sent)
+	}
+	tests := []struct {
+		authzid, authcid, password string
+		want                       error
+		text                       string
+	}{
+		{"", "tim", "tanstaaftanstaaf", nil, ""},
+		{"admin", "tim", "tanstaaftanstaaf", nil, ""},
+		{"", "tim", "wrong", ErrAuthFailed, "Bad password for tim"},
+		{"", "loop", "loop", nil, "unexpected"},
+		// Also checks the connection is in step after cancelling.
+		{"", "other", "x", ErrAuthUnavailable, "unencrypted"},
+	}
+	for _, test := range tests {
+		sent = nil
+		err := c.AuthenticateSASLPlain(test.authzid, test.authcid, test.password)
+		if test.text == "" {
+			if err != nil {
+				t.Errorf("%s: %v (sent %q)", test.authcid, err, sent)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.text) ||
+			(test.want != nil && !errors.Is(err, test.want)) {
+			t.Errorf("%s: got %v, want %v with %q", test.authcid, err, test.want, test.text)
+		}
+	}
+}
+
+func TestSASLExternal(t *testing.T) {
+	cert := testCert(t, "localhost", nil, false)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(nc, func(c *textproto.Conn, line string) {
+				switch line {
+				case "AUTHINFO SASL EXTERNAL =":
+					c.PrintfLine("281 Welcome, CN=localhost")
+				case "AUTHINFO SASL EXTERNAL YWRtaW4=":
+					// Ignore the initial response, as some servers do.
+					c.PrintfLine("383 =")
+				case "YWRtaW4=":
+					c.PrintfLine("281 Welcome, admin")
+				case "AUTHINFO SASL EXTERNAL bm9ib2R5":
+					c.PrintfLine("481 Not allowed to act as nobody")
+				default:
+					c.PrintfLine("483 Encryption required")
+				}
+			})
+		}
+	}()
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	c, err := NewTLS("tcp", l.Addr().String(), &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		authzid string
+		msg     string
+		want    error
+	}{
+		{"", "Welcome, CN=localhost", nil},
+		{"admin", "Welcome, admin", nil},
+		{"nobody", "Not allowed to act as nobody", ErrAuthFailed},
+		{"other", "Encryption required", ErrAuthEncryptionRequired},
+	}
+	for _, test := range tests {
+		msg, err := c.AuthenticateSASLExternal(test.authzid)
+		if msg != test.msg || !errors.Is(err, test.want) || (err == nil) != (test.want == nil) {
+			t.Errorf("%q: got %q, %v", test.authzid, msg, err)
+		}
+	}
+
+	plain := fakeServer(t, func(c *textproto.Conn, line string) {
+		c.PrintfLine("281 Welcome")
+	})
+	if _, err := plain.AuthenticateSASLExternal(""); err == nil {
+		t.Errorf("EXTERNAL accepted without TLS")
+	}
+}
+
+func TestAuthenticateGeneric(t *testing.T) {
+	challenges := 0
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "AUTHINFO GENERIC token", "AUTHINFO GENERIC loop":
+			c.PrintfLine("381 nonce-%d", challenges)
+		case "hash(nonce-0)":
+			challenges++
+			c.PrintfLine("382 nonce-%d", challenges)
+		case "hash(nonce-1)":
+			c.PrintfLine("281 Welcome back")
+		case "again":
+			challenges++
+			c.PrintfLine("381 nonce-%d", challenges)
+		case "*":
+			c.PrintfLine("481 Authentication cancelled")
+		case "AUTHINFO GENERIC unknown":
+			c.PrintfLine("501 Unknown authenticator")
+		default:
+			c.PrintfLine("481 Wrong answer")
+		}
+	})
+
+	hash := func(challenge string) (string, error) { return "hash(" + challenge + ")", nil }
+	msg, err := c.AuthenticateGeneric("token", hash)
+	if err != nil || msg != "Welcome back" {
+		t.Errorf("token: %q, %v", msg, err)
+	}
+
+	challenges = 0
+	msg, err = c.AuthenticateGeneric("token", func(string) (string, error) { return "guess", nil })
+	if !errors.Is(err, ErrAuthFailed) || msg != "Wrong answer" {
+		t.Errorf("Wrong answer: %q, %v", msg, err)
+	}
+
+	msg, err = c.AuthenticateGeneric("unknown", hash)
+	if err == nil || msg != "Unknown authenticator" {
+		t.Errorf("Unknown mechanism: %q, %v", msg, err)
+	}
+
+	challenges = 0
+	msg, err = c.AuthenticateGeneric("loop", func(string) (string, error) { return "again", nil })
+	if err == nil || challenges != maxGenericSteps || msg != "Authentication cancelled" {
+		t.Errorf("Endless challenges: %d answered, %q, %v", challenges, msg, err)
+	}
+
+	challenges = 0
+	broken := errors.New("no token available")
+	if _, err = c.AuthenticateGeneric("token", func(string) (string, error) { return "", broken }); err != broken {
+		t.Errorf("Failed step: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package nntpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "STAT <a@b>":
+			c.PrintfLine("223 0 <a@b>")
+		case "STAT 12":
+			c.PrintfLine("223 12 <c@d>")
+		case "STAT <gone@b>":
+			c.PrintfLine("430 No such article")
+		case "STAT 99":
+			c.PrintfLine("423 No article with that number")
+		case "STAT":
+			c.PrintfLine("420 Current article number is invalid")
+		case "STAT 13":
+			c.PrintfLine("223 garbage")
+		default:
+			c.PrintfLine("503 program fault")
+		}
+	})
+
+	if n, id, err := c.Stat("<a@b>"); n != 0 || id != "<a@b>" || err != nil {
+		t.Errorf("Stat by message-id: %d %s %v", n, id, err)
+	}
+	if n, id, err := c.Stat("12"); n != 12 || id != "<c@d>" || err != nil {
+		t.Errorf("Stat by number: %d %s %v", n, id, err)
+	}
+	for _, spec := range []string{"<gone@b>", "99", ""} {
+		_, _, err := c.Stat(spec)
+		var terr *textproto.Error
+		if !errors.Is(err, ErrNoSuchArticle) || !errors.As(err, &terr) {
+			t.Errorf("Stat(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"13", "<fault@b>"} {
+		if _, _, err := c.Stat(spec); err == nil || errors.Is(err, ErrNoSuchArticle) {
+			t.Errorf("Stat(%q): %v", spec, err)
+		}
+	}
+}
+
+func TestHasArticles(t *testing.T) {
+	stats := 0
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		stats++
+		id := strings.TrimPrefix(line, "STAT ")
+		switch {
+		case id == "<broken@x>":
+			c.PrintfLine("503 Program fault")
+		case strings.HasPrefix(id, "<even"):
+			c.PrintfLine("223 0 %s", id)
+		default:
+			c.PrintfLine("430 No such article")
+		}
+	})
+
+	if ok, err := c.HasArticle("<even0@x>"); !ok || err != nil {
+		t.Errorf("HasArticle(<even0@x>) = %v, %v", ok, err)
+	}
+	if ok, err := c.HasArticle("<odd1@x>"); ok || err != nil {
+		t.Errorf("HasArticle(<odd1@x>) = %v, %v", ok, err)
+	}
+	if _, err := c.HasArticle("<broken@x>"); err == nil {
+		t.Errorf("HasArticle(<broken@x>) hid the error")
+	}
+	if ok, err := c.HasArticle("even2@x"); !ok || err != nil {
+		t.Errorf("HasArticle(even2@x) = %v, %v", ok, err)
+	}
+	stats = 0
+	for _, bad := range []string{"", "12", "<no-at>"} {
+		if _, err := c.HasArticle(bad); err == nil {
+			t.Errorf("HasArticle(%q) was sent", bad)
+		}
+		if _, err := c.HasArticles([]string{"<even0@x>", bad}); err == nil {
+			t.Errorf("HasArticles with %q was sent", bad)
+		}
+	}
+	if stats != 0 {
+		t.Errorf("Sent %d STATs for invalid message-ids", stats)
+	}
+
+	var ids []string
+	for i := 0; i < 250; i++ {
+		kind := "odd"
+		if i%2 == 0 {
+			kind = "even"
+		}
+		ids = append(ids, fmt.Sprintf("<%s%d@x>", kind, i))
+	}
+	stats = 0
+	got, err := c.HasArticles(ids)
+	if err != nil || len(got) != 250 || stats != 250 {
+		t.Fatalf("Got %d results after %d STATs: %v", len(got), stats, err)
+	}
+	for i, id := range ids {
+		if got[id] != (i%2 == 0) {
+			t.Errorf("%s: %v", id, got[id])
+		}
+	}
+
+	// A failure stops the run after the batch it's in.
+	ids[150] = "<broken@x>"
+	got, err = c.HasArticles(ids)
+	if err == nil || len(got) != 100 {
+		t.Errorf("Got %d results: %v", len(got), err)
+	}
+	if _, err := c.HasArticle("<even0@x>"); err != nil {
+		t.Errorf("Connection out of step: %v", err)
+	}
+}
+
+func TestArticleSpecifiers(t *testing.T) {
+	var sent []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		verb, _ := nextField(line)
+		if verb == "STAT" {
+			c.PrintfLine("223 1 <a@b>")
+			return
+		}
+		code := map[string]int{"ARTICLE": 220, "HEAD": 221, "BODY": 222}[verb]
+		writeLines(c, code, "1 <a@b>", "Subject: x")
+	})
+	tests := []struct {
+		spec, want string
+	}{
+		{"a@b", "<a@b>"},
+		{"<a@b>", "<a@b>"},
+		{" a@b ", "<a@b>"},
+		{"12", "12"},
+		{"", ""},
+		{"a b@c", "error"},
+		{"junk", "error"},
+		{"a@b@c", "error"},
+		{"<a@b>\r\nQUIT", "error"},
+	}
+	for _, verb := range []string{"ARTICLE", "HEAD", "BODY", "STAT"} {
+		for _, test := range tests {
+			sent = nil
+			var err error
+			switch verb {
+			case "ARTICLE":
+				_, _, r, aerr := c.Article(test.spec)
+				readAll(r)
+				err = aerr
+			case "HEAD":
+				_, _, r, aerr := c.Head(test.spec)
+				readAll(r)
+				err = aerr
+			case "BODY":
+				_, _, r, aerr := c.Body(test.spec)
+				readAll(r)
+				err = aerr
+			case "STAT":
+				_, _, err = c.Stat(test.spec)
+			}
+			want := strings.TrimSpace(verb + " " + test.want)
+			if test.want == "error" {
+				if err == nil || len(sent) != 0 {
+					t.Errorf("%s %q: sent %q, %v", verb, test.spec, sent, err)
+				}
+				continue
+			}
+			if err != nil || len(sent) != 1 || sent[0] != want {
+				t.Errorf("%s %q: sent %q, %v; want %q", verb, test.spec, sent, err, want)
+			}
+		}
+	}
+}
+
+func readAll(r io.Reader) {
+	if r != nil {
+		io.Copy(ioutil.Discard, r)
+	}
+}
+
+func TestMissingArticles(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch {
+		case line == "STAT 1":
+			c.PrintfLine("223 1 <ok@b>")
+		case strings.HasSuffix(line, "<gone@b>"):
+			c.PrintfLine("430 No such article")
+		case strings.HasSuffix(line, " 99"), strings.HasSuffix(line, " 5-9"):
+			c.PrintfLine("423 No articles in that range")
+		default:
+			c.PrintfLine("420 Current article number is invalid")
+		}
+	})
+
+	calls := map[string]func(string) error{
+		"ARTICLE": func(s string) error { _, _, _, err := c.Article(s); return err },
+		"HEAD":    func(s string) error { _, _, _, err := c.Head(s); return err },
+		"BODY":    func(s string) error { _, _, _, err := c.Body(s); return err },
+		"STAT":    func(s string) error { _, _, err := c.Stat(s); return err },
+		"OVER": func(s string) error {
+			if s == "" {
+				// Over always needs a range.
+				s = "5-9"
+			}
+			_, err := c.Over(s)
+			return err
+		},
+	}
+	for verb, call := range calls {
+		for _, spec := range []string{"<gone@b>", "99", ""} {
+			err := call(spec)
+			if !errors.Is(err, ErrNoSuchArticle) {
+				t.Errorf("%s %q: %v", verb, spec, err)
+			} else if !strings.Contains(err.Error(), verb) || !strings.Contains(err.Error(), spec) {
+				t.Errorf("%s %q: error doesn't name the command: %v", verb, spec, err)
+			}
+			if n, _, err := c.Stat("1"); n != 1 || err != nil {
+				t.Fatalf("after %s %q: %d %v", verb, spec, n, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package nntpclient
+
+import (
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+	"github.com/yannik995/go-nntp/newsrc"
+)
+
+// GroupStatus is what a poll found for a subscribed group.
+type GroupStatus struct {
+	Name string
+	// Low and High are the group's watermarks.
+	Low, High int64
+	// Unread counts the articles between the watermarks not marked read.
+	Unread int64
+	// New counts articles that arrived since the previous poll.
+	New int64
+	// Renumbered is set when the group's numbers went backwards, and its
+	// read articles were forgotten.
+	Renumbered bool
+	// Err is set if the group couldn't be selected.
+	Err error
+}
+
+// Subscriptions tracks subscribed groups and read articles in a .newsrc
+// file, and polls the subscribed groups for new articles.
+type Subscriptions struct {
+	// SaveOnChange saves the file after every change rather than only
+	// from Save and Run.
+	SaveOnChange bool
+
+	path  string
+	mu    sync.Mutex
+	rc    *newsrc.Newsrc
+	marks map[string]nntp.Group
+	dirty bool
+}
+
+// OpenSubscriptions loads the .newsrc file at path, which needn't exist.
+func OpenSubscriptions(path string) (*Subscriptions, error) {
+	rc, err := newsrc.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscriptions{path: path, rc: rc, marks: map[string]nntp.Group{}}, nil
+}
+
+// Subscribed returns the subscribed groups in file order.
+func (s *Subscriptions) Subscribed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rv []string
+	for _, g := range s.rc.Groups() {
+		if g.Subscribed {
+			rv = append(rv, g.Name)
+		}
+	}
+	return rv
+}
+
+// Subscribe to a group, adding it to the file if necessary.
+func (s *Subscriptions) Subscribe(name string) error {
+	return s.change(name, func(g *newsrc.Group) bool {
+		changed := !g.Subscribed
+		g.Subscribed = true
+		return changed
+	})
+}
+
+// Unsubscribe from a group.  Its read articles are kept.
+func (s *Subscriptions) Unsubscribe(name string) error {
+	return s.change(name, func(g *newsrc.Group) bool {
+		changed := g.Subscribed
+		g.Subscribed = false
+		return changed
+	})
+}
+
+// MarkRead marks an article read.
+func (s *Subscriptions) MarkRead(name string, number int64) error {
+	return s.MarkRangeRead(name, number, number)
+}
+
+// MarkRangeRead marks the articles from low to high read.
+func (s *Subscriptions) MarkRangeRead(name string, low, high int64) error {
+	return s.change(name, func(g *newsrc.Group) bool {
+		before := g.Read.Count()
+		g.Read.AddRange(low, high)
+		return g.Read.Count() != before
+	})
+}
+
+// MarkAllRead marks everything up to the group's high watermark, as of
+// the last poll, read.
+func (s *Subscriptions) MarkAllRead(name string) error {
+	s.mu.Lock()
+	high := s.marks[name].High
+	s.mu.Unlock()
+	if high < 1 {
+		return nil
+	}
+	return s.MarkRangeRead(name, 1, high)
+}
+
+// IsRead reports whether an article is marked read.
+func (s *Subscriptions) IsRead(name string, number int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.rc.Group(name)
+	return g != nil && g.Read.Contains(number)
+}
+
+// Unread returns the number of unread articles in a group, as of the
+// last poll.
+func (s *Subscriptions) Unread(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unread(name)
+}
+
+func (s *Subscriptions) unread(name string) int64 {
+	m, ok := s.marks[name]
+	if !ok || m.High < m.Low {
+		return 0
+	}
+	var read int64
+	if g := s.rc.Group(name); g != nil {
+		read = g.Read.CountIn(m.Low, m.High)
+	}
+	return m.High - m.Low + 1 - read
+}
+
+// change applies fn to the named group, saving if it reports a change
+// and SaveOnChange is set.
+func (s *Subscriptions) change(name string, fn func(*newsrc.Group) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fn(s.rc.Add(name)) {
+		s.dirty = true
+		if s.SaveOnChange {
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// Save writes the file if anything changed since it was last written.
+func (s *Subscriptions) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+func (s *Subscriptions) save() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.rc.Save(s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Poll selects each subscribed group with c and reports its status.
+//
+// A group whose high watermark went down, or whose read articles go
+// beyond its high watermark, has been renumbered by the server; its read
+// articles no longer mean anything and are forgotten.
+func (s *Subscriptions) Poll(c *Client) ([]GroupStatus, error) {
+	var rv []GroupStatus
+	for _, name := range s.Subscribed() {
+		g, err := c.Group(name)
+		if err != nil {
+			if _, ok := err.(*textproto.Error); !ok {
+				return rv, err
+			}
+			rv = append(rv, GroupStatus{Name: name, Err: err})
+			continue
+		}
+		rv = append(rv, s.update(name, g))
+	}
+	return rv, nil
+}
+
+// update records a group's watermarks.
+func (s *Subscriptions) update(name string, g nntp.Group) GroupStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := GroupStatus{Name: name, Low: g.Low, High: g.High}
+	prev, seen := s.marks[name]
+	rg := s.rc.Add(name)
+	if (seen && g.High < prev.High) || rg.Read.Max() > g.High {
+		st.Renumbered = true
+		rg.Read = newsrc.RangeSet{}
+		s.dirty = true
+		seen = false
+	}
+	if seen && g.High > prev.High {
+		st.New = g.High - prev.High
+	}
+	s.marks[name] = g
+	st.Unread = s.unread(name)
+	if st.Renumbered && s.SaveOnChange {
+		st.Err = s.save()
+	}
+	return st
+}
+
+// Run polls every interval until stop is closed, calling fn with the
+// results and saving any changes after each poll.  It returns the first
+// connection error.
+func (s *Subscriptions) Run(c *Client, interval time.Duration, stop <-chan struct{}, fn func([]GroupStatus)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		st, err := s.Poll(c)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			fn(st)
+		}
+		if err := s.Save(); err != nil {
+			return err
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
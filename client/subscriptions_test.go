@@ -0,0 +1,80 @@
+package nntpclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSubscriptions(t *testing.T) {
+	var mu sync.Mutex
+	marks := map[string][2]int64{"misc.test": {1, 100}, "alt.test": {10, 20}}
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := strings.TrimPrefix(line, "GROUP ")
+		m, ok := marks[name]
+		if !ok {
+			c.PrintfLine("411 No such group")
+			return
+		}
+		c.PrintfLine("211 %d %d %d %s", m[1]-m[0]+1, m[0], m[1], name)
+	})
+	setMarks := func(name string, low, high int64) {
+		mu.Lock()
+		marks[name] = [2]int64{low, high}
+		mu.Unlock()
+	}
+
+	path := filepath.Join(t.TempDir(), "newsrc")
+	err := ioutil.WriteFile(path, []byte("misc.test: 1-50\nalt.test: 1-30\ngone.test: 1-5\nother! 1-3\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenSubscriptions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := s.Poll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(st[0].Unread, st[0].Renumbered, st[1].Unread, st[1].Renumbered, st[2].Err != nil)
+	// alt.test was read beyond its high watermark, so it was renumbered.
+	if got != "50 false 11 true true" {
+		t.Errorf("First poll: %s", got)
+	}
+
+	s.MarkRangeRead("misc.test", 51, 60)
+	s.MarkRead("misc.test", 100)
+	setMarks("misc.test", 1, 110)
+	st, _ = s.Poll(c)
+	if st[0].New != 10 || st[0].Unread != 49 {
+		t.Errorf("Second poll: %+v", st[0])
+	}
+
+	// The server renumbered misc.test.
+	setMarks("misc.test", 1, 5)
+	st, _ = s.Poll(c)
+	if !st[0].Renumbered || st[0].Unread != 5 || s.IsRead("misc.test", 1) {
+		t.Errorf("Renumbered poll: %+v", st[0])
+	}
+
+	s.MarkAllRead("misc.test")
+	if s.Unread("misc.test") != 0 {
+		t.Errorf("Unread after MarkAllRead: %d", s.Unread("misc.test"))
+	}
+	s.Unsubscribe("alt.test")
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "misc.test: 1-5\nalt.test!\ngone.test: 1-5\nother! 1-3\n" {
+		t.Errorf("Saved %q", data)
+	}
+}
@@ -0,0 +1,198 @@
+package nntpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// startTLSServer serves STARTTLS with cert, then CAPABILITIES and QUIT.
+func startTLSServer(t *testing.T, cert tls.Certificate) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				c := textproto.NewConn(nc)
+				c.PrintfLine("200 fake server ready")
+				if line, _ := c.ReadLine(); line != "STARTTLS" {
+					return
+				}
+				c.PrintfLine("382 go ahead")
+				tc := tls.Server(nc, &tls.Config{Certificates: []tls.Certificate{cert}})
+				c = textproto.NewConn(tc)
+				for {
+					line, err := c.ReadLine()
+					if err != nil {
+						return
+					}
+					switch line {
+					case "CAPABILITIES":
+						writeLines(c, 101, "Capabilities", "VERSION 2", "READER")
+					case "QUIT":
+						c.PrintfLine("205 bye")
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestStartTLSServerName(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	addr := startTLSServer(t, leaf)
+
+	c, err := New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	verified := false
+	err = c.StartTLS(&tls.Config{
+		RootCAs: pool,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			// Passed through untouched, and run after verification.
+			verified = len(cs.VerifiedChains) > 0
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("StartTLS: %v", err)
+	}
+	if !verified || !c.HasTLS() || !c.Caps().Has("READER") {
+		t.Errorf("Verified %v, TLS %v, caps %v", verified, c.HasTLS(), c.Caps().Lines())
+	}
+
+	c, err = New("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	err = c.StartTLS(&tls.Config{RootCAs: pool, ServerName: "news.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "news.example.com") {
+		t.Errorf("StartTLS with the wrong name: %v", err)
+	}
+}
+
+func TestServerName(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	_, port, _ := net.SplitHostPort(tlsServer(t, leaf))
+
+	// Dial by address, but verify the name on the certificate.
+	sc := ServerConfig{Addr: "127.0.0.1:" + port, TLS: &tls.Config{RootCAs: pool}, ServerName: "localhost"}
+	c, err := sc.Dial()
+	if err != nil {
+		t.Fatalf("Dial with ServerName: %v", err)
+	}
+	c.Close()
+	sc.ServerName = "news.example.com"
+	if c, err := sc.Dial(); err == nil {
+		c.Close()
+		t.Errorf("Dial with the wrong ServerName succeeded")
+	}
+}
+
+func TestTLSConnectionState(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	config := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+
+	plain := fakeServer(t, func(c *textproto.Conn, line string) {})
+	if _, ok := plain.TLSConnectionState(); ok {
+		t.Errorf("TLS state without TLS")
+	}
+
+	addr := tlsServer(t, leaf)
+	dialed, err := NewTLS("tcp", addr, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialed.Close()
+	tc, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	given, err := NewConn(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer given.Close()
+	for name, c := range map[string]*Client{"NewTLS": dialed, "NewConn": given} {
+		cs, ok := c.TLSConnectionState()
+		if !ok || cs.Version == 0 || len(cs.PeerCertificates) == 0 ||
+			cs.PeerCertificates[0].Subject.CommonName != "localhost" {
+			t.Errorf("%s: state %v %+v", name, ok, cs)
+		}
+		if c.RemoteAddr().String() != addr || c.LocalAddr() == nil {
+			t.Errorf("%s: addresses %v %v", name, c.RemoteAddr(), c.LocalAddr())
+		}
+	}
+}
+
+func TestStartTLSChecks(t *testing.T) {
+	ca := testCert(t, "Test CA", nil, true)
+	leaf := testCert(t, "localhost", &ca, false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	config := &tls.Config{RootCAs: pool}
+
+	c, err := New("tcp", startTLSServer(t, leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.StartTLS(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartTLS(config); err == nil {
+		t.Errorf("STARTTLS twice succeeded")
+	}
+
+	// Capabilities without STARTTLS.
+	c = fakeServer(t, func(c *textproto.Conn, line string) {
+		if line == "CAPABILITIES" {
+			writeLines(c, 101, "Capabilities", "VERSION 2", "READER")
+		}
+	})
+	if _, err := c.Capabilities(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartTLS(config); err != ErrNoStartTLS {
+		t.Errorf("STARTTLS not advertised: %v", err)
+	}
+
+	// A response injected after 382, in the same packet.
+	c = fakeServer(t, func(c *textproto.Conn, line string) {
+		if line == "STARTTLS" {
+			c.W.WriteString("382 go ahead\r\n211 1 1 1 injected\r\n")
+			c.W.Flush()
+		}
+	})
+	if err := c.StartTLS(config); err != ErrPlaintextInjection {
+		t.Errorf("Injected response: %v", err)
+	}
+	if _, err := c.Date(); err == nil {
+		t.Errorf("Connection still open after the injection")
+	}
+}
@@ -0,0 +1,127 @@
+package nntpclient
+
+import (
+	"context"
+	"io"
+	"net/textproto"
+	"strings"
+)
+
+// Attribute keys the client puts on spans.
+const (
+	AttrServer   = "nntp.server"
+	AttrVerb     = "nntp.verb"
+	AttrCode     = "nntp.code"
+	AttrBytes    = "nntp.bytes"
+	AttrSegments = "nntp.segments"
+	AttrMissing  = "nntp.missing"
+	AttrDialed   = "nntp.pool.dialed"
+)
+
+// An Attr is a key and value describing a span.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// An EndFunc finishes a span, recording its outcome.
+type EndFunc func(err error, attrs ...Attr)
+
+// A Tracer records spans: the commands a Client sends and the larger
+// operations, like a Fetcher job, made of them.  It is deliberately small
+// so that an adapter for OpenTelemetry or similar can live outside this
+// package.
+//
+// StartSpan returns the context that children of the span are started
+// in.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, EndFunc)
+}
+
+// SetContext sets the context the client's spans are started in, so they
+// become children of the caller's span, and which bounds the commands
+// sent from then on.  The context's deadline becomes the connection's,
+// and cancelling it interrupts the command in progress, even part way
+// through a data block, with the context's error.  NNTP can't abandon a
+// response, so an interrupted connection is unusable and should be
+// closed.
+func (c *Client) SetContext(ctx context.Context) {
+	c.mu.Lock()
+	c.ctx = ctx
+	cc := c.cc
+	c.mu.Unlock()
+	if cc != nil {
+		cc.watch(ctx)
+	}
+}
+
+// Context returns the context set with SetContext, or the background
+// context.
+func (c *Client) Context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// startSpan starts a span for cmd, or returns nil if the client has no
+// Tracer.  Nothing is allocated in that case.
+func (c *Client) startSpan(name, cmd string) EndFunc {
+	if c.Tracer == nil {
+		return nil
+	}
+	verb := cmd
+	if i := strings.IndexByte(cmd, ' '); i != -1 {
+		verb = cmd[:i]
+	}
+	attrs := []Attr{{AttrVerb, strings.ToUpper(verb)}}
+	c.mu.Lock()
+	netconn := c.netconn
+	c.mu.Unlock()
+	if netconn != nil {
+		attrs = append(attrs, Attr{AttrServer, netconn.RemoteAddr().String()})
+	}
+	_, end := c.Tracer.StartSpan(c.Context(), name, attrs...)
+	return end
+}
+
+// endSpan finishes a span started by startSpan.  A negative n leaves out
+// the byte count.
+func endSpan(end EndFunc, code int, n int64, err error) {
+	if end == nil {
+		return
+	}
+	if terr, ok := err.(*textproto.Error); ok {
+		code = terr.Code
+	}
+	attrs := []Attr{{AttrCode, code}}
+	if n >= 0 {
+		attrs = append(attrs, Attr{AttrBytes, n})
+	}
+	end(err, attrs...)
+}
+
+// spanReader counts the bytes of an article as they're read and finishes
+// its span at the end.
+type spanReader struct {
+	r    io.Reader
+	end  EndFunc
+	code int
+	n    int64
+}
+
+func (s *spanReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	if err != nil && s.end != nil {
+		if err == io.EOF {
+			endSpan(s.end, s.code, s.n, nil)
+		} else {
+			endSpan(s.end, s.code, s.n, err)
+		}
+		s.end = nil
+	}
+	return n, err
+}
@@ -0,0 +1,116 @@
+package nntpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type span struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type spanKey struct{}
+
+// recordingTracer keeps every span it's asked to start.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, EndFunc) {
+	s := &span{name: name, attrs: map[string]interface{}{}}
+	if p, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.parent = p.name
+	}
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), func(err error, attrs ...Attr) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		s.err, s.ended = err, true
+		for _, a := range attrs {
+			s.attrs[a.Key] = a.Value
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch {
+		case strings.HasPrefix(line, "GROUP"):
+			c.PrintfLine("211 2 1 2 misc.test")
+		case strings.HasPrefix(line, "BODY"):
+			writeLines(c, 222, "1 <a@b> body", "hello", "world")
+		case line == "POST":
+			c.PrintfLine("340 send it")
+			c.ReadDotLines()
+			c.PrintfLine("240 thanks")
+		default:
+			c.PrintfLine("500 what?")
+		}
+	})
+	tracer := &recordingTracer{}
+	c.Tracer = tracer
+	ctx, _ := tracer.StartSpan(context.Background(), "job")
+	c.SetContext(ctx)
+
+	c.Group("misc.test")
+	_, _, body, err := c.Body("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(body)
+	c.Post(strings.NewReader("Subject: x\r\n\r\nhi\r\n"))
+	c.Command("FOO", 200)
+
+	want := []struct {
+		name, verb string
+		code       int
+		bytes      int64
+		failed     bool
+	}{
+		{"nntp.command", "GROUP", 211, -1, false},
+		{"nntp.article", "BODY", 222, 12, false},
+		{"nntp.post", "POST", 240, 18, false},
+		{"nntp.command", "FOO", 500, -1, true},
+	}
+	spans := tracer.spans[1:]
+	if len(spans) != len(want) {
+		t.Fatalf("Got %d spans, want %d", len(spans), len(want))
+	}
+	for i, w := range want {
+		s := spans[i]
+		if s.name != w.name || s.attrs[AttrVerb] != w.verb || s.attrs[AttrCode] != w.code ||
+			!s.ended || (s.err != nil) != w.failed || s.parent != "job" {
+			t.Errorf("Span %d: %+v", i, s)
+		}
+		if w.bytes >= 0 && s.attrs[AttrBytes] != w.bytes {
+			t.Errorf("Span %d bytes %v, want %d", i, s.attrs[AttrBytes], w.bytes)
+		}
+		if s.attrs[AttrServer] == nil {
+			t.Errorf("Span %d has no server", i)
+		}
+	}
+}
+
+func TestNoTracerAllocs(t *testing.T) {
+	c := &Client{}
+	allocs := testing.AllocsPerRun(100, func() {
+		endSpan(c.startSpan("nntp.command", "GROUP misc.test"), 211, -1, nil)
+	})
+	if allocs != 0 {
+		t.Errorf("Untraced span allocated %v times", allocs)
+	}
+}
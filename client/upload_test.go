@@ -0,0 +1,96 @@
+package nntpclient
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestCommandUpload(t *testing.T) {
+	var got []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "XREPLIC misc.test:3":
+			c.PrintfLine("335 Send it")
+			lines, err := c.ReadDotLines()
+			if err != nil {
+				return
+			}
+			got = lines
+			c.PrintfLine("235 Replicated")
+		default:
+			c.PrintfLine("437 Not here")
+		}
+	})
+
+	code, msg, err := c.CommandUpload("XREPLIC misc.test:3", 335, 235,
+		strings.NewReader("Subject: hi\r\n\r\n.body\r\n"))
+	if err != nil || code != 235 || msg != "Replicated" {
+		t.Errorf("Got %d %q, %v", code, msg, err)
+	}
+	if strings.Join(got, "|") != "Subject: hi||.body" {
+		t.Errorf("Server got %q", got)
+	}
+
+	read := false
+	r := readerFunc(func([]byte) (int, error) {
+		read = true
+		return 0, io.EOF
+	})
+	code, _, err = c.CommandUpload("XREPLIC misc.test:4", 335, 235, r)
+	if code != 437 || err == nil || read {
+		t.Errorf("Refused upload: %d, %v, read %v", code, err, read)
+	}
+
+	// A failing reader still ends the block.
+	broken := errors.New("disk error")
+	code, _, err = c.CommandUpload("XREPLIC misc.test:3", 335, 235,
+		io.MultiReader(strings.NewReader("Subject: half\r\n"),
+			readerFunc(func([]byte) (int, error) { return 0, broken })))
+	if err != broken || code != 235 {
+		t.Errorf("Failed read: %d, %v", code, err)
+	}
+	if _, err := c.Date(); err == nil || !strings.Contains(err.Error(), "437") {
+		t.Errorf("Connection out of step: %v", err)
+	}
+}
+
+func TestLastResponse(t *testing.T) {
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch line {
+		case "POST":
+			c.PrintfLine("340 Send article")
+			if _, err := c.ReadDotLines(); err != nil {
+				return
+			}
+			c.PrintfLine("240 <new@example.com> Article received")
+		case "LIST NEWSGROUPS":
+			writeLines(c, 215, "Descriptions follow", "misc.test Testing")
+		default:
+			c.PrintfLine("199 Whatever you say")
+		}
+	})
+	if code, msg := c.LastResponse(); code != 200 || msg != "fake server ready" {
+		t.Errorf("Greeting: %d %q", code, msg)
+	}
+	if err := c.Post(strings.NewReader("Subject: hi\r\n\r\nbody\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if code, msg := c.LastResponse(); code != 240 || msg != "<new@example.com> Article received" {
+		t.Errorf("After Post: %d %q", code, msg)
+	}
+	if _, err := c.ListNewsgroups(""); err != nil {
+		t.Fatal(err)
+	}
+	if code, msg := c.LastResponse(); code != 215 || msg != "Descriptions follow" {
+		t.Errorf("After a data block: %d %q", code, msg)
+	}
+	if _, _, err := c.Command("XSOMETHING", -1); err != nil {
+		t.Fatal(err)
+	}
+	if code, msg := c.LastResponse(); code != 199 || msg != "Whatever you say" {
+		t.Errorf("After Command: %d %q", code, msg)
+	}
+}
@@ -0,0 +1,100 @@
+package nntpclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// gzipMarker flags a response whose data block is compressed after
+// XFEATURE COMPRESS GZIP.
+const gzipMarker = "[COMPRESS=GZIP]"
+
+// EnableXFeatureCompressGzip turns on the XFEATURE COMPRESS GZIP
+// extension some providers, like Giganews, offer.  Over and Hdr then
+// receive their data compressed, which for overview is typically a
+// fraction of the size; they decompress it transparently, and still
+// accept responses the server chose not to compress.
+func (c *Client) EnableXFeatureCompressGzip() error {
+	if _, _, err := c.tracedCommand("XFEATURE COMPRESS GZIP", 290, ""); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.xfeatureGzip = true
+	c.mu.Unlock()
+	return nil
+}
+
+// responseData returns where to read the data block of a response with
+// the text msg: the connection, or the decompressed block if it's
+// marked as compressed.
+func (c *Client) responseData(msg string) (*bufio.Reader, error) {
+	c.mu.Lock()
+	gzipped := c.xfeatureGzip && strings.Contains(msg, gzipMarker)
+	c.mu.Unlock()
+	if !gzipped {
+		return c.conn.R, nil
+	}
+	data, err := c.inflateBlock()
+	if err != nil {
+		// There's no telling where the block ends.
+		c.CloseNow()
+		return nil, fmt.Errorf("reading compressed response: %w", err)
+	}
+	return bufio.NewReader(bytes.NewReader(data)), nil
+}
+
+// inflateBlock reads a compressed data block and returns it
+// decompressed, still dot-encoded and terminated.
+//
+// The compressed stream is zlib, or gzip from some servers.  It either
+// contains the terminating dot line, or is followed by one, depending
+// on the server, so both are accepted.  The stream is read through the
+// connection's bufio.Reader, so decompression stops exactly at its end.
+func (c *Client) inflateBlock() ([]byte, error) {
+	br := c.conn.R
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	var zr io.ReadCloser
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		// Don't read on into the terminator looking for another member.
+		gz.Multistream(false)
+		zr = gz
+	} else if zr, err = zlib.NewReader(br); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	zr.Close()
+	if hasTerminator(data) {
+		return data, nil
+	}
+	line, err := c.conn.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if line != "." {
+		return nil, fmt.Errorf("expected end of block after compressed data, got %q", line)
+	}
+	return append(data, ".\r\n"...), nil
+}
+
+// hasTerminator reports whether a data block ends with its terminating
+// dot line.
+func hasTerminator(data []byte) bool {
+	return bytes.Equal(data, []byte(".\r\n")) || bytes.Equal(data, []byte(".\n")) ||
+		bytes.HasSuffix(data, []byte("\n.\r\n")) || bytes.HasSuffix(data, []byte("\n.\n"))
+}
@@ -0,0 +1,88 @@
+package nntpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestXFeatureCompressGzip(t *testing.T) {
+	overview := []string{
+		"3\tHello\tfred@example.com\t5 Mar 2021 23:30:01 +0000\t<a@example.com>\t\t120\t4",
+		"4\t.Dotted\tjo@example.com\t6 Mar 2021 08:00:00 +0000\t<b@example.com>\t<a@example.com>\t200\t6",
+	}
+	block := strings.Join([]string{overview[0], "." + overview[1]}, "\r\n") + "\r\n"
+	compress := func(gz bool, data string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser = zlib.NewWriter(&buf)
+		if gz {
+			w = gzip.NewWriter(&buf)
+		}
+		io.WriteString(w, data)
+		w.Close()
+		return buf.Bytes()
+	}
+	responses := map[string][]byte{
+		// The terminator inside the compressed data.
+		"1-2": compress(false, block+".\r\n"),
+		// The terminator after it.
+		"3-4": append(compress(false, block), ".\r\n"...),
+		"5-6": append(compress(true, block), ".\r\n"...),
+		"9-9": []byte("not compressed at all\r\n.\r\n"),
+	}
+
+	enabled := false
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		cmd, rest := nextField(line)
+		rng := strings.TrimSpace(rest)
+		switch {
+		case line == "XFEATURE COMPRESS GZIP":
+			enabled = true
+			c.PrintfLine("290 feature enabled")
+		case cmd == "OVER" && enabled && responses[rng] != nil:
+			c.PrintfLine("224 Overview follows [COMPRESS=GZIP]")
+			c.W.Write(responses[rng])
+			c.W.Flush()
+		case cmd == "OVER":
+			writeLines(c, 224, "Overview follows", overview...)
+		case line == "HDR Subject 3-4":
+			c.PrintfLine("225 Headers follow [COMPRESS=GZIP]")
+			c.W.Write(compress(false, "3 Hello\r\n4 .Dotted\r\n.\r\n"))
+			c.W.Flush()
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+	if err := c.EnableXFeatureCompressGzip(); err != nil {
+		t.Fatal(err)
+	}
+	for _, rng := range []string{"1-2", "3-4", "5-6", "7-8", "1-2"} {
+		lines, err := c.Over(rng)
+		if err != nil {
+			t.Fatalf("Over(%s): %v", rng, err)
+		}
+		if strings.Join(lines, "\n") != strings.Join(overview, "\n") {
+			t.Errorf("Over(%s) = %q", rng, lines)
+		}
+	}
+	hdrs, err := c.Hdr("Subject", "3-4")
+	if err != nil || hdrs[3] != "Hello" || hdrs[4] != ".Dotted" {
+		t.Errorf("Hdr: %q, %v", hdrs, err)
+	}
+	if _, err := c.Over("1-2"); err != nil {
+		t.Errorf("Connection out of step: %v", err)
+	}
+
+	// Without knowing where a corrupt block ends, the connection is
+	// given up.
+	if _, err := c.Over("9-9"); err == nil {
+		t.Errorf("Corrupt block accepted")
+	}
+	if _, err := c.Over("1-2"); err == nil {
+		t.Errorf("Connection still in use after a corrupt block")
+	}
+}
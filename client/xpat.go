@@ -0,0 +1,48 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+	"strings"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// ErrXPatUnsupported is returned by XPat when the server doesn't know
+// XPAT (500) or won't take the arguments (501), in which case the
+// headers have to be fetched with Hdr and matched locally.
+var ErrXPatUnsupported = errors.New("XPAT not supported")
+
+// XPat searches a header across a range of articles in the current
+// group, or a message-id, for values matching any of the wildmat
+// patterns, returning the matching values keyed by article number.
+// Articles found by message-id are keyed 0.
+//
+// XPAT is a common extension rather than part of RFC 3977.  Errors for
+// servers without it match ErrXPatUnsupported.
+func (c *Client) XPat(field string, rng string, patterns ...string) (map[int64]string, error) {
+	if rng == "" || len(patterns) == 0 {
+		return nil, errors.New("XPAT needs a range or message-id and at least one pattern")
+	}
+	for _, p := range patterns {
+		if err := nntp.ValidateWildmat(p); err != nil {
+			return nil, err
+		}
+	}
+	cmd := "XPAT " + field + " " + rng + " " + strings.Join(patterns, " ")
+	_, msg, err := c.tracedCommand(cmd, 221, "")
+	if terr, ok := err.(*textproto.Error); ok && (terr.Code == 500 || terr.Code == 501) {
+		return nil, &codedError{terr, ErrXPatUnsupported}
+	}
+	if err != nil {
+		return nil, articleError(cmd, err)
+	}
+	rv := map[int64]string{}
+	err = c.eachHdrLine(msg, func(n int64, value string) {
+		rv[n] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
@@ -0,0 +1,48 @@
+package nntpclient
+
+import (
+	"errors"
+	"net/textproto"
+	"testing"
+)
+
+func TestXPat(t *testing.T) {
+	var sent []string
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		sent = append(sent, line)
+		switch line {
+		case "XPAT Subject 1-10 *.rar* *.zip*":
+			writeLines(c, 221, "Header follows", "3 files.rar", "7 more.zip")
+		case "XPAT Subject <a@x> *":
+			writeLines(c, 221, "Header follows", "0 hello")
+		case "XPAT Subject 1- *":
+			c.PrintfLine("500 What?")
+		default:
+			c.PrintfLine("501 Syntax error")
+		}
+	})
+	got, err := c.XPat("Subject", "1-10", "*.rar*", "*.zip*")
+	if err != nil || len(got) != 2 || got[3] != "files.rar" || got[7] != "more.zip" {
+		t.Errorf("XPat = %v, %v", got, err)
+	}
+	if got, err = c.XPat("Subject", "<a@x>", "*"); err != nil || got[0] != "hello" {
+		t.Errorf("XPat by message-id = %v, %v", got, err)
+	}
+	for _, rng := range []string{"1-", "2-"} {
+		_, err = c.XPat("Subject", rng, "*")
+		var terr *textproto.Error
+		if !errors.Is(err, ErrXPatUnsupported) || !errors.As(err, &terr) {
+			t.Errorf("XPat %s = %v, want ErrXPatUnsupported", rng, err)
+		}
+	}
+
+	n := len(sent)
+	for _, bad := range [][]string{nil, {"a b"}, {"*", ""}} {
+		if _, err := c.XPat("Subject", "1-", bad...); err == nil {
+			t.Errorf("XPat with patterns %q succeeded", bad)
+		}
+	}
+	if len(sent) != n {
+		t.Errorf("Sent %q with bad patterns", sent[n:])
+	}
+}
@@ -0,0 +1,78 @@
+package nntpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	nntpencoding "github.com/yannik995/go-nntp/encoding"
+)
+
+// ErrNoXZVer is returned by XZVer when the server's capabilities don't
+// list XZVER.
+var ErrNoXZVer = errors.New("server doesn't advertise XZVER")
+
+// XZVer is Over for servers with the XZVER extension, which sends the
+// overview compressed and wrapped in yEnc.  It returns the same lines
+// Over does.
+//
+// Capabilities must list XZVER; they're retrieved if they haven't been.
+// A block that fails its CRC, or ends early, is an error; no lines are
+// returned from it.
+func (c *Client) XZVer(specifier string) ([]string, error) {
+	if !c.Supports("XZVER") {
+		return nil, ErrNoXZVer
+	}
+	if _, _, err := c.tracedCommand("XZVER "+specifier, 224, ""); err != nil {
+		return nil, err
+	}
+	defer c.done()
+	dr := c.conn.DotReader()
+	var compressed bytes.Buffer
+	_, err := nntpencoding.YEnc{}.Decode(&compressed, dr)
+	// Leave the connection ready for the next command.
+	if _, derr := io.Copy(ioutil.Discard, dr); err == nil {
+		err = derr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("XZVER: %w", err)
+	}
+	data, err := inflate(compressed.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("XZVER: inflating overview: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	rv := lines[:0]
+	for _, l := range lines {
+		l = strings.TrimSuffix(l, "\r")
+		if l == "." {
+			// Some servers compress the terminator too.
+			break
+		}
+		if l != "" {
+			rv = append(rv, l)
+		}
+	}
+	return rv, nil
+}
+
+// inflate decompresses zlib data, or raw deflate, which some servers
+// send without the zlib header.
+func inflate(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	if len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0 {
+		var err error
+		if r, err = zlib.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,95 @@
+package nntpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"testing"
+
+	nntpencoding "github.com/yannik995/go-nntp/encoding"
+)
+
+func TestXZVer(t *testing.T) {
+	overview := []string{
+		"3\tHello\tfred@example.com\t5 Mar 2021 23:30:01 +0000\t<a@example.com>\t\t120\t4",
+		"4\tRe: Hello\tjo@example.com\t6 Mar 2021 08:00:00 +0000\t<b@example.com>\t<a@example.com>\t200\t6",
+	}
+	plain := strings.Join(overview, "\r\n") + "\r\n.\r\n"
+	encode := func(zlibHeader bool) string {
+		var compressed bytes.Buffer
+		if zlibHeader {
+			zw := zlib.NewWriter(&compressed)
+			zw.Write([]byte(plain))
+			zw.Close()
+		} else {
+			fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+			fw.Write([]byte(plain))
+			fw.Close()
+		}
+		var buf bytes.Buffer
+		err := nntpencoding.YEnc{}.Encode(&buf, bytes.NewReader(compressed.Bytes()), &nntpencoding.Meta{
+			Name: "xzver", Size: int64(compressed.Len()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	crc := regexp.MustCompile(`crc32=[0-9a-f]+`)
+	blocks := map[string]string{
+		"1-2": encode(true),
+		"3-4": encode(false),
+		"5-6": crc.ReplaceAllString(encode(true), "crc32=00000000"),
+		"7-8": encode(true)[:strings.Index(encode(true), "=yend")],
+	}
+
+	caps := []string{"VERSION 2", "READER"}
+	without := fakeServer(t, func(c *textproto.Conn, line string) {
+		writeLines(c, 101, "Capability list:", caps...)
+	})
+	if _, err := without.XZVer("1-2"); err != ErrNoXZVer {
+		t.Errorf("Without XZVER: %v", err)
+	}
+
+	// The capabilities are retrieved by the first call.
+	c := fakeServer(t, func(c *textproto.Conn, line string) {
+		switch {
+		case line == "CAPABILITIES":
+			writeLines(c, 101, "Capability list:", append(caps, "XZVER")...)
+		case strings.HasPrefix(line, "XZVER "):
+			c.PrintfLine("224 Compressed overview follows")
+			dw := c.DotWriter()
+			dw.Write([]byte(blocks[strings.TrimPrefix(line, "XZVER ")]))
+			dw.Close()
+		default:
+			c.PrintfLine("500 Unknown command")
+		}
+	})
+
+	tests := []struct {
+		rng  string
+		want error
+	}{
+		{"1-2", nil},
+		{"3-4", nil},
+		{"5-6", nntpencoding.ErrCRCMismatch},
+		{"7-8", nntpencoding.ErrTruncated},
+		{"1-2", nil},
+	}
+	for _, test := range tests {
+		lines, err := c.XZVer(test.rng)
+		if !errors.Is(err, test.want) || (err == nil) != (test.want == nil) {
+			t.Errorf("XZVer(%s) error %v, want %v", test.rng, err, test.want)
+		}
+		if err == nil && strings.Join(lines, "\n") != strings.Join(overview, "\n") {
+			t.Errorf("XZVer(%s) = %q", test.rng, lines)
+		}
+		if err != nil && lines != nil {
+			t.Errorf("XZVer(%s) returned lines with an error", test.rng)
+		}
+	}
+}
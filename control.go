@@ -0,0 +1,141 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// Control message types.
+const (
+	ControlCancel      = "cancel"
+	ControlNewgroup    = "newgroup"
+	ControlRmgroup     = "rmgroup"
+	ControlCheckgroups = "checkgroups"
+)
+
+// GroupDescription is a line of a newsgroups file.
+type GroupDescription struct {
+	Name        string
+	Description string
+}
+
+// A Control message, see RFC 5537 section 5.
+type Control struct {
+	// Type is the lowercase control verb, such as ControlCancel.
+	Type string
+	// Args are the arguments following the verb.
+	Args []string
+	// Target is the message-id of a cancel.
+	Target string
+	// Group created or removed by newgroup and rmgroup.
+	Group string
+	// Moderated is set for a newgroup of a moderated group.
+	Moderated bool
+	// Descriptions from the body of a newgroup or checkgroups.
+	Descriptions []GroupDescription
+}
+
+// ParseControl parses the Control header of an article, and the body
+// for newgroup and checkgroups messages.
+//
+// When the body is parsed it is replaced by an in-memory copy, so the
+// article can still be stored afterwards.
+func ParseControl(a *Article) (*Control, error) {
+	fields := strings.Fields(a.Header.Get("Control"))
+	if len(fields) == 0 {
+		return nil, errors.New("not a control message")
+	}
+	c := &Control{
+		Type: strings.ToLower(fields[0]),
+		Args: fields[1:],
+	}
+
+	switch c.Type {
+	case ControlCancel:
+		if len(c.Args) != 1 || !strings.HasPrefix(c.Args[0], "<") {
+			return nil, errors.New("cancel needs one message-id")
+		}
+		c.Target = c.Args[0]
+	case ControlNewgroup, ControlRmgroup:
+		if len(c.Args) < 1 {
+			return nil, errors.New(c.Type + " needs a group name")
+		}
+		c.Group = c.Args[0]
+		c.Moderated = len(c.Args) > 1 && strings.EqualFold(c.Args[1], "moderated")
+	}
+
+	if (c.Type == ControlNewgroup || c.Type == ControlCheckgroups) && a.Body != nil {
+		body, err := ioutil.ReadAll(a.Body)
+		if err != nil {
+			return nil, err
+		}
+		a.Body = bytes.NewReader(body)
+		if c.Type == ControlNewgroup {
+			c.Descriptions = newgroupDescription(body, c.Group)
+		} else {
+			c.Descriptions = ParseDescriptions(body)
+		}
+	}
+	return c, nil
+}
+
+// splitDescription splits a newsgroups file line into the group name and
+// its description.
+func splitDescription(line string) (GroupDescription, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	i := strings.IndexAny(line, " \t")
+	if i <= 0 {
+		if line == "" {
+			return GroupDescription{}, false
+		}
+		return GroupDescription{Name: line}, true
+	}
+	return GroupDescription{
+		Name:        line[:i],
+		Description: strings.TrimLeft(line[i:], " \t"),
+	}, true
+}
+
+// ParseDescriptions parses lines of "group description", as found in
+// checkgroups bodies and newsgroups files.  Empty lines and lines
+// starting with # are skipped.
+func ParseDescriptions(data []byte) []GroupDescription {
+	var rv []GroupDescription
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if d, ok := splitDescription(line); ok {
+			rv = append(rv, d)
+		}
+	}
+	return rv
+}
+
+// newgroupDescription finds the line following "For your newsgroups
+// file:" in a newgroup body.
+func newgroupDescription(body []byte, group string) []GroupDescription {
+	s := bufio.NewScanner(bytes.NewReader(body))
+	found := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !found {
+			found = strings.EqualFold(line, "For your newsgroups file:")
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		d, _ := splitDescription(line)
+		if d.Name != group {
+			return nil
+		}
+		return []GroupDescription{d}
+	}
+	return nil
+}
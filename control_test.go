@@ -0,0 +1,50 @@
+package nntp
+
+import (
+	"io/ioutil"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseControl(t *testing.T) {
+	tests := []struct {
+		control, body string
+		exp           *Control
+	}{
+		{"cancel <a@b>", "", &Control{Type: "cancel", Args: []string{"<a@b>"}, Target: "<a@b>"}},
+		{"rmgroup  alt.dead", "", &Control{Type: "rmgroup", Args: []string{"alt.dead"}, Group: "alt.dead"}},
+		{"NEWGROUP comp.new moderated",
+			"Please create it.\r\n\r\nFor your newsgroups file:\r\ncomp.new\tNew things. (Moderated)\r\n",
+			&Control{Type: "newgroup", Args: []string{"comp.new", "moderated"},
+				Group: "comp.new", Moderated: true,
+				Descriptions: []GroupDescription{{"comp.new", "New things. (Moderated)"}}}},
+		{"checkgroups #20210101", "# comment\ncomp.a\tA\n\ncomp.b    B things\ncomp.c\n",
+			&Control{Type: "checkgroups", Args: []string{"#20210101"},
+				Descriptions: []GroupDescription{{"comp.a", "A"}, {"comp.b", "B things"}, {"comp.c", ""}}}},
+	}
+	for _, test := range tests {
+		a := &Article{
+			Header: textproto.MIMEHeader{"Control": {test.control}},
+			Body:   strings.NewReader(test.body),
+		}
+		got, err := ParseControl(a)
+		if err != nil {
+			t.Fatalf("%s: %v", test.control, err)
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%s: got %+v, wanted %+v", test.control, got, test.exp)
+		}
+		if body, _ := ioutil.ReadAll(a.Body); string(body) != test.body {
+			t.Errorf("%s: body not preserved", test.control)
+		}
+	}
+
+	for _, bad := range []string{"", "cancel", "cancel a@b", "newgroup"} {
+		a := &Article{Header: textproto.MIMEHeader{"Control": {bad}}}
+		if _, err := ParseControl(a); err == nil {
+			t.Errorf("Parsed invalid control %q", bad)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package dedup remembers which message-ids have been processed, so
+// feeding pipelines don't offer the same article twice.
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// A Store remembers message-ids.  Implementations are safe for
+// concurrent use.
+type Store interface {
+	// Seen reports whether the id has been marked and not expired.
+	Seen(id string) bool
+	// Mark records the id as processed now.
+	Mark(id string) error
+	// Expire forgets ids marked before t.
+	Expire(before time.Time) error
+}
+
+type entry struct {
+	id     string
+	marked time.Time
+}
+
+// Memory is an in-memory Store that keeps at most a fixed number of ids,
+// forgetting the least recently marked first, and optionally forgets
+// ids after a TTL.
+type Memory struct {
+	max   int
+	ttl   time.Duration
+	mu    sync.Mutex
+	order *list.List
+	ids   map[string]*list.Element
+	now   func() time.Time
+}
+
+// NewMemory creates a Memory store holding up to max ids, or any number
+// if max is zero.  A positive ttl expires ids that long after they were
+// marked.
+func NewMemory(max int, ttl time.Duration) *Memory {
+	return &Memory{
+		max:   max,
+		ttl:   ttl,
+		order: list.New(),
+		ids:   map[string]*list.Element{},
+		now:   time.Now,
+	}
+}
+
+// Seen implements Store.
+func (m *Memory) Seen(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.ids[id]
+	if !ok {
+		return false
+	}
+	if m.ttl > 0 && m.now().Sub(el.Value.(*entry).marked) >= m.ttl {
+		m.remove(el)
+		return false
+	}
+	return true
+}
+
+// Mark implements Store.
+func (m *Memory) Mark(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(id, m.now())
+	return nil
+}
+
+func (m *Memory) add(id string, t time.Time) {
+	if el, ok := m.ids[id]; ok {
+		el.Value.(*entry).marked = t
+		m.order.MoveToBack(el)
+		return
+	}
+	m.ids[id] = m.order.PushBack(&entry{id, t})
+	for m.max > 0 && m.order.Len() > m.max {
+		m.remove(m.order.Front())
+	}
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.ids, el.Value.(*entry).id)
+}
+
+// Expire implements Store.
+func (m *Memory) Expire(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Marks are kept in order, so the oldest are at the front.
+	for el := m.order.Front(); el != nil; el = m.order.Front() {
+		if !el.Value.(*entry).marked.Before(before) {
+			break
+		}
+		m.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of ids held.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
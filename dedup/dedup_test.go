@@ -0,0 +1,120 @@
+package dedup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryLRU(t *testing.T) {
+	m := NewMemory(2, 0)
+	m.Mark("<1@x>")
+	m.Mark("<2@x>")
+	m.Mark("<1@x>")
+	m.Mark("<3@x>")
+	if !m.Seen("<1@x>") || m.Seen("<2@x>") || !m.Seen("<3@x>") {
+		t.Errorf("Wrong id evicted")
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len = %d", m.Len())
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := NewMemory(0, time.Minute)
+	m.now = func() time.Time { return now }
+	m.Mark("<1@x>")
+	now = now.Add(30 * time.Second)
+	m.Mark("<2@x>")
+	if !m.Seen("<1@x>") {
+		t.Errorf("Forgot <1@x> early")
+	}
+	now = now.Add(45 * time.Second)
+	if m.Seen("<1@x>") || !m.Seen("<2@x>") {
+		t.Errorf("TTL not applied")
+	}
+	m.Expire(now)
+	if m.Len() != 0 {
+		t.Errorf("Expire left %d", m.Len())
+	}
+}
+
+func TestMemoryConcurrent(t *testing.T) {
+	var s Store = NewMemory(100, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				id := fmt.Sprintf("<%d.%d@x>", i, j)
+				s.Mark(id)
+				s.Seen(id)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "seen.log")
+
+	l, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Mark("<1@x>")
+	l.Mark("<2@x>")
+	if err := l.Mark("no brackets"); err == nil {
+		t.Errorf("Marked an invalid id")
+	}
+	l.Close()
+
+	// Simulate a crash in the middle of a write.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString("12345 <torn")
+	f.Close()
+
+	l, err = OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Seen("<1@x>") || !l.Seen("<2@x>") || l.Seen("<torn") {
+		t.Errorf("Index not rebuilt correctly")
+	}
+	l.Mark("<3@x>")
+	l.Close()
+
+	l, _ = OpenLog(path)
+	if !l.Seen("<3@x>") {
+		t.Errorf("Mark after a torn line lost")
+	}
+	if err := l.Expire(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if l.Seen("<1@x>") {
+		t.Errorf("Expire didn't forget")
+	}
+	l.Mark("<4@x>")
+	l.Close()
+
+	l, _ = OpenLog(path)
+	defer l.Close()
+	if l.Seen("<1@x>") || !l.Seen("<4@x>") {
+		t.Errorf("Expire not persisted")
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Left files behind: %v", files)
+	}
+}
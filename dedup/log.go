@@ -0,0 +1,162 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log is a Store kept in an append-only file, with an in-memory index
+// rebuilt when it's opened.  Each line is a mark's time in Unix
+// nanoseconds and the message-id.
+type Log struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	ids  map[string]time.Time
+}
+
+// OpenLog opens or creates the log at path.  A torn final line, left by
+// a crash mid-write, is ignored.
+func OpenLog(path string) (*Log, error) {
+	l := &Log{path: path, ids: map[string]time.Time{}}
+	if f, err := os.Open(path); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if id, t, ok := parseLogLine(s.Text()); ok {
+				l.ids[id] = t
+			}
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := l.openForAppend(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func parseLogLine(line string) (string, time.Time, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "<") || !strings.HasSuffix(fields[1], ">") {
+		return "", time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return fields[1], time.Unix(0, ns), true
+}
+
+func (l *Log) openForAppend() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// Start on a fresh line in case the last one was torn.
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if r, err := os.Open(l.path); err == nil {
+			r.ReadAt(last, fi.Size()-1)
+			r.Close()
+		}
+		if last[0] != '\n' {
+			if _, err := f.Write([]byte("\n")); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	l.f, l.w = f, bufio.NewWriter(f)
+	return nil
+}
+
+// Seen implements Store.
+func (l *Log) Seen(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.ids[id]
+	return ok
+}
+
+// Mark implements Store.  The mark is written through to the file
+// before Mark returns.  ids must be in angle brackets.
+func (l *Log) Mark(id string) error {
+	if strings.ContainsAny(id, " \t\r\n") || !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, ">") {
+		return fmt.Errorf("invalid message-id %q", id)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if _, err := fmt.Fprintf(l.w, "%d %s\n", now.UnixNano(), id); err != nil {
+		return err
+	}
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	l.ids[id] = now
+	return nil
+}
+
+// Expire implements Store.  The log is rewritten without the expired
+// ids and atomically replaced.
+func (l *Log) Expire(before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tmp, err := ioutil.TempFile(filepath.Dir(l.path), "."+filepath.Base(l.path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	kept := map[string]time.Time{}
+	for id, t := range l.ids {
+		if t.Before(before) {
+			continue
+		}
+		kept[id] = t
+		fmt.Fprintf(w, "%d %s\n", t.UnixNano(), id)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	l.f.Close()
+	l.ids = kept
+	return l.openForAppend()
+}
+
+// Sync flushes the log to stable storage.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Sync()
+}
+
+// Close the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -0,0 +1,68 @@
+package nntp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A Distribution is a line of LIST DISTRIBUTIONS.
+type Distribution struct {
+	Name        string
+	Description string
+}
+
+// A DistribPat is a line of LIST DISTRIB.PATS: the Distribution value
+// to use for groups matching a wildmat, weighted against other matches.
+type DistribPat struct {
+	Weight  int
+	Pattern string
+	Value   string
+}
+
+// ParseDistribPats parses the lines of a LIST DISTRIB.PATS response.
+func ParseDistribPats(lines []string) ([]DistribPat, error) {
+	var rv []DistribPat
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.New("invalid distrib.pats line: " + line)
+		}
+		w, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, errors.New("invalid distrib.pats weight: " + line)
+		}
+		rv = append(rv, DistribPat{w, parts[1], parts[2]})
+	}
+	return rv, nil
+}
+
+// ChooseDistribution returns the value of the highest weighted pattern
+// matching any of groups, or "" if none match.
+func ChooseDistribution(pats []DistribPat, groups []string) string {
+	best, rv := 0, ""
+	for _, p := range pats {
+		if rv != "" && p.Weight <= best {
+			continue
+		}
+		for _, g := range groups {
+			if MatchWildmat(p.Pattern, g) {
+				best, rv = p.Weight, p.Value
+				break
+			}
+		}
+	}
+	return rv
+}
+
+// ParseDistributions splits a Distribution header value into its
+// distributions.
+func ParseDistributions(value string) []string {
+	var rv []string
+	for _, d := range strings.Split(value, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			rv = append(rv, d)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,48 @@
+package nntp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistribPats(t *testing.T) {
+	pats, err := ParseDistribPats([]string{
+		"10:local.*:local",
+		"5:*:world",
+		"20:de.*,!de.alt.*:de",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pats[0], DistribPat{10, "local.*", "local"}) {
+		t.Errorf("Parsed %+v", pats[0])
+	}
+	tests := []struct {
+		groups []string
+		exp    string
+	}{
+		{[]string{"local.test"}, "local"},
+		{[]string{"comp.lang.go"}, "world"},
+		{[]string{"comp.lang.go", "de.comp.lang.go"}, "de"},
+		{[]string{"de.alt.test"}, "world"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := ChooseDistribution(pats, test.groups); got != test.exp {
+			t.Errorf("%v: got %q, wanted %q", test.groups, got, test.exp)
+		}
+	}
+
+	for _, bad := range []string{"local.*:local", "x:*:world"} {
+		if _, err := ParseDistribPats([]string{bad}); err == nil {
+			t.Errorf("Parsed %q", bad)
+		}
+	}
+}
+
+func TestParseDistributions(t *testing.T) {
+	got := ParseDistributions(" local, world ,,")
+	if !reflect.DeepEqual(got, []string{"local", "world"}) {
+		t.Errorf("Got %v", got)
+	}
+}
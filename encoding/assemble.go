@@ -0,0 +1,167 @@
+package nntpencoding
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// A Range of bytes in a file, 1-based and inclusive like yEnc offsets.
+type Range struct {
+	Begin, End int64
+}
+
+// ErrOverlap is returned when a part overlaps data already assembled.
+var ErrOverlap = errors.New("part overlaps assembled data")
+
+// An Assembler joins decoded parts of a multipart file, which may arrive
+// in any order.  It's safe for concurrent use.
+type Assembler struct {
+	mu   sync.Mutex
+	w    io.WriterAt
+	size int64
+	// done is sorted and coalesced.
+	done []Range
+
+	// Bookkeeping for the Report.
+	name       string
+	fileCRC    uint32
+	hasFileCRC bool
+	bad        []SegmentProblem
+}
+
+// NewAssembler assembles a file of the given size into w.
+//
+// To continue assembling a file from an earlier run, pass the ranges
+// that run reported as Done.
+func NewAssembler(w io.WriterAt, size int64, done ...Range) (*Assembler, error) {
+	a := &Assembler{w: w, size: size}
+	for _, r := range done {
+		if err := a.mark(r); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// CreateOutput opens the file at path for assembling, creating it if
+// necessary and sizing it without touching existing content, so that a
+// partially assembled file can be resumed.
+func CreateOutput(path string, size int64) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Add writes the decoded data of the part described by m.
+//
+// Adding a part whose range was already assembled is a no-op, so
+// duplicate downloads are harmless; partial overlaps return ErrOverlap.
+func (a *Assembler) Add(m *Meta, data []byte) error {
+	r := Range{m.Begin, m.End}
+	if m.Part == 0 {
+		r = Range{1, m.Size}
+	}
+	if m.Size != 0 && m.Size != a.size {
+		return fmt.Errorf("part declares file size %d, assembling %d",
+			m.Size, a.size)
+	}
+	if int64(len(data)) != r.End-r.Begin+1 {
+		return fmt.Errorf("%w: %d bytes for range %d-%d",
+			ErrSizeMismatch, len(data), r.Begin, r.End)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.check(r); err != nil {
+		if err == errDuplicate {
+			return nil
+		}
+		return err
+	}
+	if _, err := a.w.WriteAt(data, r.Begin-1); err != nil {
+		return err
+	}
+	return a.mark(r)
+}
+
+var errDuplicate = errors.New("duplicate range")
+
+func (a *Assembler) check(r Range) error {
+	if r.Begin < 1 || r.End > a.size || r.End < r.Begin {
+		return fmt.Errorf("range %d-%d outside file of %d bytes",
+			r.Begin, r.End, a.size)
+	}
+	for _, d := range a.done {
+		if r.Begin >= d.Begin && r.End <= d.End {
+			return errDuplicate
+		}
+		if r.Begin <= d.End && r.End >= d.Begin {
+			return fmt.Errorf("%w: %d-%d and %d-%d",
+				ErrOverlap, r.Begin, r.End, d.Begin, d.End)
+		}
+	}
+	return nil
+}
+
+func (a *Assembler) mark(r Range) error {
+	if err := a.check(r); err != nil && err != errDuplicate {
+		return err
+	}
+	a.done = append(a.done, r)
+	sort.Slice(a.done, func(i, j int) bool {
+		return a.done[i].Begin < a.done[j].Begin
+	})
+	merged := a.done[:1]
+	for _, d := range a.done[1:] {
+		last := &merged[len(merged)-1]
+		if d.Begin <= last.End+1 {
+			if d.End > last.End {
+				last.End = d.End
+			}
+			continue
+		}
+		merged = append(merged, d)
+	}
+	a.done = merged
+	return nil
+}
+
+// Done returns the assembled ranges.
+func (a *Assembler) Done() []Range {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Range(nil), a.done...)
+}
+
+// Missing returns the ranges not yet assembled.
+func (a *Assembler) Missing() []Range {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var rv []Range
+	next := int64(1)
+	for _, d := range a.done {
+		if d.Begin > next {
+			rv = append(rv, Range{next, d.Begin - 1})
+		}
+		next = d.End + 1
+	}
+	if next <= a.size {
+		rv = append(rv, Range{next, a.size})
+	}
+	return rv
+}
+
+// Complete reports whether the whole file has been assembled.
+func (a *Assembler) Complete() bool {
+	return len(a.Missing()) == 0
+}
@@ -0,0 +1,95 @@
+package nntpencoding
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type memFile []byte
+
+func (m memFile) WriteAt(p []byte, off int64) (int, error) {
+	return copy(m[off:], p), nil
+}
+
+func (m memFile) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(m).ReadAt(p, off)
+}
+
+func part(begin, end int64, data string) (*Meta, []byte) {
+	return &Meta{Part: 1, Size: 10, Begin: begin, End: end}, []byte(data)
+}
+
+func TestAssembler(t *testing.T) {
+	out := make(memFile, 10)
+	a, err := NewAssembler(out, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add(part(5, 7, "567")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add(part(1, 2, "12")); err != nil {
+		t.Fatal(err)
+	}
+	exp := []Range{{3, 4}, {8, 10}}
+	if got := a.Missing(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("Missing %v, wanted %v", got, exp)
+	}
+	if err := a.Add(part(5, 7, "567")); err != nil {
+		t.Errorf("Duplicate part: %v", err)
+	}
+	if err := a.Add(part(6, 8, "678")); !errors.Is(err, ErrOverlap) {
+		t.Errorf("Overlapping part: %v", err)
+	}
+	if err := a.Add(part(9, 11, "9ab")); err == nil {
+		t.Errorf("Part beyond the end was accepted")
+	}
+	if err := a.Add(part(3, 4, "3")); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("Short part: %v", err)
+	}
+	if err := a.Add(part(8, 10, "890")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add(part(3, 4, "34")); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Complete() || string(out) != "1234567890" {
+		t.Errorf("Complete=%v with %q", a.Complete(), out)
+	}
+	if got := a.Done(); !reflect.DeepEqual(got, []Range{{1, 10}}) {
+		t.Errorf("Done %v", got)
+	}
+}
+
+func TestAssemblerResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	f, err := CreateOutput(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := NewAssembler(f, 10)
+	a.Add(part(1, 5, "12345"))
+	done := a.Done()
+	f.Close()
+
+	f, err = CreateOutput(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err = NewAssembler(f, 10, done...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add(part(6, 10, "67890")); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if !a.Complete() || string(got) != "1234567890" {
+		t.Errorf("Resumed file is %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+// Package nntpencoding provides the binary-to-text encodings used to post
+// files to Usenet.
+package nntpencoding
+
+import (
+	"errors"
+	"io"
+)
+
+// A Format is a binary-to-text encoding.  YEnc and UU implement it.
+type Format interface {
+	// Encode writes the data from r, described by m, to w.
+	Encode(w io.Writer, r io.Reader, m *Meta) error
+	// Decode reads encoded data from r and writes the decoded bytes
+	// to w.  The Result is returned even on error.
+	Decode(w io.Writer, r io.Reader) (*Result, error)
+}
+
+// Meta describes an encoded file, or one part of a multipart file.
+type Meta struct {
+	// Name of the file.
+	Name string
+	// Size of the whole file in bytes.
+	Size int64
+	// Part number, starting at 1.  Zero for single part files.
+	Part int
+	// Total number of parts, zero if unknown.
+	Total int
+	// Begin and End are the 1-based, inclusive offsets of a part's data
+	// within the file.  Unused for single part files.
+	Begin, End int64
+	// FileCRC32 is the CRC of the whole file.  When encoding a part it
+	// is only written if non-zero, since it may not be known yet.
+	FileCRC32 uint32
+}
+
+// PartSize returns the number of bytes in the described part, or in the
+// whole file for single part files.
+func (m *Meta) PartSize() int64 {
+	if m.Part == 0 {
+		return m.Size
+	}
+	return m.End - m.Begin + 1
+}
+
+// Result describes the outcome of decoding.
+type Result struct {
+	// Meta as declared by the encoded data.
+	Meta
+	// Written is the number of decoded bytes.
+	Written int64
+	// DeclaredSize is the size of the data according to the trailer,
+	// or the header if there is no trailer size.
+	DeclaredSize int64
+	// CRC32 computed over the decoded bytes.
+	CRC32 uint32
+	// ExpectedCRC32 is the CRC of the data according to the trailer.
+	// Only valid if HasCRC32 is set.
+	ExpectedCRC32 uint32
+	HasCRC32      bool
+}
+
+// Errors returned when decoded data fails verification.  They're
+// returned wrapped with the details, use errors.Is to check for them.
+var (
+	ErrCRCMismatch  = errors.New("CRC mismatch")
+	ErrSizeMismatch = errors.New("size mismatch")
+	// ErrTruncated means the data ended before its trailer.
+	ErrTruncated = errors.New("encoded data truncated")
+)
@@ -0,0 +1,154 @@
+package nntpencoding
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Bytes encoded per uuencoded line.
+const uuLineBytes = 45
+
+// UU encodes and decodes uuencode.
+type UU struct {
+	// Mode written to the begin line, 0644 if zero.
+	Mode os.FileMode
+}
+
+func uuChar(b byte) byte {
+	if b == 0 {
+		// Backtick rather than space, which gets stripped in transit.
+		return '`'
+	}
+	return b + ' '
+}
+
+// Encode writes r to w uuencoded.  Only the Name of m is used, other
+// than to check the amount of data read against a non-zero Size.
+func (u UU) Encode(w io.Writer, r io.Reader, m *Meta) error {
+	mode := u.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "begin %o %s\r\n", mode.Perm(), m.Name)
+
+	var n int64
+	buf := make([]byte, uuLineBytes)
+	for {
+		l, err := io.ReadFull(r, buf)
+		if l > 0 {
+			n += int64(l)
+			line := buf[:l]
+			bw.WriteByte(uuChar(byte(l)))
+			for i := 0; i < l; i += 3 {
+				var g [3]byte
+				copy(g[:], line[i:])
+				bw.WriteByte(uuChar(g[0] >> 2))
+				bw.WriteByte(uuChar((g[0]<<4 | g[1]>>4) & 63))
+				bw.WriteByte(uuChar((g[1]<<2 | g[2]>>6) & 63))
+				bw.WriteByte(uuChar(g[2] & 63))
+			}
+			bw.WriteString("\r\n")
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if m.Size != 0 && n != m.Size {
+		return fmt.Errorf("read %d bytes, expected %d", n, m.Size)
+	}
+	bw.WriteString("`\r\nend\r\n")
+	return bw.Flush()
+}
+
+// uuBegin returns the name from a "begin <mode> <name>" line, where the
+// name may contain spaces, or "" if the line lacks an octal mode or a
+// name, as prose that happens to start with "begin " does.
+func uuBegin(line []byte) string {
+	f := strings.SplitN(string(line), " ", 3)
+	if len(f) != 3 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(f[1], 8, 32); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(f[2])
+}
+
+// Decode reads uuencoded data from r, writing the decoded bytes to w.
+//
+// Junk before the begin line and after the end line is ignored,
+// including lines starting "begin " without a mode and name.  Both
+// space and backtick are accepted for zero, and lines whose trailing
+// spaces were stripped in transit are padded back out.  The error wraps
+// ErrTruncated if the data ends without an end line.
+func (u UU) Decode(w io.Writer, r io.Reader) (*Result, error) {
+	br := bufio.NewReader(r)
+	res := &Result{}
+
+	for res.Name == "" {
+		line, err := skipTo(br, "begin ")
+		if err != nil {
+			return res, err
+		}
+		res.Name = uuBegin(line)
+	}
+
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	ended := false
+	for !ended {
+		line, err := readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		if bytes.Equal(bytes.TrimSpace(line), []byte("end")) {
+			ended = true
+			break
+		}
+		if len(line) == 0 {
+			continue
+		}
+		n := int((line[0] - ' ') & 63)
+		data := line[1:]
+		if need := (n + 2) / 3 * 4; len(data) < need {
+			data = append(append([]byte(nil), data...),
+				bytes.Repeat([]byte{'`'}, need-len(data))...)
+		}
+		for i := 0; n > 0; i += 4 {
+			var c [4]byte
+			for j := range c {
+				c[j] = (data[i+j] - ' ') & 63
+			}
+			out := []byte{c[0]<<2 | c[1]>>4, c[1]<<4 | c[2]>>2, c[2]<<6 | c[3]}
+			if n < 3 {
+				out = out[:n]
+			}
+			bw.Write(out)
+			res.Written += int64(len(out))
+			n -= len(out)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return res, err
+	}
+	res.CRC32 = crc.Sum32()
+	res.Size = res.Written
+	res.DeclaredSize = res.Written
+	if !ended {
+		return res, fmt.Errorf("%w: no end line after %d bytes", ErrTruncated, res.Written)
+	}
+	return res, nil
+}
@@ -0,0 +1,73 @@
+package nntpencoding
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 44, 45, 46, 1000} {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+		for _, f := range []Format{YEnc{}, UU{}} {
+			var enc bytes.Buffer
+			m := &Meta{Name: "some file.bin", Size: int64(size)}
+			if err := f.Encode(&enc, bytes.NewReader(data), m); err != nil {
+				t.Fatalf("%T: %v", f, err)
+			}
+			var dec bytes.Buffer
+			res, err := f.Decode(&dec, &enc)
+			if err != nil {
+				t.Fatalf("%T size %d: %v", f, size, err)
+			}
+			if !bytes.Equal(dec.Bytes(), data) || res.Name != m.Name {
+				t.Errorf("%T size %d: round trip failed, name %q", f, size, res.Name)
+			}
+		}
+	}
+}
+
+func TestUUReference(t *testing.T) {
+	var buf bytes.Buffer
+	err := UU{}.Encode(&buf, strings.NewReader("Cat"), &Meta{Name: "cat.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "begin 644 cat.txt\r\n#0V%T\r\n`\r\nend\r\n"
+	if buf.String() != exp {
+		t.Errorf("Got %q, wanted %q", buf.String(), exp)
+	}
+}
+
+func TestUUQuirks(t *testing.T) {
+	tests := []struct {
+		name, input, exp string
+		err              error
+	}{
+		{"junk around", "Hi there\nsee attached\nbegin 644 x\n#0V%T\n`\nend\n-- \nsig\n", "Cat", nil},
+		{"space for zero", "begin 644 x\n\"``` \n \nend\n", "\x00\x00", nil},
+		{"stripped trailing spaces", "begin 644 x\n\"``\nend\n", "\x00\x00", nil},
+		{"no zero length line", "begin 644 x\n#0V%T\nend\n", "Cat", nil},
+		{"prose begin lines", "begin 644\nbegin the data here\nbegin 9 x\nbegin 644 x\n#0V%T\nend\n", "Cat", nil},
+		{"missing end", "begin 644 x\n#0V%T\n", "Cat", ErrTruncated},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := UU{}.Decode(&out, strings.NewReader(test.input))
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: error %v, wanted %v", test.name, err, test.err)
+		}
+		if out.String() != test.exp {
+			t.Errorf("%s: got %q, wanted %q", test.name, out.String(), test.exp)
+		}
+	}
+	for _, input := range []string{"no data", "begin with this\n#0V%T\nend\n"} {
+		if _, err := (UU{}).Decode(ioutil.Discard, strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error without a begin line in %q", input)
+		}
+	}
+}
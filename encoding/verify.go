@@ -0,0 +1,164 @@
+package nntpencoding
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Status of an assembled file.
+type Status int
+
+// Status values.
+const (
+	// Complete files have all their data, and it passed verification.
+	Complete = Status(iota)
+	// Incomplete files are missing data, but nothing is known bad.
+	Incomplete
+	// Corrupt files have segments or a whole-file CRC that failed
+	// verification.
+	Corrupt
+)
+
+func (s Status) String() string {
+	switch s {
+	case Complete:
+		return "complete"
+	case Incomplete:
+		return "incomplete"
+	case Corrupt:
+		return "corrupt"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// A SegmentProblem is a segment that failed verification.
+type SegmentProblem struct {
+	MessageID string
+	// Range the segment claimed to cover, zero if unknown.
+	Range Range
+	Err   error
+}
+
+// A FileReport is the verdict on an assembled file.
+type FileReport struct {
+	Name   string
+	Status Status
+	// Size declared by the segments.
+	Size int64
+	// Missing ranges, including those of bad segments.
+	Missing []Range
+	// BadSegments that haven't been replaced by a good copy.
+	BadSegments []SegmentProblem
+	// FileCRC32 verification, only done when the segments declared a
+	// whole-file CRC and the file is otherwise complete.
+	FileCRCChecked bool
+	FileCRC32      uint32
+	ComputedCRC32  uint32
+}
+
+// AddSegment adds a segment as returned by a Format's Decode, recording
+// the outcome for the Report.
+//
+// Segments that failed decoding with a verification error are recorded
+// as bad rather than written; adding a good copy of the same range later
+// clears the problem.  The returned error is the one recorded, if any.
+func (a *Assembler) AddSegment(msgid string, res *Result, data []byte, decodeErr error) error {
+	if res == nil {
+		res = &Result{}
+	}
+	r := Range{res.Begin, res.End}
+	if res.Part == 0 {
+		r = Range{1, res.Size}
+	}
+	err := decodeErr
+	if err == nil {
+		err = a.Add(&res.Meta, data)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.name == "" {
+		a.name = res.Name
+	}
+	if res.FileCRC32 != 0 {
+		a.fileCRC, a.hasFileCRC = res.FileCRC32, true
+	}
+	if res.Part == 0 && res.HasCRC32 {
+		a.fileCRC, a.hasFileCRC = res.ExpectedCRC32, true
+	}
+	if err != nil {
+		a.bad = append(a.bad, SegmentProblem{msgid, r, err})
+		return err
+	}
+	// A good copy supersedes earlier bad ones for the same range.
+	kept := a.bad[:0]
+	for _, p := range a.bad {
+		if p.Range != r {
+			kept = append(kept, p)
+		}
+	}
+	a.bad = kept
+	return nil
+}
+
+// Report returns the verdict on the file.
+//
+// If r is non-nil it's used to read back the assembled file to check the
+// whole-file CRC, when one was declared and all the data is present.
+func (a *Assembler) Report(r io.ReaderAt) (*FileReport, error) {
+	missing := a.Missing()
+
+	a.mu.Lock()
+	rep := &FileReport{
+		Name:        a.name,
+		Size:        a.size,
+		Missing:     missing,
+		BadSegments: append([]SegmentProblem(nil), a.bad...),
+		FileCRC32:   a.fileCRC,
+	}
+	hasFileCRC := a.hasFileCRC
+	a.mu.Unlock()
+
+	switch {
+	case len(rep.BadSegments) > 0:
+		rep.Status = Corrupt
+	case len(rep.Missing) > 0:
+		rep.Status = Incomplete
+	default:
+		rep.Status = Complete
+	}
+
+	if rep.Status == Complete && hasFileCRC && r != nil {
+		crc := crc32.NewIEEE()
+		if _, err := io.Copy(crc, io.NewSectionReader(r, 0, a.size)); err != nil {
+			return rep, err
+		}
+		rep.FileCRCChecked = true
+		rep.ComputedCRC32 = crc.Sum32()
+		if rep.ComputedCRC32 != rep.FileCRC32 {
+			rep.Status = Corrupt
+		}
+	}
+	return rep, nil
+}
+
+// Refetch returns the message-ids of bad segments, which may well be
+// fine on another server.
+func (rep *FileReport) Refetch() []string {
+	var rv []string
+	for _, p := range rep.BadSegments {
+		if p.MessageID != "" {
+			rv = append(rv, p.MessageID)
+		}
+	}
+	return rv
+}
+
+// IsVerificationError reports whether err means the data was damaged, as
+// opposed to failing to be read or written.
+func IsVerificationError(err error) bool {
+	return errors.Is(err, ErrCRCMismatch) || errors.Is(err, ErrSizeMismatch) ||
+		errors.Is(err, ErrTruncated)
+}
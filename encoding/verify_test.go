@@ -0,0 +1,95 @@
+package nntpencoding
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// encodeParts yEnc encodes data as parts of the given size.
+func encodeParts(t *testing.T, data []byte, size int) [][]byte {
+	var parts [][]byte
+	total := (len(data) + size - 1) / size
+	for i := 0; i < total; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		var buf bytes.Buffer
+		err := YEnc{}.Encode(&buf, bytes.NewReader(data[i*size:end]), &Meta{
+			Name: "f", Size: int64(len(data)), Part: i + 1, Total: total,
+			Begin: int64(i*size + 1), End: int64(end),
+			FileCRC32: crc32.ChecksumIEEE(data),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, buf.Bytes())
+	}
+	return parts
+}
+
+func addEncoded(a *Assembler, msgid string, encoded []byte) error {
+	var buf bytes.Buffer
+	res, err := YEnc{}.Decode(&buf, bytes.NewReader(encoded))
+	return a.AddSegment(msgid, res, buf.Bytes(), err)
+}
+
+func TestReport(t *testing.T) {
+	data := make([]byte, 3000)
+	rand.New(rand.NewSource(1)).Read(data)
+	parts := encodeParts(t, data, 1000)
+
+	corrupt := append([]byte(nil), parts[1]...)
+	corrupt[len(corrupt)/2]++
+
+	out := make(memFile, len(data))
+	a, _ := NewAssembler(out, int64(len(data)))
+	addEncoded(a, "<1@x>", parts[0])
+	if err := addEncoded(a, "<2@x>", corrupt); !IsVerificationError(err) {
+		t.Fatalf("Corrupt segment gave %v", err)
+	}
+
+	rep, err := a.Report(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Status != Corrupt || !reflect.DeepEqual(rep.Refetch(), []string{"<2@x>"}) {
+		t.Errorf("Got %v, refetch %v", rep.Status, rep.Refetch())
+	}
+	if exp := []Range{{1001, 3000}}; !reflect.DeepEqual(rep.Missing, exp) {
+		t.Errorf("Missing %v, wanted %v", rep.Missing, exp)
+	}
+
+	// A good copy from elsewhere replaces the bad one.
+	addEncoded(a, "<2@y>", parts[1])
+	rep, _ = a.Report(out)
+	if rep.Status != Incomplete || len(rep.BadSegments) != 0 {
+		t.Errorf("Got %v with %v", rep.Status, rep.BadSegments)
+	}
+
+	addEncoded(a, "<3@x>", parts[2])
+	rep, _ = a.Report(out)
+	if rep.Status != Complete || !rep.FileCRCChecked || rep.Name != "f" {
+		t.Errorf("Got report %+v", rep)
+	}
+
+	// Damage that slipped past the part CRCs shows up in the file CRC.
+	out[5]++
+	rep, _ = a.Report(out)
+	if rep.Status != Corrupt {
+		t.Errorf("Got %v with damaged output", rep.Status)
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	for s, exp := range map[Status]string{Complete: "complete",
+		Corrupt: "corrupt", Status(9): "Status(9)"} {
+		if fmt.Sprint(s) != exp {
+			t.Errorf("Got %v, wanted %v", s, exp)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package nntpencoding
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// DefaultLineLength is the customary length of yEnc encoded lines.
+const DefaultLineLength = 128
+
+// YEnc encodes and decodes yEnc, see http://www.yenc.org/yenc-draft.1.3.txt
+type YEnc struct {
+	// LineLength of encoded lines, DefaultLineLength if zero.
+	LineLength int
+}
+
+// Encode writes r to w as yEnc with headers and trailers describing m.
+//
+// For a part of a multipart file r must supply exactly the bytes from
+// m.Begin to m.End.
+func (y YEnc) Encode(w io.Writer, r io.Reader, m *Meta) error {
+	lineLength := y.LineLength
+	if lineLength <= 0 {
+		lineLength = DefaultLineLength
+	}
+	if m.Part > 0 && (m.Begin < 1 || m.End < m.Begin) {
+		return fmt.Errorf("invalid part range %d-%d", m.Begin, m.End)
+	}
+
+	bw := bufio.NewWriter(w)
+	if m.Part > 0 {
+		fmt.Fprintf(bw, "=ybegin part=%d", m.Part)
+		if m.Total > 0 {
+			fmt.Fprintf(bw, " total=%d", m.Total)
+		}
+		fmt.Fprintf(bw, " line=%d size=%d name=%s\r\n", lineLength, m.Size, m.Name)
+		fmt.Fprintf(bw, "=ypart begin=%d end=%d\r\n", m.Begin, m.End)
+	} else {
+		fmt.Fprintf(bw, "=ybegin line=%d size=%d name=%s\r\n", lineLength, m.Size, m.Name)
+	}
+
+	crc := crc32.NewIEEE()
+	br := bufio.NewReader(io.TeeReader(r, crc))
+	var n int64
+	col := 0
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n++
+		o := b + 42
+		escape := false
+		switch o {
+		case 0, '\n', '\r', '=':
+			escape = true
+		case '.':
+			escape = col == 0
+		case ' ', '\t':
+			if col == 0 || col+1 >= lineLength {
+				escape = true
+			} else if _, err := br.Peek(1); err != nil {
+				// Last byte of the input ends the line.
+				escape = true
+			}
+		}
+		if escape {
+			bw.WriteByte('=')
+			o += 64
+			col++
+		}
+		bw.WriteByte(o)
+		col++
+		if col >= lineLength {
+			bw.WriteString("\r\n")
+			col = 0
+		}
+	}
+	if col > 0 {
+		bw.WriteString("\r\n")
+	}
+
+	if n != m.PartSize() {
+		return fmt.Errorf("read %d bytes, expected %d", n, m.PartSize())
+	}
+	if m.Part > 0 {
+		fmt.Fprintf(bw, "=yend size=%d part=%d pcrc32=%08x", n, m.Part, crc.Sum32())
+		if m.FileCRC32 != 0 {
+			fmt.Fprintf(bw, " crc32=%08x", m.FileCRC32)
+		}
+		bw.WriteString("\r\n")
+	} else {
+		fmt.Fprintf(bw, "=yend size=%d crc32=%08x\r\n", n, crc.Sum32())
+	}
+	return bw.Flush()
+}
+
+// Decode reads yEnc encoded data from r, writing the decoded bytes to w.
+//
+// Anything before the =ybegin line is skipped.  The decoded data is
+// verified against the sizes and CRC declared in the header and trailer;
+// on failure the returned error wraps ErrTruncated, ErrSizeMismatch or
+// ErrCRCMismatch, and the Result describes what was found.
+func (y YEnc) Decode(w io.Writer, r io.Reader) (*Result, error) {
+	br := bufio.NewReader(r)
+	res := &Result{}
+
+	line, err := skipTo(br, "=ybegin ")
+	if err != nil {
+		return res, err
+	}
+	kw := parseKeywords(line)
+	res.Name = kw["name"]
+	res.Size, _ = strconv.ParseInt(kw["size"], 10, 64)
+	res.Part, _ = strconv.Atoi(kw["part"])
+	res.Total, _ = strconv.Atoi(kw["total"])
+	res.DeclaredSize = res.Size
+
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	escaped := false
+	var trailer map[string]string
+	for trailer == nil {
+		line, err = readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		switch {
+		case bytes.HasPrefix(line, []byte("=ypart ")):
+			kw = parseKeywords(line)
+			res.Begin, _ = strconv.ParseInt(kw["begin"], 10, 64)
+			res.End, _ = strconv.ParseInt(kw["end"], 10, 64)
+			res.DeclaredSize = res.PartSize()
+			continue
+		case bytes.HasPrefix(line, []byte("=yend")):
+			trailer = parseKeywords(line)
+			continue
+		}
+		for _, c := range line {
+			if escaped {
+				c -= 64
+				escaped = false
+			} else if c == '=' {
+				escaped = true
+				continue
+			}
+			bw.WriteByte(c - 42)
+			res.Written++
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return res, err
+	}
+	res.CRC32 = crc.Sum32()
+	if trailer == nil {
+		return res, fmt.Errorf("%w: no =yend after %d bytes", ErrTruncated, res.Written)
+	}
+	return res, res.verify(trailer)
+}
+
+func (res *Result) verify(trailer map[string]string) error {
+	if s, err := strconv.ParseInt(trailer["size"], 10, 64); err == nil {
+		res.DeclaredSize = s
+	}
+	crcKey := "crc32"
+	if res.Part > 0 {
+		crcKey = "pcrc32"
+		if c, err := strconv.ParseUint(trailer["crc32"], 16, 32); err == nil {
+			res.FileCRC32 = uint32(c)
+		}
+	}
+	if c, err := strconv.ParseUint(trailer[crcKey], 16, 32); err == nil {
+		res.ExpectedCRC32 = uint32(c)
+		res.HasCRC32 = true
+	}
+
+	if res.Written != res.DeclaredSize {
+		return fmt.Errorf("%w: decoded %d bytes, expected %d",
+			ErrSizeMismatch, res.Written, res.DeclaredSize)
+	}
+	if res.Part > 0 && res.End > 0 && res.Written != res.PartSize() {
+		return fmt.Errorf("%w: decoded %d bytes, part %d-%d is %d bytes",
+			ErrSizeMismatch, res.Written, res.Begin, res.End, res.PartSize())
+	}
+	if res.HasCRC32 && res.CRC32 != res.ExpectedCRC32 {
+		return fmt.Errorf("%w: computed %08x, expected %08x",
+			ErrCRCMismatch, res.CRC32, res.ExpectedCRC32)
+	}
+	return nil
+}
+
+// readLine returns the next line without its line ending.
+func readLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Absurdly long line, fall back to the allocating read.
+		var rest []byte
+		rest, err = br.ReadBytes('\n')
+		line = append(append([]byte(nil), line...), rest...)
+	}
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
+func skipTo(br *bufio.Reader, prefix string) ([]byte, error) {
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil, errNoData
+			}
+			return nil, err
+		}
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return line, nil
+		}
+	}
+}
+
+var errNoData = errors.New("no encoded data found")
+
+// parseKeywords parses the key=value pairs of a yEnc header or trailer
+// line.  The name keyword extends to the end of the line, since file
+// names may contain spaces.
+func parseKeywords(line []byte) map[string]string {
+	kw := map[string]string{}
+	if i := bytes.Index(line, []byte(" name=")); i >= 0 {
+		kw["name"] = string(bytes.TrimSpace(line[i+len(" name="):]))
+		line = line[:i]
+	}
+	for _, f := range bytes.Fields(line) {
+		if eq := bytes.IndexByte(f, '='); eq > 0 {
+			kw[string(f[:eq])] = string(f[eq+1:])
+		}
+	}
+	return kw
+}
@@ -0,0 +1,134 @@
+package nntpencoding
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestYEncReferenceVectors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		exp  string
+	}{
+		{"hello", []byte("Hello"),
+			"=ybegin line=128 size=5 name=hello\r\n" +
+				"r\x8f\x96\x96\x99\r\n" +
+				"=yend size=5 crc32=f7d18982\r\n"},
+		// NUL, LF, CR and '=' after the +42 shift.
+		{"critical", []byte{214, 224, 227, 19},
+			"=ybegin line=128 size=4 name=critical\r\n" +
+				"=@=J=M=}\r\n" +
+				"=yend size=4 crc32=" + crcHex([]byte{214, 224, 227, 19}) + "\r\n"},
+		// Leading dot, leading and trailing space.
+		{"edges", []byte{4, 246, 0, 246},
+			"=ybegin line=128 size=4 name=edges\r\n" +
+				"=n" + "\x20" + "*" + "=`" + "\r\n" +
+				"=yend size=4 crc32=" + crcHex([]byte{4, 246, 0, 246}) + "\r\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := YEnc{}.Encode(&buf, bytes.NewReader(test.in), &Meta{
+			Name: test.name,
+			Size: int64(len(test.in)),
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if buf.String() != test.exp {
+			t.Errorf("%s: got\n%q\nwanted\n%q", test.name, buf.String(), test.exp)
+		}
+	}
+}
+
+func crcHex(b []byte) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(b))
+}
+
+func TestYEncRoundTrip(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+	for _, lineLength := range []int{0, 1, 2, 63, 128} {
+		var buf bytes.Buffer
+		y := YEnc{LineLength: lineLength}
+		m := &Meta{Name: "file with spaces.bin", Size: 20000, Part: 2, Total: 2,
+			Begin: 10001, End: 20000, FileCRC32: 1234}
+		if err := y.Encode(&buf, bytes.NewReader(data), m); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(buf.String(), "\r\n") {
+			if lineLength > 0 && !strings.HasPrefix(line, "=y") && len(line) > lineLength+1 {
+				t.Fatalf("Line of length %d, wanted at most %d", len(line), lineLength+1)
+			}
+		}
+		var out bytes.Buffer
+		res, err := y.Decode(&out, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("Round trip with line length %d changed the data", lineLength)
+		}
+		if res.Name != m.Name || res.Part != 2 || res.Total != 2 ||
+			res.Begin != m.Begin || res.End != m.End || res.Size != m.Size {
+			t.Errorf("Decoded metadata %+v, wanted %+v", res.Meta, *m)
+		}
+		if res.CRC32 != crc32.ChecksumIEEE(data) {
+			t.Errorf("Computed CRC %08x", res.CRC32)
+		}
+	}
+}
+
+func TestYEncEncodeSizeMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	err := YEnc{}.Encode(&buf, strings.NewReader("abc"), &Meta{Name: "x", Size: 4})
+	if err == nil {
+		t.Fatal("Expected an error for short input")
+	}
+}
+
+func encoded(t *testing.T, data string, m *Meta) string {
+	var buf bytes.Buffer
+	if err := (YEnc{}).Encode(&buf, strings.NewReader(data), m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestYEncVerification(t *testing.T) {
+	single := encoded(t, "some data", &Meta{Name: "a", Size: 9})
+	part := encoded(t, "some data", &Meta{Name: "a", Size: 100, Part: 1,
+		Begin: 1, End: 9, FileCRC32: 0xdeadbeef})
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"single ok", single, nil},
+		{"part ok", part, nil},
+		{"truncated", single[:strings.Index(single, "=yend")], ErrTruncated},
+		{"corrupt byte", strings.Replace(single, "\x9d", "\x9e", 1), ErrCRCMismatch},
+		{"dropped line", strings.Replace(part, "\x9d\x99\x97\x8f", "", 1), ErrSizeMismatch},
+		{"wrong pcrc", strings.Replace(part, crcHex([]byte("some data")), "00000000", 1), ErrCRCMismatch},
+	}
+	for _, test := range tests {
+		res, err := YEnc{}.Decode(ioutil.Discard, strings.NewReader(test.input))
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: got error %v, wanted %v", test.name, err, test.err)
+		}
+		if res == nil {
+			t.Fatalf("%s: no result", test.name)
+		}
+	}
+
+	res, _ := YEnc{}.Decode(ioutil.Discard, strings.NewReader(part))
+	if res.FileCRC32 != 0xdeadbeef || !res.HasCRC32 || res.DeclaredSize != 9 {
+		t.Errorf("Unexpected part result %+v", res)
+	}
+}
@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/dustin/go-couch v0.0.0-20160816170231-8251128dab73
 	github.com/dustin/httputil v0.0.0-20170305193905-c47743f54f89 // indirect
+	golang.org/x/text v0.13.0
 )
@@ -0,0 +1,95 @@
+package nntp
+
+import (
+	"mime"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: CharsetReader}
+
+var encodedWord = regexp.MustCompile(`=\?[^?\s]+\?[bBqQ]\?[^?\s]*\?=`)
+
+// DecodeHeader decodes RFC 2047 encoded-words in a header value.  A
+// value that isn't valid UTF-8 is taken to be raw Windows-1252, the
+// superset of Latin-1 that undeclared 8-bit headers nearly always are.
+//
+// Broken encoded-words (truncated, bad encoding, unknown charset) are
+// left as they were, so the result is always usable.  The error reports
+// the first such problem for callers that care.
+func DecodeHeader(value string) (string, error) {
+	if !utf8.ValidString(value) {
+		// Windows-1252 decodes any byte, so this can't fail.
+		value, _ = charmap.Windows1252.NewDecoder().String(value)
+	}
+	if !strings.Contains(value, "=?") {
+		return value, nil
+	}
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err == nil {
+		return decoded, nil
+	}
+
+	// Decode word by word, keeping whatever fails verbatim.
+	var firstErr error
+	var sb strings.Builder
+	prevDecoded := false
+	last := 0
+	for _, loc := range encodedWord.FindAllStringIndex(value, -1) {
+		between := value[last:loc[0]]
+		word := value[loc[0]:loc[1]]
+		d, err := wordDecoder.Decode(word)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			d = word
+		}
+		// Whitespace between adjacent encoded-words is not displayed.
+		if !(prevDecoded && err == nil && strings.TrimSpace(between) == "") {
+			sb.WriteString(between)
+		}
+		sb.WriteString(d)
+		prevDecoded = err == nil
+		last = loc[1]
+	}
+	sb.WriteString(value[last:])
+	return sb.String(), firstErr
+}
+
+func needsEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeHeader makes a header value 7-bit safe using RFC 2047
+// encoded-words.  Values that are already ASCII are returned unchanged.
+//
+// For address headers only the display names are encoded, since an
+// encoded address is no longer an address.
+func EncodeHeader(name, value string) string {
+	if !needsEncoding(value) {
+		return value
+	}
+	switch strings.ToLower(name) {
+	case "from", "sender", "reply-to", "to", "cc", "mail-copies-to", "approved":
+		addrs, err := mail.ParseAddressList(value)
+		if err == nil {
+			parts := make([]string, len(addrs))
+			for i, a := range addrs {
+				// mail.Address.String does the encoding.
+				parts[i] = a.String()
+			}
+			return strings.Join(parts, ", ")
+		}
+	}
+	return mime.QEncoding.Encode("utf-8", value)
+}
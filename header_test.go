@@ -0,0 +1,55 @@
+package nntp
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct {
+		in, exp string
+		err     bool
+	}{
+		{"plain", "plain", false},
+		{"=?UTF-8?B?w6nDqcOp?=", "ééé", false},
+		{"=?ISO-8859-1?Q?caf=E9?= au lait", "café au lait", false},
+		{"=?koi8-r?B?8NLJ18XU?=", "Привет", false},
+		{"=?windows-1252?Q?=80?=", "€", false},
+		{"=?GB2312?B?xOO6ww==?=", "你好", false},
+		{"=?utf-8?q?a?= =?utf-8?q?b?=", "ab", false},
+		// Broken words degrade to the raw text.
+		{"=?x-unknown?Q?abc?= =?utf-8?q?ok?=", "=?x-unknown?Q?abc?= ok", true},
+		{"Re: =?UTF-8?B?w6nD", "Re: =?UTF-8?B?w6nD", false},
+		// Undeclared 8-bit text is Windows-1252.
+		{"caf\xe9 \x80 =?utf-8?q?ok?=", "café € ok", false},
+	}
+	for _, test := range tests {
+		got, err := DecodeHeader(test.in)
+		if got != test.exp {
+			t.Errorf("DecodeHeader(%q) = %q, wanted %q", test.in, got, test.exp)
+		}
+		if (err != nil) != test.err {
+			t.Errorf("DecodeHeader(%q) error = %v", test.in, err)
+		}
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	if got := EncodeHeader("Subject", "ascii"); got != "ascii" {
+		t.Errorf("ASCII subject was encoded as %q", got)
+	}
+	dec := new(mime.WordDecoder)
+	for _, test := range []struct{ name, value string }{
+		{"Subject", "Grüße"},
+		{"From", "Jürgen <j@example.com>"},
+	} {
+		enc := EncodeHeader(test.name, test.value)
+		if needsEncoding(enc) {
+			t.Errorf("%s: %q is not 7-bit", test.name, enc)
+		}
+		got, err := dec.DecodeHeader(enc)
+		if err != nil || got != test.value && got != `"Jürgen" <j@example.com>` {
+			t.Errorf("%s: %q decoded to %q, %v", test.name, enc, got, err)
+		}
+	}
+}
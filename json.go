@@ -0,0 +1,209 @@
+package nntp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MarshalJSON encodes the status as its letter, or "" when unknown.
+func (ps PostingStatus) MarshalJSON() ([]byte, error) {
+	if ps == Unknown {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(ps.String())
+}
+
+// UnmarshalJSON decodes a status letter.
+func (ps *PostingStatus) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "":
+		*ps = Unknown
+	case "y", "n", "m", "x", "j", "=":
+		*ps = PostingStatus(s[0])
+	default:
+		return errors.New("invalid posting status " + s)
+	}
+	return nil
+}
+
+// overviewJSON is the JSON form of an Overview.
+type overviewJSON struct {
+	Number     int64    `json:"number"`
+	Subject    string   `json:"subject"`
+	From       string   `json:"from"`
+	Date       string   `json:"date,omitempty"`
+	DateHeader string   `json:"date_header"`
+	MessageID  string   `json:"message_id"`
+	References string   `json:"references,omitempty"`
+	Bytes      int64    `json:"bytes"`
+	Lines      int64    `json:"lines"`
+	Xref       string   `json:"xref,omitempty"`
+	Extra      []string `json:"extra,omitempty"`
+}
+
+// MarshalJSON encodes the overview as an object.  "date" is the parsed
+// time in RFC 3339 format, omitted if it couldn't be parsed, and
+// "date_header" the Date field as sent by the server.  "extra" lists
+// any fields after the first eight.
+func (o Overview) MarshalJSON() ([]byte, error) {
+	v := overviewJSON{
+		Number:     o.Number,
+		Subject:    o.Subject,
+		From:       o.From,
+		DateHeader: o.Date,
+		MessageID:  o.MessageID,
+		References: o.References,
+		Bytes:      o.Bytes,
+		Lines:      o.Lines,
+		Xref:       o.Xref,
+		Extra:      o.ExtraFields(),
+	}
+	if !o.Time.IsZero() {
+		v.Date = o.Time.Format(time.RFC3339)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON.
+func (o *Overview) UnmarshalJSON(data []byte) error {
+	var v overviewJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Overview{
+		Number:     v.Number,
+		Subject:    v.Subject,
+		From:       v.From,
+		Date:       v.DateHeader,
+		MessageID:  v.MessageID,
+		References: v.References,
+		Bytes:      v.Bytes,
+		Lines:      v.Lines,
+		Xref:       v.Xref,
+		extra:      strings.Join(v.Extra, "\t"),
+	}
+	if v.Date != "" {
+		t, err := time.Parse(time.RFC3339, v.Date)
+		if err != nil {
+			return err
+		}
+		o.Time = t
+	}
+	return nil
+}
+
+// HeaderField is a single header line in an article's JSON form.
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// articleJSON is the JSON form of an Article.
+type articleJSON struct {
+	Headers    []HeaderField `json:"headers"`
+	Body       *string       `json:"body,omitempty"`
+	BodyBase64 []byte        `json:"body_base64,omitempty"`
+	Bytes      int           `json:"bytes"`
+	Lines      int           `json:"lines"`
+}
+
+// MarshalJSON encodes the article as an object.  "headers" is a list of
+// name/value pairs, sorted by name, keeping repeated headers in order.
+// The body is "body" if it's valid UTF-8 and "body_base64" otherwise.
+//
+// The body is read in full and replaced by an in-memory copy.
+func (a *Article) MarshalJSON() ([]byte, error) {
+	v := articleJSON{
+		Headers: []HeaderField{},
+		Bytes:   a.Bytes,
+		Lines:   a.Lines,
+	}
+	keys := make([]string, 0, len(a.Header))
+	for k := range a.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, val := range a.Header[k] {
+			v.Headers = append(v.Headers, HeaderField{k, val})
+		}
+	}
+	if a.Body != nil {
+		body, err := ioutil.ReadAll(a.Body)
+		if err != nil {
+			return nil, err
+		}
+		a.Body = bytes.NewReader(body)
+		if utf8.Valid(body) {
+			s := string(body)
+			v.Body = &s
+		} else {
+			v.BodyBase64 = body
+		}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON.
+func (a *Article) UnmarshalJSON(data []byte) error {
+	var v articleJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = Article{
+		Header: textproto.MIMEHeader{},
+		Bytes:  v.Bytes,
+		Lines:  v.Lines,
+	}
+	for _, h := range v.Headers {
+		a.Header.Add(h.Name, h.Value)
+	}
+	switch {
+	case v.Body != nil:
+		a.Body = bytes.NewReader([]byte(*v.Body))
+	case v.BodyBase64 != nil:
+		a.Body = bytes.NewReader(v.BodyBase64)
+	}
+	return nil
+}
+
+// articleRangeJSON is the JSON form of an ArticleRange.
+type articleRangeJSON struct {
+	Low  int64  `json:"low"`
+	High *int64 `json:"high,omitempty"`
+}
+
+// MarshalJSON encodes the range as {"low": n, "high": m}, leaving out
+// "high" for a range without an upper bound.
+func (r ArticleRange) MarshalJSON() ([]byte, error) {
+	v := articleRangeJSON{Low: r.Low}
+	if r.High != math.MaxInt64 {
+		v.High = &r.High
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON.
+func (r *ArticleRange) UnmarshalJSON(data []byte) error {
+	var v articleRangeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Low, r.High = v.Low, math.MaxInt64
+	if v.High != nil {
+		r.High = *v.High
+	}
+	return nil
+}
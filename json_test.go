@@ -0,0 +1,152 @@
+package nntp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGroupJSON(t *testing.T) {
+	g := Group{Name: "comp.lang.go", Description: "Go", Count: 10, High: 20, Low: 11,
+		Posting: PostingModerated}
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"name":"comp.lang.go","description":"Go","count":10,"high":20,"low":11,"posting":"m"}`
+	if string(data) != exp {
+		t.Errorf("Got %s, wanted %s", data, exp)
+	}
+	var back Group
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != g {
+		t.Errorf("Round trip got %+v", back)
+	}
+	if err := json.Unmarshal([]byte(`{"posting":"q"}`), &back); err == nil {
+		t.Errorf("Accepted invalid posting status")
+	}
+
+	alias := Group{Name: "old.name", Posting: PostingAlias, AliasOf: "misc.test"}
+	data, err = json.Marshal(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back = Group{}
+	if err := json.Unmarshal(data, &back); err != nil || back != alias {
+		t.Errorf("Alias round trip got %+v, %v from %s", back, err, data)
+	}
+}
+
+func TestOverviewJSON(t *testing.T) {
+	o := Overview{
+		Number:     42,
+		Subject:    "Hello",
+		From:       "a@example.com",
+		Date:       "Mon, 2 Jan 2006 15:04:05 -0700",
+		MessageID:  "<1@x>",
+		References: "<0@x>",
+		Bytes:      1234,
+		Lines:      20,
+		Time:       time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*3600)),
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"number":42,"subject":"Hello","from":"a@example.com",` +
+		`"date":"2006-01-02T15:04:05-07:00","date_header":"Mon, 2 Jan 2006 15:04:05 -0700",` +
+		`"message_id":"\u003c1@x\u003e","references":"\u003c0@x\u003e","bytes":1234,"lines":20}`
+	if string(data) != exp {
+		t.Errorf("Got %s, wanted %s", data, exp)
+	}
+	var back Overview
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.Time.Equal(o.Time) {
+		t.Errorf("Time %v, wanted %v", back.Time, o.Time)
+	}
+	back.Time = o.Time
+	if back != o {
+		t.Errorf("Round trip got %+v", back)
+	}
+
+	o, _ = ParseOverview("1\t\t\t\t<1@x>\t\t0\t0\tXref: h a:1\tX-Foo: bar")
+	data, err = json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back = Overview{}
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != o {
+		t.Errorf("Round trip with extra fields got %+v from %s", back, data)
+	}
+}
+
+func TestArticleJSON(t *testing.T) {
+	for _, body := range []string{"Hello\r\n", "\xff\xfe binary"} {
+		a := &Article{
+			Header: textproto.MIMEHeader{
+				"Subject":  {"Hi"},
+				"Received": {"first", "second"},
+			},
+			Body:  strings.NewReader(body),
+			Bytes: len(body),
+			Lines: 1,
+		}
+		data, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The body is still readable after marshaling.
+		if got, _ := ioutil.ReadAll(a.Body); string(got) != body {
+			t.Errorf("Body consumed by marshaling: %q", got)
+		}
+		var back Article
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back.Header, a.Header) || back.Bytes != a.Bytes || back.Lines != a.Lines {
+			t.Errorf("Round trip got %+v", back)
+		}
+		if got, _ := ioutil.ReadAll(back.Body); string(got) != body {
+			t.Errorf("Round trip body %q, wanted %q", got, body)
+		}
+	}
+}
+
+func TestArticleRangeJSON(t *testing.T) {
+	tests := []struct {
+		r   ArticleRange
+		exp string
+	}{
+		{ArticleRange{5, 5}, `{"low":5,"high":5}`},
+		{ArticleRange{1, 10}, `{"low":1,"high":10}`},
+		{ArticleRange{100, math.MaxInt64}, `{"low":100}`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.exp {
+			t.Errorf("%v: got %s, wanted %s", test.r, data, test.exp)
+		}
+		var back ArticleRange
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if back != test.r {
+			t.Errorf("%v: round trip got %v", test.r, back)
+		}
+	}
+}
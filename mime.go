@@ -0,0 +1,186 @@
+package nntp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"unicode/utf8"
+)
+
+// Deeper nesting than this is treated as opaque content.
+const maxPartDepth = 8
+
+// A BodyPart is a decoded article body, or one part of a multipart body.
+type BodyPart struct {
+	Header textproto.MIMEHeader
+	// MediaType is the lowercase media type, "text/plain" if none was
+	// declared.
+	MediaType string
+	// Params are the Content-Type parameters.
+	Params map[string]string
+	// Filename from Content-Disposition or the Content-Type name.
+	Filename string
+	// Content has its transfer encoding removed.  Text content is
+	// converted to UTF-8.  Empty for multipart bodies.
+	Content []byte
+	// Parts of a multipart body.
+	Parts []*BodyPart
+}
+
+// DecodedBody reads the article body and decodes it according to its
+// MIME headers.
+//
+// The body reader is consumed.  Malformed MIME structure doesn't fail the
+// decode; whatever could be made sense of is returned, falling back to
+// treating the body as plain text.
+func (a *Article) DecodedBody() (*BodyPart, error) {
+	body, err := ioutil.ReadAll(a.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodePart(a.Header, body, 0), nil
+}
+
+// Text returns the content of the first text/plain part, or of the first
+// text part of any kind if there's no plain one.
+func (p *BodyPart) Text() string {
+	if t := p.findText("text/plain"); t != nil {
+		return string(t.Content)
+	}
+	if t := p.findText("text/"); t != nil {
+		return string(t.Content)
+	}
+	return ""
+}
+
+func (p *BodyPart) findText(prefix string) *BodyPart {
+	if len(p.Parts) == 0 {
+		if strings.HasPrefix(p.MediaType, prefix) && p.Filename == "" {
+			return p
+		}
+		return nil
+	}
+	for _, sub := range p.Parts {
+		if t := sub.findText(prefix); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+func decodePart(h textproto.MIMEHeader, body []byte, depth int) *BodyPart {
+	p := &BodyPart{Header: h}
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if mt == "" || (err != nil && err != mime.ErrInvalidMediaParameter) {
+		mt, params = "text/plain", map[string]string{}
+	}
+	p.MediaType, p.Params = mt, params
+	if _, dp, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		p.Filename = dp["filename"]
+	}
+	if p.Filename == "" {
+		p.Filename = params["name"]
+	}
+
+	if strings.HasPrefix(mt, "multipart/") {
+		p.Parts = splitParts(body, params["boundary"], depth)
+		if len(p.Parts) > 0 {
+			return p
+		}
+		// No parts could be found; show the body as text.
+		p.MediaType = "text/plain"
+	}
+
+	p.Content = decodeTransfer(h.Get("Content-Transfer-Encoding"), body)
+	if strings.HasPrefix(p.MediaType, "text/") {
+		p.Content = toUTF8(params["charset"], p.Content)
+	}
+	return p
+}
+
+func splitParts(body []byte, boundary string, depth int) []*BodyPart {
+	if boundary == "" || depth >= maxPartDepth {
+		return nil
+	}
+	var parts []*BodyPart
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		// Raw parts, since we do our own lenient decoding.
+		part, err := mr.NextRawPart()
+		if err != nil {
+			// EOF, or a missing closing boundary.
+			return parts
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil && len(data) == 0 {
+			return parts
+		}
+		parts = append(parts, decodePart(part.Header, data, depth+1))
+	}
+}
+
+// decodeTransfer removes a content transfer encoding, keeping whatever
+// decoded cleanly when the encoding is damaged.
+func decodeTransfer(cte string, body []byte) []byte {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, base64Filter{bytes.NewReader(body)})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(bytes.NewReader(body))
+	default:
+		return body
+	}
+	decoded, _ := ioutil.ReadAll(r)
+	return decoded
+}
+
+// base64Filter drops anything that isn't base64 alphabet, since posting
+// software frequently leaves trailing junk or signatures after the data.
+type base64Filter struct {
+	r io.Reader
+}
+
+func (f base64Filter) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		j := 0
+		for _, b := range p[:n] {
+			if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' ||
+				b >= '0' && b <= '9' || b == '+' || b == '/' || b == '=' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// toUTF8 converts text from the declared charset.  Undeclared text that
+// isn't valid UTF-8 is assumed to be Windows-1252, which is what most
+// such posts turn out to be.
+func toUTF8(charset string, text []byte) []byte {
+	if charset == "" || strings.EqualFold(charset, "us-ascii") {
+		if utf8.Valid(text) {
+			return text
+		}
+		charset = "windows-1252"
+	}
+	e, err := LookupCharset(charset)
+	if err != nil || e == nil {
+		return text
+	}
+	out, err := e.NewDecoder().Bytes(text)
+	if err != nil {
+		return text
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package nntp
+
+import (
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func article(headers map[string]string, body string) *Article {
+	h := textproto.MIMEHeader{}
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return &Article{Header: h, Body: strings.NewReader(body)}
+}
+
+func TestDecodedBodyText(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		exp     string
+	}{
+		{"plain", nil, "hello\n", "hello\n"},
+		{"undeclared latin1", nil, "caf\xe9\n", "café\n"},
+		{"qp", map[string]string{
+			"Content-Type":              "text/plain; charset=iso-8859-1",
+			"Content-Transfer-Encoding": "quoted-printable",
+		}, "caf=E9 =\nau lait\n", "café au lait\n"},
+		{"base64 with junk", map[string]string{
+			"Content-Type":              "text/plain; charset=utf-8",
+			"Content-Transfer-Encoding": "base64",
+		}, "w6nDqcOp\r\n-- \r\n", "ééé"},
+		{"bad content type", map[string]string{
+			"Content-Type": "text/plain; charset",
+		}, "hi", "hi"},
+		{"multipart without boundary", map[string]string{
+			"Content-Type": "multipart/mixed",
+		}, "hi", "hi"},
+	}
+	for _, test := range tests {
+		p, err := article(test.headers, test.body).DecodedBody()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := p.Text(); got != test.exp {
+			t.Errorf("%s: got %q, wanted %q", test.name, got, test.exp)
+		}
+	}
+}
+
+func TestDecodedBodyMultipart(t *testing.T) {
+	body := "preamble\r\n" +
+		"--XX\r\n" +
+		"Content-Type: text/plain; charset=koi8-r\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n\r\n" +
+		"\xf0\xd2\xc9\xd7\xc5\xd4\r\n" +
+		"--XX\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename=\"a.bin\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"AAEC\r\n"
+	// Note the missing closing boundary.
+	p, err := article(map[string]string{
+		"Content-Type": `multipart/mixed; boundary="XX"`,
+	}, body).DecodedBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Parts) != 2 {
+		t.Fatalf("Got %d parts, wanted 2", len(p.Parts))
+	}
+	if got := p.Text(); got != "Привет" {
+		t.Errorf("Got text %q", got)
+	}
+	a := p.Parts[1]
+	if a.Filename != "a.bin" || string(a.Content) != "\x00\x01\x02" {
+		t.Errorf("Got attachment %q with %q", a.Filename, a.Content)
+	}
+}
@@ -0,0 +1,62 @@
+package nntp
+
+import (
+	"errors"
+	"strings"
+)
+
+// A Moderator is a line of LIST MODERATORS: the submission address
+// template for moderated groups matching a wildmat.
+type Moderator struct {
+	Pattern string
+	// Address may contain %s, standing for the group name with its
+	// periods replaced by dashes, and %% for a literal percent sign.
+	Address string
+}
+
+// ParseModerators parses the lines of a LIST MODERATORS response, or a
+// moderators file.  Empty lines and comments are skipped.
+func ParseModerators(lines []string) ([]Moderator, error) {
+	var rv []Moderator
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, errors.New("invalid moderators line: " + line)
+		}
+		rv = append(rv, Moderator{line[:i], line[i+1:]})
+	}
+	return rv, nil
+}
+
+// SubmissionAddress returns the address to mail submissions to a
+// moderated group, from the first pattern matching it, or "" if none
+// do.
+func SubmissionAddress(mods []Moderator, group string) string {
+	for _, m := range mods {
+		if !MatchWildmat(m.Pattern, group) {
+			continue
+		}
+		var b strings.Builder
+		for i := 0; i < len(m.Address); i++ {
+			if m.Address[i] != '%' || i+1 == len(m.Address) {
+				b.WriteByte(m.Address[i])
+				continue
+			}
+			i++
+			switch m.Address[i] {
+			case 's':
+				b.WriteString(strings.ReplaceAll(group, ".", "-"))
+			case '%':
+				b.WriteByte('%')
+			default:
+				b.WriteByte('%')
+				b.WriteByte(m.Address[i])
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
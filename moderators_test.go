@@ -0,0 +1,39 @@
+package nntp
+
+import "testing"
+
+func TestModerators(t *testing.T) {
+	mods, err := ParseModerators([]string{
+		"# Local groups",
+		"example.*:mod-%s@example.com",
+		"",
+		"gnu.*:%s@gnu.example.org",
+		"*:%s@moderators.isc.org:x",
+		"odd.*:100%%-moderated@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 4 || mods[2].Address != "%s@moderators.isc.org:x" {
+		t.Errorf("Parsed %+v", mods)
+	}
+	tests := []struct{ group, want string }{
+		{"example.announce", "mod-example-announce@example.com"},
+		{"gnu.emacs.announce", "gnu-emacs-announce@gnu.example.org"},
+		{"comp.lang.go.announce", "comp-lang-go-announce@moderators.isc.org:x"},
+	}
+	for _, test := range tests {
+		if got := SubmissionAddress(mods, test.group); got != test.want {
+			t.Errorf("%s: %q, want %q", test.group, got, test.want)
+		}
+	}
+	if got := SubmissionAddress(mods[3:], "odd.one"); got != "100%-moderated@example.com" {
+		t.Errorf("Percent: %q", got)
+	}
+	if got := SubmissionAddress(mods[:1], "misc.test"); got != "" {
+		t.Errorf("No match: %q", got)
+	}
+	if _, err := ParseModerators([]string{"no colon here"}); err == nil {
+		t.Errorf("Parsed a line without a colon")
+	}
+}
@@ -0,0 +1,59 @@
+package nntp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxMessageID is the longest message-id RFC 5536 allows.
+const maxMessageID = 250
+
+// CanonicalMessageID trims whitespace from a message-id and adds the
+// angle brackets if they're missing.  It doesn't check the result; see
+// ValidateMessageID.
+func CanonicalMessageID(msgid string) string {
+	msgid = strings.TrimSpace(msgid)
+	if !strings.HasPrefix(msgid, "<") {
+		msgid = "<" + msgid
+	}
+	if !strings.HasSuffix(msgid, ">") || msgid == "<" {
+		msgid += ">"
+	}
+	return msgid
+}
+
+// ValidateMessageID checks a message-id against the msg-id grammar of
+// RFC 5536: printable ASCII in angle brackets, with a single @ between
+// non-empty parts, no spaces and no more than 250 characters.
+func ValidateMessageID(msgid string) error {
+	if len(msgid) > maxMessageID {
+		return fmt.Errorf("message-id %.20q... is longer than %d characters", msgid, maxMessageID)
+	}
+	if len(msgid) < 2 || msgid[0] != '<' || msgid[len(msgid)-1] != '>' {
+		return fmt.Errorf("message-id %q isn't in angle brackets", msgid)
+	}
+	id := msgid[1 : len(msgid)-1]
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c <= ' ' || c >= 0x7f || c == '<' || c == '>' {
+			return fmt.Errorf("message-id %q contains %q", msgid, c)
+		}
+	}
+	at := strings.IndexByte(id, '@')
+	if at <= 0 || at == len(id)-1 || strings.Count(id, "@") != 1 {
+		return fmt.Errorf("message-id %q needs one @ between non-empty parts", msgid)
+	}
+	return nil
+}
+
+// GenerateMessageID returns a new random message-id in the domain of
+// hostname, which should be a name the poster controls.
+func GenerateMessageID(hostname string) string {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	return "<" + hex.EncodeToString(b) + "@" + hostname + ">"
+}
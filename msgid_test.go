@@ -0,0 +1,50 @@
+package nntp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMessageID(t *testing.T) {
+	tests := []struct {
+		in, canonical string
+		valid         bool
+	}{
+		{"<a@b>", "<a@b>", true},
+		{"a@b", "<a@b>", true},
+		{"  a.b$c@host.example\t", "<a.b$c@host.example>", true},
+		{"<a@b", "<a@b>", true},
+		{"a@b>", "<a@b>", true},
+		{"", "<>", false},
+		{"<>", "<>", false},
+		{"ab", "<ab>", false},
+		{"a@@b", "<a@@b>", false},
+		{"a@b@c", "<a@b@c>", false},
+		{"@b", "<@b>", false},
+		{"a@", "<a@>", false},
+		{"a b@c", "<a b@c>", false},
+		{"a\x00@b", "<a\x00@b>", false},
+		{"a<@b", "<a<@b>", false},
+		{"ä@b", "<ä@b>", false},
+		{strings.Repeat("a", 250) + "@b", "<" + strings.Repeat("a", 250) + "@b>", false},
+	}
+	for _, test := range tests {
+		got := CanonicalMessageID(test.in)
+		if got != test.canonical {
+			t.Errorf("CanonicalMessageID(%q) = %q, want %q", test.in, got, test.canonical)
+		}
+		if err := ValidateMessageID(got); (err == nil) != test.valid {
+			t.Errorf("ValidateMessageID(%q) = %v", got, err)
+		}
+	}
+}
+
+func TestGenerateMessageID(t *testing.T) {
+	a, b := GenerateMessageID("example.com"), GenerateMessageID("example.com")
+	if a == b {
+		t.Errorf("Generated %s twice", a)
+	}
+	if err := ValidateMessageID(a); err != nil || !strings.HasSuffix(a, "@example.com>") {
+		t.Errorf("Generated %s: %v", a, err)
+	}
+}
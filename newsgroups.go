@@ -0,0 +1,176 @@
+package nntp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Newsgroups maps group names to their descriptions, as in a newsgroups
+// file.
+type Newsgroups map[string]string
+
+// ReadNewsgroups reads a newsgroups file.  Comment lines are skipped, a
+// group listed more than once gets its last description, and lines that
+// aren't valid UTF-8 are assumed to be Windows-1252.
+func ReadNewsgroups(r io.Reader) (Newsgroups, error) {
+	rv := Newsgroups{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := string(toUTF8("", s.Bytes()))
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if d, ok := splitDescription(line); ok {
+			rv[d.Name] = d.Description
+		}
+	}
+	return rv, s.Err()
+}
+
+// LoadNewsgroups reads the newsgroups file at path.
+func LoadNewsgroups(path string) (Newsgroups, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNewsgroups(f)
+}
+
+// Descriptions returns the groups sorted by name.
+func (n Newsgroups) Descriptions() []GroupDescription {
+	rv := make([]GroupDescription, 0, len(n))
+	for name, desc := range n {
+		rv = append(rv, GroupDescription{name, desc})
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Name < rv[j].Name })
+	return rv
+}
+
+// Write the groups in newsgroups file format, sorted by name.
+func (n Newsgroups) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, d := range n.Descriptions() {
+		if _, err := fmt.Fprintf(bw, "%s\t%s\n", d.Name, d.Description); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Save atomically replaces the newsgroups file at path.
+func (n Newsgroups) Save(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := n.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// A CheckgroupsDiff lists how a checkgroups message differs from the
+// local groups.
+type CheckgroupsDiff struct {
+	// Added are groups missing locally.
+	Added []GroupDescription
+	// Changed are groups with a different description.
+	Changed []GroupDescription
+	// Removed are local groups within the message's scope that it
+	// doesn't list.
+	Removed []string
+}
+
+// Empty is true if there are no differences.
+func (d *CheckgroupsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Checkgroups compares a checkgroups control message against n.
+//
+// Only groups within the message's scope are considered for removal.
+// Without an explicit scope it's the top-level hierarchies of the groups
+// listed.
+func (n Newsgroups) Checkgroups(ctl *Control) *CheckgroupsDiff {
+	var scope []string
+	for _, arg := range ctl.Args {
+		if !strings.HasPrefix(arg, "#") {
+			scope = append(scope, arg)
+		}
+	}
+	explicit := len(scope) > 0
+	listed := map[string]bool{}
+	for _, d := range ctl.Descriptions {
+		listed[d.Name] = true
+		if !explicit {
+			top := strings.SplitN(d.Name, ".", 2)[0]
+			if !inScope(scope, top) {
+				scope = append(scope, top)
+			}
+		}
+	}
+
+	rv := &CheckgroupsDiff{}
+	for _, d := range ctl.Descriptions {
+		desc, ok := n[d.Name]
+		switch {
+		case !ok:
+			rv.Added = append(rv.Added, d)
+		case desc != d.Description:
+			rv.Changed = append(rv.Changed, d)
+		}
+	}
+	for _, d := range n.Descriptions() {
+		if !listed[d.Name] && inScope(scope, d.Name) {
+			rv.Removed = append(rv.Removed, d.Name)
+		}
+	}
+	return rv
+}
+
+// Apply the differences to n.
+func (d *CheckgroupsDiff) Apply(n Newsgroups) {
+	for _, g := range d.Added {
+		n[g.Name] = g.Description
+	}
+	for _, g := range d.Changed {
+		n[g.Name] = g.Description
+	}
+	for _, name := range d.Removed {
+		delete(n, name)
+	}
+}
+
+// inScope reports whether group falls under a checkgroups scope, a list
+// of hierarchies where a leading ! excludes one.  The longest match wins.
+func inScope(scope []string, group string) bool {
+	rv, best := false, -1
+	for _, h := range scope {
+		negated := strings.HasPrefix(h, "!")
+		h = strings.TrimPrefix(h, "!")
+		if (group == h || strings.HasPrefix(group, h+".")) && len(h) > best {
+			rv, best = !negated, len(h)
+		}
+	}
+	return rv
+}
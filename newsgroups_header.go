@@ -0,0 +1,105 @@
+package nntp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NewsgroupsOptions configure ValidateNewsgroups.
+type NewsgroupsOptions struct {
+	// MaxCrosspost limits the number of groups, if positive.
+	MaxCrosspost int
+	// StrictCase rejects names with uppercase letters instead of only
+	// warning about them.
+	StrictCase bool
+}
+
+// A NewsgroupProblem is something wrong with a newsgroup name.
+type NewsgroupProblem struct {
+	Name   string
+	Reason string
+}
+
+func (p NewsgroupProblem) String() string {
+	return fmt.Sprintf("%q (%s)", p.Name, p.Reason)
+}
+
+// A NewsgroupsError lists the invalid names in a Newsgroups header.
+type NewsgroupsError struct {
+	Problems []NewsgroupProblem
+}
+
+func (e *NewsgroupsError) Error() string {
+	parts := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		parts[i] = p.String()
+	}
+	return "invalid newsgroups: " + strings.Join(parts, ", ")
+}
+
+// ValidateNewsgroups checks a Newsgroups header value against RFC 5536
+// and returns the group names, trimmed and without duplicates.
+//
+// Problems that are only recommendations, such as uppercase letters, are
+// returned as warnings unless opts.StrictCase is set.  opts may be nil.
+func ValidateNewsgroups(value string, opts *NewsgroupsOptions) (groups []string, warnings []NewsgroupProblem, err error) {
+	if opts == nil {
+		opts = &NewsgroupsOptions{}
+	}
+	var problems []NewsgroupProblem
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if reason := checkGroupName(name); reason != "" {
+			problems = append(problems, NewsgroupProblem{name, reason})
+			continue
+		}
+		if strings.ToLower(name) != name {
+			p := NewsgroupProblem{name, "uppercase letters"}
+			if opts.StrictCase {
+				problems = append(problems, p)
+				continue
+			}
+			warnings = append(warnings, p)
+		}
+		groups = append(groups, name)
+	}
+	if len(groups) == 0 && len(problems) == 0 {
+		problems = append(problems, NewsgroupProblem{value, "no newsgroups"})
+	}
+	if opts.MaxCrosspost > 0 && len(groups) > opts.MaxCrosspost {
+		problems = append(problems, NewsgroupProblem{value,
+			fmt.Sprintf("crossposted to %d groups, at most %d allowed",
+				len(groups), opts.MaxCrosspost)})
+	}
+	if len(problems) > 0 {
+		return groups, warnings, &NewsgroupsError{problems}
+	}
+	return groups, warnings, nil
+}
+
+// checkGroupName returns why name isn't a valid newsgroup name, or "".
+func checkGroupName(name string) string {
+	if strings.ContainsAny(name, " \t\r\n") {
+		return "contains whitespace"
+	}
+	for _, c := range strings.Split(name, ".") {
+		switch c {
+		case "":
+			return "empty component"
+		case "all", "ctl":
+			return "reserved component " + c
+		}
+		for _, r := range c {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+				r >= '0' && r <= '9' || r == '+' || r == '-' || r == '_') {
+				return fmt.Sprintf("invalid character %q", r)
+			}
+		}
+	}
+	return ""
+}
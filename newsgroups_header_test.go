@@ -0,0 +1,54 @@
+package nntp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateNewsgroups(t *testing.T) {
+	groups, warnings, err := ValidateNewsgroups(" comp.lang.go ,alt.test,comp.lang.go,, Misc.Test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(groups, []string{"comp.lang.go", "alt.test", "Misc.Test"}) {
+		t.Errorf("Groups %v", groups)
+	}
+	if len(warnings) != 1 || warnings[0].Name != "Misc.Test" {
+		t.Errorf("Warnings %v", warnings)
+	}
+
+	_, _, err = ValidateNewsgroups("Misc.Test", &NewsgroupsOptions{StrictCase: true})
+	if err == nil {
+		t.Errorf("Uppercase accepted with StrictCase")
+	}
+
+	tests := []struct {
+		value, bad string
+	}{
+		{"comp..lang", "comp..lang"},
+		{".comp", ".comp"},
+		{"comp.", "comp."},
+		{"alt.test, comp lang", "comp lang"},
+		{"comp.all", "comp.all"},
+		{"ctl.foo", "ctl.foo"},
+		{"comp.lang/go", "comp.lang/go"},
+		{" , ", " , "},
+	}
+	for _, test := range tests {
+		_, _, err := ValidateNewsgroups(test.value, nil)
+		ne, ok := err.(*NewsgroupsError)
+		if !ok {
+			t.Errorf("%q: got %v", test.value, err)
+			continue
+		}
+		if len(ne.Problems) != 1 || ne.Problems[0].Name != test.bad {
+			t.Errorf("%q: problems %v", test.value, ne.Problems)
+		}
+	}
+
+	_, _, err = ValidateNewsgroups("a.a,a.b,a.c", &NewsgroupsOptions{MaxCrosspost: 2})
+	if err == nil || !strings.Contains(err.Error(), "3 groups") {
+		t.Errorf("Crosspost limit: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package nntp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadNewsgroups(t *testing.T) {
+	in := "# local groups\n" +
+		"comp.lang.go\tThe Go language.\n" +
+		"de.test   Tests\xe4\n" +
+		"\n" +
+		"comp.lang.go\tGo, again.\r\n" +
+		"alt.nodesc\n"
+	got, err := ReadNewsgroups(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := Newsgroups{
+		"comp.lang.go": "Go, again.",
+		"de.test":      "Testsä",
+		"alt.nodesc":   "",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Got %q, wanted %q", got, exp)
+	}
+
+	var buf bytes.Buffer
+	if err := got.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "alt.nodesc\t\ncomp.lang.go\tGo, again.\nde.test\tTestsä\n"
+	if buf.String() != want {
+		t.Errorf("Wrote %q, wanted %q", buf.String(), want)
+	}
+}
+
+func TestNewsgroupsSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "newsgroups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "newsgroups")
+
+	n := Newsgroups{"a.b": "A B", "c.d": "C D"}
+	if err := n.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	n["e.f"] = "E F"
+	if err := n.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadNewsgroups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, n) {
+		t.Errorf("Loaded %v, wanted %v", got, n)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Temporary files left behind: %v", files)
+	}
+}
+
+func TestCheckgroups(t *testing.T) {
+	n := Newsgroups{
+		"comp.a":        "A",
+		"comp.b":        "B",
+		"comp.old":      "Old",
+		"comp.os.stale": "Stale",
+		"misc.other":    "Other",
+	}
+	ctl := &Control{
+		Type: ControlCheckgroups,
+		Args: []string{"#2"},
+		Descriptions: []GroupDescription{
+			{"comp.a", "A"},
+			{"comp.b", "B, better"},
+			{"comp.c", "C (Moderated)"},
+		},
+	}
+	diff := n.Checkgroups(ctl)
+	exp := &CheckgroupsDiff{
+		Added:   []GroupDescription{{"comp.c", "C (Moderated)"}},
+		Changed: []GroupDescription{{"comp.b", "B, better"}},
+		Removed: []string{"comp.old", "comp.os.stale"},
+	}
+	if !reflect.DeepEqual(diff, exp) {
+		t.Errorf("Got %+v, wanted %+v", diff, exp)
+	}
+
+	// An explicit scope excluding comp.os keeps comp.os.stale.
+	ctl.Args = []string{"comp", "!comp.os", "#3"}
+	diff = n.Checkgroups(ctl)
+	if !reflect.DeepEqual(diff.Removed, []string{"comp.old"}) {
+		t.Errorf("Scoped removal: %v", diff.Removed)
+	}
+
+	diff.Apply(n)
+	if n["comp.c"] != "C (Moderated)" || n["comp.b"] != "B, better" {
+		t.Errorf("Apply didn't add or change: %v", n)
+	}
+	if _, ok := n["comp.old"]; ok {
+		t.Errorf("Apply didn't remove: %v", n)
+	}
+	if diff := n.Checkgroups(ctl); !diff.Empty() {
+		t.Errorf("Diff after apply: %+v", diff)
+	}
+}
@@ -0,0 +1,175 @@
+// Package newsrc reads and writes .newsrc subscription files.
+package newsrc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Group is a newsrc line: a group, whether it's subscribed, and the
+// articles read in it.
+type Group struct {
+	Name       string
+	Subscribed bool
+	Read       RangeSet
+}
+
+// entry is a line of the file, either a group or anything else, which is
+// kept as is.
+type entry struct {
+	group *Group
+	raw   string
+}
+
+// A Newsrc is the contents of a .newsrc file.
+type Newsrc struct {
+	entries []entry
+}
+
+// Parse a .newsrc file.  Lines that aren't groups, such as an options
+// line, are kept and written back unchanged.
+func Parse(r io.Reader) (*Newsrc, error) {
+	rv := &Newsrc{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if g := parseGroup(line); g != nil {
+			rv.entries = append(rv.entries, entry{group: g})
+		} else {
+			rv.entries = append(rv.entries, entry{raw: line})
+		}
+	}
+	return rv, s.Err()
+}
+
+func parseGroup(line string) *Group {
+	i := strings.IndexAny(line, ":!")
+	if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+		return nil
+	}
+	read, err := ParseRangeSet(line[i+1:])
+	if err != nil {
+		return nil
+	}
+	return &Group{
+		Name:       line[:i],
+		Subscribed: line[i] == ':',
+		Read:       read,
+	}
+}
+
+// Load reads a .newsrc file.  A file that doesn't exist reads as empty.
+func Load(path string) (*Newsrc, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Newsrc{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Save writes the file to path, replacing it atomically.
+func (n *Newsrc) Save(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := n.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// Newsrc files are private.
+	if err := os.Chmod(f.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Write the file, in its original order.
+func (n *Newsrc) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range n.entries {
+		var err error
+		if e.group == nil {
+			_, err = fmt.Fprintln(bw, e.raw)
+		} else {
+			_, err = fmt.Fprintln(bw, e.group)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// String formats the group as a newsrc line.
+func (g *Group) String() string {
+	mark := "!"
+	if g.Subscribed {
+		mark = ":"
+	}
+	read := g.Read.String()
+	if read == "" {
+		return g.Name + mark
+	}
+	return g.Name + mark + " " + read
+}
+
+// Groups returns the groups in file order.
+func (n *Newsrc) Groups() []*Group {
+	var rv []*Group
+	for _, e := range n.entries {
+		if e.group != nil {
+			rv = append(rv, e.group)
+		}
+	}
+	return rv
+}
+
+// Group returns the named group, or nil.
+func (n *Newsrc) Group(name string) *Group {
+	for _, e := range n.entries {
+		if e.group != nil && e.group.Name == name {
+			return e.group
+		}
+	}
+	return nil
+}
+
+// Add returns the named group, appending it unsubscribed if it's not
+// already present.
+func (n *Newsrc) Add(name string) *Group {
+	if g := n.Group(name); g != nil {
+		return g
+	}
+	g := &Group{Name: name}
+	n.entries = append(n.entries, entry{group: g})
+	return g
+}
+
+// Remove the named group.
+func (n *Newsrc) Remove(name string) {
+	for i, e := range n.entries {
+		if e.group != nil && e.group.Name == name {
+			n.entries = append(n.entries[:i], n.entries[i+1:]...)
+			return
+		}
+	}
+}
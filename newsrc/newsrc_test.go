@@ -0,0 +1,146 @@
+package newsrc
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRangeSetAdd(t *testing.T) {
+	tests := []struct {
+		add []Range
+		exp string
+	}{
+		{nil, ""},
+		{[]Range{{5, 5}}, "5"},
+		{[]Range{{1, 3}, {4, 6}}, "1-6"},
+		{[]Range{{4, 6}, {1, 3}}, "1-6"},
+		{[]Range{{1, 3}, {5, 6}}, "1-3,5-6"},
+		{[]Range{{1, 10}, {3, 4}}, "1-10"},
+		{[]Range{{3, 4}, {1, 10}}, "1-10"},
+		{[]Range{{1, 2}, {5, 6}, {9, 10}, {3, 8}}, "1-10"},
+		{[]Range{{1, 2}, {5, 6}, {9, 10}, {4, 7}}, "1-2,4-7,9-10"},
+		{[]Range{{10, 12}, {1, 1}, {20, 20}, {11, 19}}, "1,10-20"},
+	}
+	for _, test := range tests {
+		var s RangeSet
+		for _, r := range test.add {
+			s.AddRange(r.Low, r.High)
+		}
+		if s.String() != test.exp {
+			t.Errorf("Adding %v got %q, wanted %q", test.add, s, test.exp)
+		}
+	}
+}
+
+func TestRangeSetParse(t *testing.T) {
+	s, err := ParseRangeSet("1-234,236,240-300, 235")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != "1-236,240-300" {
+		t.Errorf("Parsed %q", s)
+	}
+	if s.Count() != 236+61 {
+		t.Errorf("Count = %d", s.Count())
+	}
+	for n, exp := range map[int64]bool{0: false, 1: true, 236: true, 237: false, 240: true, 300: true, 301: false} {
+		if s.Contains(n) != exp {
+			t.Errorf("Contains(%d) = %v", n, !exp)
+		}
+	}
+	for _, bad := range []string{"a", "5-3", "1-", "-1"} {
+		if _, err := ParseRangeSet(bad); err == nil {
+			t.Errorf("Parsed %q", bad)
+		}
+	}
+}
+
+func TestRangeSetMerge(t *testing.T) {
+	a, _ := ParseRangeSet("1-5,20-30")
+	b, _ := ParseRangeSet("6-10,25-40,50")
+	a.Merge(b)
+	if !reflect.DeepEqual(a.Ranges(), []Range{{1, 10}, {20, 40}, {50, 50}}) {
+		t.Errorf("Merged %v", a.Ranges())
+	}
+}
+
+func TestNewsrc(t *testing.T) {
+	in := "options -n all !comp.*\n" +
+		"comp.lang.go: 1-234,236,240-300\n" +
+		"alt.test! 1-10\n" +
+		"misc.empty:\n" +
+		"something unexpected\n"
+	n, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := n.Groups()
+	if len(groups) != 3 {
+		t.Fatalf("Got %d groups", len(groups))
+	}
+	if g := n.Group("alt.test"); g == nil || g.Subscribed || g.Read.String() != "1-10" {
+		t.Errorf("alt.test = %+v", g)
+	}
+	if g := n.Group("misc.empty"); g == nil || !g.Subscribed || g.Read.Count() != 0 {
+		t.Errorf("misc.empty = %+v", g)
+	}
+
+	var buf bytes.Buffer
+	n.Write(&buf)
+	if buf.String() != in {
+		t.Errorf("Round trip got %q", buf.String())
+	}
+
+	n.Group("comp.lang.go").Read.Add(235)
+	n.Add("new.group").Subscribed = true
+	n.Remove("alt.test")
+	buf.Reset()
+	n.Write(&buf)
+	exp := "options -n all !comp.*\n" +
+		"comp.lang.go: 1-236,240-300\n" +
+		"misc.empty:\n" +
+		"something unexpected\n" +
+		"new.group:\n"
+	if buf.String() != exp {
+		t.Errorf("Rewrite got %q, wanted %q", buf.String(), exp)
+	}
+}
+
+func TestRangeSetCountIn(t *testing.T) {
+	s, _ := ParseRangeSet("1-10,20,30-40")
+	tests := []struct {
+		low, high, exp int64
+	}{
+		{1, 100, 22},
+		{5, 25, 7},
+		{11, 19, 0},
+		{35, 50, 6},
+	}
+	for _, test := range tests {
+		if got := s.CountIn(test.low, test.high); got != test.exp {
+			t.Errorf("CountIn(%d, %d) = %d, want %d", test.low, test.high, got, test.exp)
+		}
+	}
+	if s.Max() != 40 || (RangeSet{}).Max() != 0 {
+		t.Errorf("Max %d", s.Max())
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "newsrc")
+	n, err := Load(path)
+	if err != nil || len(n.Groups()) != 0 {
+		t.Fatalf("Loading a missing file: %v, %v", n.Groups(), err)
+	}
+	n.Add("misc.test").Subscribed = true
+	if err := n.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	n, err = Load(path)
+	if err != nil || n.Group("misc.test") == nil || !n.Group("misc.test").Subscribed {
+		t.Errorf("Reloaded %v, %v", n.Groups(), err)
+	}
+}
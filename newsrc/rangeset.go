@@ -0,0 +1,143 @@
+package newsrc
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Range of article numbers, inclusive at both ends.
+type Range struct {
+	Low, High int64
+}
+
+// A RangeSet is a set of article numbers kept as sorted, disjoint,
+// non-adjacent ranges.  The zero value is an empty set.
+type RangeSet struct {
+	ranges []Range
+}
+
+// ParseRangeSet parses the newsrc form "1-234,236,240-300".
+func ParseRangeSet(s string) (RangeSet, error) {
+	var rv RangeSet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		l, err := strconv.ParseInt(lo, 10, 64)
+		if err != nil {
+			return RangeSet{}, err
+		}
+		h, err := strconv.ParseInt(hi, 10, 64)
+		if err != nil {
+			return RangeSet{}, err
+		}
+		if l > h || l < 0 {
+			return RangeSet{}, errors.New("invalid range " + part)
+		}
+		rv.AddRange(l, h)
+	}
+	return rv, nil
+}
+
+// Add a single article number.
+func (s *RangeSet) Add(n int64) {
+	s.AddRange(n, n)
+}
+
+// AddRange adds all numbers from low to high.
+func (s *RangeSet) AddRange(low, high int64) {
+	if low > high {
+		return
+	}
+	// First range that could touch the new one.
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].High >= low-1
+	})
+	j := i
+	for j < len(s.ranges) && s.ranges[j].Low <= high+1 {
+		if s.ranges[j].Low < low {
+			low = s.ranges[j].Low
+		}
+		if s.ranges[j].High > high {
+			high = s.ranges[j].High
+		}
+		j++
+	}
+	merged := append([]Range{{low, high}}, s.ranges[j:]...)
+	s.ranges = append(s.ranges[:i], merged...)
+}
+
+// Merge adds all numbers in other.
+func (s *RangeSet) Merge(other RangeSet) {
+	for _, r := range other.ranges {
+		s.AddRange(r.Low, r.High)
+	}
+}
+
+// Contains reports whether n is in the set.
+func (s RangeSet) Contains(n int64) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].High >= n
+	})
+	return i < len(s.ranges) && s.ranges[i].Low <= n
+}
+
+// Ranges returns a copy of the set's ranges in ascending order.
+func (s RangeSet) Ranges() []Range {
+	return append([]Range(nil), s.ranges...)
+}
+
+// Count returns the number of article numbers in the set.
+func (s RangeSet) Count() int64 {
+	var rv int64
+	for _, r := range s.ranges {
+		rv += r.High - r.Low + 1
+	}
+	return rv
+}
+
+// CountIn returns how many numbers from low to high are in the set.
+func (s RangeSet) CountIn(low, high int64) int64 {
+	var rv int64
+	for _, r := range s.ranges {
+		l, h := r.Low, r.High
+		if l < low {
+			l = low
+		}
+		if h > high {
+			h = high
+		}
+		if l <= h {
+			rv += h - l + 1
+		}
+	}
+	return rv
+}
+
+// Max returns the highest number in the set, or 0 if it's empty.
+func (s RangeSet) Max() int64 {
+	if len(s.ranges) == 0 {
+		return 0
+	}
+	return s.ranges[len(s.ranges)-1].High
+}
+
+// String formats the set in newsrc form.
+func (s RangeSet) String() string {
+	parts := make([]string, len(s.ranges))
+	for i, r := range s.ranges {
+		if r.Low == r.High {
+			parts[i] = strconv.FormatInt(r.Low, 10)
+		} else {
+			parts[i] = strconv.FormatInt(r.Low, 10) + "-" + strconv.FormatInt(r.High, 10)
+		}
+	}
+	return strings.Join(parts, ",")
+}
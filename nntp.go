@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/textproto"
+	"strings"
 )
 
 // PostingStatus type for groups.
@@ -16,20 +17,56 @@ const (
 	PostingPermitted    = PostingStatus('y')
 	PostingNotPermitted = PostingStatus('n')
 	PostingModerated    = PostingStatus('m')
+	// PostingNoLocal groups take articles from peers but not local
+	// posts.
+	PostingNoLocal = PostingStatus('x')
+	// PostingJunk groups file articles posted to them in junk.
+	PostingJunk = PostingStatus('j')
+	// PostingAlias groups are another group's old name; articles go to
+	// the group named in Group.AliasOf.
+	PostingAlias = PostingStatus('=')
 )
 
+// String returns the status's flag as in an active file; an alias's
+// target isn't included, see Group.PostingFlag.
 func (ps PostingStatus) String() string {
 	return fmt.Sprintf("%c", ps)
 }
 
+// ParsePostingStatus parses the flag of an active file line, returning
+// the target group of an "=group" alias too.
+func ParsePostingStatus(flag string) (status PostingStatus, aliasOf string, err error) {
+	if strings.HasPrefix(flag, "=") && len(flag) > 1 {
+		return PostingAlias, flag[1:], nil
+	}
+	switch flag {
+	case "y", "n", "m", "x", "j":
+		return PostingStatus(flag[0]), "", nil
+	}
+	return Unknown, "", fmt.Errorf("invalid posting status %q", flag)
+}
+
 // Group represents a usenet newsgroup.
+//
+// In JSON the posting status is its letter, as in an active file.
 type Group struct {
-	Name        string
-	Description string
-	Count       int64
-	High        int64
-	Low         int64
-	Posting     PostingStatus
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Count       int64         `json:"count"`
+	High        int64         `json:"high"`
+	Low         int64         `json:"low"`
+	Posting     PostingStatus `json:"posting"`
+	// AliasOf is the group a PostingAlias group stands for.
+	AliasOf string `json:"alias_of,omitempty"`
+}
+
+// PostingFlag returns the group's flag as in an active file, such as
+// "y", or "=group" for an alias.
+func (g *Group) PostingFlag() string {
+	if g.Posting == PostingAlias {
+		return "=" + g.AliasOf
+	}
+	return g.Posting.String()
 }
 
 // An Article that may appear in one or more groups.
@@ -48,3 +85,10 @@ type Article struct {
 func (a *Article) MessageID() string {
 	return a.Header.Get("Message-Id")
 }
+
+// DecodedHeader returns the first value of the named header with any
+// RFC 2047 encoded-words decoded.
+func (a *Article) DecodedHeader(key string) string {
+	v, _ := DecodeHeader(a.Header.Get(key))
+	return v
+}
@@ -0,0 +1,33 @@
+package nntp
+
+import "testing"
+
+func TestParsePostingStatus(t *testing.T) {
+	tests := []struct {
+		flag    string
+		status  PostingStatus
+		aliasOf string
+	}{
+		{"y", PostingPermitted, ""},
+		{"n", PostingNotPermitted, ""},
+		{"m", PostingModerated, ""},
+		{"x", PostingNoLocal, ""},
+		{"j", PostingJunk, ""},
+		{"=misc.test", PostingAlias, "misc.test"},
+	}
+	for _, test := range tests {
+		status, aliasOf, err := ParsePostingStatus(test.flag)
+		if err != nil || status != test.status || aliasOf != test.aliasOf {
+			t.Errorf("ParsePostingStatus(%q) = %v, %q, %v", test.flag, status, aliasOf, err)
+		}
+		g := Group{Posting: status, AliasOf: aliasOf}
+		if got := g.PostingFlag(); got != test.flag {
+			t.Errorf("%q: PostingFlag() = %q", test.flag, got)
+		}
+	}
+	for _, bad := range []string{"", "=", "q", "yy"} {
+		if _, _, err := ParsePostingStatus(bad); err == nil {
+			t.Errorf("ParsePostingStatus(%q) succeeded", bad)
+		}
+	}
+}
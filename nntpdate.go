@@ -0,0 +1,55 @@
+package nntp
+
+import (
+	"fmt"
+	"time"
+)
+
+// FormatNNTPDate formats t as the date and time arguments of NEWGROUPS
+// and NEWNEWS, "yyyymmdd" and "hhmmss" in UTC, to be sent with the GMT
+// keyword.
+func FormatNNTPDate(t time.Time) (date, tm string) {
+	t = t.UTC()
+	return t.Format("20060102"), t.Format("150405")
+}
+
+// ParseNNTPDate parses the date and time arguments of NEWGROUPS and
+// NEWNEWS.  The date is "yyyymmdd", or "yymmdd" with the century chosen
+// as RFC 3977 says: the current one if yy is no later than this year's
+// last two digits, and the previous one otherwise.  The time is UTC if
+// gmt is set, for the GMT keyword, and local otherwise.
+func ParseNNTPDate(date, tm string, gmt bool) (time.Time, error) {
+	loc := time.Local
+	if gmt {
+		loc = time.UTC
+	}
+	return parseNNTPDate(date, tm, loc, time.Now().In(loc))
+}
+
+func parseNNTPDate(date, tm string, loc *time.Location, now time.Time) (time.Time, error) {
+	if !allDigits(date) || (len(date) != 8 && len(date) != 6) || !allDigits(tm) || len(tm) != 6 {
+		return time.Time{}, fmt.Errorf("invalid date %q %q", date, tm)
+	}
+	if len(date) == 6 {
+		century := now.Year() / 100 * 100
+		yy := int(date[0]-'0')*10 + int(date[1]-'0')
+		if yy > now.Year()%100 {
+			century -= 100
+		}
+		date = fmt.Sprintf("%04d", century+yy) + date[2:]
+	}
+	t, err := time.ParseInLocation("20060102150405", date+tm, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q %q", date, tm)
+	}
+	return t, nil
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
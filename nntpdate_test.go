@@ -0,0 +1,50 @@
+package nntp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNNTPDate(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	tests := []struct {
+		date, tm string
+		loc      *time.Location
+		want     time.Time
+	}{
+		{"20210305", "233001", time.UTC, time.Date(2021, 3, 5, 23, 30, 1, 0, time.UTC)},
+		{"210305", "233001", time.UTC, time.Date(2021, 3, 5, 23, 30, 1, 0, time.UTC)},
+		{"260101", "000000", time.UTC, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"271231", "000000", time.UTC, time.Date(1927, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"991231", "235959", time.UTC, time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"20210305", "233001", tokyo, time.Date(2021, 3, 5, 23, 30, 1, 0, tokyo)},
+	}
+	for _, test := range tests {
+		got, err := parseNNTPDate(test.date, test.tm, test.loc, now)
+		if err != nil || !got.Equal(test.want) {
+			t.Errorf("parseNNTPDate(%q, %q) = %v, %v; want %v", test.date, test.tm, got, err, test.want)
+		}
+	}
+	for _, bad := range [][2]string{
+		{"2021035", "233001"}, {"20210305", "2330"}, {"20211305", "000000"},
+		{"2021-3-5", "000000"}, {"20210305", "246000"}, {"", ""},
+	} {
+		if _, err := ParseNNTPDate(bad[0], bad[1], true); err == nil {
+			t.Errorf("ParseNNTPDate(%q, %q) succeeded", bad[0], bad[1])
+		}
+	}
+}
+
+func TestFormatNNTPDate(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	in := time.Date(2021, 3, 6, 8, 30, 1, 0, tokyo)
+	date, tm := FormatNNTPDate(in)
+	if date != "20210305" || tm != "233001" {
+		t.Errorf("FormatNNTPDate = %s %s", date, tm)
+	}
+	back, err := ParseNNTPDate(date, tm, true)
+	if err != nil || !back.Equal(in) {
+		t.Errorf("Round trip gave %v, %v", back, err)
+	}
+}
@@ -0,0 +1,208 @@
+// Package nzb reads and writes NZB files, the XML format describing which
+// articles make up a binary post.
+//
+// See https://sabnzbd.org/wiki/extra/nzb-spec
+package nzb
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// Namespace of NZB documents.
+const Namespace = "http://www.newzbin.com/DTD/2003/nzb"
+
+const doctype = `<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">`
+
+// NZB is a parsed NZB document.
+type NZB struct {
+	// Meta entries from the head, in document order.
+	Meta  []Meta
+	Files []File
+}
+
+// Meta is a head entry, such as the title or password of a post.
+type Meta struct {
+	Type  string
+	Value string
+}
+
+// A File made up of one or more article segments.
+type File struct {
+	Poster  string
+	Date    time.Time
+	Subject string
+	Groups  []string
+	// Segments in document order.
+	Segments []Segment
+}
+
+// A Segment is one article of a file.
+type Segment struct {
+	// Bytes is the size of the article.
+	Bytes int64
+	// Number of the segment within the file, starting at 1.
+	Number int
+	// MessageID without angle brackets, as NZB stores it.
+	MessageID string
+}
+
+// Size returns the sum of the segment sizes.
+func (f *File) Size() int64 {
+	var n int64
+	for _, s := range f.Segments {
+		n += s.Bytes
+	}
+	return n
+}
+
+// The XML shape of a document.
+type xmlNZB struct {
+	XMLName xml.Name  `xml:"nzb"`
+	Xmlns   string    `xml:"xmlns,attr,omitempty"`
+	Meta    []xmlMeta `xml:"head>meta"`
+	Files   []xmlFile `xml:"file"`
+}
+
+type xmlMeta struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlFile struct {
+	Poster   string       `xml:"poster,attr"`
+	Date     string       `xml:"date,attr"`
+	Subject  string       `xml:"subject,attr"`
+	Groups   []string     `xml:"groups>group"`
+	Segments []xmlSegment `xml:"segments>segment"`
+}
+
+type xmlSegment struct {
+	Bytes     string `xml:"bytes,attr"`
+	Number    string `xml:"number,attr"`
+	MessageID string `xml:",chardata"`
+}
+
+// Parse reads an NZB document.
+//
+// Parsing is lenient: missing optional attributes are left zero, HTML
+// entities are accepted, and message-ids have stray brackets removed.
+func Parse(r io.Reader) (*NZB, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = nntp.CharsetReader
+	var doc xmlNZB
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	rv := &NZB{}
+	for _, m := range doc.Meta {
+		rv.Meta = append(rv.Meta, Meta{m.Type, strings.TrimSpace(m.Value)})
+	}
+	for _, xf := range doc.Files {
+		f := File{
+			Poster:  xf.Poster,
+			Subject: xf.Subject,
+		}
+		if secs, err := strconv.ParseInt(strings.TrimSpace(xf.Date), 10, 64); err == nil {
+			f.Date = time.Unix(secs, 0).UTC()
+		}
+		for _, g := range xf.Groups {
+			if g = strings.TrimSpace(g); g != "" {
+				f.Groups = append(f.Groups, g)
+			}
+		}
+		for _, xs := range xf.Segments {
+			s := Segment{
+				MessageID: strings.Trim(strings.TrimSpace(xs.MessageID), "<>"),
+			}
+			s.Bytes, _ = strconv.ParseInt(strings.TrimSpace(xs.Bytes), 10, 64)
+			s.Number, _ = strconv.Atoi(strings.TrimSpace(xs.Number))
+			if s.MessageID != "" {
+				f.Segments = append(f.Segments, s)
+			}
+		}
+		rv.Files = append(rv.Files, f)
+	}
+	return rv, nil
+}
+
+// Validate checks that the document has everything a downloader needs.
+func (n *NZB) Validate() error {
+	if len(n.Files) == 0 {
+		return errors.New("nzb has no files")
+	}
+	for i, f := range n.Files {
+		if f.Subject == "" {
+			return fmt.Errorf("file %d has no subject", i+1)
+		}
+		if len(f.Groups) == 0 {
+			return fmt.Errorf("file %q has no groups", f.Subject)
+		}
+		if len(f.Segments) == 0 {
+			return fmt.Errorf("file %q has no segments", f.Subject)
+		}
+		seen := map[int]bool{}
+		for _, s := range f.Segments {
+			if s.Number < 1 || seen[s.Number] {
+				return fmt.Errorf("file %q has invalid or duplicate segment number %d",
+					f.Subject, s.Number)
+			}
+			seen[s.Number] = true
+			if s.MessageID == "" || strings.ContainsAny(s.MessageID, "<> \t\r\n") {
+				return fmt.Errorf("file %q segment %d has invalid message-id %q",
+					f.Subject, s.Number, s.MessageID)
+			}
+		}
+	}
+	return nil
+}
+
+// Write writes the document as XML after validating it.
+func (n *NZB) Write(w io.Writer) error {
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	doc := xmlNZB{Xmlns: Namespace}
+	for _, m := range n.Meta {
+		doc.Meta = append(doc.Meta, xmlMeta{m.Type, m.Value})
+	}
+	for _, f := range n.Files {
+		xf := xmlFile{
+			Poster:  f.Poster,
+			Subject: f.Subject,
+			Groups:  f.Groups,
+		}
+		if !f.Date.IsZero() {
+			xf.Date = strconv.FormatInt(f.Date.Unix(), 10)
+		}
+		for _, s := range f.Segments {
+			xf.Segments = append(xf.Segments, xmlSegment{
+				Bytes:     strconv.FormatInt(s.Bytes, 10),
+				Number:    strconv.Itoa(s.Number),
+				MessageID: s.MessageID,
+			})
+		}
+		doc.Files = append(doc.Files, xf)
+	}
+
+	if _, err := io.WriteString(w, xml.Header+doctype+"\n"); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", " ")
+	if err := e.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
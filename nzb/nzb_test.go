@@ -0,0 +1,87 @@
+package nzb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sample = `<?xml version="1.0" encoding="iso-8859-1" ?>
+<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+ <head>
+   <meta type="title">Your File!</meta>
+   <meta type="tag">Example</meta>
+ </head>
+ <file poster="Joe Bloggs &lt;bloggs@nowhere.example&gt;" date="1071674882" subject="Here's your file!&nbsp;abc-mr2a.r01 (1/2) caf` + "\xe9" + `">
+   <groups>
+     <group>alt.binaries.newzbin</group>
+     <group>alt.binaries.mojo</group>
+   </groups>
+   <segments>
+     <segment bytes="102394" number="1">123456789abcdef@news.newzbin.com</segment>
+     <segment number="2"> &lt;123456789abcdeg@news.newzbin.com&gt; </segment>
+   </segments>
+ </file>
+</nzb>
+`
+
+func TestParse(t *testing.T) {
+	n, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &NZB{
+		Meta: []Meta{{"title", "Your File!"}, {"tag", "Example"}},
+		Files: []File{{
+			Poster:  "Joe Bloggs <bloggs@nowhere.example>",
+			Date:    time.Unix(1071674882, 0).UTC(),
+			Subject: "Here's your file!\u00a0abc-mr2a.r01 (1/2) café",
+			Groups:  []string{"alt.binaries.newzbin", "alt.binaries.mojo"},
+			Segments: []Segment{
+				{102394, 1, "123456789abcdef@news.newzbin.com"},
+				{0, 2, "123456789abcdeg@news.newzbin.com"},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(n, exp) {
+		t.Errorf("Got\n%#v\nwanted\n%#v", n, exp)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	n, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := n.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n2, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, n2) {
+		t.Errorf("Round trip changed\n%#v\ninto\n%#v", n, n2)
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	tests := []*NZB{
+		{},
+		{Files: []File{{Subject: "x", Segments: []Segment{{1, 1, "a@b"}}}}},
+		{Files: []File{{Subject: "x", Groups: []string{"a.b"}}}},
+		{Files: []File{{Subject: "x", Groups: []string{"a.b"},
+			Segments: []Segment{{1, 1, "a@b"}, {1, 1, "c@d"}}}}},
+		{Files: []File{{Subject: "x", Groups: []string{"a.b"},
+			Segments: []Segment{{1, 1, "<a@b>"}}}}},
+	}
+	for i, n := range tests {
+		if err := n.Write(&bytes.Buffer{}); err == nil {
+			t.Errorf("Invalid document %d was written", i)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package nntp
+
+import (
+	"errors"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Overview is the overview information for a single article as returned
+// by OVER/XOVER.
+type Overview struct {
+	// Article number within the group
+	Number     int64
+	Subject    string
+	From       string
+	Date       string
+	MessageID  string
+	References string
+	// Number of bytes in the article
+	Bytes int64
+	// Number of lines in the article body
+	Lines int64
+	// Xref is the value of the Xref header, if the server includes it.
+	Xref string
+	// extra is the fields after the first eight, still tab separated.
+	extra string
+	// Time is Date parsed, or the zero time if it could not be parsed.
+	Time time.Time
+}
+
+// ReferenceIDs splits the References field into individual message-ids,
+// oldest first.
+//
+// Anything that isn't enclosed in angle brackets is discarded, since real
+// world References headers are frequently mangled by broken clients.
+func (o *Overview) ReferenceIDs() []string {
+	return ParseReferences(o.References)
+}
+
+// ExtraFields returns the fields after the first eight as sent, such as
+// "Xref: host misc.test:42", Xref included.
+func (o *Overview) ExtraFields() []string {
+	if o.extra == "" {
+		return nil
+	}
+	return strings.Split(o.extra, "\t")
+}
+
+// Fields names the fields after the first eight, using format, the
+// lines of LIST OVERVIEW.FMT, and returns them keyed by canonical header
+// name.  A field the format marks ":full" has its "Name:" prefix
+// removed.  Fields beyond those the format lists are named by their own
+// prefix if they have one, and left out otherwise; fields the format
+// lists but the line lacks are missing from the map.
+func (o *Overview) Fields(format []string) map[string]string {
+	extra := o.ExtraFields()
+	if extra == nil {
+		return nil
+	}
+	rv := make(map[string]string, len(extra))
+	for i, f := range extra {
+		if i+7 < len(format) {
+			name, full := parseFormatField(format[i+7])
+			if !full {
+				rv[name] = f
+				continue
+			}
+			if j := strings.IndexByte(f, ':'); j >= 0 && strings.EqualFold(f[:j], name) {
+				f = f[j+1:]
+			}
+			rv[name] = strings.TrimSpace(f)
+			continue
+		}
+		if j := strings.IndexByte(f, ':'); j > 0 && !strings.ContainsAny(f[:j], " \t") {
+			rv[textproto.CanonicalMIMEHeaderKey(f[:j])] = strings.TrimSpace(f[j+1:])
+		}
+	}
+	return rv
+}
+
+// parseFormatField parses a line of LIST OVERVIEW.FMT, such as "Xref:full",
+// into a canonical header name and whether values include that name.
+// Metadata items like ":bytes" are returned as they are.
+func parseFormatField(line string) (name string, full bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, ":") {
+		return line, false
+	}
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		full = strings.EqualFold(line[i+1:], "full")
+		line = line[:i]
+	}
+	return textproto.CanonicalMIMEHeaderKey(line), full
+}
+
+// ParseReferences extracts the message-ids from a References header value.
+func ParseReferences(refs string) []string {
+	var rv []string
+	for {
+		start := strings.IndexByte(refs, '<')
+		if start == -1 {
+			return rv
+		}
+		end := strings.IndexByte(refs[start:], '>')
+		if end == -1 {
+			return rv
+		}
+		id := refs[start : start+end+1]
+		// A '<' inside an id means the previous one was unterminated.
+		if i := strings.LastIndexByte(id, '<'); i > 0 {
+			id = id[i:]
+		}
+		if len(id) > 2 {
+			rv = append(rv, id)
+		}
+		refs = refs[start+end+1:]
+	}
+}
+
+// Decoded returns a copy of the overview with RFC 2047 encoded-words in
+// the Subject and From fields decoded.
+func (o *Overview) Decoded() Overview {
+	rv := *o
+	rv.Subject, _ = DecodeHeader(o.Subject)
+	rv.From, _ = DecodeHeader(o.From)
+	return rv
+}
+
+// ParseOverview parses a line of OVER/XOVER output.
+//
+// Missing trailing fields are left empty, and an Xref field is picked up
+// if the server includes it.  Any fields after the eighth are available
+// from ExtraFields.  Only an invalid article number is an
+// error.
+func ParseOverview(line string) (Overview, error) {
+	// Split by hand rather than with strings.Split: this runs once per
+	// line of very long OVER responses, and the fields slice was most of
+	// its garbage.
+	var fields [8]string
+	rest := strings.TrimRight(line, "\r\n")
+	for i := range fields {
+		j := strings.IndexByte(rest, '\t')
+		if j == -1 {
+			fields[i], rest = rest, ""
+			break
+		}
+		fields[i], rest = rest[:j], rest[j+1:]
+	}
+	n, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Overview{}, errors.New("invalid overview line: " + line)
+	}
+	rv := Overview{
+		Number:     n,
+		Subject:    fields[1],
+		From:       fields[2],
+		Date:       fields[3],
+		MessageID:  fields[4],
+		References: fields[5],
+	}
+	rv.Bytes, _ = strconv.ParseInt(strings.TrimSpace(fields[6]), 10, 64)
+	rv.Lines, _ = strconv.ParseInt(strings.TrimSpace(fields[7]), 10, 64)
+	rv.extra = rest
+	for rest != "" {
+		f := rest
+		if j := strings.IndexByte(rest, '\t'); j != -1 {
+			f, rest = rest[:j], rest[j+1:]
+		} else {
+			rest = ""
+		}
+		if len(f) > 5 && strings.EqualFold(f[:5], "Xref:") {
+			rv.Xref = strings.TrimSpace(f[5:])
+		}
+	}
+	rv.Time, _ = ParseDate(rv.Date)
+	return rv, nil
+}
+
+// dateLayouts are the forms of Date header nearly every article uses.
+// Unlike time.RFC1123Z they accept single digit days.
+var dateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+// ParseDate parses a Date header, trying the common RFC 1123 forms before
+// the much slower mail.ParseDate.
+func ParseDate(date string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	return mail.ParseDate(date)
+}
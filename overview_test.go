@@ -0,0 +1,94 @@
+package nntp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOverview(t *testing.T) {
+	line := "42\tHello\ta@example.com\tMon, 2 Jan 2006 15:04:05 -0700\t<1@x>\t<0@x>\t1234\t20\tXref: host misc.test:42"
+	o, err := ParseOverview(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := Overview{
+		Number: 42, Subject: "Hello", From: "a@example.com",
+		Date: "Mon, 2 Jan 2006 15:04:05 -0700", MessageID: "<1@x>", References: "<0@x>",
+		Bytes: 1234, Lines: 20, Xref: "host misc.test:42",
+		Time: time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC),
+	}
+	if !o.Time.Equal(exp.Time) {
+		t.Errorf("Time %v, wanted %v", o.Time, exp.Time)
+	}
+	if got := o.ExtraFields(); len(got) != 1 || got[0] != "Xref: host misc.test:42" {
+		t.Errorf("ExtraFields() = %q", got)
+	}
+	o.Time = exp.Time
+	o.extra = ""
+	if o != exp {
+		t.Errorf("Got %+v, wanted %+v", o, exp)
+	}
+
+	o, err = ParseOverview("7\tShort\tb@x\tbogus date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Number != 7 || o.Subject != "Short" || !o.Time.IsZero() || o.Lines != 0 {
+		t.Errorf("Short line got %+v", o)
+	}
+
+	if _, err := ParseOverview("x\tfoo"); err == nil {
+		t.Errorf("Parsed line without a number")
+	}
+}
+
+func TestOverviewFields(t *testing.T) {
+	format := []string{"Subject:", "From:", "Date:", "Message-ID:", "References:",
+		"Bytes:", "Lines:", "Xref:full", "newsgroups:"}
+	tests := []struct {
+		line   string
+		format []string
+		want   map[string]string
+	}{
+		{"1\ts\tf\td\tm\tr\t1\t2", format, nil},
+		{"1\ts\tf\td\tm\tr\t1\t2\tXref: h a:1\ta,b", format,
+			map[string]string{"Xref": "h a:1", "Newsgroups": "a,b"}},
+		// Fewer fields than the format lists.
+		{"1\ts\tf\td\tm\tr\t1\t2\tXREF: h a:1", format,
+			map[string]string{"Xref": "h a:1"}},
+		// More, named by their prefix where they have one.
+		{"1\ts\tf\td\tm\tr\t1\t2\tXref: h a:1\ta\tX-Foo: bar\tanonymous", format,
+			map[string]string{"Xref": "h a:1", "Newsgroups": "a", "X-Foo": "bar"}},
+		// No format at all.
+		{"1\ts\tf\td\tm\tr\t1\t2\tXref: h a:1", nil,
+			map[string]string{"Xref": "h a:1"}},
+	}
+	for _, test := range tests {
+		o, err := ParseOverview(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := o.Fields(test.format); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Fields of %q = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)
+	for _, s := range []string{
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"2 Jan 2006 15:04:05 -0700",
+		// Only mail.ParseDate knows these.
+		"Mon, 02 Jan 2006 22:04:05 GMT",
+		"Mon, 2 Jan 2006 15:04:05 -0700 (MST)",
+	} {
+		if got, err := ParseDate(s); err != nil || !got.Equal(want) {
+			t.Errorf("ParseDate(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseDate("bogus date"); err == nil {
+		t.Errorf("Parsed a bogus date")
+	}
+}
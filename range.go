@@ -0,0 +1,129 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An ArticleRange is a range of article numbers, inclusive at both ends.
+// High is math.MaxInt64 for a range without an upper bound, such as
+// "100-".
+type ArticleRange struct {
+	Low, High int64
+}
+
+// Single is the range of just article n.
+func Single(n int64) ArticleRange {
+	return ArticleRange{n, n}
+}
+
+// From is the range of article low and every one after it.
+func From(low int64) ArticleRange {
+	return ArticleRange{low, math.MaxInt64}
+}
+
+// Between is the range from article low to high.
+func Between(low, high int64) ArticleRange {
+	return ArticleRange{low, high}
+}
+
+// Validate checks that the range can be sent in a command: its numbers
+// are positive and Low isn't above High.
+func (r ArticleRange) Validate() error {
+	if r.Low < 1 || r.High < 1 {
+		return fmt.Errorf("article range %d-%d: numbers must be positive", r.Low, r.High)
+	}
+	if r.High < r.Low {
+		return fmt.Errorf("article range %d-%d is empty", r.Low, r.High)
+	}
+	return nil
+}
+
+// String formats the range in RFC 3977 syntax: "n", "n-" or "n-m".
+func (r ArticleRange) String() string {
+	low := strconv.FormatInt(r.Low, 10)
+	switch r.High {
+	case r.Low:
+		return low
+	case math.MaxInt64:
+		return low + "-"
+	}
+	return low + "-" + strconv.FormatInt(r.High, 10)
+}
+
+// ParseArticleRange parses the RFC 3977 forms "n", "n-" and "n-m".
+func ParseArticleRange(s string) (ArticleRange, error) {
+	lo, hi := s, s
+	open := false
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+		open = hi == ""
+	}
+	low, err := parseArticleNumber(lo)
+	if err != nil {
+		return ArticleRange{}, fmt.Errorf("invalid range %q", s)
+	}
+	if open {
+		return ArticleRange{low, math.MaxInt64}, nil
+	}
+	high, err := parseArticleNumber(hi)
+	if err != nil || high < low {
+		return ArticleRange{}, fmt.Errorf("invalid range %q", s)
+	}
+	return ArticleRange{low, high}, nil
+}
+
+func parseArticleNumber(s string) (int64, error) {
+	if s == "" {
+		return 0, errors.New("empty article number")
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, errors.New("invalid article number")
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Empty is true if the range contains no numbers.
+func (r ArticleRange) Empty() bool {
+	return r.High < r.Low
+}
+
+// Contains reports whether n is in the range.
+func (r ArticleRange) Contains(n int64) bool {
+	return n >= r.Low && n <= r.High
+}
+
+// Clamp intersects the range with low-high, typically a group's
+// watermarks.  The result may be Empty.
+func (r ArticleRange) Clamp(low, high int64) ArticleRange {
+	if r.Low < low {
+		r.Low = low
+	}
+	if r.High > high {
+		r.High = high
+	}
+	return r
+}
+
+// Iterate splits the range into consecutive sub-ranges of at most chunk
+// numbers.  A range without an upper bound should be clamped first;
+// otherwise its last sub-range is left unbounded.
+func (r ArticleRange) Iterate(chunk int) []ArticleRange {
+	if r.Empty() || chunk <= 0 {
+		return nil
+	}
+	var rv []ArticleRange
+	for low := r.Low; ; {
+		if r.High == math.MaxInt64 || r.High-low < int64(chunk) {
+			return append(rv, ArticleRange{low, r.High})
+		}
+		high := low + int64(chunk) - 1
+		rv = append(rv, ArticleRange{low, high})
+		low = high + 1
+	}
+}
@@ -0,0 +1,95 @@
+package nntp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseArticleRange(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp ArticleRange
+	}{
+		{"5", ArticleRange{5, 5}},
+		{"100-", ArticleRange{100, math.MaxInt64}},
+		{"73-1845", ArticleRange{73, 1845}},
+		{"7-8", ArticleRange{7, 8}},
+	}
+	for _, test := range tests {
+		got, err := ParseArticleRange(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if got != test.exp {
+			t.Errorf("%q: got %v, wanted %v", test.in, got, test.exp)
+		}
+		if got.String() != test.in {
+			t.Errorf("%q: formatted as %q", test.in, got.String())
+		}
+	}
+	for _, bad := range []string{"", "-", "-5", "5--", "a", "1-b", "+1", "1-+5",
+		"10-5", "1 -5", "99999999999999999999"} {
+		if r, err := ParseArticleRange(bad); err == nil {
+			t.Errorf("Parsed %q as %v", bad, r)
+		}
+	}
+}
+
+func TestArticleRangeClamp(t *testing.T) {
+	r := ArticleRange{100, math.MaxInt64}.Clamp(50, 500)
+	if r != (ArticleRange{100, 500}) {
+		t.Errorf("Clamped to %v", r)
+	}
+	if r := (ArticleRange{1, 10}).Clamp(20, 30); !r.Empty() {
+		t.Errorf("Disjoint clamp %v isn't empty", r)
+	}
+	if !r.Contains(100) || !r.Contains(500) || r.Contains(501) {
+		t.Errorf("Contains is wrong for %v", r)
+	}
+}
+
+func TestArticleRangeIterate(t *testing.T) {
+	tests := []struct {
+		r     ArticleRange
+		chunk int
+		exp   []ArticleRange
+	}{
+		{ArticleRange{1, 10}, 4, []ArticleRange{{1, 4}, {5, 8}, {9, 10}}},
+		{ArticleRange{1, 8}, 4, []ArticleRange{{1, 4}, {5, 8}}},
+		{ArticleRange{5, 5}, 100, []ArticleRange{{5, 5}}},
+		{ArticleRange{5, 4}, 100, nil},
+		{ArticleRange{5, math.MaxInt64}, 100, []ArticleRange{{5, math.MaxInt64}}},
+	}
+	for _, test := range tests {
+		got := test.r.Iterate(test.chunk)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%v by %d: got %v, wanted %v", test.r, test.chunk, got, test.exp)
+		}
+	}
+}
+
+func TestArticleRangeConstructors(t *testing.T) {
+	tests := []struct {
+		r     ArticleRange
+		s     string
+		valid bool
+	}{
+		{Single(5), "5", true},
+		{From(100), "100-", true},
+		{Between(73, 1845), "73-1845", true},
+		{Between(7, 7), "7", true},
+		{Between(8, 7), "8-7", false},
+		{Single(0), "0", false},
+		{From(-1), "-1-", false},
+	}
+	for _, test := range tests {
+		if got := test.r.String(); got != test.s {
+			t.Errorf("%+v formatted as %q, wanted %q", test.r, got, test.s)
+		}
+		if err := test.r.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v: Validate() = %v", test.r, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package nntpserver
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// A ControlAction is what the server does with a control message.
+type ControlAction int
+
+const (
+	// ControlLog stores the article and logs the request without acting on it.
+	ControlLog ControlAction = iota
+	// ControlHonor stores the article and carries out the request.
+	ControlHonor
+	// ControlDrop silently discards the article.
+	ControlDrop
+)
+
+// A ControlPolicy decides what to do with a control message.  authorized
+// reports whether the posting session is authorized; an unauthorized
+// control message is never honored, ControlHonor is downgraded to
+// ControlLog.
+type ControlPolicy func(ctl *nntp.Control, article *nntp.Article, authorized bool) ControlAction
+
+// A GroupBackend is a Backend that can create and remove groups.
+// newgroup, rmgroup and checkgroups control messages are only honored
+// when the backend implements it.  Checkgroups creates missing groups and
+// removes those within its scope it doesn't list; changed descriptions
+// are only logged.
+type GroupBackend interface {
+	Backend
+	CreateGroup(name, description string, moderated bool) error
+	RemoveGroup(name string) error
+}
+
+// controlAction applies the server's policy to a control message.
+func (s *session) controlAction(ctl *nntp.Control, article *nntp.Article) ControlAction {
+	authorized := s.backend.Authorized()
+	action := ControlLog
+	if s.server.ControlPolicy != nil {
+		action = s.server.ControlPolicy(ctl, article, authorized)
+	}
+	if action == ControlHonor && !authorized {
+		action = ControlLog
+	}
+	return action
+}
+
+// control carries out a stored control message according to action.
+func (s *session) control(ctl *nntp.Control, article *nntp.Article, action ControlAction) {
+	if action != ControlHonor {
+		log.Printf("Logged control message %s: %s %v",
+			article.MessageID(), ctl.Type, ctl.Args)
+		if ctl.Type == nntp.ControlCheckgroups {
+			if diff, err := s.checkgroups(ctl); err == nil && !diff.Empty() {
+				log.Printf("Checkgroups %s would add %v, change %v, remove %v",
+					article.MessageID(), diff.Added, diff.Changed, diff.Removed)
+			}
+		}
+		return
+	}
+	if err := s.honorControl(ctl, article); err != nil {
+		log.Printf("Not honoring control message %s (%s %v): %v",
+			article.MessageID(), ctl.Type, ctl.Args, err)
+	}
+}
+
+func (s *session) honorControl(ctl *nntp.Control, article *nntp.Article) error {
+	if ctl.Type == nntp.ControlCancel {
+		return s.cancel(article, ctl.Target)
+	}
+	gb, ok := s.backend.(GroupBackend)
+	switch ctl.Type {
+	case nntp.ControlNewgroup:
+		if !ok {
+			return errors.New("backend can't manage groups")
+		}
+		description := ""
+		for _, d := range ctl.Descriptions {
+			if d.Name == ctl.Group {
+				description = d.Description
+			}
+		}
+		return gb.CreateGroup(ctl.Group, description, ctl.Moderated)
+	case nntp.ControlRmgroup:
+		if !ok {
+			return errors.New("backend can't manage groups")
+		}
+		return gb.RemoveGroup(ctl.Group)
+	case nntp.ControlCheckgroups:
+		if !ok {
+			return errors.New("backend can't manage groups")
+		}
+		diff, err := s.checkgroups(ctl)
+		if err != nil {
+			return err
+		}
+		for _, d := range diff.Added {
+			err := gb.CreateGroup(d.Name, d.Description,
+				strings.HasSuffix(d.Description, "(Moderated)"))
+			if err != nil {
+				return err
+			}
+		}
+		for _, name := range diff.Removed {
+			if err := gb.RemoveGroup(name); err != nil {
+				return err
+			}
+		}
+		if len(diff.Changed) > 0 {
+			log.Printf("Checkgroups changes descriptions of %v", diff.Changed)
+		}
+		return nil
+	}
+	return errors.New("unsupported control message")
+}
+
+// checkgroups compares a checkgroups message to the backend's groups.
+func (s *session) checkgroups(ctl *nntp.Control) (*nntp.CheckgroupsDiff, error) {
+	groups, err := s.backend.ListGroups(-1)
+	if err != nil {
+		return nil, err
+	}
+	current := nntp.Newsgroups{}
+	for _, g := range groups {
+		current[g.Name] = g.Description
+	}
+	return current.Checkgroups(ctl), nil
+}
@@ -0,0 +1,148 @@
+package nntpserver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func (b *memBackend) CreateGroup(name, description string, moderated bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	posting := nntp.PostingPermitted
+	if moderated {
+		posting = nntp.PostingModerated
+	}
+	b.groups = append(b.groups, &nntp.Group{Name: name, Description: description, Posting: posting})
+	return nil
+}
+
+func (b *memBackend) RemoveGroup(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, g := range b.groups {
+		if g.Name == name {
+			b.groups = append(b.groups[:i], b.groups[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoSuchGroup
+}
+
+const newgroupArticle = "From: admin@example.com\r\nNewsgroups: misc.test\r\n" +
+	"Message-Id: <ng@example.com>\r\nControl: newgroup misc.new moderated\r\n" +
+	"\r\nFor your newsgroups file:\r\nmisc.new\tNew stuff. (Moderated)\r\n"
+
+func honorAll(ctl *nntp.Control, article *nntp.Article, authorized bool) ControlAction {
+	return ControlHonor
+}
+
+func TestControlNewgroupRmgroup(t *testing.T) {
+	b := newMemBackend()
+	s := NewServer(b)
+	s.ControlPolicy = honorAll
+	c := dialServer(t, s)
+
+	if code, msg := postArticle(t, c, newgroupArticle); code != 240 {
+		t.Fatalf("Posting newgroup: %d %s", code, msg)
+	}
+	g, err := b.GetGroup("misc.new")
+	if err != nil {
+		t.Fatalf("Group wasn't created")
+	}
+	if g.Description != "New stuff. (Moderated)" || g.Posting != nntp.PostingModerated {
+		t.Errorf("Created %+v", g)
+	}
+	if _, ok := b.articles["<ng@example.com>"]; !ok {
+		t.Errorf("Control message wasn't stored")
+	}
+
+	postArticle(t, c, "From: admin@example.com\r\nMessage-Id: <rg@example.com>\r\n"+
+		"Control: rmgroup misc.new\r\n\r\nGone.\r\n")
+	if _, err := b.GetGroup("misc.new"); err == nil {
+		t.Errorf("Group wasn't removed")
+	}
+}
+
+func TestControlCancel(t *testing.T) {
+	b := newMemBackend()
+	s := NewServer(b)
+	s.ControlPolicy = honorAll
+	c := dialServer(t, s)
+
+	postArticle(t, c, "From: a@example.com\r\nMessage-Id: <1@x>\r\n\r\n1\r\n")
+	postArticle(t, c, "From: b@example.com\r\nMessage-Id: <c1@x>\r\n"+
+		"Control: cancel <1@x>\r\n\r\n")
+	if len(b.cancelled) != 0 {
+		t.Fatalf("Cancel by someone else honored: %v", b.cancelled)
+	}
+	postArticle(t, c, "From: a@example.com\r\nMessage-Id: <c2@x>\r\n"+
+		"Control: cancel <1@x>\r\n\r\n")
+	if len(b.cancelled) != 1 || b.cancelled[0] != "<1@x>" {
+		t.Errorf("Cancelled %v", b.cancelled)
+	}
+}
+
+func TestControlPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     ControlPolicy
+		authorized bool
+		created    bool
+		stored     bool
+	}{
+		{"default", nil, true, false, true},
+		{"honor", honorAll, true, true, true},
+		{"unauthorized", honorAll, false, false, true},
+		{"drop", func(*nntp.Control, *nntp.Article, bool) ControlAction {
+			return ControlDrop
+		}, true, false, false},
+	}
+	for _, test := range tests {
+		b := newMemBackend()
+		b.authorized = test.authorized
+		s := NewServer(b)
+		s.ControlPolicy = test.policy
+		c := dialServer(t, s)
+		if code, msg := postArticle(t, c, newgroupArticle); code != 240 {
+			t.Fatalf("%s: posting got %d %s", test.name, code, msg)
+		}
+		if _, err := b.GetGroup("misc.new"); (err == nil) != test.created {
+			t.Errorf("%s: created = %v", test.name, err == nil)
+		}
+		if _, ok := b.articles["<ng@example.com>"]; ok != test.stored {
+			t.Errorf("%s: stored = %v", test.name, ok)
+		}
+	}
+}
+
+func TestControlInvalid(t *testing.T) {
+	s := NewServer(newMemBackend())
+	c := dialServer(t, s)
+	if code, _ := postArticle(t, c, "Message-Id: <c@x>\r\nControl: cancel\r\n\r\n"); code != 441 {
+		t.Errorf("Invalid control got %d", code)
+	}
+}
+
+func TestControlCheckgroups(t *testing.T) {
+	b := newMemBackend()
+	b.groups = append(b.groups, &nntp.Group{Name: "misc.old", Description: "Old"})
+	s := NewServer(b)
+	s.ControlPolicy = honorAll
+	c := dialServer(t, s)
+
+	postArticle(t, c, "From: admin@example.com\r\nMessage-Id: <cg@x>\r\n"+
+		"Control: checkgroups #1\r\n\r\n"+
+		"misc.test\tTesting\r\nmisc.new\tNew stuff. (Moderated)\r\n")
+	var names []string
+	for _, g := range b.groups {
+		names = append(names, g.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"misc.test", "misc.new"}) {
+		t.Errorf("Groups after checkgroups: %v", names)
+	}
+	if g, _ := b.GetGroup("misc.new"); g.Posting != nntp.PostingModerated {
+		t.Errorf("misc.new isn't moderated")
+	}
+}
@@ -0,0 +1,298 @@
+package nntpserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// DefaultNumberReserve is how many article numbers a Numbering reserves
+// in a group at a time.
+const DefaultNumberReserve = 100
+
+// Watermarks describe the article numbers in use in a group, as GROUP
+// and LIST report them.
+type Watermarks struct {
+	Low, High, Count int64
+}
+
+// NumberState is what a Numbering persists for a group.  Reserved is the
+// highest number that may have been handed out; after a restart
+// numbering continues above it.  High and Count are saved along with
+// changes to Reserved and Low, so they may be behind after a crash; the
+// numbers between High and Reserved are then counted as in use, since
+// Count may be too high but mustn't be too low.
+type NumberState struct {
+	Low      int64 `json:"low"`
+	High     int64 `json:"high"`
+	Reserved int64 `json:"reserved"`
+	Count    int64 `json:"count"`
+	// Holes are numbers between Low and High that aren't in use.
+	Holes []int64 `json:"holes,omitempty"`
+}
+
+// A NumberStore persists a Numbering's state.  SaveNumbers must not
+// return until the state is durable: a number is only handed out once
+// the state reserving it has been saved.
+type NumberStore interface {
+	LoadNumbers() (map[string]NumberState, error)
+	SaveNumbers(group string, state NumberState) error
+}
+
+// A Numbering assigns article numbers and keeps each group's watermarks,
+// the bookkeeping every backend needs.  It's safe for concurrent use.
+//
+// Numbers are reserved in blocks of Reserve, so the store is written
+// once per block rather than once per article.  A restart skips the rest
+// of the block, but never reissues a number.
+type Numbering struct {
+	// Reserve is how many numbers to reserve at a time.  Defaults to
+	// DefaultNumberReserve.
+	Reserve int64
+
+	mu     sync.Mutex
+	store  NumberStore
+	groups map[string]*NumberState
+}
+
+// NewNumbering loads a Numbering from store, which may be nil to keep
+// numbers in memory only.
+func NewNumbering(store NumberStore) (*Numbering, error) {
+	rv := &Numbering{store: store, groups: map[string]*NumberState{}}
+	if store == nil {
+		return rv, nil
+	}
+	states, err := store.LoadNumbers()
+	if err != nil {
+		return nil, err
+	}
+	for name, st := range states {
+		st := st
+		// Numbers up to Reserved may have been handed out after the
+		// state was last saved, so they're skipped and counted as in
+		// use.  Releasing or retiring them then leaves Count right.
+		if st.Reserved > st.High {
+			from := st.High + 1
+			if from < st.Low {
+				from = st.Low
+			}
+			if st.Reserved >= from {
+				st.Count += st.Reserved - from + 1
+			}
+			st.High = st.Reserved
+		}
+		rv.groups[name] = &st
+	}
+	return rv, nil
+}
+
+func (n *Numbering) group(name string) *NumberState {
+	st, ok := n.groups[name]
+	if !ok {
+		st = &NumberState{Low: 1}
+		n.groups[name] = st
+	}
+	return st
+}
+
+func (n *Numbering) save(name string, st *NumberState) error {
+	if n.store == nil {
+		return nil
+	}
+	return n.store.SaveNumbers(name, *st)
+}
+
+// AssignNext assigns the next article number in a group.
+func (n *Numbering) AssignNext(group string) (int64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st := n.group(group)
+	next := st.High + 1
+	if next > st.Reserved {
+		reserve := n.Reserve
+		if reserve <= 0 {
+			reserve = DefaultNumberReserve
+		}
+		saved := *st
+		saved.Reserved = st.High + reserve
+		if err := n.save(group, &saved); err != nil {
+			return 0, err
+		}
+		st.Reserved = saved.Reserved
+	}
+	st.High = next
+	st.Count++
+	return next, nil
+}
+
+// AssignArticle assigns numbers in each group in an article's Newsgroups
+// header, for a NumberingBackend to return.
+func (n *Numbering) AssignArticle(article *nntp.Article) ([]nntp.GroupNumber, error) {
+	var rv []nntp.GroupNumber
+	for _, g := range nntp.ParseDistributions(article.Header.Get("Newsgroups")) {
+		num, err := n.AssignNext(g)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, nntp.GroupNumber{Group: g, Number: num})
+	}
+	return rv, nil
+}
+
+// Release gives back a number that won't be used after all, such as
+// one assigned to an article that failed to store or was cancelled.
+func (n *Numbering) Release(group string, number int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st, ok := n.groups[group]
+	if !ok || number < st.Low || number > st.High {
+		return nil
+	}
+	i := sort.Search(len(st.Holes), func(i int) bool { return st.Holes[i] >= number })
+	if i < len(st.Holes) && st.Holes[i] == number {
+		return nil
+	}
+	st.Holes = append(st.Holes, 0)
+	copy(st.Holes[i+1:], st.Holes[i:])
+	st.Holes[i] = number
+	st.Count--
+	return n.save(group, st)
+}
+
+// Retire removes the numbers up to upTo from a group, as when its
+// articles expire, raising the low watermark past them.
+func (n *Numbering) Retire(group string, upTo int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st, ok := n.groups[group]
+	if !ok || upTo < st.Low {
+		return nil
+	}
+	if upTo > st.High {
+		upTo = st.High
+	}
+	holes := sort.Search(len(st.Holes), func(i int) bool { return st.Holes[i] > upTo })
+	st.Count -= upTo - st.Low + 1 - int64(holes)
+	st.Holes = append([]int64(nil), st.Holes[holes:]...)
+	st.Low = upTo + 1
+	return n.save(group, st)
+}
+
+// Watermarks returns a group's watermarks.  An empty group's high
+// watermark is one less than its low.
+func (n *Numbering) Watermarks(group string) Watermarks {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st, ok := n.groups[group]
+	if !ok {
+		return Watermarks{Low: 1}
+	}
+	return watermarks(st)
+}
+
+func watermarks(st *NumberState) Watermarks {
+	return Watermarks{Low: st.Low, High: st.High, Count: st.Count}
+}
+
+// Snapshot returns every group's watermarks.
+func (n *Numbering) Snapshot() map[string]Watermarks {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	rv := make(map[string]Watermarks, len(n.groups))
+	for name, st := range n.groups {
+		rv[name] = watermarks(st)
+	}
+	return rv
+}
+
+// Fill sets a group's Low, High and Count from its watermarks.
+func (n *Numbering) Fill(g *nntp.Group) {
+	w := n.Watermarks(g.Name)
+	g.Low, g.High, g.Count = w.Low, w.High, w.Count
+}
+
+// A FileNumberStore keeps a Numbering's state in a JSON file, replaced
+// atomically on every save.
+type FileNumberStore struct {
+	path   string
+	mu     sync.Mutex
+	states map[string]NumberState
+}
+
+// NewFileNumberStore stores state in the file at path, which needn't
+// exist yet.
+func NewFileNumberStore(path string) *FileNumberStore {
+	return &FileNumberStore{path: path}
+}
+
+// LoadNumbers implements NumberStore.
+func (s *FileNumberStore) LoadNumbers() (map[string]NumberState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = map[string]NumberState{}
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return map[string]NumberState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.states); err != nil {
+		return nil, err
+	}
+	rv := make(map[string]NumberState, len(s.states))
+	for k, v := range s.states {
+		rv[k] = v
+	}
+	return rv, nil
+}
+
+// SaveNumbers implements NumberStore.
+func (s *FileNumberStore) SaveNumbers(group string, state NumberState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = map[string]NumberState{}
+	}
+	old, had := s.states[group]
+	s.states[group] = state
+	data, err := json.Marshal(s.states)
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+	if err != nil {
+		if had {
+			s.states[group] = old
+		} else {
+			delete(s.states, group)
+		}
+	}
+	return err
+}
+
+// writeFileAtomic replaces the file at path with data, syncing it before
+// it's renamed into place.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
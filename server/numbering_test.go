@@ -0,0 +1,189 @@
+package nntpserver
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNumberingConcurrent(t *testing.T) {
+	n, _ := NewNumbering(nil)
+	var mu sync.Mutex
+	seen := map[int64]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				num, err := n.AssignNext("misc.test")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[num] {
+					t.Errorf("%d assigned twice", num)
+				}
+				seen[num] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if w := n.Watermarks("misc.test"); w != (Watermarks{1, 1000, 1000}) {
+		t.Errorf("Watermarks %+v", w)
+	}
+}
+
+func TestNumberingRetire(t *testing.T) {
+	n, _ := NewNumbering(nil)
+	for i := 0; i < 10; i++ {
+		n.AssignNext("misc.test")
+	}
+	n.Release("misc.test", 3)
+	n.Release("misc.test", 3)
+	n.Release("misc.test", 8)
+	if w := n.Watermarks("misc.test"); w != (Watermarks{1, 10, 8}) {
+		t.Errorf("After release %+v", w)
+	}
+	n.Retire("misc.test", 5)
+	if w := n.Watermarks("misc.test"); w != (Watermarks{6, 10, 4}) {
+		t.Errorf("After retiring %+v", w)
+	}
+	n.Retire("misc.test", 20)
+	if w := n.Watermarks("misc.test"); w != (Watermarks{11, 10, 0}) {
+		t.Errorf("After retiring everything %+v", w)
+	}
+	if num, _ := n.AssignNext("misc.test"); num != 11 {
+		t.Errorf("Assigned %d after emptying the group", num)
+	}
+	if w := n.Watermarks("alt.empty"); w != (Watermarks{1, 0, 0}) {
+		t.Errorf("Unknown group %+v", w)
+	}
+}
+
+// flakyStore fails saves while broken.
+type flakyStore struct {
+	*FileNumberStore
+	broken bool
+}
+
+func (s *flakyStore) SaveNumbers(group string, state NumberState) error {
+	if s.broken {
+		return errors.New("disk full")
+	}
+	return s.FileNumberStore.SaveNumbers(group, state)
+}
+
+func TestNumberingCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "numbers.json")
+	store := &flakyStore{FileNumberStore: NewFileNumberStore(path)}
+	n, err := NewNumbering(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Reserve = 10
+	var last int64
+	for i := 0; i < 15; i++ {
+		last, _ = n.AssignNext("misc.test")
+	}
+	n.Retire("misc.test", 4)
+
+	// Numbers beyond the reservation can't be handed out unsaved.
+	store.broken = true
+	for i := 0; i < 5; i++ {
+		last, _ = n.AssignNext("misc.test")
+	}
+	if _, err := n.AssignNext("misc.test"); err == nil {
+		t.Fatalf("Assigned past the reservation without saving")
+	}
+
+	// Restart without a clean shutdown.
+	n, err = NewNumbering(NewFileNumberStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	num, err := n.AssignNext("misc.test")
+	if err != nil || num <= last {
+		t.Errorf("Assigned %d after restart, but %d was already issued: %v", num, last, err)
+	}
+	if w := n.Watermarks("misc.test"); w.Low != 5 || w.High != num {
+		t.Errorf("Watermarks after restart %+v", w)
+	}
+}
+
+func TestNumberingRestartRetire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "numbers.json")
+	n, err := NewNumbering(NewFileNumberStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Reserve = 100
+	if num, err := n.AssignNext("misc.test"); err != nil || num != 1 {
+		t.Fatalf("Assigned %d, %v", num, err)
+	}
+
+	// Restart without a clean shutdown: 2 to 100 may have been issued.
+	n, err = NewNumbering(NewFileNumberStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := n.Watermarks("misc.test"); w != (Watermarks{Low: 1, High: 100, Count: 100}) {
+		t.Errorf("Watermarks after restart %+v", w)
+	}
+	if num, _ := n.AssignNext("misc.test"); num != 101 {
+		t.Errorf("Assigned %d after restart", num)
+	}
+	if err := n.Retire("misc.test", 100); err != nil {
+		t.Fatal(err)
+	}
+	if w := n.Watermarks("misc.test"); w != (Watermarks{Low: 101, High: 101, Count: 1}) {
+		t.Errorf("Watermarks after Retire %+v", w)
+	}
+}
+
+func TestNumberingRestartIssued(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "numbers.json")
+	n, err := NewNumbering(NewFileNumberStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Reserve = 10
+	for want := int64(1); want <= 3; want++ {
+		if num, err := n.AssignNext("misc.test"); err != nil || num != want {
+			t.Fatalf("Assigned %d, %v", num, err)
+		}
+	}
+
+	// Restart without a clean shutdown: 2 and 3 were issued from the
+	// reserved block, though only 1 was saved.
+	n, err = NewNumbering(NewFileNumberStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := n.Watermarks("misc.test"); w.Count < 3 || w != (Watermarks{Low: 1, High: 10, Count: 10}) {
+		t.Errorf("Watermarks after restart %+v", w)
+	}
+	if holes := n.groups["misc.test"].Holes; len(holes) != 0 {
+		t.Errorf("Holes after restart %v", holes)
+	}
+
+	// Releasing numbers from the block, issued or not, keeps Count
+	// right.
+	for _, num := range []int64{3, 7} {
+		if err := n.Release("misc.test", num); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := n.Watermarks("misc.test"); w != (Watermarks{Low: 1, High: 10, Count: 8}) {
+		t.Errorf("Watermarks after Release %+v", w)
+	}
+	if err := n.Retire("misc.test", 5); err != nil {
+		t.Fatal(err)
+	}
+	if w := n.Watermarks("misc.test"); w != (Watermarks{Low: 6, High: 10, Count: 4}) {
+		t.Errorf("Watermarks after Retire %+v", w)
+	}
+}
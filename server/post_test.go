@@ -0,0 +1,255 @@
+package nntpserver
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// memBackend is a minimal in-memory backend for exercising the server.
+type memBackend struct {
+	mu         sync.Mutex
+	groups     []*nntp.Group
+	articles   map[string]*nntp.Article
+	bodies     map[string]string
+	authorized bool
+	cancelled  []string
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{
+		groups: []*nntp.Group{
+			{Name: "misc.test", Description: "Testing", Posting: nntp.PostingPermitted},
+		},
+		articles:   map[string]*nntp.Article{},
+		bodies:     map[string]string{},
+		authorized: true,
+	}
+}
+
+func (b *memBackend) ListGroups(max int) ([]*nntp.Group, error) {
+	return b.groups, nil
+}
+
+func (b *memBackend) GetGroup(name string) (*nntp.Group, error) {
+	for _, g := range b.groups {
+		if g.Name == name {
+			return g, nil
+		}
+	}
+	return nil, ErrNoSuchGroup
+}
+
+func (b *memBackend) GetArticle(group *nntp.Group, id string) (*nntp.Article, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, ok := b.articles[id]
+	if !ok {
+		return nil, ErrInvalidMessageID
+	}
+	return &nntp.Article{Header: a.Header, Body: strings.NewReader(b.bodies[id])}, nil
+}
+
+func (b *memBackend) GetArticles(group *nntp.Group, from, to int64) ([]NumberedArticle, error) {
+	return nil, nil
+}
+
+func (b *memBackend) Authorized() bool {
+	return b.authorized
+}
+
+func (b *memBackend) Authenticate(user, pass string) (Backend, error) {
+	return nil, ErrAuthRejected
+}
+
+func (b *memBackend) AllowPost() bool {
+	return true
+}
+
+func (b *memBackend) Post(article *nntp.Article) error {
+	body, err := ioutil.ReadAll(article.Body)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.articles[article.MessageID()] = article
+	b.bodies[article.MessageID()] = string(body)
+	return nil
+}
+
+func (b *memBackend) CancelArticle(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.articles[id]; !ok {
+		return errors.New("no such article")
+	}
+	delete(b.articles, id)
+	b.cancelled = append(b.cancelled, id)
+	return nil
+}
+
+// dialServer runs s on one end of a pipe and returns the other end,
+// with the greeting already read.
+func dialServer(t *testing.T, s *Server) *textproto.Conn {
+	server, client := net.Pipe()
+	go s.Process(server)
+	c := textproto.NewConn(client)
+	t.Cleanup(func() { c.Close() })
+	if _, _, err := c.ReadCodeLine(200); err != nil {
+		t.Fatalf("Reading greeting: %v", err)
+	}
+	return c
+}
+
+func postArticle(t *testing.T, c *textproto.Conn, article string) (int, string) {
+	if err := c.PrintfLine("POST"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.ReadCodeLine(340); err != nil {
+		t.Fatal(err)
+	}
+	w := c.DotWriter()
+	w.Write([]byte(article))
+	w.Close()
+	code, msg, _ := c.ReadCodeLine(-1)
+	return code, msg
+}
+
+func TestPostSupersedes(t *testing.T) {
+	secret := []byte("secret")
+	b := newMemBackend()
+	s := NewServer(b)
+	s.HonorSupersedes = true
+	c := dialServer(t, s)
+
+	original := "From: a@example.com\r\nNewsgroups: misc.test\r\n" +
+		"Message-Id: <1@example.com>\r\n" +
+		"Cancel-Lock: " + nntp.CancelLock(secret, "<1@example.com>") + "\r\n" +
+		"\r\nFirst\r\n"
+	if code, msg := postArticle(t, c, original); code != 240 {
+		t.Fatalf("Posting original: %d %s", code, msg)
+	}
+
+	// Wrong key: accepted, but the original stays.
+	bad := "From: a@example.com\r\nNewsgroups: misc.test\r\n" +
+		"Message-Id: <2@example.com>\r\nSupersedes: <1@example.com>\r\n" +
+		"Cancel-Key: " + nntp.CancelKey([]byte("wrong"), "<1@example.com>") + "\r\n" +
+		"\r\nSecond\r\n"
+	if code, msg := postArticle(t, c, bad); code != 240 {
+		t.Fatalf("Posting bad supersede: %d %s", code, msg)
+	}
+	if len(b.cancelled) != 0 {
+		t.Fatalf("Supersede with the wrong key cancelled %v", b.cancelled)
+	}
+
+	good := "From: a@example.com\r\nNewsgroups: misc.test\r\n" +
+		"Message-Id: <3@example.com>\r\nSupersedes: <1@example.com>\r\n" +
+		"Cancel-Key: " + nntp.CancelKey(secret, "<1@example.com>") + "\r\n" +
+		"\r\nThird\r\n"
+	if code, msg := postArticle(t, c, good); code != 240 {
+		t.Fatalf("Posting supersede: %d %s", code, msg)
+	}
+	if len(b.cancelled) != 1 || b.cancelled[0] != "<1@example.com>" {
+		t.Errorf("Cancelled %v", b.cancelled)
+	}
+}
+
+func TestPostSupersedesUnauthorized(t *testing.T) {
+	b := newMemBackend()
+	b.authorized = false
+	s := NewServer(b)
+	s.HonorSupersedes = true
+	c := dialServer(t, s)
+	postArticle(t, c, "From: a@example.com\r\nMessage-Id: <1@x>\r\n\r\n1\r\n")
+	postArticle(t, c, "From: a@example.com\r\nMessage-Id: <2@x>\r\n"+
+		"Supersedes: <1@x>\r\n\r\n2\r\n")
+	if len(b.cancelled) != 0 {
+		t.Errorf("Unauthorized supersede cancelled %v", b.cancelled)
+	}
+}
+
+func TestPostFilter(t *testing.T) {
+	b := newMemBackend()
+	s := NewServer(b)
+	s.HonorSupersedes = true
+	s.PostFilter = func(a *nntp.Article, opts *PostOptions) error {
+		if a.Header.Get("Subject") == "spam" {
+			return errors.New("no spam")
+		}
+		opts.HonorSupersedes = false
+		return nil
+	}
+	c := dialServer(t, s)
+	if code, msg := postArticle(t, c, "Subject: spam\r\nMessage-Id: <s@x>\r\n\r\n"+
+		strings.Repeat("buy now\r\n", 100)); code != 441 || msg != "no spam" {
+		t.Errorf("Spam got %d %s", code, msg)
+	}
+	postArticle(t, c, "From: a@example.com\r\nMessage-Id: <1@x>\r\n\r\n1\r\n")
+	if code, _ := postArticle(t, c, "From: a@example.com\r\nMessage-Id: <2@x>\r\n"+
+		"Supersedes: <1@x>\r\n\r\n2\r\n"); code != 240 {
+		t.Errorf("Supersede got %d", code)
+	}
+	if len(b.cancelled) != 0 {
+		t.Errorf("Filter didn't disable supersedes: %v", b.cancelled)
+	}
+}
+
+func TestPostNewsgroups(t *testing.T) {
+	b := newMemBackend()
+	s := NewServer(b)
+	s.NewsgroupsOptions.MaxCrosspost = 2
+	c := dialServer(t, s)
+
+	code, msg := postArticle(t, c, "Newsgroups: misc.test, misc..bad,ctl.x\r\n"+
+		"Message-Id: <1@x>\r\n\r\n"+strings.Repeat("body\r\n", 100))
+	if code != 441 || !strings.Contains(msg, `"misc..bad"`) || !strings.Contains(msg, `"ctl.x"`) {
+		t.Errorf("Invalid groups got %d %s", code, msg)
+	}
+	code, msg = postArticle(t, c, "Newsgroups: a.a,a.b,a.c\r\nMessage-Id: <2@x>\r\n\r\nx\r\n")
+	if code != 441 {
+		t.Errorf("Excessive crosspost got %d %s", code, msg)
+	}
+	code, msg = postArticle(t, c, "Newsgroups: misc.test , alt.test,misc.test\r\n"+
+		"Message-Id: <3@x>\r\n\r\nx\r\n")
+	if code != 240 {
+		t.Fatalf("Valid post got %d %s", code, msg)
+	}
+	if got := b.articles["<3@x>"].Header.Get("Newsgroups"); got != "misc.test,alt.test" {
+		t.Errorf("Stored Newsgroups %q", got)
+	}
+}
+
+func TestPostDistribution(t *testing.T) {
+	b := newMemBackend()
+	s := NewServer(b)
+	s.Distributions = []nntp.Distribution{
+		{Name: "local", Description: "Local only"},
+		{Name: "world", Description: "Everywhere"},
+	}
+	s.StrictDistributions = true
+	c := dialServer(t, s)
+
+	code, msg := postArticle(t, c, "Distribution: local, mars\r\nMessage-Id: <1@x>\r\n\r\nx\r\n")
+	if code != 441 || !strings.Contains(msg, "mars") {
+		t.Errorf("Unknown distribution got %d %s", code, msg)
+	}
+	if code, msg := postArticle(t, c, "Distribution: World\r\nMessage-Id: <2@x>\r\n\r\nx\r\n"); code != 240 {
+		t.Errorf("Known distribution got %d %s", code, msg)
+	}
+
+	c.PrintfLine("LIST DISTRIBUTIONS")
+	if _, _, err := c.ReadCodeLine(215); err != nil {
+		t.Fatal(err)
+	}
+	lines, _ := c.ReadDotLines()
+	if len(lines) != 2 || lines[0] != "local Local only" {
+		t.Errorf("LIST DISTRIBUTIONS got %q", lines)
+	}
+}
@@ -2,12 +2,16 @@
 package nntpserver
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"math"
 	"net"
+	"net/mail"
 	"net/textproto"
+	"os"
 	"strconv"
 	"strings"
 
@@ -89,6 +93,34 @@ type Backend interface {
 	Post(article *nntp.Article) error
 }
 
+// A CancelBackend is a Backend that can remove articles.  Cancels and
+// supersedes are only honored when the backend implements it.
+type CancelBackend interface {
+	Backend
+	CancelArticle(id string) error
+}
+
+// A NumberingBackend assigns an article its numbers in the groups it's
+// posted to before it's stored, so the server can stamp its Xref header.
+type NumberingBackend interface {
+	Backend
+	AssignNumbers(article *nntp.Article) ([]nntp.GroupNumber, error)
+}
+
+// PostOptions control how the server treats a posted article.
+type PostOptions struct {
+	// HonorSupersedes cancels the article named by the Supersedes
+	// header once the replacement has been accepted.
+	HonorSupersedes bool
+}
+
+// A PostFilter may inspect or modify an article posted via POST or IHAVE
+// before it's handed to the backend, and adjust how it's treated.
+//
+// Returning an error rejects the article.  An *NNTPError is sent to the
+// client as is, anything else as a 441 with the error's text.
+type PostFilter func(article *nntp.Article, opts *PostOptions) error
+
 type session struct {
 	server  *Server
 	backend Backend
@@ -101,6 +133,27 @@ type Server struct {
 	Handlers map[string]Handler
 	// The backend (your code) that provides data
 	Backend Backend
+	// PostFilter, if set, is applied to every posted article.
+	PostFilter PostFilter
+	// HonorSupersedes is the default for PostOptions.HonorSupersedes.
+	HonorSupersedes bool
+	// NewsgroupsOptions control validation of the Newsgroups header of
+	// articles sent with POST.
+	NewsgroupsOptions nntp.NewsgroupsOptions
+	// Distributions are served by LIST DISTRIBUTIONS.
+	Distributions []nntp.Distribution
+	// StrictDistributions rejects articles sent with POST whose
+	// Distribution header names one not in Distributions.
+	StrictDistributions bool
+	// Name is this server's name in Xref headers.  It defaults to the
+	// host name.
+	Name string
+	// ControlPolicy decides what to do with control messages.  If nil
+	// they're stored and logged, but not acted upon.
+	ControlPolicy ControlPolicy
+	// XGTitle enables the legacy XGTITLE command for old clients.  It's
+	// never listed in CAPABILITIES.
+	XGTitle bool
 	// The currently selected group.
 	group *nntp.Group
 }
@@ -126,9 +179,20 @@ func NewServer(backend Backend) *Server {
 	rv.Handlers["newgroups"] = handleNewGroups
 	rv.Handlers["over"] = handleOver
 	rv.Handlers["xover"] = handleOver
+	rv.Handlers["xgtitle"] = handleXGTitle
 	return &rv
 }
 
+func (s *Server) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "localhost"
+}
+
 func (e *NNTPError) Error() string {
 	return fmt.Sprintf("%d %s", e.Code, e.Msg)
 }
@@ -217,13 +281,21 @@ func parseRange(spec string) (low, high int64) {
    References header content
    :bytes metadata item
    :lines metadata item
+   Xref:full header
 */
 
 func handleOver(args []string, s *session, c *textproto.Conn) error {
 	if s.group == nil {
 		return ErrNoGroupSelected
 	}
-	from, to := parseRange(args[0])
+	from, to := parseRange("")
+	if len(args) > 0 {
+		r, err := nntp.ParseArticleRange(args[0])
+		if err != nil {
+			return ErrSyntax
+		}
+		from, to = r.Low, r.High
+	}
 	articles, err := s.backend.GetArticles(s.group, from, to)
 	if err != nil {
 		return err
@@ -232,13 +304,17 @@ func handleOver(args []string, s *session, c *textproto.Conn) error {
 	dw := c.DotWriter()
 	defer dw.Close()
 	for _, a := range articles {
-		fmt.Fprintf(dw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n", a.Num,
+		xref := a.Article.Header.Get("Xref")
+		if xref != "" {
+			xref = "Xref: " + xref
+		}
+		fmt.Fprintf(dw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", a.Num,
 			a.Article.Header.Get("Subject"),
 			a.Article.Header.Get("From"),
 			a.Article.Header.Get("Date"),
 			a.Article.Header.Get("Message-Id"),
 			a.Article.Header.Get("References"),
-			a.Article.Bytes, a.Article.Lines)
+			a.Article.Bytes, a.Article.Lines, xref)
 	}
 	return nil
 }
@@ -256,18 +332,35 @@ Date:
 Message-ID:
 References:
 :bytes
-:lines`)
+:lines
+Xref:full`)
 	return err
 }
 
+func handleListDistributions(s *session, c *textproto.Conn) error {
+	if len(s.server.Distributions) == 0 {
+		return &NNTPError{503, "No distributions"}
+	}
+	c.PrintfLine("215 Distributions follow")
+	dw := c.DotWriter()
+	defer dw.Close()
+	for _, d := range s.server.Distributions {
+		fmt.Fprintf(dw, "%s %s\r\n", d.Name, d.Description)
+	}
+	return nil
+}
+
 func handleList(args []string, s *session, c *textproto.Conn) error {
 	ltype := "active"
 	if len(args) > 0 {
 		ltype = strings.ToLower(args[0])
 	}
 
-	if ltype == "overview.fmt" {
+	switch ltype {
+	case "overview.fmt":
 		return handleListOverviewFmt(c)
+	case "distributions":
+		return handleListDistributions(s, c)
 	}
 
 	groups, err := s.backend.ListGroups(-1)
@@ -280,8 +373,8 @@ func handleList(args []string, s *session, c *textproto.Conn) error {
 	for _, g := range groups {
 		switch ltype {
 		case "active":
-			fmt.Fprintf(dw, "%s %d %d %v\r\n",
-				g.Name, g.High, g.Low, g.Posting)
+			fmt.Fprintf(dw, "%s %d %d %s\r\n",
+				g.Name, g.High, g.Low, g.PostingFlag())
 		case "newsgroups":
 			fmt.Fprintf(dw, "%s %s\r\n", g.Name, g.Description)
 		}
@@ -291,11 +384,58 @@ func handleList(args []string, s *session, c *textproto.Conn) error {
 }
 
 func handleNewGroups(args []string, s *session, c *textproto.Conn) error {
+	if len(args) < 2 || len(args) > 3 || (len(args) == 3 && !strings.EqualFold(args[2], "GMT")) {
+		return ErrSyntax
+	}
+	if _, err := nntp.ParseNNTPDate(args[0], args[1], len(args) == 3); err != nil {
+		return ErrSyntax
+	}
 	c.PrintfLine("231 list of newsgroups follows")
 	c.PrintfLine(".")
 	return nil
 }
 
+// handleXGTitle lists descriptions of the groups matching a wildmat, or
+// of the current group, like LIST NEWSGROUPS in the old XGTITLE form.
+func handleXGTitle(args []string, s *session, c *textproto.Conn) error {
+	if !s.server.XGTitle {
+		return ErrUnknownCommand
+	}
+	var wildmat string
+	switch {
+	case len(args) > 0:
+		wildmat = args[0]
+	case s.group != nil:
+		wildmat = s.group.Name
+	default:
+		return ErrNoGroupSelected
+	}
+	w, err := nntp.Compile(wildmat)
+	if err != nil {
+		return ErrSyntax
+	}
+	groups, err := s.backend.ListGroups(-1)
+	if err != nil {
+		return err
+	}
+	var matched []*nntp.Group
+	for _, g := range groups {
+		if w.Match(g.Name) {
+			matched = append(matched, g)
+		}
+	}
+	if len(matched) == 0 {
+		return &NNTPError{481, "No matching groups"}
+	}
+	c.PrintfLine("282 list of groups and descriptions follows")
+	dw := c.DotWriter()
+	defer dw.Close()
+	for _, g := range matched {
+		fmt.Fprintf(dw, "%s\t%s\r\n", g.Name, g.Description)
+	}
+	return nil
+}
+
 func handleDefault(args []string, s *session, c *textproto.Conn) error {
 	return ErrUnknownCommand
 }
@@ -480,7 +620,11 @@ func handlePost(args []string, s *session, c *textproto.Conn) error {
 		return ErrPostingFailed
 	}
 	article.Body = c.DotReader()
-	err = s.backend.Post(&article)
+	if err := s.checkPost(&article); err != nil {
+		io.Copy(ioutil.Discard, article.Body)
+		return err
+	}
+	err = s.post(&article)
 	if err != nil {
 		return err
 	}
@@ -488,6 +632,152 @@ func handlePost(args []string, s *session, c *textproto.Conn) error {
 	return nil
 }
 
+// checkPost validates the headers of an article sent with POST.
+func (s *session) checkPost(article *nntp.Article) error {
+	if err := s.checkNewsgroups(article); err != nil {
+		return err
+	}
+	return s.checkDistribution(article)
+}
+
+// checkDistribution rejects unknown distributions in strict mode.
+func (s *session) checkDistribution(article *nntp.Article) error {
+	if !s.server.StrictDistributions {
+		return nil
+	}
+	var unknown []string
+	for _, d := range nntp.ParseDistributions(article.Header.Get("Distribution")) {
+		found := false
+		for _, known := range s.server.Distributions {
+			if strings.EqualFold(d, known.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, d)
+		}
+	}
+	if len(unknown) > 0 {
+		return &NNTPError{441, "unknown distribution " + strings.Join(unknown, ", ")}
+	}
+	return nil
+}
+
+// checkNewsgroups validates and normalizes the Newsgroups header of a
+// posted article.
+func (s *session) checkNewsgroups(article *nntp.Article) error {
+	value := article.Header.Get("Newsgroups")
+	if value == "" {
+		return nil
+	}
+	groups, warnings, err := nntp.ValidateNewsgroups(value, &s.server.NewsgroupsOptions)
+	if err != nil {
+		return &NNTPError{441, err.Error()}
+	}
+	for _, w := range warnings {
+		log.Printf("Newsgroups of %s: %v", article.MessageID(), w)
+	}
+	article.Header.Set("Newsgroups", strings.Join(groups, ","))
+	return nil
+}
+
+// post hands an article to the backend after applying the post filter,
+// and then acts on its Control or Supersedes header.
+func (s *session) post(article *nntp.Article) error {
+	// Whatever happens, the rest of the article must be read off the
+	// connection before the next command.
+	defer io.Copy(ioutil.Discard, article.Body)
+
+	opts := PostOptions{HonorSupersedes: s.server.HonorSupersedes}
+	if s.server.PostFilter != nil {
+		if err := s.server.PostFilter(article, &opts); err != nil {
+			if _, ok := err.(*NNTPError); ok {
+				return err
+			}
+			return &NNTPError{441, err.Error()}
+		}
+	}
+	var ctl *nntp.Control
+	action := ControlLog
+	if article.Header.Get("Control") != "" {
+		var err error
+		ctl, err = nntp.ParseControl(article)
+		if err != nil {
+			return &NNTPError{441, err.Error()}
+		}
+		action = s.controlAction(ctl, article)
+		if action == ControlDrop {
+			log.Printf("Dropped control message %s: %s %v",
+				article.MessageID(), ctl.Type, ctl.Args)
+			return nil
+		}
+	}
+	if nb, ok := s.backend.(NumberingBackend); ok {
+		// An Xref from elsewhere doesn't mean anything here.
+		article.Header.Del("Xref")
+		locations, err := nb.AssignNumbers(article)
+		if err != nil {
+			return err
+		}
+		if len(locations) > 0 {
+			article.Header.Set("Xref", nntp.FormatXref(s.server.name(), locations))
+		}
+	}
+	if err := s.backend.Post(article); err != nil {
+		return err
+	}
+	if ctl != nil {
+		s.control(ctl, article, action)
+	}
+	target := strings.TrimSpace(article.Header.Get("Supersedes"))
+	if opts.HonorSupersedes && target != "" {
+		if err := s.cancel(article, target); err != nil {
+			log.Printf("Not honoring supersedes of %s by %s: %v",
+				target, article.MessageID(), err)
+		}
+	}
+	return nil
+}
+
+// cancel removes the article target on behalf of article, a cancel or
+// supersede.
+//
+// The session must be authorized.  If the target carries a Cancel-Lock
+// the article must have a matching Cancel-Key, otherwise the senders
+// must match.
+func (s *session) cancel(article *nntp.Article, target string) error {
+	cb, ok := s.backend.(CancelBackend)
+	if !ok {
+		return errors.New("backend can't cancel articles")
+	}
+	if !s.backend.Authorized() {
+		return errors.New("session not authorized")
+	}
+	original, err := s.backend.GetArticle(nil, target)
+	if err != nil {
+		return err
+	}
+	if lock := original.Header.Get("Cancel-Lock"); lock != "" {
+		if !nntp.VerifyCancelKey(lock, article.Header.Get("Cancel-Key")) {
+			return errors.New("Cancel-Key doesn't match Cancel-Lock")
+		}
+	} else if !sameSender(original, article) {
+		return errors.New("sender doesn't match")
+	}
+	return cb.CancelArticle(target)
+}
+
+func sameSender(a, b *nntp.Article) bool {
+	fa, fb := a.Header.Get("From"), b.Header.Get("From")
+	aa, erra := mail.ParseAddress(fa)
+	ab, errb := mail.ParseAddress(fb)
+	if erra != nil || errb != nil {
+		return fa != "" && fa == fb
+	}
+	return strings.EqualFold(aa.Address, ab.Address)
+}
+
 func handleIHave(args []string, s *session, c *textproto.Conn) error {
 	if !s.backend.AllowPost() {
 		return ErrNotWanted
@@ -506,7 +796,7 @@ func handleIHave(args []string, s *session, c *textproto.Conn) error {
 		return ErrPostingFailed
 	}
 	article.Body = c.DotReader()
-	err = s.backend.Post(article)
+	err = s.post(article)
 	if err != nil {
 		return err
 	}
@@ -527,7 +817,11 @@ func handleCap(args []string, s *session, c *textproto.Conn) error {
 	}
 	fmt.Fprintf(dw, "OVER\n")
 	fmt.Fprintf(dw, "XOVER\n")
-	fmt.Fprintf(dw, "LIST ACTIVE NEWSGROUPS OVERVIEW.FMT\n")
+	if len(s.server.Distributions) > 0 {
+		fmt.Fprintf(dw, "LIST ACTIVE NEWSGROUPS OVERVIEW.FMT DISTRIBUTIONS\n")
+	} else {
+		fmt.Fprintf(dw, "LIST ACTIVE NEWSGROUPS OVERVIEW.FMT\n")
+	}
 	return nil
 }
 
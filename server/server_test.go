@@ -30,3 +30,28 @@ func TestRangeEmpty(t *testing.T) {
 		}
 	}
 }
+
+func TestNewGroupsArguments(t *testing.T) {
+	c := dialServer(t, NewServer(newMemBackend()))
+	tests := []struct {
+		args string
+		code int
+	}{
+		{"20210305 233001 GMT", 231},
+		{"210305 233001", 231},
+		{"20210305 233001 gmt", 231},
+		{"20210305", 501},
+		{"20211305 233001 GMT", 501},
+		{"20210305 233001 UTC", 501},
+	}
+	for _, test := range tests {
+		c.PrintfLine("NEWGROUPS %s", test.args)
+		code, _, _ := c.ReadCodeLine(-1)
+		if code != test.code {
+			t.Errorf("NEWGROUPS %s got %d, want %d", test.args, code, test.code)
+		}
+		if code == 231 {
+			c.ReadDotLines()
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package nntpserver
+
+import (
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+func TestXGTitle(t *testing.T) {
+	b := newMemBackend()
+	b.groups = append(b.groups, &nntp.Group{Name: "misc.misc", Description: "Anything"},
+		&nntp.Group{Name: "alt.test", Description: "Alternative testing"})
+	s := NewServer(b)
+	c := dialServer(t, s)
+
+	c.PrintfLine("XGTITLE misc.*")
+	if code, _, _ := c.ReadCodeLine(-1); code != 500 {
+		t.Errorf("Disabled XGTITLE got %d", code)
+	}
+
+	s.XGTitle = true
+	c.PrintfLine("XGTITLE misc.*")
+	if _, _, err := c.ReadCodeLine(282); err != nil {
+		t.Fatal(err)
+	}
+	lines, _ := c.ReadDotLines()
+	if len(lines) != 2 || lines[0] != "misc.test\tTesting" || lines[1] != "misc.misc\tAnything" {
+		t.Errorf("XGTITLE misc.* got %q", lines)
+	}
+
+	c.PrintfLine("XGTITLE")
+	if code, _, _ := c.ReadCodeLine(-1); code != 412 {
+		t.Errorf("XGTITLE without a group got %d", code)
+	}
+	c.PrintfLine("GROUP alt.test")
+	c.ReadCodeLine(211)
+	c.PrintfLine("XGTITLE")
+	c.ReadCodeLine(282)
+	if lines, _ := c.ReadDotLines(); len(lines) != 1 || lines[0] != "alt.test\tAlternative testing" {
+		t.Errorf("XGTITLE of the current group got %q", lines)
+	}
+
+	c.PrintfLine("XGTITLE comp.*")
+	if code, _, _ := c.ReadCodeLine(-1); code != 481 {
+		t.Errorf("XGTITLE with no matches got %d", code)
+	}
+
+	c.PrintfLine("CAPABILITIES")
+	c.ReadCodeLine(101)
+	caps, _ := c.ReadDotLines()
+	for _, l := range caps {
+		if l == "XGTITLE" {
+			t.Errorf("XGTITLE listed in CAPABILITIES")
+		}
+	}
+}
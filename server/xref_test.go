@@ -0,0 +1,78 @@
+package nntpserver
+
+import (
+	"testing"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// numberingBackend numbers articles sequentially in every group they're
+// posted to.
+type numberingBackend struct {
+	*memBackend
+	next  map[string]int64
+	order []string
+}
+
+func (b *numberingBackend) AssignNumbers(article *nntp.Article) ([]nntp.GroupNumber, error) {
+	var rv []nntp.GroupNumber
+	b.order = append(b.order, article.MessageID())
+	for _, g := range []string{"misc.test", "alt.test"} {
+		b.next[g]++
+		rv = append(rv, nntp.GroupNumber{Group: g, Number: b.next[g]})
+	}
+	return rv, nil
+}
+
+func (b *numberingBackend) GetArticles(group *nntp.Group, from, to int64) ([]NumberedArticle, error) {
+	var rv []NumberedArticle
+	for i, id := range b.order {
+		rv = append(rv, NumberedArticle{int64(i + 1), b.articles[id]})
+	}
+	return rv, nil
+}
+
+func TestPostXref(t *testing.T) {
+	b := &numberingBackend{memBackend: newMemBackend(), next: map[string]int64{"alt.test": 41}}
+	s := NewServer(b)
+	s.Name = "news.example.com"
+	c := dialServer(t, s)
+
+	code, msg := postArticle(t, c, "From: a@example.com\r\nSubject: hi\r\n"+
+		"Message-Id: <1@x>\r\nXref: elsewhere misc.test:999\r\n\r\nHi\r\n")
+	if code != 240 {
+		t.Fatalf("Posting: %d %s", code, msg)
+	}
+	exp := "news.example.com misc.test:1 alt.test:42"
+	if got := b.articles["<1@x>"].Header.Get("Xref"); got != exp {
+		t.Errorf("Stored Xref %q, wanted %q", got, exp)
+	}
+
+	c.PrintfLine("GROUP misc.test")
+	c.ReadCodeLine(211)
+	c.PrintfLine("OVER 1-")
+	if _, _, err := c.ReadCodeLine(224); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := c.ReadDotLines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\thi\ta@example.com\t\t<1@x>\t\t0\t0\tXref: " + exp
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("OVER got %q, wanted %q", lines, want)
+	}
+}
+
+func TestOverInvalidRange(t *testing.T) {
+	b := &numberingBackend{memBackend: newMemBackend(), next: map[string]int64{}}
+	c := dialServer(t, NewServer(b))
+	c.PrintfLine("GROUP misc.test")
+	c.ReadCodeLine(211)
+	for _, bad := range []string{"10-5", "-5", "x"} {
+		c.PrintfLine("OVER %s", bad)
+		if code, _, _ := c.ReadCodeLine(-1); code != 501 {
+			t.Errorf("OVER %s got %d", bad, code)
+		}
+	}
+}
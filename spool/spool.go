@@ -0,0 +1,421 @@
+// Package spool provides a disk-backed FIFO queue of articles waiting to
+// be fed to a peer.
+//
+// Entries are appended to segment files and fsync'd before Push returns.
+// The position of the first unacknowledged entry is kept in a separate
+// file, and segments are deleted once every entry in them has been
+// acknowledged.
+package spool
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize is the size at which a new segment file is started.
+const DefaultSegmentSize = 16 << 20
+
+// ErrEmpty is returned by Peek when there are no entries.
+var ErrEmpty = errors.New("spool is empty")
+
+// An Entry is an article waiting to be fed.
+type Entry struct {
+	MessageID string `json:"id"`
+	// Article holds the raw article, or Locator names where a backend
+	// can find it.
+	Article []byte `json:"article,omitempty"`
+	Locator string `json:"locator,omitempty"`
+	// Queued is set by Push.
+	Queued time.Time `json:"queued"`
+}
+
+// Options configure a Queue.
+type Options struct {
+	// SegmentSize defaults to DefaultSegmentSize.
+	SegmentSize int64
+	// MaxEntries, MaxBytes and MaxAge limit the backlog, if positive.
+	// When a limit is exceeded the oldest entries are dropped.
+	MaxEntries int
+	MaxBytes   int64
+	MaxAge     time.Duration
+	// OnDrop, if set, is called for each entry dropped by a limit.
+	OnDrop func(Entry)
+}
+
+// pos is the location of a record.
+type pos struct {
+	Segment int   `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+type record struct {
+	pos
+	size   int64
+	queued time.Time
+}
+
+// A Queue is a persistent FIFO of entries.  It's safe for concurrent
+// use.
+type Queue struct {
+	dir     string
+	opts    Options
+	mu      sync.Mutex
+	records []record
+	bytes   int64
+	tail    *os.File
+	tailPos pos
+	dropped int64
+}
+
+// Records are a 4 byte length and CRC-32 followed by the JSON encoded
+// entry.
+const (
+	headerSize = 8
+	maxRecord  = 1 << 30
+)
+
+// Open opens or creates the queue in dir, recovering its entries.  A
+// torn record at the end of the last segment, left by a crash, is cut
+// off.
+func Open(dir string, opts *Options) (*Queue, error) {
+	q := &Queue{dir: dir}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.SegmentSize <= 0 {
+		q.opts.SegmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	head, err := q.readHead()
+	if err != nil {
+		return nil, err
+	}
+	segments, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	for i, seg := range segments {
+		if seg < head.Segment {
+			os.Remove(q.segmentPath(seg))
+			continue
+		}
+		start := int64(0)
+		if seg == head.Segment {
+			start = head.Offset
+		}
+		end, err := q.scan(seg, start)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(segments)-1 {
+			if err := os.Truncate(q.segmentPath(seg), end); err != nil {
+				return nil, err
+			}
+			q.tailPos = pos{seg, end}
+		}
+	}
+	if len(segments) == 0 {
+		// Start a fresh segment, the head may point into the last one.
+		q.tailPos = pos{head.Segment + 1, 0}
+	}
+	if err := q.openTail(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) segmentPath(seg int) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%08d.seg", seg))
+}
+
+func (q *Queue) headPath() string {
+	return filepath.Join(q.dir, "head")
+}
+
+func (q *Queue) readHead() (pos, error) {
+	var rv pos
+	data, err := ioutil.ReadFile(q.headPath())
+	if os.IsNotExist(err) {
+		return rv, nil
+	}
+	if err != nil {
+		return rv, err
+	}
+	return rv, json.Unmarshal(data, &rv)
+}
+
+func (q *Queue) segments() ([]int, error) {
+	names, err := filepath.Glob(filepath.Join(q.dir, "*.seg"))
+	if err != nil {
+		return nil, err
+	}
+	var rv []int
+	for _, name := range names {
+		var seg int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(filepath.Base(name), ".seg"), "%d", &seg); err == nil {
+			rv = append(rv, seg)
+		}
+	}
+	sort.Ints(rv)
+	return rv, nil
+}
+
+// scan indexes the valid records of a segment from start and returns
+// the offset after the last one.
+func (q *Queue) scan(seg int, start int64) (int64, error) {
+	f, err := os.Open(q.segmentPath(seg))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	off := start
+	for {
+		e, size, err := readRecord(f, off)
+		if err != nil {
+			return off, nil
+		}
+		q.records = append(q.records, record{pos{seg, off}, size, e.Queued})
+		q.bytes += size
+		off += size
+	}
+}
+
+func readRecord(r io.ReaderAt, off int64) (*Entry, int64, error) {
+	var hdr [headerSize]byte
+	if _, err := r.ReadAt(hdr[:], off); err != nil {
+		return nil, 0, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n > maxRecord {
+		return nil, 0, errors.New("record too large")
+	}
+	data := make([]byte, n)
+	if _, err := r.ReadAt(data, off+headerSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(hdr[4:]) {
+		return nil, 0, errors.New("record checksum mismatch")
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, 0, err
+	}
+	return &e, headerSize + int64(n), nil
+}
+
+func (q *Queue) openTail() error {
+	f, err := os.OpenFile(q.segmentPath(q.tailPos.Segment), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	q.tail = f
+	return nil
+}
+
+// Push appends an entry, returning once it's on stable storage.
+func (q *Queue) Push(e Entry) error {
+	if e.Queued.IsZero() {
+		e.Queued = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(data))
+	copy(buf[headerSize:], data)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.tailPos.Offset > 0 && q.tailPos.Offset+int64(len(buf)) > q.opts.SegmentSize {
+		if err := q.tail.Close(); err != nil {
+			return err
+		}
+		q.tailPos = pos{q.tailPos.Segment + 1, 0}
+		if err := q.openTail(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.tail.WriteAt(buf, q.tailPos.Offset); err != nil {
+		return err
+	}
+	if err := q.tail.Sync(); err != nil {
+		return err
+	}
+	q.records = append(q.records, record{q.tailPos, int64(len(buf)), e.Queued})
+	q.bytes += int64(len(buf))
+	q.tailPos.Offset += int64(len(buf))
+	return q.enforceLimits()
+}
+
+func (q *Queue) enforceLimits() error {
+	drop := 0
+	bytes := q.bytes
+	for drop < len(q.records) {
+		r := q.records[drop]
+		over := (q.opts.MaxEntries > 0 && len(q.records)-drop > q.opts.MaxEntries) ||
+			(q.opts.MaxBytes > 0 && bytes > q.opts.MaxBytes) ||
+			(q.opts.MaxAge > 0 && time.Since(r.queued) > q.opts.MaxAge)
+		if !over {
+			break
+		}
+		bytes -= r.size
+		drop++
+	}
+	if drop == 0 {
+		return nil
+	}
+	if q.opts.OnDrop != nil {
+		for _, r := range q.records[:drop] {
+			if e, err := q.read(r); err == nil {
+				q.opts.OnDrop(*e)
+			}
+		}
+	}
+	q.dropped += int64(drop)
+	return q.ack(drop)
+}
+
+func (q *Queue) read(r record) (*Entry, error) {
+	f, err := os.Open(q.segmentPath(r.Segment))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e, _, err := readRecord(f, r.Offset)
+	return e, err
+}
+
+// Peek returns up to n entries from the front of the queue without
+// removing them.
+func (q *Queue) Peek(n int) ([]Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.records) == 0 {
+		return nil, ErrEmpty
+	}
+	if n > len(q.records) {
+		n = len(q.records)
+	}
+	rv := make([]Entry, 0, n)
+	for _, r := range q.records[:n] {
+		e, err := q.read(r)
+		if err != nil {
+			return rv, err
+		}
+		rv = append(rv, *e)
+	}
+	return rv, nil
+}
+
+// Ack removes n entries from the front of the queue.
+func (q *Queue) Ack(n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n > len(q.records) {
+		n = len(q.records)
+	}
+	return q.ack(n)
+}
+
+func (q *Queue) ack(n int) error {
+	if n == 0 {
+		return nil
+	}
+	first := q.records[0].Segment
+	for _, r := range q.records[:n] {
+		q.bytes -= r.size
+	}
+	q.records = q.records[n:]
+	head := q.tailPos
+	if len(q.records) > 0 {
+		head = q.records[0].pos
+	}
+	if err := q.writeHead(head); err != nil {
+		return err
+	}
+	for seg := first; seg < head.Segment; seg++ {
+		os.Remove(q.segmentPath(seg))
+	}
+	return nil
+}
+
+func (q *Queue) writeHead(head pos) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	tmp := q.headPath() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.headPath())
+}
+
+// Drain feeds entries to fn in order, acknowledging each one fn accepts,
+// until the queue is empty or fn returns an error.
+func (q *Queue) Drain(fn func(Entry) error) error {
+	for {
+		entries, err := q.Peek(1)
+		if err == ErrEmpty {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entries[0]); err != nil {
+			return err
+		}
+		if err := q.Ack(1); err != nil {
+			return err
+		}
+	}
+}
+
+// Len returns the number of entries queued.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.records)
+}
+
+// Dropped returns the number of entries dropped by the backlog limits
+// since the queue was opened.
+func (q *Queue) Dropped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Close the queue.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tail.Close()
+}
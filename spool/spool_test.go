@@ -0,0 +1,166 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func ids(entries []Entry) string {
+	var rv []string
+	for _, e := range entries {
+		rv = append(rv, e.MessageID)
+	}
+	return fmt.Sprint(rv)
+}
+
+func TestQueueOrderAndRecovery(t *testing.T) {
+	dir := tempDir(t)
+	q, err := Open(dir, &Options{SegmentSize: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		e := Entry{MessageID: fmt.Sprintf("<%d@x>", i), Locator: "spool/" + fmt.Sprint(i)}
+		if i == 1 {
+			e.Article = []byte("Subject: hi\r\n\r\nbody\r\n")
+		}
+		if err := q.Push(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := q.Peek(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(entries) != "[<1@x> <2@x> <3@x>]" || string(entries[0].Article) != "Subject: hi\r\n\r\nbody\r\n" {
+		t.Errorf("Peeked %v", entries)
+	}
+	if err := q.Ack(4); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segs) < 2 {
+		t.Errorf("Expected several segments, got %v", segs)
+	}
+
+	q, err = Open(dir, &Options{SegmentSize: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if q.Len() != 6 {
+		t.Errorf("Recovered %d entries", q.Len())
+	}
+	var drained []Entry
+	stop := errors.New("peer gone")
+	err = q.Drain(func(e Entry) error {
+		if len(drained) == 3 {
+			return stop
+		}
+		drained = append(drained, e)
+		return nil
+	})
+	if err != stop || ids(drained) != "[<5@x> <6@x> <7@x>]" {
+		t.Errorf("Drained %v, %v", ids(drained), err)
+	}
+	drained = nil
+	q.Drain(func(e Entry) error {
+		drained = append(drained, e)
+		return nil
+	})
+	if ids(drained) != "[<8@x> <9@x> <10@x>]" || q.Len() != 0 {
+		t.Errorf("Drained %v, %d left", ids(drained), q.Len())
+	}
+	if _, err := q.Peek(1); err != ErrEmpty {
+		t.Errorf("Peek on empty queue: %v", err)
+	}
+}
+
+func TestQueueTornWrite(t *testing.T) {
+	dir := tempDir(t)
+	q, _ := Open(dir, nil)
+	q.Push(Entry{MessageID: "<1@x>"})
+	q.Push(Entry{MessageID: "<2@x>"})
+	q.Close()
+
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	fi, _ := os.Stat(segs[0])
+	// Cut the last record short.
+	os.Truncate(segs[0], fi.Size()-5)
+
+	q, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("Recovered %d entries", q.Len())
+	}
+	q.Push(Entry{MessageID: "<3@x>"})
+	q.Close()
+
+	q, _ = Open(dir, nil)
+	defer q.Close()
+	entries, _ := q.Peek(10)
+	if ids(entries) != "[<1@x> <3@x>]" {
+		t.Errorf("After torn write got %v", ids(entries))
+	}
+}
+
+func TestQueueEmptyAfterAck(t *testing.T) {
+	dir := tempDir(t)
+	q, _ := Open(dir, nil)
+	q.Push(Entry{MessageID: "<1@x>"})
+	q.Ack(1)
+	q.Close()
+	// Remove the segment, as if a crash happened after it was deleted.
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	for _, s := range segs {
+		os.Remove(s)
+	}
+	q, _ = Open(dir, nil)
+	q.Push(Entry{MessageID: "<2@x>"})
+	q.Close()
+	q, _ = Open(dir, nil)
+	defer q.Close()
+	entries, _ := q.Peek(10)
+	if ids(entries) != "[<2@x>]" {
+		t.Errorf("Got %v", ids(entries))
+	}
+}
+
+func TestQueueLimits(t *testing.T) {
+	var dropped []string
+	q, _ := Open(tempDir(t), &Options{
+		MaxEntries: 3,
+		MaxAge:     time.Hour,
+		OnDrop:     func(e Entry) { dropped = append(dropped, e.MessageID) },
+	})
+	defer q.Close()
+	q.Push(Entry{MessageID: "<old@x>", Queued: time.Now().Add(-2 * time.Hour)})
+	for i := 1; i <= 4; i++ {
+		q.Push(Entry{MessageID: fmt.Sprintf("<%d@x>", i)})
+	}
+	entries, _ := q.Peek(10)
+	if ids(entries) != "[<2@x> <3@x> <4@x>]" {
+		t.Errorf("Kept %v", ids(entries))
+	}
+	if fmt.Sprint(dropped) != "[<old@x> <1@x>]" || q.Dropped() != 2 {
+		t.Errorf("Dropped %v (%d)", dropped, q.Dropped())
+	}
+}
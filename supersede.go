@@ -0,0 +1,60 @@
+package nntp
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Headers describing a particular copy of an article, which must not be
+// carried over into its replacement.
+var perCopyHeaders = []string{
+	"Path", "Xref", "Lines", "Bytes", "Control", "Supersedes",
+	"Cancel-Lock", "Cancel-Key", "Injection-Date", "Injection-Info",
+	"Nntp-Posting-Host", "Nntp-Posting-Date", "X-Trace", "X-Complaints-To",
+}
+
+// BuildSupersede builds an article replacing original, with a new body
+// and, if subject isn't empty, a new subject.
+//
+// Everything else, including References, is carried over, and the result
+// gets a fresh Message-ID and Date.  With WithCancelSecret the replacement
+// carries a Cancel-Key for the original (if it was locked) and a
+// Cancel-Lock of its own.
+func BuildSupersede(original *Article, subject string, body io.Reader, opts ...CancelOption) (*Article, error) {
+	var cfg cancelConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	msgid := strings.TrimSpace(original.MessageID())
+	if msgid == "" {
+		return nil, errors.New("can't supersede an article without a Message-ID")
+	}
+
+	h := make(textproto.MIMEHeader, len(original.Header))
+	for k, v := range original.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	for _, k := range perCopyHeaders {
+		h.Del(k)
+	}
+	if cfg.from != "" {
+		h.Set("From", cfg.from)
+	}
+	if subject != "" {
+		h.Set("Subject", subject)
+	}
+	newID := GenerateMessageID(messageIDDomain(msgid))
+	h.Set("Message-Id", newID)
+	h.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	h.Set("Supersedes", msgid)
+	if cfg.secret != nil {
+		if original.Header.Get("Cancel-Lock") != "" {
+			h.Set("Cancel-Key", CancelKey(cfg.secret, msgid))
+		}
+		h.Set("Cancel-Lock", CancelLock(cfg.secret, newID))
+	}
+	return &Article{Header: h, Body: body}, nil
+}
@@ -0,0 +1,282 @@
+// Package nntpthread builds discussion threads from overview data.
+//
+// The algorithm is the one described by Jamie Zawinski at
+// https://www.jwz.org/doc/threading.html
+package nntpthread
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+)
+
+// A Node is a single position in a thread tree.
+type Node struct {
+	// Overview is nil for placeholder nodes standing in for articles
+	// that were referenced but not present in the input.
+	Overview *nntp.Overview
+	// MessageID of the article at this position.  Empty for
+	// placeholders created while grouping by subject.
+	MessageID string
+	Parent    *Node
+	Children  []*Node
+
+	date  time.Time
+	index int
+}
+
+// Options control how articles are threaded.
+type Options struct {
+	// GroupBySubject gathers root articles with the same subject (after
+	// stripping "Re:" and friends) into a single thread.
+	GroupBySubject bool
+}
+
+// Thread arranges overviews into a forest of threads.
+//
+// Roots and the children of every node are ordered by date, ties being
+// broken by the position of the article in the input.  Missing parents,
+// duplicate message-ids and cyclic References are tolerated.
+func Thread(overviews []nntp.Overview, opts *Options) []*Node {
+	if opts == nil {
+		opts = &Options{}
+	}
+	ids := make(map[string]*Node, len(overviews))
+	all := make([]*Node, 0, len(overviews))
+	get := func(id string) *Node {
+		n := ids[id]
+		if n == nil {
+			n = &Node{MessageID: id}
+			ids[id] = n
+			all = append(all, n)
+		}
+		return n
+	}
+
+	for i := range overviews {
+		ov := &overviews[i]
+		n := ids[ov.MessageID]
+		if ov.MessageID == "" || (n != nil && n.Overview != nil) {
+			// Without a (unique) id nothing can refer to this
+			// article, but it may still have parents.
+			n = &Node{MessageID: ov.MessageID}
+			all = append(all, n)
+		} else {
+			n = get(ov.MessageID)
+		}
+		n.Overview = ov
+		n.index = i
+		n.date = ov.Time
+		if n.date.IsZero() {
+			n.date, _ = nntp.ParseDate(ov.Date)
+		}
+
+		var parent *Node
+		for _, ref := range ov.ReferenceIDs() {
+			r := get(ref)
+			if parent != nil && r.Parent == nil && canLink(parent, r) {
+				link(parent, r)
+			}
+			parent = r
+		}
+		// An article's own References are authoritative for its
+		// parent, overriding whatever other articles claimed.
+		if n.Parent != nil {
+			unlink(n)
+		}
+		if parent != nil && canLink(parent, n) {
+			link(parent, n)
+		}
+	}
+
+	var roots []*Node
+	for _, n := range all {
+		if n.Parent == nil {
+			roots = append(roots, n)
+		}
+	}
+	roots = prune(roots, true)
+	if opts.GroupBySubject {
+		roots = groupBySubject(roots)
+	}
+	sortNodes(roots)
+	return roots
+}
+
+// canLink reports whether making child a child of parent keeps the
+// tree acyclic.
+func canLink(parent, child *Node) bool {
+	for p := parent; p != nil; p = p.Parent {
+		if p == child {
+			return false
+		}
+	}
+	return true
+}
+
+func link(parent, child *Node) {
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+func unlink(child *Node) {
+	siblings := child.Parent.Children
+	for i, c := range siblings {
+		if c == child {
+			child.Parent.Children = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	child.Parent = nil
+}
+
+// prune removes placeholders without children and replaces placeholders
+// with their children, except at the root where a placeholder is kept
+// to tie several children together.
+func prune(nodes []*Node, root bool) []*Node {
+	rv := make([]*Node, 0, len(nodes))
+	for _, n := range nodes {
+		n.Children = prune(n.Children, false)
+		if n.Overview == nil {
+			if len(n.Children) == 0 {
+				continue
+			}
+			if !root || len(n.Children) == 1 {
+				for _, c := range n.Children {
+					c.Parent = n.Parent
+					rv = append(rv, c)
+				}
+				continue
+			}
+		}
+		rv = append(rv, n)
+	}
+	return rv
+}
+
+// subjectOf returns the subject of a node, or of its first real
+// descendant if the node is a placeholder.
+func subjectOf(n *Node) string {
+	for n.Overview == nil {
+		if len(n.Children) == 0 {
+			return ""
+		}
+		n = n.Children[0]
+	}
+	return n.Overview.Subject
+}
+
+var replyPrefixes = []string{"re:", "fw:", "fwd:", "aw:", "sv:", "antw:"}
+
+// baseSubject strips reply and forward markers and reports whether any
+// were present.
+func baseSubject(subject string) (string, bool) {
+	s := strings.ToLower(strings.TrimSpace(subject))
+	reply := false
+	for {
+		stripped := false
+		for _, p := range replyPrefixes {
+			if strings.HasPrefix(s, p) {
+				s = strings.TrimSpace(s[len(p):])
+				reply, stripped = true, true
+			}
+		}
+		// Re[2]: and Re^2: counted forms.
+		if strings.HasPrefix(s, "re[") || strings.HasPrefix(s, "re^") {
+			if i := strings.IndexByte(s, ':'); i > 0 {
+				s = strings.TrimSpace(s[i+1:])
+				reply, stripped = true, true
+			}
+		}
+		if !stripped {
+			return s, reply
+		}
+	}
+}
+
+func groupBySubject(roots []*Node) []*Node {
+	table := make(map[string]*Node, len(roots))
+	for _, r := range roots {
+		subj, reply := baseSubject(subjectOf(r))
+		if subj == "" {
+			continue
+		}
+		old := table[subj]
+		if old == nil ||
+			(r.Overview == nil && old.Overview != nil) {
+			table[subj] = r
+			continue
+		}
+		if old.Overview != nil && r.Overview != nil {
+			if _, oldReply := baseSubject(old.Overview.Subject); oldReply && !reply {
+				table[subj] = r
+			}
+		}
+	}
+
+	rv := make([]*Node, 0, len(roots))
+	for _, r := range roots {
+		subj, reply := baseSubject(subjectOf(r))
+		t := table[subj]
+		if subj == "" || t == nil || t == r {
+			rv = append(rv, r)
+			continue
+		}
+		_, tReply := baseSubject(subjectOf(t))
+		switch {
+		case t.Overview == nil && r.Overview == nil:
+			for _, c := range r.Children {
+				link(t, c)
+			}
+			r.Children = nil
+		case t.Overview == nil:
+			link(t, r)
+		case !tReply && reply:
+			link(t, r)
+		default:
+			demote(t)
+			link(t, r)
+		}
+	}
+	return rv
+}
+
+// demote turns t into a placeholder in place, moving its contents to a
+// new first child so that references to t remain valid roots.
+func demote(t *Node) {
+	c := &Node{
+		Overview:  t.Overview,
+		MessageID: t.MessageID,
+		Children:  t.Children,
+		date:      t.date,
+		index:     t.index,
+	}
+	for _, gc := range c.Children {
+		gc.Parent = c
+	}
+	t.Overview = nil
+	t.MessageID = ""
+	t.Children = nil
+	link(t, c)
+}
+
+// sortNodes orders nodes and all their descendants by date.  Placeholders
+// take the date of their earliest child.
+func sortNodes(nodes []*Node) {
+	for _, n := range nodes {
+		sortNodes(n.Children)
+		if n.Overview == nil && len(n.Children) > 0 {
+			n.date = n.Children[0].date
+			n.index = n.Children[0].index
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if !a.date.Equal(b.date) {
+			return a.date.Before(b.date)
+		}
+		return a.index < b.index
+	})
+}
@@ -0,0 +1,138 @@
+package nntpthread
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yannik995/go-nntp"
+)
+
+var epoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func ov(n int, id, refs, subject string) nntp.Overview {
+	return nntp.Overview{
+		Number:     int64(n),
+		Subject:    subject,
+		MessageID:  id,
+		References: refs,
+		Time:       epoch.Add(time.Duration(n) * time.Minute),
+	}
+}
+
+// shape renders a forest as a compact string for comparison.
+func shape(nodes []*Node) string {
+	s := ""
+	for i, n := range nodes {
+		if i > 0 {
+			s += " "
+		}
+		if n.Overview == nil {
+			s += "_"
+		} else {
+			s += n.MessageID
+		}
+		if len(n.Children) > 0 {
+			s += "(" + shape(n.Children) + ")"
+		}
+	}
+	return s
+}
+
+func TestThread(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []nntp.Overview
+		opts  *Options
+		exp   string
+	}{
+		{"simple", []nntp.Overview{
+			ov(1, "<a>", "", "x"),
+			ov(2, "<b>", "<a>", "Re: x"),
+			ov(3, "<c>", "<a> <b>", "Re: x"),
+			ov(4, "<d>", "<a>", "Re: x"),
+		}, nil, "<a>(<b>(<c>) <d>)"},
+		{"missing parent", []nntp.Overview{
+			ov(1, "<b>", "<a>", "Re: x"),
+			ov(2, "<c>", "<a>", "Re: x"),
+			ov(3, "<d>", "<z>", "Re: y"),
+		}, nil, "_(<b> <c>) <d>"},
+		{"duplicate ids", []nntp.Overview{
+			ov(1, "<a>", "", "x"),
+			ov(2, "<a>", "", "x"),
+			ov(3, "<b>", "<a>", "Re: x"),
+		}, nil, "<a>(<b>) <a>"},
+		{"cycle", []nntp.Overview{
+			ov(1, "<a>", "<b>", "x"),
+			ov(2, "<b>", "<a>", "x"),
+			ov(3, "<c>", "<c>", "x"),
+		}, nil, "<b>(<a>) <c>"},
+		{"mangled refs", []nntp.Overview{
+			ov(1, "<a>", "", "x"),
+			ov(2, "<b>", "junk <a", "x"),
+			ov(3, "<c>", "<a> <b", "x"),
+		}, nil, "<a>(<c>) <b>"},
+		{"date order", []nntp.Overview{
+			ov(3, "<c>", "", "x"),
+			ov(1, "<a>", "", "y"),
+			ov(1, "<b>", "", "z"),
+		}, nil, "<a> <b> <c>"},
+		{"subject", []nntp.Overview{
+			ov(1, "<a>", "", "hello"),
+			ov(2, "<b>", "", "Re: hello"),
+			ov(3, "<c>", "", "RE: Re[2]: Hello"),
+			ov(4, "<d>", "", "other"),
+		}, &Options{GroupBySubject: true}, "<a>(<b> <c>) <d>"},
+		{"subject siblings", []nntp.Overview{
+			ov(1, "<a>", "", "hello"),
+			ov(2, "<b>", "", "hello"),
+		}, &Options{GroupBySubject: true}, "_(<a> <b>)"},
+	}
+	for _, test := range tests {
+		got := shape(Thread(test.input, test.opts))
+		if got != test.exp {
+			t.Errorf("%s: got %s, wanted %s", test.name, got, test.exp)
+		}
+	}
+}
+
+func TestThreadParents(t *testing.T) {
+	roots := Thread([]nntp.Overview{
+		ov(1, "<b>", "<a>", "x"),
+		ov(2, "<c>", "<a>", "x"),
+	}, nil)
+	for _, r := range roots {
+		for _, c := range r.Children {
+			if c.Parent != r {
+				t.Errorf("%s has parent %v, wanted %v", c.MessageID, c.Parent, r)
+			}
+		}
+	}
+}
+
+func BenchmarkThread(b *testing.B) {
+	const size = 50000
+	input := make([]nntp.Overview, size)
+	for i := range input {
+		o := nntp.Overview{
+			Number:    int64(i),
+			Subject:   fmt.Sprintf("Subject %d", i/20),
+			MessageID: fmt.Sprintf("<%d@example.com>", i),
+			Date:      epoch.Add(time.Duration(i) * time.Second).Format(time.RFC1123Z),
+		}
+		// Threads of 20 articles, each replying to a random-ish
+		// earlier article in the same thread.
+		if i%20 != 0 {
+			root := i - i%20
+			parent := root + (i*7)%(i%20)
+			o.References = fmt.Sprintf("<%d@example.com> <%d@example.com>",
+				root, parent)
+		}
+		input[i] = o
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Thread(input, &Options{GroupBySubject: true})
+	}
+}
@@ -0,0 +1,159 @@
+package nntp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchWildmat matches s against a wildmat: comma-separated patterns,
+// where those starting with ! exclude, and the last match wins.  An
+// invalid wildmat matches nothing.  To match many names, Compile the
+// wildmat once instead.
+func MatchWildmat(wildmat, s string) bool {
+	w, err := Compile(wildmat)
+	if err != nil {
+		return false
+	}
+	return w.Match(s)
+}
+
+// A Wildmat is a compiled RFC 3977 wildmat, safe for concurrent use.
+type Wildmat struct {
+	// patterns are in reverse, so the first match is the one that counts.
+	patterns []wildmatPattern
+}
+
+// wildmatPattern is one of a wildmat's comma-separated patterns.
+type wildmatPattern struct {
+	negated bool
+	kind    patternKind
+	// text is the pattern, or for prefixPattern the part before the *.
+	text string
+}
+
+type patternKind int
+
+const (
+	// generalPattern is matched by matchPattern.
+	generalPattern patternKind = iota
+	// exactPattern has no wildcards.
+	exactPattern
+	// prefixPattern is text followed by a single *.
+	prefixPattern
+	// anyPattern is "*".
+	anyPattern
+)
+
+// Compile parses a wildmat: comma-separated patterns where * matches
+// any run of characters, ? any single one, and a leading ! makes the
+// pattern exclude.  It must pass ValidateWildmat, and not use the
+// characters RFC 3977 reserves, "[", "]" and "\".
+func Compile(wildmat string) (*Wildmat, error) {
+	if err := ValidateWildmat(wildmat); err != nil {
+		return nil, err
+	}
+	if i := strings.IndexAny(wildmat, "[]\\"); i >= 0 {
+		return nil, fmt.Errorf("wildmat %q contains reserved character %q", wildmat, wildmat[i])
+	}
+	parts := strings.Split(wildmat, ",")
+	rv := &Wildmat{patterns: make([]wildmatPattern, len(parts))}
+	for i, p := range parts {
+		wp := wildmatPattern{negated: strings.HasPrefix(p, "!")}
+		wp.text = strings.TrimPrefix(p, "!")
+		switch wild := strings.IndexAny(wp.text, "*?"); {
+		case wp.text == "*":
+			wp.kind = anyPattern
+		case wild < 0:
+			wp.kind = exactPattern
+		case wild == len(wp.text)-1 && wp.text[wild] == '*':
+			wp.kind = prefixPattern
+			wp.text = wp.text[:wild]
+		}
+		rv.patterns[len(parts)-1-i] = wp
+	}
+	return rv, nil
+}
+
+// Match reports whether name matches: the last pattern that matches it
+// must not be negated.
+func (w *Wildmat) Match(name string) bool {
+	for _, p := range w.patterns {
+		var ok bool
+		switch p.kind {
+		case anyPattern:
+			ok = true
+		case exactPattern:
+			ok = name == p.text
+		case prefixPattern:
+			ok = strings.HasPrefix(name, p.text)
+		default:
+			ok = matchPattern(p.text, name)
+		}
+		if ok {
+			return !p.negated
+		}
+	}
+	return false
+}
+
+// matchPattern matches a single pattern with * and ? wildcards, where ?
+// stands for one UTF-8 character.  It backtracks only to the last *,
+// which is enough since a later * can absorb anything an earlier one
+// would have.
+func matchPattern(pattern, name string) bool {
+	p, n := 0, 0
+	starP, starN := -1, 0
+	for n < len(name) {
+		if p < len(pattern) {
+			switch c := pattern[p]; c {
+			case '*':
+				starP, starN = p, n
+				p++
+				continue
+			case '?':
+				_, size := utf8.DecodeRuneInString(name[n:])
+				p++
+				n += size
+				continue
+			default:
+				if c == name[n] {
+					p++
+					n++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// Let the last * take one more character, and try again.
+		_, size := utf8.DecodeRuneInString(name[starN:])
+		starN += size
+		p, n = starP+1, starN
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// ValidateWildmat checks that a wildmat is well formed and safe to send
+// as a command argument: no empty patterns, and no whitespace or control
+// characters.
+func ValidateWildmat(wildmat string) error {
+	if !utf8.ValidString(wildmat) {
+		return fmt.Errorf("wildmat %q isn't valid UTF-8", wildmat)
+	}
+	for _, r := range wildmat {
+		if r <= ' ' || r == 0x7f {
+			return fmt.Errorf("wildmat %q contains %q", wildmat, r)
+		}
+	}
+	for _, p := range strings.Split(wildmat, ",") {
+		if strings.TrimPrefix(p, "!") == "" {
+			return fmt.Errorf("wildmat %q has an empty pattern", wildmat)
+		}
+	}
+	return nil
+}
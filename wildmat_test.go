@@ -0,0 +1,125 @@
+package nntp
+
+import (
+	"math/rand"
+	"path"
+	"testing"
+)
+
+func TestMatchWildmat(t *testing.T) {
+	tests := []struct {
+		wildmat, s string
+		exp        bool
+	}{
+		{"comp.*", "comp.lang.go", true},
+		{"comp.*", "alt.comp", false},
+		{"*,!alt.*", "alt.test", false},
+		{"*,!alt.*,alt.test", "alt.test", true},
+		{"misc.?est", "misc.test", true},
+		{"", "misc.test", false},
+	}
+	for _, test := range tests {
+		if got := MatchWildmat(test.wildmat, test.s); got != test.exp {
+			t.Errorf("MatchWildmat(%q, %q) = %v", test.wildmat, test.s, got)
+		}
+	}
+}
+
+func TestValidateWildmat(t *testing.T) {
+	tests := []struct {
+		wildmat string
+		ok      bool
+	}{
+		{"comp.*", true},
+		{"*,!alt.*,alt.test", true},
+		{"de.comp.*,!de.alt.ärger", true},
+		{"", false},
+		{"comp.*,", false},
+		{"comp.*,!", false},
+		{"comp.* alt.*", false},
+		{"comp.*\r\nQUIT", false},
+		{"comp.\x7f", false},
+		{"comp.\xff", false},
+	}
+	for _, test := range tests {
+		if err := ValidateWildmat(test.wildmat); (err == nil) != test.ok {
+			t.Errorf("ValidateWildmat(%q) = %v", test.wildmat, err)
+		}
+	}
+}
+
+func TestCompileWildmat(t *testing.T) {
+	// The examples of RFC 3977 section 4.4, and some more.
+	tests := []struct {
+		wildmat string
+		match   []string
+		nomatch []string
+	}{
+		{"a*,!*b,*c*", []string{"aaa", "ccb"}, []string{"abb", "xxx"}},
+		{"a*,*c*,!*b", []string{"aaa"}, []string{"abb", "ccb", "xxx"}},
+		{"?a*", []string{"aa", "ba", "bab"}, []string{"a", "ab", ""}},
+		{"*", []string{"", "anything"}, nil},
+		{"!*", nil, []string{"", "anything"}},
+		{"comp.lang.go", []string{"comp.lang.go"}, []string{"comp.lang.golang", "comp.lang.g"}},
+		{"*.test", []string{"misc.test", ".test"}, []string{"misc.tests", "test"}},
+		{"a*b*c", []string{"abc", "aXbYc", "abbbc", "acbc"}, []string{"ab", "acb", "abcd"}},
+		{"de.?rger", []string{"de.ärger", "de.xrger"}, []string{"de.rger"}},
+		{"*ä*", []string{"de.ärger"}, []string{"de.arger"}},
+		{"??", []string{"äö", "ab"}, []string{"ä", "abc"}},
+	}
+	for _, test := range tests {
+		w, err := Compile(test.wildmat)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.wildmat, err)
+			continue
+		}
+		for _, s := range test.match {
+			if !w.Match(s) {
+				t.Errorf("%q doesn't match %q", test.wildmat, s)
+			}
+		}
+		for _, s := range test.nomatch {
+			if w.Match(s) {
+				t.Errorf("%q matches %q", test.wildmat, s)
+			}
+		}
+	}
+	for _, bad := range []string{"", "a,,b", "[ab]", "a\\*", "a b"} {
+		if _, err := Compile(bad); err == nil {
+			t.Errorf("Compile(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestMatchPatternProperty checks single patterns against path.Match,
+// which agrees with wildmat when there are no brackets, backslashes or
+// slashes.
+func TestMatchPatternProperty(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	gen := func(alphabet string, max int) string {
+		b := make([]byte, r.Intn(max))
+		for i := range b {
+			b[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for i := 0; i < 20000; i++ {
+		pattern, name := gen("ab.*?", 8), gen("ab.", 10)
+		want, _ := path.Match(pattern, name)
+		if got := matchPattern(pattern, name); got != want {
+			t.Fatalf("matchPattern(%q, %q) = %v, path.Match says %v", pattern, name, got, want)
+		}
+	}
+}
+
+func BenchmarkWildmatMatch(b *testing.B) {
+	w, err := Compile("comp.*,!comp.lang.*,comp.lang.go,*.test,!alt.bin*.*.d")
+	if err != nil {
+		b.Fatal(err)
+	}
+	names := []string{"comp.lang.go", "comp.lang.c", "misc.test", "alt.binaries.pictures.d", "rec.arts"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Match(names[i%len(names)])
+	}
+}
@@ -0,0 +1,51 @@
+package nntp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A GroupNumber is an article's number within a group.
+type GroupNumber struct {
+	Group  string
+	Number int64
+}
+
+// ParseXref parses an Xref header value such as
+// "news.example.com comp.lang.go:123 alt.test:45".
+//
+// Pairs that don't parse are skipped; an error is only returned if no
+// server name is present.
+func ParseXref(value string) (server string, locations []GroupNumber, err error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return "", nil, errors.New("empty Xref")
+	}
+	server = fields[0]
+	for _, f := range fields[1:] {
+		i := strings.LastIndexByte(f, ':')
+		if i <= 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(f[i+1:], 10, 64)
+		if err != nil || n < 1 {
+			continue
+		}
+		locations = append(locations, GroupNumber{f[:i], n})
+	}
+	return server, locations, nil
+}
+
+// FormatXref builds an Xref header value.
+func FormatXref(server string, locations []GroupNumber) string {
+	var b strings.Builder
+	b.WriteString(server)
+	for _, l := range locations {
+		b.WriteByte(' ')
+		b.WriteString(l.Group)
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(l.Number, 10))
+	}
+	return b.String()
+}
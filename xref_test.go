@@ -0,0 +1,45 @@
+package nntp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseXref(t *testing.T) {
+	tests := []struct {
+		in     string
+		server string
+		locs   []GroupNumber
+	}{
+		{"news.example.com comp.lang.go:123 alt.test:45", "news.example.com",
+			[]GroupNumber{{"comp.lang.go", 123}, {"alt.test", 45}}},
+		{"  host \t a.b:1   c.d:2  ", "host", []GroupNumber{{"a.b", 1}, {"c.d", 2}}},
+		{"host a.b:x :5 c.d e.f:0 g.h:7", "host", []GroupNumber{{"g.h", 7}}},
+		{"host", "host", nil},
+	}
+	for _, test := range tests {
+		server, locs, err := ParseXref(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if server != test.server || !reflect.DeepEqual(locs, test.locs) {
+			t.Errorf("%q: got %q %v", test.in, server, locs)
+		}
+	}
+	if _, _, err := ParseXref(" "); err == nil {
+		t.Errorf("Parsed empty Xref")
+	}
+}
+
+func TestFormatXref(t *testing.T) {
+	locs := []GroupNumber{{"comp.lang.go", 123}, {"alt.test", 45}}
+	got := FormatXref("news.example.com", locs)
+	if got != "news.example.com comp.lang.go:123 alt.test:45" {
+		t.Errorf("Got %q", got)
+	}
+	server, parsed, _ := ParseXref(got)
+	if server != "news.example.com" || !reflect.DeepEqual(parsed, locs) {
+		t.Errorf("Round trip got %q %v", server, parsed)
+	}
+}